@@ -0,0 +1,320 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+
+	// Register the hash functions supported by Authenticode.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// SignatureStatus is the outcome of an Authenticode signature verification.
+type SignatureStatus int
+
+const (
+	// SignatureValid means the image hash matches the signed digest and the signer's signature verifies.
+	SignatureValid SignatureStatus = iota
+	// SignatureNotSigned means the file has no Authenticode signature.
+	SignatureNotSigned
+	// SignatureHashMismatch means the file was modified after it was signed.
+	SignatureHashMismatch
+	// SignatureUnsupportedDigest means the signature uses a digest algorithm that cannot be computed.
+	SignatureUnsupportedDigest
+	// SignatureInvalid means the signed content or the signer's signature does not verify.
+	SignatureInvalid
+)
+
+func (s SignatureStatus) String() string {
+	switch s {
+	case SignatureValid:
+		return "Valid"
+	case SignatureNotSigned:
+		return "Not Signed"
+	case SignatureHashMismatch:
+		return "Hash Mismatch"
+	case SignatureUnsupportedDigest:
+		return "Unsupported Digest"
+	case SignatureInvalid:
+		return "Invalid"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(s))
+	}
+}
+
+// SignatureVerification is the result of verifying the Authenticode signature of a PE file or script.
+type SignatureVerification struct {
+	Status SignatureStatus
+	// Reason explains a non valid status.
+	Reason string
+	// DigestAlgorithm is the algorithm of the file digest in SpcIndirectDataContent.
+	DigestAlgorithm crypto.Hash
+	// SignedDigest is the file digest stored in the signature.
+	SignedDigest []byte
+	// ComputedDigest is the Authenticode digest computed from the file.
+	ComputedDigest []byte
+	// Signer is the signer of the verified signature.
+	Signer *SignerInfo
+}
+
+// Valid reports whether the signature verified successfully.
+func (v *SignatureVerification) Valid() bool {
+	return v.Status == SignatureValid
+}
+
+var oidSpcPeImageData = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 15}
+
+// spcIndirectDataContent is the content signed by Authenticode:
+//
+//	SpcIndirectDataContent ::= SEQUENCE {
+//	    data          SpcAttributeTypeAndOptionalValue,
+//	    messageDigest DigestInfo
+//	}
+type spcIndirectDataContent struct {
+	Data          spcAttributeTypeAndOptionalValue
+	MessageDigest digestInfo
+}
+
+type spcAttributeTypeAndOptionalValue struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"optional"`
+}
+
+type digestInfo struct {
+	DigestAlgorithm pkix.AlgorithmIdentifier
+	Digest          []byte
+}
+
+func parseIndirectData(der []byte) (*spcIndirectDataContent, error) {
+	var idc spcIndirectDataContent
+	if _, err := asn1.Unmarshal(der, &idc); err != nil {
+		return nil, fmt.Errorf("failed to parse SpcIndirectDataContent: %w", err)
+	}
+	return &idc, nil
+}
+
+func verifySignature(path string) (*SignatureVerification, error) {
+	if isPowerShellScript(path) {
+		script, err := readPowerShellScript(path)
+		if err != nil {
+			return nil, err
+		}
+		return script.verifySignature()
+	}
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.verifySignature()
+}
+
+// verifySignature verifies the primary Authenticode signature of the image.
+func (p *peImage) verifySignature() (*SignatureVerification, error) {
+	certData, _, err := p.certificateTable()
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := parseWinCertificates(certData)
+	if err != nil {
+		return nil, err
+	}
+	if len(signatures) == 0 {
+		return &SignatureVerification{Status: SignatureNotSigned, Reason: "file has no Authenticode signature"}, nil
+	}
+	return p.verifySignedData(signatures[0])
+}
+
+// verifySignedData checks that sd is a valid signature over the image.
+func (p *peImage) verifySignedData(sd *signedData) (*SignatureVerification, error) {
+	return verifyIndirectData(sd, p.authenticodeDigest)
+}
+
+// verifyIndirectData verifies that the digest signed in the SpcIndirectDataContent of sd matches
+// the digest computed by digestFile and that the signer signature is intact.
+func verifyIndirectData(sd *signedData, digestFile func(crypto.Hash) ([]byte, error)) (*SignatureVerification, error) {
+	result := &SignatureVerification{Signer: sd.signer}
+	if !sd.contentType.Equal(oidSpcIndirectData) {
+		result.Status = SignatureInvalid
+		result.Reason = fmt.Sprintf("unexpected signed content type %v", sd.contentType)
+		return result, nil
+	}
+	idc, err := parseIndirectData(sd.content)
+	if err != nil {
+		result.Status = SignatureInvalid
+		result.Reason = err.Error()
+		return result, nil
+	}
+	result.SignedDigest = idc.MessageDigest.Digest
+	result.DigestAlgorithm = hashForOID(idc.MessageDigest.DigestAlgorithm.Algorithm)
+	if !supportedDigest(result.DigestAlgorithm) {
+		result.Status = SignatureUnsupportedDigest
+		result.Reason = fmt.Sprintf("unsupported file digest algorithm %v", idc.MessageDigest.DigestAlgorithm.Algorithm)
+		return result, nil
+	}
+
+	computed, err := digestFile(result.DigestAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute Authenticode digest: %w", err)
+	}
+	result.ComputedDigest = computed
+	if !bytes.Equal(computed, result.SignedDigest) {
+		result.Status = SignatureHashMismatch
+		result.Reason = "file digest does not match the signed digest"
+		return result, nil
+	}
+
+	if !supportedDigest(sd.signer.DigestAlgorithm) {
+		result.Status = SignatureUnsupportedDigest
+		result.Reason = fmt.Sprintf("unsupported signer digest algorithm %v", sd.signer.DigestAlgorithmOID)
+		return result, nil
+	}
+	if err := sd.verifySigner(); err != nil {
+		result.Status = SignatureInvalid
+		result.Reason = err.Error()
+		return result, nil
+	}
+	result.Status = SignatureValid
+	return result, nil
+}
+
+func supportedDigest(h crypto.Hash) bool {
+	switch h {
+	case crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512:
+		return h.Available()
+	}
+	return false
+}
+
+// verifySigner checks the signed attributes against the content and the signer's
+// signature over the signed attributes.
+func (sd *signedData) verifySigner() error {
+	// Authenticode digests the content octets of SpcIndirectDataContent,
+	// without the outer SEQUENCE tag and length.
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(sd.content, &content); err != nil {
+		return fmt.Errorf("failed to parse signed content: %w", err)
+	}
+	return verifySignerInfo(sd.signer, content.Bytes)
+}
+
+// verifySignerInfo checks that the signer's message digest matches content and
+// that the signature over the signed attributes was made by the signer's certificate.
+func verifySignerInfo(signer *SignerInfo, content []byte) error {
+	if signer.Certificate == nil {
+		return errors.New("signing certificate is not embedded in the signature")
+	}
+	if !supportedDigest(signer.DigestAlgorithm) {
+		return fmt.Errorf("unsupported signer digest algorithm %v", signer.DigestAlgorithmOID)
+	}
+
+	var signed []byte
+	if len(signer.rawSignedAttributes) > 0 {
+		if !bytes.Equal(signer.MessageDigest, digest(signer.DigestAlgorithm, content)) {
+			return errors.New("message digest attribute does not match the signed content")
+		}
+		// The signature covers the attributes encoded as an explicit SET OF.
+		signed = append([]byte{0x31}, signer.rawSignedAttributes[1:]...)
+	} else {
+		signed = content
+	}
+	return checkSignature(signer.Certificate.PublicKey, signer.DigestAlgorithm, digest(signer.DigestAlgorithm, signed), signer.signature)
+}
+
+// checkSignature verifies a PKCS#1 v1.5 or ECDSA signature over the given digest.
+func checkSignature(publicKey any, h crypto.Hash, hashed, signature []byte) error {
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, h, hashed, signature); err != nil {
+			return fmt.Errorf("signature verification failed: %w", err)
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, hashed, signature) {
+			return errors.New("signature verification failed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+func digest(h crypto.Hash, data []byte) []byte {
+	hh := h.New()
+	hh.Write(data)
+	return hh.Sum(nil)
+}
+
+// authenticodeDigest computes the Authenticode image hash. The hash covers the
+// headers without the CheckSum field and the security directory entry, the
+// section data in file order and any data after the last section, excluding the
+// attribute certificate table.
+func (p *peImage) authenticodeDigest(h crypto.Hash) ([]byte, error) {
+	hh := h.New()
+	checksum := p.checksumOffset()
+	securityEntry := p.dataDirectoryOffset(imageDirectoryEntrySecurity)
+	headersEnd := int64(p.sizeOfHeaders())
+	if headersEnd < securityEntry+8 || headersEnd > p.size {
+		return nil, fmt.Errorf("invalid SizeOfHeaders 0x%x", headersEnd)
+	}
+
+	ranges := [][2]int64{
+		{0, checksum},
+		{checksum + 4, securityEntry},
+		{securityEntry + 8, headersEnd},
+	}
+
+	sections := make([][2]int64, 0, len(p.file.Sections))
+	for _, s := range p.file.Sections {
+		if s.Size == 0 {
+			continue
+		}
+		sections = append(sections, [2]int64{int64(s.Offset), int64(s.Offset) + int64(s.Size)})
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i][0] < sections[j][0] })
+	hashed := headersEnd
+	for _, s := range sections {
+		ranges = append(ranges, s)
+		hashed += s[1] - s[0]
+	}
+
+	// Data past the last section, such as an installer payload, is hashed up to
+	// the certificate table.
+	end := p.size
+	if dir, ok := p.dataDirectory(imageDirectoryEntrySecurity); ok && dir.Size > 0 {
+		end = int64(dir.VirtualAddress)
+	}
+	if end > hashed {
+		ranges = append(ranges, [2]int64{hashed, end})
+	}
+
+	for _, r := range ranges {
+		if err := p.hashRange(hh, r[0], r[1]); err != nil {
+			return nil, err
+		}
+	}
+	return hh.Sum(nil), nil
+}
+
+// hashRange writes the file bytes in [start, end) to h.
+func (p *peImage) hashRange(h hash.Hash, start, end int64) error {
+	if start < 0 || end > p.size || start > end {
+		return fmt.Errorf("range 0x%x-0x%x is outside of the file", start, end)
+	}
+	if _, err := io.Copy(h, io.NewSectionReader(p.r, start, end-start)); err != nil {
+		return fmt.Errorf("failed to read range 0x%x-0x%x: %w", start, end, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUnsignedImage(t *testing.T, pe32 bool) []byte {
+	b := newTestPE()
+	b.pe32 = pe32
+	b.addSection(".text", []byte("\xc3 some code"), 0x60000020)
+	b.addSection(".data", []byte("some initialized data"), 0xc0000040)
+	return b.build(t)
+}
+
+func TestVerifySignatureValid(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	tests := []struct {
+		name string
+		pe32 bool
+		hash crypto.Hash
+	}{
+		{"PE32+ SHA-256", false, crypto.SHA256},
+		{"PE32 SHA-256", true, crypto.SHA256},
+		{"PE32+ SHA-1", false, crypto.SHA1},
+		{"PE32 SHA-512", true, crypto.SHA512},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image := testSignPE(t, testUnsignedImage(t, tt.pe32), pki, tt.hash)
+			v, err := testOpenPE(t, image).verifySignature()
+			require.NoError(t, err)
+			assert.Equal(t, SignatureValid, v.Status, v.Reason)
+			assert.True(t, v.Valid())
+			assert.Equal(t, tt.hash, v.DigestAlgorithm)
+			assert.Equal(t, v.SignedDigest, v.ComputedDigest)
+			require.NotNil(t, v.Signer)
+			assert.Equal(t, "Test Publisher", v.Signer.Certificate.Subject.CommonName)
+		})
+	}
+}
+
+func TestVerifySignatureIgnoresChecksum(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	image := testSignPE(t, testUnsignedImage(t, false), pki, crypto.SHA256)
+	checksum, _ := testPEOffsets(image)
+	image[checksum] ^= 0xff
+
+	v, err := testOpenPE(t, image).verifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureValid, v.Status, v.Reason)
+}
+
+func TestVerifySignatureTampered(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+
+	t.Run("section data", func(t *testing.T) {
+		image := testSignPE(t, testUnsignedImage(t, false), pki, crypto.SHA256)
+		image[0x400] ^= 0x01 // first byte of .data
+		v, err := testOpenPE(t, image).verifySignature()
+		require.NoError(t, err)
+		assert.Equal(t, SignatureHashMismatch, v.Status)
+		assert.NotEqual(t, v.SignedDigest, v.ComputedDigest)
+	})
+
+	t.Run("overlay", func(t *testing.T) {
+		b := newTestPE()
+		b.addSection(".text", []byte("\xc3"), 0x60000020)
+		b.overlay = []byte("appended payload")
+		unsigned := b.build(t)
+		image := testSignPE(t, unsigned, pki, crypto.SHA256)
+		image[len(unsigned)-len(b.overlay)] ^= 0x01 // first byte of the overlay
+		v, err := testOpenPE(t, image).verifySignature()
+		require.NoError(t, err)
+		assert.Equal(t, SignatureHashMismatch, v.Status)
+	})
+
+	t.Run("signed content", func(t *testing.T) {
+		unsigned := testUnsignedImage(t, false)
+		// The image digest is right but the signature was made by a different key.
+		other := newTestPKI(t, "Other")
+		image := testSignPEWith(t, unsigned, testSignOptions{
+			certs:  []*x509.Certificate{pki.leaf},
+			signer: pki.leaf,
+			key:    other.leafKey,
+		})
+		v, err := testOpenPE(t, image).verifySignature()
+		require.NoError(t, err)
+		assert.Equal(t, SignatureInvalid, v.Status)
+	})
+}
+
+func TestVerifySignatureUnsupportedDigest(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	image := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		content: testSpcIndirectData(t, crypto.MD5, make([]byte, 16)),
+		certs:   []*x509.Certificate{pki.leaf},
+		signer:  pki.leaf,
+		key:     pki.leafKey,
+	})
+	v, err := testOpenPE(t, image).verifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureUnsupportedDigest, v.Status)
+}
+
+func TestVerifySignatureNotSigned(t *testing.T) {
+	path := testWriteFile(t, "unsigned.exe", testUnsignedImage(t, false))
+	wfi, err := NewWinFileInfo(path)
+	require.NoError(t, err)
+	v, err := wfi.VerifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureNotSigned, v.Status)
+	assert.Equal(t, "Not Signed", v.Status.String())
+}
+
+func TestVerifySignatureFromFile(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	path := testWriteFile(t, "signed.exe", testSignPE(t, testUnsignedImage(t, false), pki, crypto.SHA256))
+	wfi, err := NewWinFileInfo(path)
+	require.NoError(t, err)
+
+	v, err := wfi.VerifySignature()
+	require.NoError(t, err)
+	assert.True(t, v.Valid(), v.Reason)
+
+	certs, err := wfi.GetCertificates()
+	require.NoError(t, err)
+	assert.True(t, certs.SignedBy("Test Publisher"))
+}
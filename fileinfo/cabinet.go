@@ -0,0 +1,197 @@
+package fileinfo
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Cabinet header flags and folder compression types.
+const (
+	cabinetFlagPrevCabinet    = 0x0001
+	cabinetFlagNextCabinet    = 0x0002
+	cabinetFlagReservePresent = 0x0004
+
+	cabinetCompressionMask  = 0x000f
+	cabinetCompressionNone  = 0x0000
+	cabinetCompressionMSZIP = 0x0001
+)
+
+const (
+	// maxCabinetFiles limits the number of files of a cabinet.
+	maxCabinetFiles = 0x10000
+	// maxCabinetExtractSize limits the folder data decompressed to extract a file.
+	maxCabinetExtractSize = 16 << 20
+	// mszipWindowSize is the history MSZIP blocks share.
+	mszipWindowSize = 32 << 10
+)
+
+// cabinet is a parsed Microsoft cabinet (MS-CAB): the folders holding the compressed data and the
+// files stored in them.
+type cabinet struct {
+	data     []byte
+	folders  []cabinetFolder
+	files    []cabinetFile
+	reserved int
+}
+
+type cabinetFolder struct {
+	dataOffset  uint32
+	blocks      uint16
+	compression uint16
+}
+
+type cabinetFile struct {
+	name string
+	size uint32
+	// offset is the offset of the file in the uncompressed folder data.
+	offset uint32
+	folder uint16
+}
+
+// parseCabinet reads CFHEADER, the CFFOLDER entries and the CFFILE entries of a cabinet.
+func parseCabinet(data []byte) (*cabinet, error) {
+	if len(data) < 36 || string(data[:4]) != cabinetSignature {
+		return nil, errors.New("cabinet has no MSCF signature")
+	}
+	filesOffset := int(binary.LittleEndian.Uint32(data[16:]))
+	folders := int(binary.LittleEndian.Uint16(data[26:]))
+	files := int(binary.LittleEndian.Uint16(data[28:]))
+	flags := binary.LittleEndian.Uint16(data[30:])
+	c := &cabinet{data: data}
+	pos, folderReserve := 36, 0
+	if flags&cabinetFlagReservePresent != 0 {
+		if len(data) < 40 {
+			return nil, errors.New("cabinet header is truncated")
+		}
+		pos = 40 + int(binary.LittleEndian.Uint16(data[36:]))
+		folderReserve, c.reserved = int(data[38]), int(data[39])
+	}
+	// Names of the previous and next cabinet and disk of a cabinet set.
+	for _, flag := range []uint16{cabinetFlagPrevCabinet, cabinetFlagNextCabinet} {
+		if flags&flag == 0 {
+			continue
+		}
+		for range 2 {
+			end := bytes.IndexByte(data[min(pos, len(data)):], 0)
+			if end < 0 {
+				return nil, errors.New("cabinet header is truncated")
+			}
+			pos += end + 1
+		}
+	}
+	for range folders {
+		if pos+8 > len(data) {
+			return nil, errors.New("cabinet folders are truncated")
+		}
+		c.folders = append(c.folders, cabinetFolder{
+			dataOffset:  binary.LittleEndian.Uint32(data[pos:]),
+			blocks:      binary.LittleEndian.Uint16(data[pos+4:]),
+			compression: binary.LittleEndian.Uint16(data[pos+6:]),
+		})
+		pos += 8 + folderReserve
+	}
+
+	if files > maxCabinetFiles {
+		return nil, errors.New("cabinet has too many files")
+	}
+	pos = filesOffset
+	for range files {
+		if pos < 0 || pos+16 > len(data) {
+			return nil, errors.New("cabinet files are truncated")
+		}
+		end := bytes.IndexByte(data[pos+16:], 0)
+		if end < 0 {
+			return nil, errors.New("cabinet file name is not terminated")
+		}
+		c.files = append(c.files, cabinetFile{
+			name:   string(data[pos+16 : pos+16+end]),
+			size:   binary.LittleEndian.Uint32(data[pos:]),
+			offset: binary.LittleEndian.Uint32(data[pos+4:]),
+			folder: binary.LittleEndian.Uint16(data[pos+8:]),
+		})
+		pos += 16 + end + 1
+	}
+	return c, nil
+}
+
+// names returns the names of the files in the cabinet.
+func (c *cabinet) names() []string {
+	names := make([]string, len(c.files))
+	for i, f := range c.files {
+		names[i] = f.name
+	}
+	return names
+}
+
+// extract returns the content of the named file. Only uncompressed and MSZIP folders are supported,
+// files continued from or to other cabinets are not.
+func (c *cabinet) extract(name string) ([]byte, error) {
+	for _, f := range c.files {
+		if f.name != name {
+			continue
+		}
+		if int(f.folder) >= len(c.folders) {
+			return nil, fmt.Errorf("cabinet file %s is in another cabinet", name)
+		}
+		end := uint64(f.offset) + uint64(f.size)
+		if end > maxCabinetExtractSize {
+			return nil, fmt.Errorf("cabinet file %s is too large", name)
+		}
+		data, err := c.folderData(c.folders[f.folder], int(end))
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract cabinet file %s: %w", name, err)
+		}
+		return data[f.offset:end], nil
+	}
+	return nil, fmt.Errorf("cabinet has no file %s", name)
+}
+
+// folderData decodes the CFDATA blocks of the folder until at least size bytes are uncompressed.
+// MSZIP blocks are deflate streams prefixed with "CK" that use the output of the previous blocks
+// as their dictionary.
+func (c *cabinet) folderData(folder cabinetFolder, size int) ([]byte, error) {
+	compression := folder.compression & cabinetCompressionMask
+	if compression != cabinetCompressionNone && compression != cabinetCompressionMSZIP {
+		return nil, fmt.Errorf("unsupported cabinet compression %d", compression)
+	}
+	var out []byte
+	pos := int(folder.dataOffset)
+	for range folder.blocks {
+		if len(out) >= size {
+			break
+		}
+		if pos < 0 || pos+8+c.reserved > len(c.data) {
+			return nil, errors.New("cabinet data block is truncated")
+		}
+		compressedSize := int(binary.LittleEndian.Uint16(c.data[pos+4:]))
+		uncompressedSize := int(binary.LittleEndian.Uint16(c.data[pos+6:]))
+		pos += 8 + c.reserved
+		if pos+compressedSize > len(c.data) {
+			return nil, errors.New("cabinet data block is truncated")
+		}
+		block := c.data[pos : pos+compressedSize]
+		pos += compressedSize
+		if compression == cabinetCompressionNone {
+			out = append(out, block...)
+			continue
+		}
+		if !bytes.HasPrefix(block, []byte("CK")) {
+			return nil, errors.New("MSZIP block has no CK signature")
+		}
+		history := out[max(0, len(out)-mszipWindowSize):]
+		r := flate.NewReaderDict(bytes.NewReader(block[2:]), history)
+		decoded := make([]byte, uncompressedSize)
+		if _, err := io.ReadFull(r, decoded); err != nil {
+			return nil, fmt.Errorf("failed to decompress MSZIP block: %w", err)
+		}
+		out = append(out, decoded...)
+	}
+	if len(out) < size {
+		return nil, errors.New("cabinet folder is truncated")
+	}
+	return out, nil
+}
@@ -0,0 +1,102 @@
+package fileinfo
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCabinetFile struct {
+	name string
+	data []byte
+}
+
+// testCabinet lays out a cabinet with a single folder holding the files, split into data blocks of
+// blockSize uncompressed bytes.
+func testCabinet(t *testing.T, compression uint16, blockSize int, files ...testCabinetFile) []byte {
+	t.Helper()
+	var folder []byte
+	var entries []byte
+	for _, f := range files {
+		entry := binary.LittleEndian.AppendUint32(nil, uint32(len(f.data)))
+		entry = binary.LittleEndian.AppendUint32(entry, uint32(len(folder)))
+		entry = append(entry, make([]byte, 8)...)
+		entries = append(append(entries, entry...), f.name+"\x00"...)
+		folder = append(folder, f.data...)
+	}
+
+	var blocks []byte
+	count := 0
+	for start := 0; start < len(folder); start += blockSize {
+		block := folder[start:min(start+blockSize, len(folder))]
+		data := block
+		if compression == cabinetCompressionMSZIP {
+			var buf bytes.Buffer
+			buf.WriteString("CK")
+			w, err := flate.NewWriterDict(&buf, flate.BestCompression, folder[max(0, start-mszipWindowSize):start])
+			require.NoError(t, err)
+			_, err = w.Write(block)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			data = buf.Bytes()
+		}
+		blocks = binary.LittleEndian.AppendUint32(blocks, 0)
+		blocks = binary.LittleEndian.AppendUint16(blocks, uint16(len(data)))
+		blocks = binary.LittleEndian.AppendUint16(blocks, uint16(len(block)))
+		blocks = append(blocks, data...)
+		count++
+	}
+
+	cab := make([]byte, 44)
+	copy(cab, cabinetSignature)
+	binary.LittleEndian.PutUint32(cab[16:], 44)
+	cab[24], cab[25] = 3, 1
+	binary.LittleEndian.PutUint16(cab[26:], 1)
+	binary.LittleEndian.PutUint16(cab[28:], uint16(len(files)))
+	binary.LittleEndian.PutUint32(cab[36:], uint32(44+len(entries)))
+	binary.LittleEndian.PutUint16(cab[40:], uint16(count))
+	binary.LittleEndian.PutUint16(cab[42:], compression)
+	cab = append(append(cab, entries...), blocks...)
+	binary.LittleEndian.PutUint32(cab[8:], uint32(len(cab)))
+	return cab
+}
+
+func TestParseCabinet(t *testing.T) {
+	readme := []byte("Setup installs the agent service.\r\n")
+	config := bytes.Repeat([]byte("<setting name=\"interval\" value=\"60\"/>\r\n"), 20)
+	for _, compression := range []uint16{cabinetCompressionNone, cabinetCompressionMSZIP} {
+		cab, err := parseCabinet(testCabinet(t, compression, 0x40, testCabinetFile{"readme.txt", readme}, testCabinetFile{"config.xml", config}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"readme.txt", "config.xml"}, cab.names())
+
+		data, err := cab.extract("config.xml")
+		require.NoError(t, err)
+		assert.Equal(t, config, data)
+		data, err = cab.extract("readme.txt")
+		require.NoError(t, err)
+		assert.Equal(t, readme, data)
+
+		_, err = cab.extract("missing.txt")
+		require.ErrorContains(t, err, "cabinet has no file missing.txt")
+	}
+}
+
+func TestParseCabinetErrors(t *testing.T) {
+	_, err := parseCabinet([]byte("PK\x03\x04"))
+	require.ErrorContains(t, err, "cabinet has no MSCF signature")
+
+	cab := testCabinet(t, cabinetCompressionNone, 0x40, testCabinetFile{"a.txt", []byte("a")})
+	_, err = parseCabinet(cab[:62])
+	require.ErrorContains(t, err, "cabinet file name is not terminated")
+
+	// LZX folders are not supported.
+	binary.LittleEndian.PutUint16(cab[42:], 0x1503)
+	parsed, err := parseCabinet(cab)
+	require.NoError(t, err)
+	_, err = parsed.extract("a.txt")
+	require.ErrorContains(t, err, "unsupported cabinet compression 3")
+}
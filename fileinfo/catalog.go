@@ -0,0 +1,360 @@
+package fileinfo
+
+import (
+	"crypto"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+var (
+	oidCertificateTrustList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 10, 1}
+	oidCatalogListMember    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 12, 1, 2}
+	oidCatalogListMember2   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 12, 1, 3}
+)
+
+// Catalog is a security catalog (.cat) file. Catalogs are PKCS#7 signed Certificate Trust Lists
+// whose members vouch for files by their Authenticode hash, which is how most files shipped
+// with Windows are signed instead of carrying an embedded signature.
+type Catalog struct {
+	// Path is the path the catalog was loaded from.
+	Path string
+	// Version is 1 for SHA-1 member lists and 2 for catalogs using the member2 format.
+	Version int
+	// ThisUpdate is the time the catalog was issued.
+	ThisUpdate time.Time
+	// Members are the files the catalog vouches for.
+	Members []*CatalogMember
+	// Certificates are the signer and the certificates of the catalog signature.
+	Certificates *Certificates
+	// Verification is the result of verifying the catalog signature over the member list.
+	// Lookups prefer catalogs with a valid signature.
+	Verification *SignatureVerification
+}
+
+// CatalogMember is a catalog entry vouching for a single file.
+type CatalogMember struct {
+	// Tag is the member tag, usually the hex encoded file hash.
+	Tag string
+	// DigestAlgorithm and Digest are the Authenticode hash of the file.
+	// DigestAlgorithm is zero when the member has no SpcIndirectDataContent.
+	DigestAlgorithm crypto.Hash
+	Digest          []byte
+}
+
+// CatalogSignature is the catalog member vouching for a file.
+type CatalogSignature struct {
+	Catalog *Catalog
+	Member  *CatalogMember
+}
+
+// Valid reports whether the signature of the vouching catalog is valid.
+// The catalog certificate chain is checked separately with Catalog.Certificates.VerifyChain.
+func (s *CatalogSignature) Valid() bool {
+	return s != nil && s.Catalog.Verification.Valid()
+}
+
+// SystemCatRoot returns the directory holding the catalogs of the running Windows installation.
+func SystemCatRoot() string {
+	root := os.Getenv("SystemRoot")
+	if root == "" {
+		root = `C:\Windows`
+	}
+	return filepath.Join(root, "System32", "CatRoot")
+}
+
+// LoadCatalog parses the catalog file at path and verifies its signature.
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	catalog, err := parseCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse catalog %s: %w", path, err)
+	}
+	catalog.Path = path
+	return catalog, nil
+}
+
+func parseCatalog(data []byte) (*Catalog, error) {
+	sd, err := parseSignedData(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sd.contentType.Equal(oidCertificateTrustList) {
+		return nil, fmt.Errorf("unexpected content type %v", sd.contentType)
+	}
+	catalog, err := parseCertificateTrustList(sd.content)
+	if err != nil {
+		return nil, err
+	}
+	catalog.Certificates = newCertificates([]*signedData{sd})
+	catalog.Verification = verifyCatalogSignature(sd)
+	return catalog, nil
+}
+
+func verifyCatalogSignature(sd *signedData) *SignatureVerification {
+	result := &SignatureVerification{Signer: sd.signer, DigestAlgorithm: sd.signer.DigestAlgorithm}
+	if !supportedDigest(sd.signer.DigestAlgorithm) {
+		result.Status = SignatureUnsupportedDigest
+		result.Reason = fmt.Sprintf("unsupported signer digest algorithm %v", sd.signer.DigestAlgorithmOID)
+		return result
+	}
+	if err := sd.verifySigner(); err != nil {
+		result.Status = SignatureInvalid
+		result.Reason = err.Error()
+		return result
+	}
+	result.Status = SignatureValid
+	return result
+}
+
+// parseCertificateTrustList parses the CTL content of a catalog:
+//
+//	CertificateTrustList ::= SEQUENCE {
+//	  subjectUsage      SEQUENCE OF OBJECT IDENTIFIER,
+//	  listIdentifier    OCTET STRING OPTIONAL,
+//	  sequenceNumber    INTEGER OPTIONAL,
+//	  thisUpdate        Time,
+//	  nextUpdate        Time OPTIONAL,
+//	  subjectAlgorithm  AlgorithmIdentifier,
+//	  trustedSubjects   SEQUENCE OF TrustedSubject OPTIONAL,
+//	  extensions        [0] EXPLICIT Extensions OPTIONAL }
+func parseCertificateTrustList(der []byte) (*Catalog, error) {
+	var ctl asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &ctl); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate trust list: %w", err)
+	}
+	if ctl.Class != asn1.ClassUniversal || ctl.Tag != asn1.TagSequence {
+		return nil, errors.New("certificate trust list is not a sequence")
+	}
+	var fields []asn1.RawValue
+	for rest := ctl.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return nil, fmt.Errorf("failed to parse certificate trust list: %w", err)
+		}
+		fields = append(fields, field)
+	}
+
+	// fields[0] is the subject usage, skip the optional list identifier and sequence number.
+	i := 1
+	for i < len(fields) && fields[i].Class == asn1.ClassUniversal &&
+		(fields[i].Tag == asn1.TagOctetString || fields[i].Tag == asn1.TagInteger) {
+		i++
+	}
+	catalog := &Catalog{}
+	if i >= len(fields) || !isTime(fields[i]) {
+		return nil, errors.New("certificate trust list has no update time")
+	}
+	if _, err := asn1.Unmarshal(fields[i].FullBytes, &catalog.ThisUpdate); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog update time: %w", err)
+	}
+	i++
+	if i < len(fields) && isTime(fields[i]) {
+		i++
+	}
+	if i >= len(fields) {
+		return nil, errors.New("certificate trust list has no subject algorithm")
+	}
+	var algorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.RawValue `asn1:"optional"`
+	}
+	if _, err := asn1.Unmarshal(fields[i].FullBytes, &algorithm); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog subject algorithm: %w", err)
+	}
+	switch {
+	case algorithm.Algorithm.Equal(oidCatalogListMember):
+		catalog.Version = 1
+	case algorithm.Algorithm.Equal(oidCatalogListMember2):
+		catalog.Version = 2
+	default:
+		return nil, fmt.Errorf("unsupported catalog subject algorithm %v", algorithm.Algorithm)
+	}
+	i++
+	if i < len(fields) && fields[i].Class == asn1.ClassUniversal && fields[i].Tag == asn1.TagSequence {
+		members, err := parseCatalogMembers(fields[i].Bytes)
+		if err != nil {
+			return nil, err
+		}
+		catalog.Members = members
+	}
+	return catalog, nil
+}
+
+func isTime(v asn1.RawValue) bool {
+	return v.Class == asn1.ClassUniversal && (v.Tag == asn1.TagUTCTime || v.Tag == asn1.TagGeneralizedTime)
+}
+
+type catalogTrustedSubject struct {
+	Identifier []byte
+	Attributes asn1.RawValue `asn1:"optional"`
+}
+
+func parseCatalogMembers(data []byte) ([]*CatalogMember, error) {
+	var members []*CatalogMember
+	for len(data) > 0 {
+		var subject catalogTrustedSubject
+		var err error
+		if data, err = asn1.Unmarshal(data, &subject); err != nil {
+			return nil, fmt.Errorf("failed to parse catalog member: %w", err)
+		}
+		member := &CatalogMember{Tag: decodeCatalogTag(subject.Identifier)}
+		attrs, err := parseAttributes(subject.Attributes.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse attributes of catalog member %s: %w", member.Tag, err)
+		}
+		if attr := findAttribute(attrs, oidSpcIndirectData); attr != nil && len(attr.Values) > 0 {
+			idc, err := parseIndirectData(attr.Values[0].FullBytes)
+			if err != nil {
+				return nil, fmt.Errorf("catalog member %s: %w", member.Tag, err)
+			}
+			member.DigestAlgorithm = hashForOID(idc.MessageDigest.DigestAlgorithm.Algorithm)
+			member.Digest = idc.MessageDigest.Digest
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// decodeCatalogTag decodes a member tag. Tags are usually a NUL terminated UTF-16LE string
+// of the hex encoded file hash, member2 catalogs may store the raw hash instead.
+func decodeCatalogTag(b []byte) string {
+	if len(b) >= 2 && len(b)%2 == 0 {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])|uint16(b[i+1])<<8)
+		}
+		s := strings.TrimRight(string(utf16.Decode(u)), "\x00")
+		if s != "" && isPrintableTag(s) {
+			return s
+		}
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+func isPrintableTag(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// CatalogIndex looks up files in a set of catalogs by Authenticode hash.
+// Load the catalogs once and reuse the index to check many files.
+type CatalogIndex struct {
+	Catalogs []*Catalog
+	// Skipped are the catalogs found in directories that could not be parsed, by path.
+	Skipped map[string]error
+
+	members map[crypto.Hash]map[string][]*CatalogSignature
+}
+
+// LoadCatalogs loads catalog files and all *.cat files below directories such as SystemCatRoot().
+// Catalog files given explicitly must parse, unparsable catalogs found in directories are
+// recorded in Skipped so that a single broken catalog does not prevent lookups.
+func LoadCatalogs(paths ...string) (*CatalogIndex, error) {
+	index := &CatalogIndex{Skipped: map[string]error{}}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load catalogs: %w", err)
+		}
+		if !info.IsDir() {
+			catalog, err := LoadCatalog(path)
+			if err != nil {
+				return nil, err
+			}
+			index.Add(catalog)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".cat") {
+				return nil
+			}
+			catalog, err := LoadCatalog(p)
+			if err != nil {
+				index.Skipped[p] = err
+				return nil
+			}
+			index.Add(catalog)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load catalogs from %s: %w", path, err)
+		}
+	}
+	return index, nil
+}
+
+// Add adds a catalog to the index.
+func (ix *CatalogIndex) Add(catalog *Catalog) {
+	if ix.members == nil {
+		ix.members = map[crypto.Hash]map[string][]*CatalogSignature{}
+	}
+	ix.Catalogs = append(ix.Catalogs, catalog)
+	for _, member := range catalog.Members {
+		if !supportedDigest(member.DigestAlgorithm) {
+			continue
+		}
+		byDigest := ix.members[member.DigestAlgorithm]
+		if byDigest == nil {
+			byDigest = map[string][]*CatalogSignature{}
+			ix.members[member.DigestAlgorithm] = byDigest
+		}
+		key := string(member.Digest)
+		byDigest[key] = append(byDigest[key], &CatalogSignature{Catalog: catalog, Member: member})
+	}
+}
+
+// Lookup computes the Authenticode hash of the PE file at path and returns the catalog
+// member vouching for it, or nil when no catalog lists the file. Catalogs with a valid
+// signature are preferred, check CatalogSignature.Valid before trusting the result.
+func (ix *CatalogIndex) Lookup(path string) (*CatalogSignature, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return ix.lookupImage(img)
+}
+
+func (ix *CatalogIndex) lookupImage(img *peImage) (*CatalogSignature, error) {
+	var found *CatalogSignature
+	for _, h := range []crypto.Hash{crypto.SHA256, crypto.SHA1, crypto.SHA384, crypto.SHA512} {
+		byDigest := ix.members[h]
+		if len(byDigest) == 0 {
+			continue
+		}
+		computed, err := img.authenticodeDigest(h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute Authenticode digest: %w", err)
+		}
+		for _, match := range byDigest[string(computed)] {
+			if match.Valid() {
+				return match, nil
+			}
+			if found == nil {
+				found = match
+			}
+		}
+	}
+	return found, nil
+}
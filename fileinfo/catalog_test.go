@@ -0,0 +1,163 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var oidCatalogList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 12, 1, 1}
+
+// testCatalogTag encodes a member tag the way MakeCat does, as NUL terminated UTF-16LE.
+func testCatalogTag(digest []byte) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(strings.ToUpper(hex.EncodeToString(digest)) + "\x00")) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+// buildTestCatalog returns a catalog signed by the PKI leaf vouching for the given image digests.
+func buildTestCatalog(t *testing.T, pki *testPKI, h crypto.Hash, digests ...[]byte) []byte {
+	t.Helper()
+	var members [][]byte
+	for _, d := range digests {
+		members = append(members, derSequence(t,
+			derMarshal(t, testCatalogTag(d)),
+			derTagged(t, asn1.ClassUniversal, asn1.TagSet, testAttribute(t, oidSpcIndirectData, testSpcIndirectData(t, h, d))),
+		))
+	}
+	memberAlgorithm := oidCatalogListMember
+	if h != crypto.SHA1 {
+		memberAlgorithm = oidCatalogListMember2
+	}
+	ctl := derSequence(t,
+		derSequence(t, derMarshal(t, oidCatalogList)),
+		derMarshal(t, []byte("list identifier")),
+		derMarshal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		derSequence(t, derMarshal(t, memberAlgorithm), derMarshal(t, asn1.NullRawValue)),
+		derSequence(t, members...),
+	)
+	return buildTestSignedData(t, testSignOptions{
+		contentType: oidCertificateTrustList,
+		content:     ctl,
+		certs:       []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer:      pki.leaf,
+		key:         pki.leafKey,
+	})
+}
+
+func TestParseCatalog(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	digest := testImageDigest(testUnsignedImage(t, false), crypto.SHA1)
+
+	catalog, err := parseCatalog(buildTestCatalog(t, pki, crypto.SHA1, digest, []byte("another digest value")))
+	require.NoError(t, err)
+	assert.Equal(t, 1, catalog.Version)
+	assert.True(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Equal(catalog.ThisUpdate))
+	require.Len(t, catalog.Members, 2)
+	assert.Equal(t, strings.ToUpper(hex.EncodeToString(digest)), catalog.Members[0].Tag)
+	assert.Equal(t, crypto.SHA1, catalog.Members[0].DigestAlgorithm)
+	assert.Equal(t, digest, catalog.Members[0].Digest)
+	assert.True(t, catalog.Verification.Valid(), catalog.Verification.Reason)
+	assert.True(t, catalog.Certificates.SignedBy("Test Publisher"))
+}
+
+func TestParseCatalogRejectsAuthenticodeSignature(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	signature := buildTestSignedData(t, testSignOptions{
+		content: testSpcIndirectData(t, crypto.SHA256, make([]byte, 32)),
+		certs:   []*x509.Certificate{pki.leaf},
+		signer:  pki.leaf,
+		key:     pki.leafKey,
+	})
+
+	_, err := parseCatalog(signature)
+	require.Error(t, err)
+	_, err = parseCatalog([]byte("not a catalog"))
+	require.Error(t, err)
+}
+
+func TestCatalogLookup(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	image := testUnsignedImage(t, false)
+	other := testUnsignedImage(t, true)
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "{F750E6C3-38EE-11D1-85E5-00C04FC295EE}"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "{F750E6C3-38EE-11D1-85E5-00C04FC295EE}", "test.cat"),
+		buildTestCatalog(t, pki, crypto.SHA256, testImageDigest(image, crypto.SHA256)), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "broken.cat"), []byte("garbage"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "catdb"), []byte("not a catalog"), 0o600))
+
+	index, err := LoadCatalogs(root)
+	require.NoError(t, err)
+	require.Len(t, index.Catalogs, 1)
+	assert.Contains(t, index.Skipped, filepath.Join(root, "broken.cat"))
+
+	sig, err := index.Lookup(testWriteFile(t, "catalog-signed.exe", image))
+	require.NoError(t, err)
+	require.NotNil(t, sig)
+	assert.True(t, sig.Valid())
+	assert.Equal(t, 2, sig.Catalog.Version)
+	assert.Equal(t, crypto.SHA256, sig.Member.DigestAlgorithm)
+	assert.True(t, sig.Catalog.Certificates.SignedBy("Test Publisher"))
+
+	chain, err := sig.Catalog.Certificates.VerifyChain(ChainOptions{Roots: testRootPool(pki.root), CurrentTime: testValidationTime})
+	require.NoError(t, err)
+	assert.Equal(t, ChainValid, chain.Status, chain.Reason)
+
+	sig, err = index.Lookup(testWriteFile(t, "unknown.exe", other))
+	require.NoError(t, err)
+	assert.Nil(t, sig)
+}
+
+func TestCatalogLookupPrefersValidCatalog(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	image := testUnsignedImage(t, false)
+	digest := testImageDigest(image, crypto.SHA1)
+
+	tampered, err := parseCatalog(buildTestCatalog(t, pki, crypto.SHA1, digest))
+	require.NoError(t, err)
+	tampered.Verification = &SignatureVerification{Status: SignatureInvalid}
+	valid, err := parseCatalog(buildTestCatalog(t, pki, crypto.SHA1, digest))
+	require.NoError(t, err)
+
+	index := &CatalogIndex{}
+	index.Add(tampered)
+	sig, err := index.lookupImage(testOpenPE(t, image))
+	require.NoError(t, err)
+	require.NotNil(t, sig)
+	assert.False(t, sig.Valid())
+
+	index.Add(valid)
+	sig, err = index.lookupImage(testOpenPE(t, image))
+	require.NoError(t, err)
+	assert.Same(t, valid, sig.Catalog)
+}
+
+func TestFindCatalogSignature(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	image := testUnsignedImage(t, false)
+	catalog := testWriteFile(t, "test.cat", buildTestCatalog(t, pki, crypto.SHA256, testImageDigest(image, crypto.SHA256)))
+
+	wf, err := NewWinFileInfo(testWriteFile(t, "catalog-signed.exe", image))
+	require.NoError(t, err)
+	sig, err := wf.FindCatalogSignature(catalog)
+	require.NoError(t, err)
+	require.NotNil(t, sig)
+	assert.True(t, sig.Valid())
+
+	_, err = wf.FindCatalogSignature(filepath.Join(t.TempDir(), "missing.cat"))
+	require.Error(t, err)
+}
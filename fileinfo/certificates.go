@@ -2,110 +2,113 @@ package fileinfo
 
 import (
 	"crypto/x509"
-	"debug/pe"
 	"encoding/binary"
 	"fmt"
-	"io"
-	"os"
 	"strings"
 	"time"
 )
 
+// Certificates holds the certificates and signer information embedded in a file's
+// Authenticode signature.
 type Certificates struct {
+	// Certificates are all certificates from the certificate bags of all signatures,
+	// including intermediates and roots.
 	Certificates []*x509.Certificate
+	// Signer describes the signer of the primary signature, nil when the file is not signed.
+	Signer *SignerInfo
+	// Signatures lists every signature of the file, the primary signature first followed
+	// by the signatures nested in it and by any further top level signatures.
+	Signatures []*Signature
 }
 
+// SignedBy reports whether the leaf signing certificate has the given common name.
+// Other certificates in the bag, such as intermediates and roots, are not considered.
 func (c *Certificates) SignedBy(verifier string) bool {
-	if len(c.Certificates) == 0 {
+	cert := c.signerCertificate()
+	if cert == nil {
 		return false
 	}
-	for _, cert := range c.Certificates {
-		if strings.EqualFold(cert.Subject.CommonName, verifier) {
-			return true
-		}
-	}
-	return false
+	return strings.EqualFold(cert.Subject.CommonName, verifier)
 }
 
+// ValidAtSignedBy reports whether the leaf signing certificate has the given common name
+// and is within its validity period at the given time.
 func (c *Certificates) ValidAtSignedBy(verifier string, at time.Time) bool {
-	if len(c.Certificates) == 0 {
+	cert := c.signerCertificate()
+	if cert == nil {
 		return false
 	}
-	for _, cert := range c.Certificates {
-		valid := cert.NotBefore.Before(at) && cert.NotAfter.After(at)
-		if strings.EqualFold(cert.Subject.CommonName, verifier) && valid {
-			return true
-		}
+	valid := cert.NotBefore.Before(at) && cert.NotAfter.After(at)
+	return strings.EqualFold(cert.Subject.CommonName, verifier) && valid
+}
+
+func (c *Certificates) signerCertificate() *x509.Certificate {
+	if c == nil || c.Signer == nil {
+		return nil
 	}
-	return false
+	return c.Signer.Certificate
 }
 
 func getCertificates(filepath string) (*Certificates, error) {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %v", err)
+	if isPowerShellScript(filepath) {
+		script, err := readPowerShellScript(filepath)
+		if err != nil {
+			return nil, err
+		}
+		return script.certificates()
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	peFile, err := pe.NewFile(file)
+	img, err := openPEImageFile(filepath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse PE file: %v", err)
+		return nil, err
 	}
 	defer func() {
-		_ = peFile.Close()
+		_ = img.Close()
 	}()
 
 	// Extract certificates from the PE file
-	certs, err := extractCertificates(file, peFile)
+	certs, err := extractCertificates(img)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract certificates: %v", err)
 	}
 
-	return &Certificates{Certificates: certs}, nil
+	return certs, nil
 }
 
-func extractCertificates(file *os.File, peFile *pe.File) ([]*x509.Certificate, error) {
-	var certDir pe.DataDirectory
-
-	// Get the certificate table from the data directory
-	switch oh := peFile.OptionalHeader.(type) {
-	case *pe.OptionalHeader32:
-		if len(oh.DataDirectory) <= 4 {
-			return nil, fmt.Errorf("no certificate table found")
-		}
-		certDir = oh.DataDirectory[4] // IMAGE_DIRECTORY_ENTRY_SECURITY
-	case *pe.OptionalHeader64:
-		if len(oh.DataDirectory) <= 4 {
-			return nil, fmt.Errorf("no certificate table found")
-		}
-		certDir = oh.DataDirectory[4] // IMAGE_DIRECTORY_ENTRY_SECURITY
-	default:
-		return nil, fmt.Errorf("unsupported PE format")
-	}
-
-	if certDir.Size == 0 {
-		return nil, nil
-	}
-
-	// Read the certificate table
-	_, err := file.Seek(int64(certDir.VirtualAddress), 0)
+func extractCertificates(img *peImage) (*Certificates, error) {
+	certData, _, err := img.certificateTable()
 	if err != nil {
-		return nil, fmt.Errorf("failed to seek to certificate table: %v", err)
+		return nil, err
 	}
+	return parseCertificateTable(certData)
+}
 
-	certData := make([]byte, certDir.Size)
-	_, err = io.ReadFull(file, certData)
+func parseCertificateTable(data []byte) (*Certificates, error) {
+	signatures, err := parseWinCertificates(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read certificate data: %v", err)
+		return nil, err
 	}
+	return newCertificates(signatures), nil
+}
 
-	return parseCertificateTable(certData)
+// newCertificates collects the signatures, their nested signatures and certificates of signed data blobs.
+func newCertificates(signatures []*signedData) *Certificates {
+	result := &Certificates{}
+	for _, sd := range signatures {
+		result.Signatures = collectSignatures(result.Signatures, sd, false)
+	}
+	for _, sig := range result.Signatures {
+		result.Certificates = append(result.Certificates, sig.Certificates...)
+	}
+	if len(result.Signatures) > 0 {
+		result.Signer = result.Signatures[0].Signer
+	}
+	return result
 }
 
-func parseCertificateTable(data []byte) ([]*x509.Certificate, error) {
-	var certificates []*x509.Certificate
+// parseWinCertificates parses the PKCS#7 signed data of every WIN_CERTIFICATE entry in the table.
+func parseWinCertificates(data []byte) ([]*signedData, error) {
+	var signatures []*signedData
 	offset := 0
 
 	for offset < len(data) {
@@ -122,16 +125,13 @@ func parseCertificateTable(data []byte) ([]*x509.Certificate, error) {
 			break
 		}
 
-		// slog.Debug("Found certificate entry",
-		// 	"Length", length,
-		// 	"Revision", fmt.Sprintf("0x%x", revision),
-		// 	"Type", fmt.Sprintf("0x%x", certType))
-
 		// WIN_CERT_TYPE_PKCS_SIGNED_DATA = 0x0002
 		if certType == 0x0002 {
-			certBytes := data[offset+8 : offset+int(length)]
-			certs := extractCertsFromPKCS7(certBytes)
-			certificates = append(certificates, certs...)
+			sd, err := parseSignedData(data[offset+8 : offset+int(length)])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse PKCS#7 signed data: %w", err)
+			}
+			signatures = append(signatures, sd)
 		}
 
 		// Move to next certificate (aligned to 8-byte boundary)
@@ -139,48 +139,5 @@ func parseCertificateTable(data []byte) ([]*x509.Certificate, error) {
 		offset = (offset + 7) &^ 7
 	}
 
-	return certificates, nil
-}
-
-func extractCertsFromPKCS7(data []byte) []*x509.Certificate {
-	var certificates []*x509.Certificate
-
-	// Try to parse as PKCS#7 DER format
-	// This is a simplified approach - in practice, you'd want to properly parse the ASN.1 structure
-
-	// Look for certificate patterns in the PKCS#7 data
-	// X.509 certificates in DER format start with 0x30 0x82 (SEQUENCE tag)
-	for i := 0; i < len(data)-4; i++ {
-		if data[i] == 0x30 && data[i+1] == 0x82 {
-			// Try to parse certificate starting at this position
-			remaining := data[i:]
-
-			// Parse the length field
-			if len(remaining) < 4 {
-				continue
-			}
-
-			certLen := int(remaining[2])<<8 + int(remaining[3]) + 4
-			if certLen > len(remaining) {
-				continue
-			}
-
-			certData := remaining[:certLen]
-			cert, err := x509.ParseCertificate(certData)
-			if err == nil {
-				certificates = append(certificates, cert)
-				i += certLen - 1 // Skip past this certificate
-			}
-		}
-	}
-
-	// If the simple approach doesn't work, try parsing the entire block
-	if len(certificates) == 0 {
-		certs, err := x509.ParseCertificates(data)
-		if err == nil {
-			certificates = append(certificates, certs...)
-		}
-	}
-
-	return certificates
+	return signatures, nil
 }
@@ -0,0 +1,174 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrNotSigned is returned by checks that need a signer when the file has no Authenticode signature.
+var ErrNotSigned = errors.New("file is not signed")
+
+// ChainStatus is the outcome of building the code-signing certificate chain.
+type ChainStatus int
+
+const (
+	// ChainValid means a chain from the signer to a trusted root was built.
+	ChainValid ChainStatus = iota
+	// ChainUntrustedRoot means the chain is complete but ends at a root that is not in the root pool.
+	ChainUntrustedRoot
+	// ChainExpired means a certificate of the chain is outside of its validity period.
+	ChainExpired
+	// ChainWrongEKU means the chain does not allow the required usage, code signing
+	// for signers and time stamping for timestamp authorities.
+	ChainWrongEKU
+	// ChainBroken means no chain could be built, e.g. because an intermediate is missing or a signature is invalid.
+	ChainBroken
+)
+
+func (s ChainStatus) String() string {
+	switch s {
+	case ChainValid:
+		return "Valid"
+	case ChainUntrustedRoot:
+		return "Untrusted Root"
+	case ChainExpired:
+		return "Expired"
+	case ChainWrongEKU:
+		return "Wrong EKU"
+	case ChainBroken:
+		return "Broken Chain"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(s))
+	}
+}
+
+// ChainOptions configures code-signing chain validation.
+type ChainOptions struct {
+	// Roots is the pool of trusted roots, it is required.
+	// The system roots are never consulted so that the check can run offline against pinned roots.
+	Roots *x509.CertPool
+	// Intermediates are additional intermediates to the ones embedded in the signature.
+	Intermediates *x509.CertPool
+	// CurrentTime is the time the chain is validated at, the current time when zero.
+	CurrentTime time.Time
+	// UseTimestamp validates the chain at the time of the signature's verified timestamp
+	// instead of CurrentTime, so that timestamped signatures stay valid after the signing
+	// certificate expires. The TSA must chain to Roots with the time stamping usage.
+	// Signatures without such a timestamp fall back to CurrentTime.
+	UseTimestamp bool
+}
+
+// ChainVerification is the result of code-signing chain validation.
+type ChainVerification struct {
+	Status ChainStatus
+	// Reason explains a non valid status.
+	Reason string
+	// Chain is the chain that was built, leaf signer first and root last.
+	// For ChainUntrustedRoot and ChainWrongEKU it is the chain that would otherwise be accepted,
+	// it is empty when no chain could be built.
+	Chain []*x509.Certificate
+}
+
+// Valid reports whether the chain is valid.
+func (v *ChainVerification) Valid() bool {
+	return v.Status == ChainValid
+}
+
+// VerifyChain builds the certificate chain from the leaf signer through the embedded
+// intermediates to one of the roots in opts.Roots and checks the code-signing extended key usage.
+// It returns ErrNotSigned when there is no signing certificate to start the chain from.
+func (c *Certificates) VerifyChain(opts ChainOptions) (*ChainVerification, error) {
+	if opts.Roots == nil {
+		return nil, errors.New("root pool is required")
+	}
+	leaf := c.signerCertificate()
+	if leaf == nil {
+		return nil, ErrNotSigned
+	}
+	if opts.UseTimestamp {
+		if ts := c.verifiedTimestamp(opts); ts != nil {
+			opts.CurrentTime = ts.Time
+		}
+	}
+	return verifyChain(leaf, c.Certificates, opts, x509.ExtKeyUsageCodeSigning), nil
+}
+
+// verifyChain validates the chain of leaf for the given usage using the other
+// certificates in bag as intermediates.
+func verifyChain(leaf *x509.Certificate, bag []*x509.Certificate, opts ChainOptions, usage x509.ExtKeyUsage) *ChainVerification {
+	intermediates := x509.NewCertPool()
+	if opts.Intermediates != nil {
+		intermediates = opts.Intermediates.Clone()
+	}
+	for _, cert := range bag {
+		if !bytes.Equal(cert.Raw, leaf.Raw) {
+			intermediates.AddCert(cert)
+		}
+	}
+	at := opts.CurrentTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	verifyOpts := x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+
+	chains, err := leaf.Verify(verifyOpts)
+	if err == nil {
+		// Go treats a leaf without the EKU extension as valid for any usage,
+		// Authenticode policy requires the usage to be stated explicitly.
+		if !slices.Contains(leaf.ExtKeyUsage, usage) {
+			return &ChainVerification{Status: ChainWrongEKU, Reason: "signing certificate does not have the required extended key usage", Chain: chains[0]}
+		}
+		return &ChainVerification{Status: ChainValid, Chain: chains[0]}
+	}
+
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &invalid) {
+		switch invalid.Reason {
+		case x509.Expired:
+			return &ChainVerification{Status: ChainExpired, Reason: err.Error()}
+		case x509.IncompatibleUsage:
+			anyUsage := verifyOpts
+			anyUsage.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+			result := &ChainVerification{Status: ChainWrongEKU, Reason: err.Error()}
+			if chains, err := leaf.Verify(anyUsage); err == nil {
+				result.Chain = chains[0]
+			}
+			return result
+		}
+		return &ChainVerification{Status: ChainBroken, Reason: err.Error()}
+	}
+
+	var unknown x509.UnknownAuthorityError
+	if errors.As(err, &unknown) {
+		// Retry with the embedded self-signed certificates trusted to tell an
+		// untrusted root apart from a chain that cannot be built at all.
+		embeddedRoots := opts.Roots.Clone()
+		for _, cert := range bag {
+			if isSelfSigned(cert) {
+				embeddedRoots.AddCert(cert)
+			}
+		}
+		untrusted := verifyOpts
+		untrusted.Roots = embeddedRoots
+		if chains, retryErr := leaf.Verify(untrusted); retryErr == nil {
+			idx := slices.IndexFunc(chains, func(chain []*x509.Certificate) bool {
+				return isSelfSigned(chain[len(chain)-1])
+			})
+			return &ChainVerification{Status: ChainUntrustedRoot, Reason: err.Error(), Chain: chains[max(idx, 0)]}
+		}
+	}
+	return &ChainVerification{Status: ChainBroken, Reason: err.Error()}
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
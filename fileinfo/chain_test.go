@@ -0,0 +1,92 @@
+package fileinfo
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSignedCertificates(leaf *x509.Certificate, bag ...*x509.Certificate) *Certificates {
+	return &Certificates{Certificates: bag, Signer: &SignerInfo{Certificate: leaf}}
+}
+
+func testRootPool(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+var testValidationTime = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestVerifyChain(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	other := newTestPKI(t, "Other Publisher")
+
+	serverLeaf, _ := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Test Publisher"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, pki.intermediate, pki.intermediateKey)
+	noEKULeaf, _ := newTestCertificate(t, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "Test Publisher"},
+	}, pki.intermediate, pki.intermediateKey)
+	shortLeaf, _ := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Test Publisher"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		NotAfter:    testNotBefore.AddDate(1, 0, 0),
+	}, pki.intermediate, pki.intermediateKey)
+
+	tests := []struct {
+		name        string
+		certs       *Certificates
+		roots       *x509.CertPool
+		status      ChainStatus
+		chainLength int
+	}{
+		{"valid", testSignedCertificates(pki.leaf, pki.root, pki.intermediate, pki.leaf), testRootPool(pki.root), ChainValid, 3},
+		{"valid without embedded root", testSignedCertificates(pki.leaf, pki.intermediate, pki.leaf), testRootPool(pki.root), ChainValid, 3},
+		{"untrusted root", testSignedCertificates(pki.leaf, pki.root, pki.intermediate, pki.leaf), testRootPool(other.root), ChainUntrustedRoot, 3},
+		{"expired", testSignedCertificates(shortLeaf, pki.intermediate, shortLeaf), testRootPool(pki.root), ChainExpired, 0},
+		{"wrong EKU", testSignedCertificates(serverLeaf, pki.intermediate, serverLeaf), testRootPool(pki.root), ChainWrongEKU, 3},
+		{"missing EKU", testSignedCertificates(noEKULeaf, pki.intermediate, noEKULeaf), testRootPool(pki.root), ChainWrongEKU, 3},
+		{"missing intermediate", testSignedCertificates(pki.leaf, pki.leaf), testRootPool(pki.root), ChainBroken, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := tt.certs.VerifyChain(ChainOptions{Roots: tt.roots, CurrentTime: testValidationTime})
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, v.Status, v.Reason)
+			assert.Equal(t, tt.status == ChainValid, v.Valid())
+			require.Len(t, v.Chain, tt.chainLength)
+			if tt.chainLength > 0 {
+				assert.Equal(t, tt.certs.Signer.Certificate, v.Chain[0])
+			}
+		})
+	}
+}
+
+func TestVerifyChainExtraIntermediates(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	v, err := testSignedCertificates(pki.leaf, pki.leaf).VerifyChain(ChainOptions{
+		Roots:         testRootPool(pki.root),
+		Intermediates: testRootPool(pki.intermediate),
+		CurrentTime:   testValidationTime,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ChainValid, v.Status, v.Reason)
+}
+
+func TestVerifyChainErrors(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+
+	_, err := testSignedCertificates(pki.leaf, pki.leaf).VerifyChain(ChainOptions{})
+	require.Error(t, err)
+
+	_, err = (&Certificates{}).VerifyChain(ChainOptions{Roots: testRootPool(pki.root)})
+	require.ErrorIs(t, err, ErrNotSigned)
+}
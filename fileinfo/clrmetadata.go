@@ -0,0 +1,382 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// metadataSignature is the "BSJB" signature of the ECMA-335 metadata root.
+const metadataSignature = 0x424a5342
+
+// Metadata tables, ECMA-335 II.22.
+const (
+	clrTableModule                 = 0x00
+	clrTableTypeRef                = 0x01
+	clrTableTypeDef                = 0x02
+	clrTableFieldPtr               = 0x03
+	clrTableField                  = 0x04
+	clrTableMethodPtr              = 0x05
+	clrTableMethodDef              = 0x06
+	clrTableParamPtr               = 0x07
+	clrTableParam                  = 0x08
+	clrTableInterfaceImpl          = 0x09
+	clrTableMemberRef              = 0x0a
+	clrTableConstant               = 0x0b
+	clrTableCustomAttribute        = 0x0c
+	clrTableFieldMarshal           = 0x0d
+	clrTableDeclSecurity           = 0x0e
+	clrTableClassLayout            = 0x0f
+	clrTableFieldLayout            = 0x10
+	clrTableStandAloneSig          = 0x11
+	clrTableEventMap               = 0x12
+	clrTableEventPtr               = 0x13
+	clrTableEvent                  = 0x14
+	clrTablePropertyMap            = 0x15
+	clrTablePropertyPtr            = 0x16
+	clrTableProperty               = 0x17
+	clrTableMethodSemantics        = 0x18
+	clrTableMethodImpl             = 0x19
+	clrTableModuleRef              = 0x1a
+	clrTableTypeSpec               = 0x1b
+	clrTableImplMap                = 0x1c
+	clrTableFieldRVA               = 0x1d
+	clrTableEncLog                 = 0x1e
+	clrTableEncMap                 = 0x1f
+	clrTableAssembly               = 0x20
+	clrTableAssemblyProcessor      = 0x21
+	clrTableAssemblyOS             = 0x22
+	clrTableAssemblyRef            = 0x23
+	clrTableAssemblyRefProcessor   = 0x24
+	clrTableAssemblyRefOS          = 0x25
+	clrTableFile                   = 0x26
+	clrTableExportedType           = 0x27
+	clrTableManifestResource       = 0x28
+	clrTableNestedClass            = 0x29
+	clrTableGenericParam           = 0x2a
+	clrTableMethodSpec             = 0x2b
+	clrTableGenericParamConstraint = 0x2c
+)
+
+// Coded indexes, ECMA-335 II.24.2.6. The position of a table is its tag, -1 marks unused tags.
+var (
+	clrTypeDefOrRef       = []int{clrTableTypeDef, clrTableTypeRef, clrTableTypeSpec}
+	clrHasConstant        = []int{clrTableField, clrTableParam, clrTableProperty}
+	clrHasCustomAttribute = []int{
+		clrTableMethodDef, clrTableField, clrTableTypeRef, clrTableTypeDef, clrTableParam, clrTableInterfaceImpl,
+		clrTableMemberRef, clrTableModule, clrTableDeclSecurity, clrTableProperty, clrTableEvent, clrTableStandAloneSig,
+		clrTableModuleRef, clrTableTypeSpec, clrTableAssembly, clrTableAssemblyRef, clrTableFile, clrTableExportedType,
+		clrTableManifestResource, clrTableGenericParam, clrTableGenericParamConstraint, clrTableMethodSpec,
+	}
+	clrHasFieldMarshal     = []int{clrTableField, clrTableParam}
+	clrHasDeclSecurity     = []int{clrTableTypeDef, clrTableMethodDef, clrTableAssembly}
+	clrMemberRefParent     = []int{clrTableTypeDef, clrTableTypeRef, clrTableModuleRef, clrTableMethodDef, clrTableTypeSpec}
+	clrHasSemantics        = []int{clrTableEvent, clrTableProperty}
+	clrMethodDefOrRef      = []int{clrTableMethodDef, clrTableMemberRef}
+	clrMemberForwarded     = []int{clrTableField, clrTableMethodDef}
+	clrImplementation      = []int{clrTableFile, clrTableAssemblyRef, clrTableExportedType}
+	clrCustomAttributeType = []int{-1, -1, clrTableMethodDef, clrTableMemberRef, -1}
+	clrResolutionScope     = []int{clrTableModule, clrTableModuleRef, clrTableAssemblyRef, clrTableTypeRef}
+	clrTypeOrMethodDef     = []int{clrTableTypeDef, clrTableMethodDef}
+)
+
+// clrColumn describes a table column: a constant, a heap index or an index into one of the tables.
+type clrColumn struct {
+	fixed int
+	// heap is the HeapSizes bit that makes the heap index 4 bytes wide.
+	heap byte
+	// tables are the tables a simple or coded index refers to.
+	tables []int
+}
+
+// Constant and heap index columns.
+var (
+	clrFixed16         = clrColumn{fixed: 2}
+	clrFixed32         = clrColumn{fixed: 4}
+	clrHeapStringIndex = clrColumn{heap: 0x01}
+	clrHeapGUIDIndex   = clrColumn{heap: 0x02}
+	clrHeapBlobIndex   = clrColumn{heap: 0x04}
+)
+
+// clrIndex is an index into a single table.
+func clrIndex(table int) clrColumn {
+	return clrColumn{tables: []int{table}}
+}
+
+// clrCoded is a coded index into one of the tables.
+func clrCoded(tables []int) clrColumn {
+	return clrColumn{tables: tables}
+}
+
+// clrTableSchemas are the columns of the metadata tables, ECMA-335 II.22.
+var clrTableSchemas = [...][]clrColumn{
+	clrTableModule:                 {clrFixed16, clrHeapStringIndex, clrHeapGUIDIndex, clrHeapGUIDIndex, clrHeapGUIDIndex},
+	clrTableTypeRef:                {clrCoded(clrResolutionScope), clrHeapStringIndex, clrHeapStringIndex},
+	clrTableTypeDef:                {clrFixed32, clrHeapStringIndex, clrHeapStringIndex, clrCoded(clrTypeDefOrRef), clrIndex(clrTableField), clrIndex(clrTableMethodDef)},
+	clrTableFieldPtr:               {clrIndex(clrTableField)},
+	clrTableField:                  {clrFixed16, clrHeapStringIndex, clrHeapBlobIndex},
+	clrTableMethodPtr:              {clrIndex(clrTableMethodDef)},
+	clrTableMethodDef:              {clrFixed32, clrFixed16, clrFixed16, clrHeapStringIndex, clrHeapBlobIndex, clrIndex(clrTableParam)},
+	clrTableParamPtr:               {clrIndex(clrTableParam)},
+	clrTableParam:                  {clrFixed16, clrFixed16, clrHeapStringIndex},
+	clrTableInterfaceImpl:          {clrIndex(clrTableTypeDef), clrCoded(clrTypeDefOrRef)},
+	clrTableMemberRef:              {clrCoded(clrMemberRefParent), clrHeapStringIndex, clrHeapBlobIndex},
+	clrTableConstant:               {clrFixed16, clrCoded(clrHasConstant), clrHeapBlobIndex},
+	clrTableCustomAttribute:        {clrCoded(clrHasCustomAttribute), clrCoded(clrCustomAttributeType), clrHeapBlobIndex},
+	clrTableFieldMarshal:           {clrCoded(clrHasFieldMarshal), clrHeapBlobIndex},
+	clrTableDeclSecurity:           {clrFixed16, clrCoded(clrHasDeclSecurity), clrHeapBlobIndex},
+	clrTableClassLayout:            {clrFixed16, clrFixed32, clrIndex(clrTableTypeDef)},
+	clrTableFieldLayout:            {clrFixed32, clrIndex(clrTableField)},
+	clrTableStandAloneSig:          {clrHeapBlobIndex},
+	clrTableEventMap:               {clrIndex(clrTableTypeDef), clrIndex(clrTableEvent)},
+	clrTableEventPtr:               {clrIndex(clrTableEvent)},
+	clrTableEvent:                  {clrFixed16, clrHeapStringIndex, clrCoded(clrTypeDefOrRef)},
+	clrTablePropertyMap:            {clrIndex(clrTableTypeDef), clrIndex(clrTableProperty)},
+	clrTablePropertyPtr:            {clrIndex(clrTableProperty)},
+	clrTableProperty:               {clrFixed16, clrHeapStringIndex, clrHeapBlobIndex},
+	clrTableMethodSemantics:        {clrFixed16, clrIndex(clrTableMethodDef), clrCoded(clrHasSemantics)},
+	clrTableMethodImpl:             {clrIndex(clrTableTypeDef), clrCoded(clrMethodDefOrRef), clrCoded(clrMethodDefOrRef)},
+	clrTableModuleRef:              {clrHeapStringIndex},
+	clrTableTypeSpec:               {clrHeapBlobIndex},
+	clrTableImplMap:                {clrFixed16, clrCoded(clrMemberForwarded), clrHeapStringIndex, clrIndex(clrTableModuleRef)},
+	clrTableFieldRVA:               {clrFixed32, clrIndex(clrTableField)},
+	clrTableEncLog:                 {clrFixed32, clrFixed32},
+	clrTableEncMap:                 {clrFixed32},
+	clrTableAssembly:               {clrFixed32, clrFixed16, clrFixed16, clrFixed16, clrFixed16, clrFixed32, clrHeapBlobIndex, clrHeapStringIndex, clrHeapStringIndex},
+	clrTableAssemblyProcessor:      {clrFixed32},
+	clrTableAssemblyOS:             {clrFixed32, clrFixed32, clrFixed32},
+	clrTableAssemblyRef:            {clrFixed16, clrFixed16, clrFixed16, clrFixed16, clrFixed32, clrHeapBlobIndex, clrHeapStringIndex, clrHeapStringIndex, clrHeapBlobIndex},
+	clrTableAssemblyRefProcessor:   {clrFixed32, clrIndex(clrTableAssemblyRef)},
+	clrTableAssemblyRefOS:          {clrFixed32, clrFixed32, clrFixed32, clrIndex(clrTableAssemblyRef)},
+	clrTableFile:                   {clrFixed32, clrHeapStringIndex, clrHeapBlobIndex},
+	clrTableExportedType:           {clrFixed32, clrFixed32, clrHeapStringIndex, clrHeapStringIndex, clrCoded(clrImplementation)},
+	clrTableManifestResource:       {clrFixed32, clrFixed32, clrHeapStringIndex, clrCoded(clrImplementation)},
+	clrTableNestedClass:            {clrIndex(clrTableTypeDef), clrIndex(clrTableTypeDef)},
+	clrTableGenericParam:           {clrFixed16, clrFixed16, clrCoded(clrTypeOrMethodDef), clrHeapStringIndex},
+	clrTableMethodSpec:             {clrCoded(clrMethodDefOrRef), clrHeapBlobIndex},
+	clrTableGenericParamConstraint: {clrIndex(clrTableGenericParam), clrCoded(clrTypeDefOrRef)},
+}
+
+// clrTable is the location and layout of a metadata table in the #~ stream.
+type clrTable struct {
+	rows    uint32
+	offset  int
+	rowSize int
+	// columns are the sizes of the columns.
+	columns []int
+}
+
+// clrMetadata is the parsed metadata root with its heaps and the table layout.
+type clrMetadata struct {
+	version string
+	strings []byte
+	blobs   []byte
+	tables  []byte
+	layout  [len(clrTableSchemas)]clrTable
+}
+
+// parseCLRMetadata parses the metadata root (ECMA-335 II.24.2.1) and the header of the
+// #~ stream. Tables are decoded on demand.
+func parseCLRMetadata(data []byte) (*clrMetadata, error) {
+	if len(data) < 16 || binary.LittleEndian.Uint32(data) != metadataSignature {
+		return nil, errors.New("metadata has no BSJB signature")
+	}
+	length := int(binary.LittleEndian.Uint32(data[12:]))
+	if length > 255 || 16+length+4 > len(data) {
+		return nil, errors.New("metadata version is truncated")
+	}
+	m := &clrMetadata{version: string(bytes.TrimRight(data[16:16+length], "\x00"))}
+	pos := 16 + length
+	streams := int(binary.LittleEndian.Uint16(data[pos+2:]))
+	pos += 4
+	for range streams {
+		if pos+8 > len(data) {
+			return nil, errors.New("metadata stream headers are truncated")
+		}
+		offset := int(binary.LittleEndian.Uint32(data[pos:]))
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := bytes.IndexByte(data[pos+8:min(pos+8+32, len(data))], 0)
+		if end < 0 {
+			return nil, errors.New("metadata stream name is not terminated")
+		}
+		name := string(data[pos+8 : pos+8+end])
+		pos += 8 + (end+4)&^3
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("metadata stream %s is outside of the metadata", name)
+		}
+		stream := data[offset : offset+size]
+		switch name {
+		case "#~", "#-":
+			m.tables = stream
+		case "#Strings":
+			m.strings = stream
+		case "#Blob":
+			m.blobs = stream
+		}
+	}
+	if m.tables == nil {
+		return nil, errors.New("metadata has no tables stream")
+	}
+	if err := m.parseTablesHeader(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parseTablesHeader reads the row counts of the #~ stream and computes the layout of the tables.
+func (m *clrMetadata) parseTablesHeader() error {
+	if len(m.tables) < 24 {
+		return errors.New("metadata tables header is truncated")
+	}
+	heapSizes := m.tables[6]
+	valid := binary.LittleEndian.Uint64(m.tables[8:])
+	pos := 24
+	for table := range 64 {
+		if valid&(1<<table) == 0 {
+			continue
+		}
+		if table >= len(clrTableSchemas) {
+			return fmt.Errorf("unsupported metadata table 0x%02x", table)
+		}
+		if pos+4 > len(m.tables) {
+			return errors.New("metadata table row counts are truncated")
+		}
+		m.layout[table].rows = binary.LittleEndian.Uint32(m.tables[pos:])
+		pos += 4
+	}
+	// Uncompressed #- streams written by edit and continue may carry 4 extra bytes.
+	if heapSizes&0x40 != 0 {
+		pos += 4
+	}
+
+	for table, schema := range clrTableSchemas {
+		t := &m.layout[table]
+		t.columns = make([]int, len(schema))
+		for i, column := range schema {
+			t.columns[i] = m.columnSize(column, heapSizes)
+			t.rowSize += t.columns[i]
+		}
+		t.offset = pos
+		size := uint64(t.rows) * uint64(t.rowSize)
+		if uint64(pos)+size > uint64(len(m.tables)) {
+			return fmt.Errorf("metadata table 0x%02x is truncated", table)
+		}
+		pos += int(size)
+	}
+	return nil
+}
+
+// columnSize returns the size of a column, ECMA-335 II.24.2.6: heap indexes are 4 bytes when the
+// heap's HeapSizes bit is set, table indexes when a referenced table has too many rows for the
+// 16 bits left next to the tag.
+func (m *clrMetadata) columnSize(column clrColumn, heapSizes byte) int {
+	switch {
+	case column.fixed != 0:
+		return column.fixed
+	case column.heap != 0:
+		if heapSizes&column.heap != 0 {
+			return 4
+		}
+		return 2
+	}
+	tagBits := bits.Len(uint(len(column.tables) - 1))
+	for _, table := range column.tables {
+		if table >= 0 && m.layout[table].rows >= 1<<(16-tagBits) {
+			return 4
+		}
+	}
+	return 2
+}
+
+// rows returns the number of rows of a table.
+func (m *clrMetadata) rows(table int) uint32 {
+	return m.layout[table].rows
+}
+
+// row returns the column values of the row with the 1-based index.
+func (m *clrMetadata) row(table int, index uint32) ([]uint32, error) {
+	t := &m.layout[table]
+	if index == 0 || index > t.rows {
+		return nil, fmt.Errorf("row %d of metadata table 0x%02x does not exist", index, table)
+	}
+	pos := t.offset + int(index-1)*t.rowSize
+	values := make([]uint32, len(t.columns))
+	for i, size := range t.columns {
+		if size == 4 {
+			values[i] = binary.LittleEndian.Uint32(m.tables[pos:])
+		} else {
+			values[i] = uint32(binary.LittleEndian.Uint16(m.tables[pos:]))
+		}
+		pos += size
+	}
+	return values, nil
+}
+
+// decodeCodedIndex splits a coded index into the table and the 1-based row, table is -1 for unused tags.
+func decodeCodedIndex(value uint32, tables []int) (table int, row uint32) {
+	tagBits := bits.Len(uint(len(tables) - 1))
+	tag := int(value & (1<<tagBits - 1))
+	if tag >= len(tables) {
+		return -1, 0
+	}
+	return tables[tag], value >> tagBits
+}
+
+// string returns the NUL terminated string at the offset of the #Strings heap.
+func (m *clrMetadata) string(offset uint32) (string, error) {
+	if int64(offset) >= int64(len(m.strings)) {
+		if offset == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("string heap offset 0x%x is out of range", offset)
+	}
+	end := bytes.IndexByte(m.strings[offset:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("string at heap offset 0x%x is not terminated", offset)
+	}
+	return string(m.strings[offset : int(offset)+end]), nil
+}
+
+// blob returns the blob at the offset of the #Blob heap.
+func (m *clrMetadata) blob(offset uint32) ([]byte, error) {
+	if int64(offset) >= int64(len(m.blobs)) {
+		if offset == 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("blob heap offset 0x%x is out of range", offset)
+	}
+	length, n, err := decodeCompressedUint(m.blobs[offset:])
+	if err != nil {
+		return nil, fmt.Errorf("blob at heap offset 0x%x: %w", offset, err)
+	}
+	start := int(offset) + n
+	if uint64(start)+uint64(length) > uint64(len(m.blobs)) {
+		return nil, fmt.Errorf("blob at heap offset 0x%x is truncated", offset)
+	}
+	return m.blobs[start : start+int(length)], nil
+}
+
+// decodeCompressedUint decodes an unsigned integer compressed to 1, 2 or 4 bytes, ECMA-335 II.23.2,
+// and returns it with the number of bytes read.
+func decodeCompressedUint(data []byte) (uint32, int, error) {
+	if len(data) == 0 {
+		return 0, 0, errors.New("compressed integer is truncated")
+	}
+	switch {
+	case data[0]&0x80 == 0:
+		return uint32(data[0]), 1, nil
+	case data[0]&0xc0 == 0x80:
+		if len(data) < 2 {
+			return 0, 0, errors.New("compressed integer is truncated")
+		}
+		return uint32(data[0]&0x3f)<<8 | uint32(data[1]), 2, nil
+	case data[0]&0xe0 == 0xc0:
+		if len(data) < 4 {
+			return 0, 0, errors.New("compressed integer is truncated")
+		}
+		return uint32(data[0]&0x1f)<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3]), 4, nil
+	}
+	return 0, 0, fmt.Errorf("invalid compressed integer prefix 0x%02x", data[0])
+}
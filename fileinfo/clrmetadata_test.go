@@ -0,0 +1,180 @@
+package fileinfo
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCLRHeaps builds the #Strings and #Blob heaps, both start with the empty entry.
+type testCLRHeaps struct {
+	strings []byte
+	blobs   []byte
+}
+
+func newTestCLRHeaps() *testCLRHeaps {
+	return &testCLRHeaps{strings: []byte{0}, blobs: []byte{0}}
+}
+
+func (h *testCLRHeaps) string(s string) uint32 {
+	offset := uint32(len(h.strings))
+	h.strings = append(h.strings, s+"\x00"...)
+	return offset
+}
+
+func (h *testCLRHeaps) blob(b []byte) uint32 {
+	offset := uint32(len(h.blobs))
+	h.blobs = append(h.blobs, byte(len(b)))
+	h.blobs = append(h.blobs, b...)
+	return offset
+}
+
+// testCLRMetadata builds a metadata root with the #~, #Strings and #Blob streams. The rows of
+// the tables are written with the column sizes of clrTableSchemas, table indexes are 2 bytes.
+func testCLRMetadata(heapSizes byte, tables map[int][][]uint32, heaps *testCLRHeaps) []byte {
+	stream := make([]byte, 24)
+	stream[4] = 2
+	stream[6] = heapSizes
+	stream[7] = 1
+	var valid uint64
+	for table := range clrTableSchemas {
+		if rows, ok := tables[table]; ok {
+			valid |= 1 << table
+			stream = binary.LittleEndian.AppendUint32(stream, uint32(len(rows)))
+		}
+	}
+	binary.LittleEndian.PutUint64(stream[8:], valid)
+	for table, schema := range clrTableSchemas {
+		for _, row := range tables[table] {
+			for i, column := range schema {
+				size := 2
+				if column.fixed != 0 {
+					size = column.fixed
+				} else if heapSizes&column.heap != 0 {
+					size = 4
+				}
+				if size == 4 {
+					stream = binary.LittleEndian.AppendUint32(stream, row[i])
+				} else {
+					stream = binary.LittleEndian.AppendUint16(stream, uint16(row[i]))
+				}
+			}
+		}
+	}
+
+	pad4 := func(n int) int {
+		return (n + 3) &^ 3
+	}
+	version := []byte("v4.0.30319\x00\x00")
+	streams := []struct {
+		name string
+		data []byte
+	}{{"#~", stream}, {"#Strings", heaps.strings}, {"#Blob", heaps.blobs}}
+	root := binary.LittleEndian.AppendUint32(nil, metadataSignature)
+	root = binary.LittleEndian.AppendUint16(root, 1)
+	root = binary.LittleEndian.AppendUint16(root, 1)
+	root = binary.LittleEndian.AppendUint32(root, 0)
+	root = binary.LittleEndian.AppendUint32(root, uint32(len(version)))
+	root = append(root, version...)
+	root = binary.LittleEndian.AppendUint16(root, 0)
+	root = binary.LittleEndian.AppendUint16(root, uint16(len(streams)))
+	headersSize := 0
+	for _, s := range streams {
+		headersSize += 8 + pad4(len(s.name)+1)
+	}
+	offset := len(root) + headersSize
+	for _, s := range streams {
+		root = binary.LittleEndian.AppendUint32(root, uint32(offset))
+		root = binary.LittleEndian.AppendUint32(root, uint32(len(s.data)))
+		root = append(root, make([]byte, pad4(len(s.name)+1))...)
+		copy(root[len(root)-pad4(len(s.name)+1):], s.name)
+		offset += pad4(len(s.data))
+	}
+	for _, s := range streams {
+		root = append(root, s.data...)
+		root = append(root, make([]byte, pad4(len(s.data))-len(s.data))...)
+	}
+	return root
+}
+
+func TestParseCLRMetadata(t *testing.T) {
+	for _, heapSizes := range []byte{0, 0x07} {
+		heaps := newTestCLRHeaps()
+		name := heaps.string("Module")
+		key := heaps.blob([]byte{1, 2, 3})
+		data := testCLRMetadata(heapSizes, map[int][][]uint32{
+			clrTableModuleRef:   {{name}},
+			clrTableAssemblyRef: {{1, 2, 3, 4, 0, key, name, 0, 0}},
+		}, heaps)
+
+		m, err := parseCLRMetadata(data)
+		require.NoError(t, err)
+		assert.Equal(t, "v4.0.30319", m.version)
+		assert.Equal(t, uint32(1), m.rows(clrTableModuleRef))
+		assert.Equal(t, uint32(0), m.rows(clrTableTypeDef))
+
+		row, err := m.row(clrTableAssemblyRef, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []uint32{1, 2, 3, 4, 0, key, name, 0, 0}, row)
+		s, err := m.string(row[6])
+		require.NoError(t, err)
+		assert.Equal(t, "Module", s)
+		b, err := m.blob(row[5])
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1, 2, 3}, b)
+
+		_, err = m.row(clrTableAssemblyRef, 2)
+		assert.ErrorContains(t, err, "row 2 of metadata table 0x23 does not exist")
+		_, err = m.string(0x1000)
+		assert.ErrorContains(t, err, "string heap offset 0x1000 is out of range")
+	}
+}
+
+func TestParseCLRMetadataErrors(t *testing.T) {
+	_, err := parseCLRMetadata(make([]byte, 32))
+	require.ErrorContains(t, err, "metadata has no BSJB signature")
+
+	data := testCLRMetadata(0, map[int][][]uint32{clrTableModuleRef: {{0}, {0}}}, newTestCLRHeaps())
+	// Claim more rows than the stream holds.
+	stream := binary.LittleEndian.Uint32(data[32:])
+	binary.LittleEndian.PutUint32(data[stream+24:], 1000)
+	_, err = parseCLRMetadata(data)
+	require.ErrorContains(t, err, "metadata table 0x1a is truncated")
+}
+
+func TestDecodeCompressedUint(t *testing.T) {
+	for _, tt := range []struct {
+		data  []byte
+		value uint32
+		n     int
+	}{
+		{[]byte{0x03}, 0x03, 1},
+		{[]byte{0x7f}, 0x7f, 1},
+		{[]byte{0x80, 0x80}, 0x80, 2},
+		{[]byte{0xbf, 0xff}, 0x3fff, 2},
+		{[]byte{0xc0, 0x00, 0x40, 0x00}, 0x4000, 4},
+		{[]byte{0xdf, 0xff, 0xff, 0xff}, 0x1fffffff, 4},
+	} {
+		value, n, err := decodeCompressedUint(tt.data)
+		require.NoError(t, err)
+		assert.Equal(t, tt.value, value)
+		assert.Equal(t, tt.n, n)
+	}
+	_, _, err := decodeCompressedUint([]byte{0x80})
+	assert.ErrorContains(t, err, "compressed integer is truncated")
+}
+
+func TestDecodeCodedIndex(t *testing.T) {
+	table, row := decodeCodedIndex(3<<5|14, clrHasCustomAttribute)
+	assert.Equal(t, clrTableAssembly, table)
+	assert.Equal(t, uint32(3), row)
+
+	table, row = decodeCodedIndex(2<<3|3, clrCustomAttributeType)
+	assert.Equal(t, clrTableMemberRef, table)
+	assert.Equal(t, uint32(2), row)
+
+	table, _ = decodeCodedIndex(1<<3|0, clrCustomAttributeType)
+	assert.Equal(t, -1, table)
+}
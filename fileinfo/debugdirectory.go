@@ -0,0 +1,399 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DebugType is the type of a debug directory entry (IMAGE_DEBUG_TYPE_*).
+// https://learn.microsoft.com/en-us/windows/win32/debug/pe-format#debug-type
+type DebugType uint32
+
+const (
+	DebugTypeUnknown              DebugType = 0
+	DebugTypeCOFF                 DebugType = 1
+	DebugTypeCodeView             DebugType = 2
+	DebugTypeFPO                  DebugType = 3
+	DebugTypeMisc                 DebugType = 4
+	DebugTypeException            DebugType = 5
+	DebugTypeFixup                DebugType = 6
+	DebugTypeOMAPToSrc            DebugType = 7
+	DebugTypeOMAPFromSrc          DebugType = 8
+	DebugTypeBorland              DebugType = 9
+	DebugTypeCLSID                DebugType = 11
+	DebugTypeVCFeature            DebugType = 12
+	DebugTypePOGO                 DebugType = 13
+	DebugTypeILTCG                DebugType = 14
+	DebugTypeMPX                  DebugType = 15
+	DebugTypeRepro                DebugType = 16
+	DebugTypeEmbeddedPortablePDB  DebugType = 17
+	DebugTypePDBChecksum          DebugType = 19
+	DebugTypeExDllCharacteristics DebugType = 20
+)
+
+var debugTypeNames = map[DebugType]string{
+	DebugTypeUnknown:              "UNKNOWN",
+	DebugTypeCOFF:                 "COFF",
+	DebugTypeCodeView:             "CODEVIEW",
+	DebugTypeFPO:                  "FPO",
+	DebugTypeMisc:                 "MISC",
+	DebugTypeException:            "EXCEPTION",
+	DebugTypeFixup:                "FIXUP",
+	DebugTypeOMAPToSrc:            "OMAP_TO_SRC",
+	DebugTypeOMAPFromSrc:          "OMAP_FROM_SRC",
+	DebugTypeBorland:              "BORLAND",
+	DebugTypeCLSID:                "CLSID",
+	DebugTypeVCFeature:            "VC_FEATURE",
+	DebugTypePOGO:                 "POGO",
+	DebugTypeILTCG:                "ILTCG",
+	DebugTypeMPX:                  "MPX",
+	DebugTypeRepro:                "REPRO",
+	DebugTypeEmbeddedPortablePDB:  "EMBEDDED_PORTABLE_PDB",
+	DebugTypePDBChecksum:          "PDBCHECKSUM",
+	DebugTypeExDllCharacteristics: "EX_DLLCHARACTERISTICS",
+}
+
+func (t DebugType) String() string {
+	if name, ok := debugTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", uint32(t))
+}
+
+// CodeView signatures of the PDB 7.0 and PDB 2.0 formats.
+const (
+	codeViewRSDS = "RSDS"
+	codeViewNB10 = "NB10"
+)
+
+// imageDebugDirectory is the binary layout of IMAGE_DEBUG_DIRECTORY.
+type imageDebugDirectory struct {
+	Characteristics  uint32
+	TimeDateStamp    uint32
+	MajorVersion     uint16
+	MinorVersion     uint16
+	Type             DebugType
+	SizeOfData       uint32
+	AddressOfRawData uint32
+	PointerToRawData uint32
+}
+
+// DebugInfo is the debug directory of an image.
+type DebugInfo struct {
+	Entries []DebugEntry
+	// TimeDateStamp and SizeOfImage from the headers make up the symbol server key of the image.
+	TimeDateStamp uint32
+	SizeOfImage   uint32
+}
+
+// DebugEntry is an IMAGE_DEBUG_DIRECTORY entry. The field matching Type holds the decoded data,
+// entries of other types only carry the raw location. When the data cannot be read or decoded the
+// field stays nil and Err holds the reason.
+type DebugEntry struct {
+	Type             DebugType
+	TimeDateStamp    uint32
+	MajorVersion     uint16
+	MinorVersion     uint16
+	SizeOfData       uint32
+	AddressOfRawData uint32
+	PointerToRawData uint32
+
+	CodeView  *CodeView
+	POGO      *POGO
+	Repro     *Repro
+	VCFeature *VCFeature
+	// Err is the error reading or decoding the data of the entry.
+	Err error
+}
+
+// CodeView is the CODEVIEW entry that links the image to its PDB.
+type CodeView struct {
+	// Signature is "RSDS" for PDB 7.0 files identified by GUID, "NB10" for PDB 2.0 files.
+	Signature string
+	// GUID identifies an RSDS PDB, formatted as "3844DBB9-2017-4967-BE7A-A4A2C20430FA".
+	GUID string
+	// Timestamp identifies an NB10 PDB.
+	Timestamp uint32
+	// Age is incremented every time the PDB is updated.
+	Age uint32
+	// PDBPath is the path of the PDB at link time.
+	PDBPath string
+}
+
+// PDBName returns the file name of the PDB.
+func (c *CodeView) PDBName() string {
+	_, name, _ := splitWindowsPath(c.PDBPath)
+	return name
+}
+
+// SymbolKey returns the symbol server path of the PDB, "<pdb>/<GUID><age>/<pdb>" with the GUID
+// without dashes and the age in hexadecimal, as symstore stores it and debuggers look it up.
+func (c *CodeView) SymbolKey() string {
+	name := c.PDBName()
+	if c.Signature == codeViewNB10 {
+		return fmt.Sprintf("%s/%08X%X/%s", name, c.Timestamp, c.Age, name)
+	}
+	return fmt.Sprintf("%s/%s%X/%s", name, strings.ReplaceAll(c.GUID, "-", ""), c.Age, name)
+}
+
+// POGO is the profile guided optimization entry, listing the contributions to the image's sections.
+type POGO struct {
+	// Signature is "LTCG" for link time code generation or "PGI", "PGO" and "PGU" for the PGO phases.
+	Signature string
+	Entries   []POGOEntry
+}
+
+// POGOEntry is a contribution such as ".text$mn" or ".rdata$zzzdbg".
+type POGOEntry struct {
+	RVA  uint32
+	Size uint32
+	Name string
+}
+
+// Repro is the REPRO entry of images linked with /Brepro, whose time stamps are hashes.
+type Repro struct {
+	// Hash is the image hash the time stamps derive from, empty for older linkers that only mark the image.
+	Hash []byte
+}
+
+// VCFeature counts the objects built with the compiler features the linker reports.
+type VCFeature struct {
+	PreVC11 uint32
+	CCpp    uint32
+	// GS counts objects with /GS stack buffer checks, SDL objects with /sdl.
+	GS     uint32
+	SDL    uint32
+	GuardN uint32
+}
+
+// CodeView returns the first CodeView entry, nil when the image has none.
+func (d *DebugInfo) CodeView() *CodeView {
+	for _, e := range d.Entries {
+		if e.CodeView != nil {
+			return e.CodeView
+		}
+	}
+	return nil
+}
+
+// PDBSymbolKey returns the symbol server path of the PDB, empty when the image has no CodeView entry.
+func (d *DebugInfo) PDBSymbolKey() string {
+	if cv := d.CodeView(); cv != nil {
+		return cv.SymbolKey()
+	}
+	return ""
+}
+
+// ImageSymbolKey returns the symbol server path of the image with the given file name,
+// "<file>/<TimeDateStamp><SizeOfImage>/<file>" with the time stamp as 8 hex digits.
+func (d *DebugInfo) ImageSymbolKey(fileName string) string {
+	return fmt.Sprintf("%s/%08X%x/%s", fileName, d.TimeDateStamp, d.SizeOfImage, fileName)
+}
+
+// ReadDebugInfo reads the debug directory of the PE image in r, size is the total image size.
+// CODEVIEW, POGO, REPRO and VC_FEATURE entries are decoded, an entry that fails to decode is kept
+// with its error in Err.
+func ReadDebugInfo(r io.ReaderAt, size int64) (*DebugInfo, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.debugInfo()
+}
+
+func readDebugInfoFile(path string) (*DebugInfo, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.debugInfo()
+}
+
+func (p *peImage) debugInfo() (*DebugInfo, error) {
+	entries, err := p.debugDirectory()
+	if err != nil {
+		return nil, err
+	}
+	info := &DebugInfo{TimeDateStamp: p.file.TimeDateStamp}
+	switch oh := p.file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		info.SizeOfImage = oh.SizeOfImage
+	case *pe.OptionalHeader64:
+		info.SizeOfImage = oh.SizeOfImage
+	}
+	for _, entry := range entries {
+		e := DebugEntry{
+			Type:             entry.Type,
+			TimeDateStamp:    entry.TimeDateStamp,
+			MajorVersion:     entry.MajorVersion,
+			MinorVersion:     entry.MinorVersion,
+			SizeOfData:       entry.SizeOfData,
+			AddressOfRawData: entry.AddressOfRawData,
+			PointerToRawData: entry.PointerToRawData,
+		}
+		switch entry.Type {
+		case DebugTypeCodeView, DebugTypePOGO, DebugTypeRepro, DebugTypeVCFeature:
+		default:
+			info.Entries = append(info.Entries, e)
+			continue
+		}
+		var data []byte
+		if entry.SizeOfData > 0 {
+			if data, err = p.debugData(entry); err != nil {
+				e.Err = fmt.Errorf("failed to read %s debug data: %w", entry.Type, err)
+				info.Entries = append(info.Entries, e)
+				continue
+			}
+		}
+		switch entry.Type {
+		case DebugTypeCodeView:
+			e.CodeView, err = parseCodeView(data)
+		case DebugTypePOGO:
+			e.POGO, err = parsePOGO(data)
+		case DebugTypeRepro:
+			e.Repro, err = parseRepro(data)
+		case DebugTypeVCFeature:
+			e.VCFeature, err = parseVCFeature(data)
+		}
+		if err != nil {
+			e.Err = fmt.Errorf("%s debug entry: %w", entry.Type, err)
+		}
+		info.Entries = append(info.Entries, e)
+	}
+	return info, nil
+}
+
+// parseCodeView parses CV_INFO_PDB70 ("RSDS") and CV_INFO_PDB20 ("NB10").
+func parseCodeView(data []byte) (*CodeView, error) {
+	if len(data) < 4 {
+		return nil, errors.New("codeview data is truncated")
+	}
+	cv := &CodeView{Signature: string(data[:4])}
+	var path []byte
+	switch cv.Signature {
+	case codeViewRSDS:
+		if len(data) < 24 {
+			return nil, errors.New("codeview data is truncated")
+		}
+		cv.GUID = formatGUID(data[4:20])
+		cv.Age = binary.LittleEndian.Uint32(data[20:])
+		path = data[24:]
+	case codeViewNB10:
+		if len(data) < 16 {
+			return nil, errors.New("codeview data is truncated")
+		}
+		cv.Timestamp = binary.LittleEndian.Uint32(data[8:])
+		cv.Age = binary.LittleEndian.Uint32(data[12:])
+		path = data[16:]
+	default:
+		return nil, fmt.Errorf("unsupported codeview signature %q", cv.Signature)
+	}
+	if end := bytes.IndexByte(path, 0); end >= 0 {
+		path = path[:end]
+	}
+	cv.PDBPath = string(path)
+	return cv, nil
+}
+
+// parsePOGO parses the signature followed by RVA, size and NUL terminated name entries,
+// each padded to a multiple of four bytes.
+func parsePOGO(data []byte) (*POGO, error) {
+	if len(data) < 4 {
+		return nil, errors.New("pogo data is truncated")
+	}
+	// The signature is a multi-character constant such as 'LTCG', stored little-endian.
+	signature := []byte{data[3], data[2], data[1], data[0]}
+	pogo := &POGO{Signature: string(bytes.TrimRight(signature, "\x00"))}
+	for pos := 4; pos+8 < len(data); {
+		end := bytes.IndexByte(data[pos+8:], 0)
+		if end < 0 {
+			return nil, errors.New("pogo entry name is not terminated")
+		}
+		pogo.Entries = append(pogo.Entries, POGOEntry{
+			RVA:  binary.LittleEndian.Uint32(data[pos:]),
+			Size: binary.LittleEndian.Uint32(data[pos+4:]),
+			Name: string(data[pos+8 : pos+8+end]),
+		})
+		pos += 8 + (end+4)&^3
+	}
+	return pogo, nil
+}
+
+// parseRepro parses the length prefixed hash of a REPRO entry, which is empty for older linkers.
+func parseRepro(data []byte) (*Repro, error) {
+	if len(data) == 0 {
+		return &Repro{}, nil
+	}
+	if len(data) < 4 {
+		return nil, errors.New("repro data is truncated")
+	}
+	length := binary.LittleEndian.Uint32(data)
+	if uint64(length) > uint64(len(data)-4) {
+		return nil, errors.New("repro hash is truncated")
+	}
+	return &Repro{Hash: bytes.Clone(data[4 : 4+length])}, nil
+}
+
+// parseVCFeature parses the five counters of a VC_FEATURE entry.
+func parseVCFeature(data []byte) (*VCFeature, error) {
+	if len(data) < 20 {
+		return nil, errors.New("vc feature data is truncated")
+	}
+	return &VCFeature{
+		PreVC11: binary.LittleEndian.Uint32(data),
+		CCpp:    binary.LittleEndian.Uint32(data[4:]),
+		GS:      binary.LittleEndian.Uint32(data[8:]),
+		SDL:     binary.LittleEndian.Uint32(data[12:]),
+		GuardN:  binary.LittleEndian.Uint32(data[16:]),
+	}, nil
+}
+
+// debugDirectory returns the entries of the debug directory, nil when the image has none.
+func (p *peImage) debugDirectory() ([]imageDebugDirectory, error) {
+	dir, ok := p.dataDirectory(imageDirectoryEntryDebug)
+	if !ok || dir.Size == 0 {
+		return nil, nil
+	}
+	data, err := p.readRVA(dir.VirtualAddress, dir.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read debug directory: %w", err)
+	}
+	entries := make([]imageDebugDirectory, 0, len(data)/28)
+	for pos := 0; pos+28 <= len(data); pos += 28 {
+		e := data[pos:]
+		entries = append(entries, imageDebugDirectory{
+			Characteristics:  binary.LittleEndian.Uint32(e),
+			TimeDateStamp:    binary.LittleEndian.Uint32(e[4:]),
+			MajorVersion:     binary.LittleEndian.Uint16(e[8:]),
+			MinorVersion:     binary.LittleEndian.Uint16(e[10:]),
+			Type:             DebugType(binary.LittleEndian.Uint32(e[12:])),
+			SizeOfData:       binary.LittleEndian.Uint32(e[16:]),
+			AddressOfRawData: binary.LittleEndian.Uint32(e[20:]),
+			PointerToRawData: binary.LittleEndian.Uint32(e[24:]),
+		})
+	}
+	return entries, nil
+}
+
+// debugData reads the data of a debug directory entry, mapped by its RVA when it has one
+// and by its file offset otherwise.
+func (p *peImage) debugData(entry imageDebugDirectory) ([]byte, error) {
+	if entry.AddressOfRawData != 0 {
+		return p.readRVA(entry.AddressOfRawData, entry.SizeOfData)
+	}
+	return p.readAt(int64(entry.PointerToRawData), int64(entry.SizeOfData))
+}
+
+// formatGUID formats a GUID stored in its Windows byte order, e.g. "3844DBB9-2017-4967-BE7A-A4A2C20430FA".
+func formatGUID(g []byte) string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+		binary.LittleEndian.Uint32(g), binary.LittleEndian.Uint16(g[4:]), binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:16])
+}
@@ -0,0 +1,182 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDebugEntry is a debug directory entry with its data.
+type testDebugEntry struct {
+	typ  DebugType
+	data []byte
+}
+
+// testImageWithDebugEntries places the debug directory at the start of an .rdata section
+// followed by the data of the entries, which are addressed by RVA and file offset.
+func testImageWithDebugEntries(t *testing.T, entries ...testDebugEntry) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.timeDateStamp = 0x5c8a1c2e
+	b.addSection(".text", []byte{0xc3}, pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	rva := b.nextRVA()
+	// The second section follows the first one's single file alignment block.
+	offset := uint32(0x200 + testFileAlignment)
+
+	data := make([]byte, 28*len(entries))
+	for i, e := range entries {
+		entry := make([]byte, 28)
+		binary.LittleEndian.PutUint32(entry[12:], uint32(e.typ))
+		binary.LittleEndian.PutUint32(entry[16:], uint32(len(e.data)))
+		if len(e.data) > 0 {
+			binary.LittleEndian.PutUint32(entry[20:], rva+uint32(len(data)))
+			binary.LittleEndian.PutUint32(entry[24:], offset+uint32(len(data)))
+		}
+		copy(data[i*28:], entry)
+		data = append(data, e.data...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	b.addSection(".rdata", data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryDebug, rva, uint32(28*len(entries)))
+	return b.build(t)
+}
+
+func testRSDS(guid []byte, age uint32, path string) []byte {
+	data := append([]byte("RSDS"), guid...)
+	data = binary.LittleEndian.AppendUint32(data, age)
+	return append(data, path+"\x00"...)
+}
+
+func testPOGO(signature string, entries ...POGOEntry) []byte {
+	sig := []byte(signature + "\x00\x00\x00\x00")[:4]
+	data := []byte{sig[3], sig[2], sig[1], sig[0]}
+	for _, e := range entries {
+		data = binary.LittleEndian.AppendUint32(data, e.RVA)
+		data = binary.LittleEndian.AppendUint32(data, e.Size)
+		data = append(data, e.Name+"\x00"...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	return data
+}
+
+var testGUID = []byte{0xb9, 0xdb, 0x44, 0x38, 0x17, 0x20, 0x67, 0x49, 0xbe, 0x7a, 0xa4, 0xa2, 0xc2, 0x04, 0x30, 0xfa}
+
+func TestReadDebugInfo(t *testing.T) {
+	hash := bytes.Repeat([]byte{0xab}, 32)
+	image := testImageWithDebugEntries(t,
+		testDebugEntry{DebugTypeCodeView, testRSDS(testGUID, 2, `D:\a\_work\1\s\out\Release\svc.pdb`)},
+		testDebugEntry{DebugTypePOGO, testPOGO("LTCG", POGOEntry{0x1000, 0x2f0, ".text$mn"}, POGOEntry{0x2000, 0x18, ".rdata$zzzdbg"})},
+		testDebugEntry{DebugTypeRepro, append(binary.LittleEndian.AppendUint32(nil, 32), hash...)},
+		testDebugEntry{DebugTypeVCFeature, []byte{0, 0, 0, 0, 12, 0, 0, 0, 12, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0}},
+		testDebugEntry{DebugTypeExDllCharacteristics, []byte{1, 0, 0, 0}},
+	)
+	info, err := ReadDebugInfo(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.Len(t, info.Entries, 5)
+
+	cv := info.CodeView()
+	require.NotNil(t, cv)
+	assert.Equal(t, &CodeView{
+		Signature: "RSDS",
+		GUID:      "3844DBB9-2017-4967-BE7A-A4A2C20430FA",
+		Age:       2,
+		PDBPath:   `D:\a\_work\1\s\out\Release\svc.pdb`,
+	}, cv)
+	assert.Equal(t, "svc.pdb", cv.PDBName())
+	assert.Equal(t, "svc.pdb/3844DBB920174967BE7AA4A2C20430FA2/svc.pdb", info.PDBSymbolKey())
+	assert.Equal(t, "svc.exe/5C8A1C2E3000/svc.exe", info.ImageSymbolKey("svc.exe"))
+
+	assert.Equal(t, &POGO{Signature: "LTCG", Entries: []POGOEntry{
+		{RVA: 0x1000, Size: 0x2f0, Name: ".text$mn"},
+		{RVA: 0x2000, Size: 0x18, Name: ".rdata$zzzdbg"},
+	}}, info.Entries[1].POGO)
+	assert.Equal(t, &Repro{Hash: hash}, info.Entries[2].Repro)
+	assert.Equal(t, &VCFeature{CCpp: 12, GS: 12, GuardN: 1}, info.Entries[3].VCFeature)
+	assert.Equal(t, DebugTypeExDllCharacteristics, info.Entries[4].Type)
+	assert.Equal(t, "EX_DLLCHARACTERISTICS", info.Entries[4].Type.String())
+	assert.Equal(t, "unknown (42)", DebugType(42).String())
+}
+
+func TestReadDebugInfoNB10(t *testing.T) {
+	nb10 := append([]byte("NB10"), 0, 0, 0, 0, 0x2e, 0x1c, 0x8a, 0x3b, 1, 0, 0, 0)
+	nb10 = append(nb10, "legacy.pdb\x00"...)
+	image := testImageWithDebugEntries(t,
+		testDebugEntry{DebugTypeCodeView, nb10},
+		testDebugEntry{DebugTypeRepro, nil},
+	)
+	info, err := ReadDebugInfo(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Equal(t, "legacy.pdb/3B8A1C2E1/legacy.pdb", info.PDBSymbolKey())
+	assert.Equal(t, &Repro{}, info.Entries[1].Repro)
+}
+
+func TestReadDebugInfoMalformed(t *testing.T) {
+	for _, tt := range []struct {
+		entry testDebugEntry
+		err   string
+	}{
+		{testDebugEntry{DebugTypeCodeView, []byte("RSDS\x00")}, "CODEVIEW debug entry: codeview data is truncated"},
+		{testDebugEntry{DebugTypeCodeView, []byte("XXXX\x00\x00\x00\x00")}, `CODEVIEW debug entry: unsupported codeview signature "XXXX"`},
+		{testDebugEntry{DebugTypePOGO, append(testPOGO("PGU"), 0, 0, 0, 0, 0, 0, 0, 0, 'x')}, "POGO debug entry: pogo entry name is not terminated"},
+		{testDebugEntry{DebugTypeRepro, binary.LittleEndian.AppendUint32(nil, 32)}, "REPRO debug entry: repro hash is truncated"},
+		{testDebugEntry{DebugTypeVCFeature, make([]byte, 8)}, "VC_FEATURE debug entry: vc feature data is truncated"},
+	} {
+		image := testImageWithDebugEntries(t, tt.entry)
+		info, err := ReadDebugInfo(bytes.NewReader(image), int64(len(image)))
+		require.NoError(t, err)
+		require.Len(t, info.Entries, 1)
+		assert.EqualError(t, info.Entries[0].Err, tt.err)
+		assert.Nil(t, info.CodeView())
+	}
+}
+
+func TestReadDebugInfoKeepsOtherEntries(t *testing.T) {
+	// An entry whose data is outside of the file and an NB11 CodeView entry before the RSDS one.
+	image := testImageWithDebugEntries(t,
+		testDebugEntry{DebugTypePOGO, testPOGO("LTCG")},
+		testDebugEntry{DebugTypeCodeView, []byte("NB11\x00\x00\x00\x00")},
+		testDebugEntry{DebugTypeCodeView, testRSDS(testGUID, 1, "svc.pdb")},
+	)
+	entry := 0x200 + testFileAlignment
+	binary.LittleEndian.PutUint32(image[entry+20:], 0x7fff0000)
+	binary.LittleEndian.PutUint32(image[entry+24:], 0x7fff0000)
+
+	info, err := ReadDebugInfo(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.Len(t, info.Entries, 3)
+	assert.Nil(t, info.Entries[0].POGO)
+	assert.ErrorContains(t, info.Entries[0].Err, "failed to read POGO debug data")
+	assert.Nil(t, info.Entries[1].CodeView)
+	assert.EqualError(t, info.Entries[1].Err, `CODEVIEW debug entry: unsupported codeview signature "NB11"`)
+	assert.NoError(t, info.Entries[2].Err)
+	assert.Equal(t, "svc.pdb/3844DBB920174967BE7AA4A2C20430FA1/svc.pdb", info.PDBSymbolKey())
+}
+
+func TestReadDebugInfoNone(t *testing.T) {
+	image := testUnsignedImage(t, false)
+	info, err := ReadDebugInfo(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Empty(t, info.Entries)
+	assert.Nil(t, info.CodeView())
+	assert.Empty(t, info.PDBSymbolKey())
+}
+
+func TestWinFileInfoGetDebugInfo(t *testing.T) {
+	path := testWriteFile(t, "svc.exe", testImageWithDebugEntries(t,
+		testDebugEntry{DebugTypeCodeView, testRSDS(testGUID, 1, "svc.pdb")}))
+	wf, err := NewWinFileInfo(path)
+	require.NoError(t, err)
+	info, err := wf.GetDebugInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "svc.pdb/3844DBB920174967BE7AA4A2C20430FA1/svc.pdb", info.PDBSymbolKey())
+	assert.Equal(t, "svc.exe/5C8A1C2E3000/svc.exe", info.ImageSymbolKey(filepath.Base(path)))
+}
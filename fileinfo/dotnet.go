@@ -0,0 +1,308 @@
+package fileinfo
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// COMIMAGE_FLAGS_* of the CLR header.
+const (
+	clrFlagILOnly           = 0x00000001
+	clrFlag32BitRequired    = 0x00000002
+	clrFlagStrongNameSigned = 0x00000008
+	clrFlag32BitPreferred   = 0x00020000
+)
+
+const (
+	// readyToRunSignature is the "RTR" signature of READYTORUN_HEADER.
+	readyToRunSignature = 0x00525452
+	// assemblyFlagPublicKey marks an AssemblyRef holding the full public key rather than its token.
+	assemblyFlagPublicKey = 0x0001
+)
+
+// AssemblyVersion is the four part version of an assembly.
+type AssemblyVersion struct {
+	Major    uint16
+	Minor    uint16
+	Build    uint16
+	Revision uint16
+}
+
+func (v AssemblyVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Build, v.Revision)
+}
+
+// AssemblyName is the identity of an assembly or of an assembly reference.
+type AssemblyName struct {
+	Name    string
+	Version AssemblyVersion
+	// Culture is empty for culture neutral assemblies.
+	Culture string
+	// PublicKeyToken is the hex encoded token of strong named assemblies, e.g. "b03f5f7f11d50a3a".
+	PublicKeyToken string
+}
+
+// String returns the display name, e.g. "System.Runtime, Version=8.0.0.0, Culture=neutral, PublicKeyToken=b03f5f7f11d50a3a".
+func (a AssemblyName) String() string {
+	culture, token := a.Culture, a.PublicKeyToken
+	if culture == "" {
+		culture = "neutral"
+	}
+	if token == "" {
+		token = "null"
+	}
+	return fmt.Sprintf("%s, Version=%s, Culture=%s, PublicKeyToken=%s", a.Name, a.Version, culture, token)
+}
+
+// DotNetAssembly is the CLR header and the assembly metadata of a .NET image.
+type DotNetAssembly struct {
+	// RuntimeVersion is the version string of the metadata root, "v4.0.30319" for .NET Framework 4
+	// and all versions of .NET Core.
+	RuntimeVersion string
+	// HeaderVersion is the runtime version in the CLR header, 2.5 for all current compilers.
+	HeaderVersion HeaderVersion
+	// Flags are the COMIMAGE_FLAGS_* of the CLR header.
+	Flags            uint32
+	ILOnly           bool
+	Required32Bit    bool
+	Preferred32Bit   bool
+	StrongNameSigned bool
+	// MixedMode reports whether the image contains native code next to IL, as C++/CLI images do.
+	MixedMode bool
+	// ReadyToRun reports whether the IL is precompiled to native code by crossgen.
+	ReadyToRun bool
+
+	// Assembly is nil for modules without an assembly manifest.
+	Assembly *AssemblyName
+	// TargetFramework is the TargetFrameworkAttribute value, e.g. ".NETCoreApp,Version=v8.0".
+	// It is empty when the assembly does not have the attribute.
+	TargetFramework string
+	References      []AssemblyName
+}
+
+// Framework splits TargetFramework into the framework identifier, e.g. ".NETCoreApp", ".NETFramework"
+// or ".NETStandard", and the version without the "v" prefix, e.g. "8.0".
+func (a *DotNetAssembly) Framework() (identifier, version string) {
+	identifier, options, _ := strings.Cut(a.TargetFramework, ",")
+	for _, option := range strings.Split(options, ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(option), "Version="); ok {
+			version = strings.TrimPrefix(v, "v")
+		}
+	}
+	return identifier, version
+}
+
+// Reference returns the referenced assembly with the given name, compared case-insensitively,
+// nil when the assembly is not referenced.
+func (a *DotNetAssembly) Reference(name string) *AssemblyName {
+	for i := range a.References {
+		if strings.EqualFold(a.References[i].Name, name) {
+			return &a.References[i]
+		}
+	}
+	return nil
+}
+
+// ReadDotNetAssembly reads the CLR header and the assembly metadata of the PE image in r, size is
+// the total image size. It returns nil for native images without a CLR header.
+func ReadDotNetAssembly(r io.ReaderAt, size int64) (*DotNetAssembly, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.dotNetAssembly()
+}
+
+func readDotNetAssemblyFile(path string) (*DotNetAssembly, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.dotNetAssembly()
+}
+
+// dotNetAssembly reads IMAGE_COR20_HEADER, the metadata it points to and the READYTORUN_HEADER
+// its ManagedNativeHeader points to.
+func (p *peImage) dotNetAssembly() (*DotNetAssembly, error) {
+	dir, ok := p.dataDirectory(imageDirectoryEntryCLR)
+	if !ok || dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	header, err := p.readRVA(dir.VirtualAddress, 72)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CLR header: %w", err)
+	}
+	flags := binary.LittleEndian.Uint32(header[16:])
+	a := &DotNetAssembly{
+		HeaderVersion: HeaderVersion{
+			Major: binary.LittleEndian.Uint16(header[4:]),
+			Minor: binary.LittleEndian.Uint16(header[6:]),
+		},
+		Flags:            flags,
+		ILOnly:           flags&clrFlagILOnly != 0,
+		Required32Bit:    flags&clrFlag32BitRequired != 0,
+		Preferred32Bit:   flags&clrFlag32BitPreferred != 0,
+		StrongNameSigned: flags&clrFlagStrongNameSigned != 0,
+		MixedMode:        flags&clrFlagILOnly == 0,
+	}
+
+	if nativeHeader := binary.LittleEndian.Uint32(header[64:]); nativeHeader != 0 {
+		signature, err := p.readRVA(nativeHeader, 4)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read managed native header: %w", err)
+		}
+		a.ReadyToRun = binary.LittleEndian.Uint32(signature) == readyToRunSignature
+	}
+
+	data, err := p.readRVA(binary.LittleEndian.Uint32(header[8:]), binary.LittleEndian.Uint32(header[12:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CLR metadata: %w", err)
+	}
+	m, err := parseCLRMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	a.RuntimeVersion = m.version
+	if err := a.readMetadata(m); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *DotNetAssembly) readMetadata(m *clrMetadata) error {
+	if m.rows(clrTableAssembly) > 0 {
+		row, err := m.row(clrTableAssembly, 1)
+		if err != nil {
+			return err
+		}
+		name, err := m.assemblyName(row[1:5], row[6], row[7], row[8], true)
+		if err != nil {
+			return fmt.Errorf("failed to read assembly name: %w", err)
+		}
+		a.Assembly = &name
+	}
+	for i := range m.rows(clrTableAssemblyRef) {
+		row, err := m.row(clrTableAssemblyRef, i+1)
+		if err != nil {
+			return err
+		}
+		name, err := m.assemblyName(row[0:4], row[5], row[6], row[7], row[4]&assemblyFlagPublicKey != 0)
+		if err != nil {
+			return fmt.Errorf("failed to read assembly reference %d: %w", i+1, err)
+		}
+		a.References = append(a.References, name)
+	}
+	targetFramework, err := m.targetFramework()
+	if err != nil {
+		return fmt.Errorf("failed to read target framework: %w", err)
+	}
+	a.TargetFramework = targetFramework
+	return nil
+}
+
+// assemblyName reads the columns shared by the Assembly and AssemblyRef tables. The key blob holds
+// either the full public key, whose token is the reversed last 8 bytes of its SHA-1, or the token.
+func (m *clrMetadata) assemblyName(version []uint32, key, name, culture uint32, fullKey bool) (AssemblyName, error) {
+	var a AssemblyName
+	var err error
+	if a.Name, err = m.string(name); err != nil {
+		return a, err
+	}
+	if a.Culture, err = m.string(culture); err != nil {
+		return a, err
+	}
+	a.Version = AssemblyVersion{uint16(version[0]), uint16(version[1]), uint16(version[2]), uint16(version[3])}
+	publicKey, err := m.blob(key)
+	if err != nil {
+		return a, err
+	}
+	switch {
+	case len(publicKey) == 0:
+	case fullKey:
+		sum := sha1.Sum(publicKey)
+		token := sum[len(sum)-8:]
+		slices.Reverse(token)
+		a.PublicKeyToken = hex.EncodeToString(token)
+	default:
+		a.PublicKeyToken = hex.EncodeToString(publicKey)
+	}
+	return a, nil
+}
+
+// targetFramework finds the System.Runtime.Versioning.TargetFrameworkAttribute of the assembly
+// and returns its constructor argument.
+func (m *clrMetadata) targetFramework() (string, error) {
+	for i := range m.rows(clrTableCustomAttribute) {
+		row, err := m.row(clrTableCustomAttribute, i+1)
+		if err != nil {
+			return "", err
+		}
+		if parent, _ := decodeCodedIndex(row[0], clrHasCustomAttribute); parent != clrTableAssembly {
+			continue
+		}
+		// The attribute type is imported, its constructor is a MemberRef of a TypeRef.
+		ctorTable, ctor := decodeCodedIndex(row[1], clrCustomAttributeType)
+		if ctorTable != clrTableMemberRef {
+			continue
+		}
+		memberRef, err := m.row(clrTableMemberRef, ctor)
+		if err != nil {
+			return "", err
+		}
+		typeTable, typeRow := decodeCodedIndex(memberRef[0], clrMemberRefParent)
+		if typeTable != clrTableTypeRef {
+			continue
+		}
+		typeRef, err := m.row(clrTableTypeRef, typeRow)
+		if err != nil {
+			return "", err
+		}
+		name, err := m.string(typeRef[1])
+		if err != nil {
+			return "", err
+		}
+		namespace, err := m.string(typeRef[2])
+		if err != nil {
+			return "", err
+		}
+		if name != "TargetFrameworkAttribute" || namespace != "System.Runtime.Versioning" {
+			continue
+		}
+		value, err := m.blob(row[2])
+		if err != nil {
+			return "", err
+		}
+		return parseCustomAttributeString(value)
+	}
+	return "", nil
+}
+
+// parseCustomAttributeString returns the first fixed argument of a custom attribute blob
+// (ECMA-335 II.23.3), which must be a string: the 0x0001 prolog and a SerString.
+func parseCustomAttributeString(value []byte) (string, error) {
+	if len(value) < 3 || binary.LittleEndian.Uint16(value) != 1 {
+		return "", errors.New("custom attribute has no prolog")
+	}
+	if value[2] == 0xff {
+		// null string
+		return "", nil
+	}
+	length, n, err := decodeCompressedUint(value[2:])
+	if err != nil {
+		return "", err
+	}
+	start := 2 + n
+	if uint64(start)+uint64(length) > uint64(len(value)) {
+		return "", errors.New("custom attribute string is truncated")
+	}
+	return string(value[start : start+int(length)]), nil
+}
@@ -0,0 +1,187 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ecmaPublicKey is the ECMA standard public key of the framework assemblies, its token is b77a5c561934e089.
+var ecmaPublicKey = []byte{0, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0}
+
+// testDotNetMetadata builds the metadata of TestService 1.2.3.4, signed with the ECMA key, targeting
+// .NET 8 and referencing System.Runtime and a satellite assembly. A DebuggableAttribute precedes the
+// TargetFrameworkAttribute.
+func testDotNetMetadata(heapSizes byte) []byte {
+	h := newTestCLRHeaps()
+	ctor := h.string(".ctor")
+	signature := h.blob([]byte{0x20, 1, 1, 0x0e})
+	debuggable := h.blob([]byte{1, 0, 2, 0, 0, 0, 0, 0})
+	targetFramework := h.blob(append([]byte{1, 0, 24}, ".NETCoreApp,Version=v8.0\x00\x00"...))
+	tables := map[int][][]uint32{
+		clrTableTypeRef: {
+			{1<<2 | 2, h.string("DebuggableAttribute"), h.string("System.Diagnostics")},
+			{1<<2 | 2, h.string("TargetFrameworkAttribute"), h.string("System.Runtime.Versioning")},
+		},
+		clrTableMemberRef: {
+			{1<<3 | 1, ctor, signature},
+			{2<<3 | 1, ctor, signature},
+		},
+		clrTableCustomAttribute: {
+			{1<<5 | 14, 1<<3 | 3, debuggable},
+			{1<<5 | 14, 2<<3 | 3, targetFramework},
+		},
+		clrTableAssembly: {
+			{0x8004, 1, 2, 3, 4, assemblyFlagPublicKey, h.blob(ecmaPublicKey), h.string("TestService"), 0},
+		},
+		clrTableAssemblyRef: {
+			{8, 0, 0, 0, 0, h.blob([]byte{0xb0, 0x3f, 0x5f, 0x7f, 0x11, 0xd5, 0x0a, 0x3a}), h.string("System.Runtime"), 0, 0},
+			{1, 0, 0, 0, 0, 0, h.string("TestService.resources"), h.string("de-DE"), 0},
+		},
+	}
+	return testCLRMetadata(heapSizes, tables, h)
+}
+
+// testDotNetImage builds an image with a CLR header and the given metadata. readyToRun adds a
+// READYTORUN_HEADER.
+func testDotNetImage(t *testing.T, pe32 bool, flags uint32, metadata []byte, readyToRun bool) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.pe32 = pe32
+	rva := b.nextRVA()
+	data := make([]byte, 72+16)
+	binary.LittleEndian.PutUint32(data, 72)
+	binary.LittleEndian.PutUint16(data[4:], 2)
+	binary.LittleEndian.PutUint16(data[6:], 5)
+	binary.LittleEndian.PutUint32(data[8:], rva+uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[12:], uint32(len(metadata)))
+	binary.LittleEndian.PutUint32(data[16:], flags)
+	if readyToRun {
+		binary.LittleEndian.PutUint32(data[72:], readyToRunSignature)
+		binary.LittleEndian.PutUint32(data[64:], rva+72)
+		binary.LittleEndian.PutUint32(data[68:], 16)
+	}
+	data = append(data, metadata...)
+	b.addSection(".text", data, pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryCLR, rva, 72)
+	return b.build(t)
+}
+
+func TestReadDotNetAssembly(t *testing.T) {
+	for _, heapSizes := range []byte{0, 0x07} {
+		image := testDotNetImage(t, false, clrFlagILOnly|clrFlagStrongNameSigned, testDotNetMetadata(heapSizes), false)
+		a, err := ReadDotNetAssembly(bytes.NewReader(image), int64(len(image)))
+		require.NoError(t, err)
+		require.NotNil(t, a)
+
+		assert.Equal(t, "v4.0.30319", a.RuntimeVersion)
+		assert.Equal(t, HeaderVersion{Major: 2, Minor: 5}, a.HeaderVersion)
+		assert.True(t, a.ILOnly)
+		assert.True(t, a.StrongNameSigned)
+		assert.False(t, a.Required32Bit)
+		assert.False(t, a.Preferred32Bit)
+		assert.False(t, a.MixedMode)
+		assert.False(t, a.ReadyToRun)
+
+		require.NotNil(t, a.Assembly)
+		assert.Equal(t, "TestService, Version=1.2.3.4, Culture=neutral, PublicKeyToken=b77a5c561934e089", a.Assembly.String())
+		assert.Equal(t, ".NETCoreApp,Version=v8.0", a.TargetFramework)
+		identifier, version := a.Framework()
+		assert.Equal(t, ".NETCoreApp", identifier)
+		assert.Equal(t, "8.0", version)
+
+		assert.Equal(t, []AssemblyName{
+			{Name: "System.Runtime", Version: AssemblyVersion{Major: 8}, PublicKeyToken: "b03f5f7f11d50a3a"},
+			{Name: "TestService.resources", Version: AssemblyVersion{Major: 1}, Culture: "de-DE"},
+		}, a.References)
+		require.NotNil(t, a.Reference("system.runtime"))
+		assert.Nil(t, a.Reference("System.Private.CoreLib"))
+		assert.Equal(t, "TestService.resources, Version=1.0.0.0, Culture=de-DE, PublicKeyToken=null", a.References[1].String())
+	}
+}
+
+func TestReadDotNetAssemblyFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		pe32       bool
+		flags      uint32
+		readyToRun bool
+		check      func(t *testing.T, a *DotNetAssembly)
+	}{
+		{"32-bit required", true, clrFlagILOnly | clrFlag32BitRequired, false, func(t *testing.T, a *DotNetAssembly) {
+			assert.True(t, a.Required32Bit)
+			assert.False(t, a.Preferred32Bit)
+		}},
+		{"32-bit preferred", true, clrFlagILOnly | clrFlag32BitRequired | clrFlag32BitPreferred, false, func(t *testing.T, a *DotNetAssembly) {
+			assert.True(t, a.Required32Bit)
+			assert.True(t, a.Preferred32Bit)
+		}},
+		{"mixed-mode", false, 0, false, func(t *testing.T, a *DotNetAssembly) {
+			assert.False(t, a.ILOnly)
+			assert.True(t, a.MixedMode)
+		}},
+		{"ReadyToRun", false, clrFlagILOnly, true, func(t *testing.T, a *DotNetAssembly) {
+			assert.True(t, a.ReadyToRun)
+			assert.False(t, a.MixedMode)
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			image := testDotNetImage(t, tt.pe32, tt.flags, testDotNetMetadata(0), tt.readyToRun)
+			a, err := ReadDotNetAssembly(bytes.NewReader(image), int64(len(image)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.flags, a.Flags)
+			tt.check(t, a)
+		})
+	}
+}
+
+func TestReadDotNetAssemblyWithoutManifest(t *testing.T) {
+	h := newTestCLRHeaps()
+	metadata := testCLRMetadata(0, map[int][][]uint32{clrTableModuleRef: {{h.string("kernel32.dll")}}}, h)
+	image := testDotNetImage(t, false, clrFlagILOnly, metadata, false)
+	a, err := ReadDotNetAssembly(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Nil(t, a.Assembly)
+	assert.Empty(t, a.TargetFramework)
+	assert.Empty(t, a.References)
+	identifier, version := a.Framework()
+	assert.Empty(t, identifier)
+	assert.Empty(t, version)
+}
+
+func TestReadDotNetAssemblyNative(t *testing.T) {
+	image := testUnsignedImage(t, false)
+	a, err := ReadDotNetAssembly(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Nil(t, a)
+}
+
+func TestReadDotNetAssemblyInvalidMetadata(t *testing.T) {
+	image := testDotNetImage(t, false, clrFlagILOnly, make([]byte, 64), false)
+	_, err := ReadDotNetAssembly(bytes.NewReader(image), int64(len(image)))
+	require.ErrorContains(t, err, "metadata has no BSJB signature")
+}
+
+func TestParseCustomAttributeString(t *testing.T) {
+	s, err := parseCustomAttributeString([]byte{1, 0, 0xff, 0, 0})
+	require.NoError(t, err)
+	assert.Empty(t, s)
+
+	_, err = parseCustomAttributeString([]byte{0, 0, 1, 'a'})
+	require.ErrorContains(t, err, "custom attribute has no prolog")
+	_, err = parseCustomAttributeString([]byte{1, 0, 10, 'a'})
+	require.ErrorContains(t, err, "custom attribute string is truncated")
+}
+
+func TestWinFileInfoGetDotNetAssembly(t *testing.T) {
+	image := testDotNetImage(t, false, clrFlagILOnly, testDotNetMetadata(0), false)
+	wf, err := NewWinFileInfo(testWriteFile(t, "service.exe", image))
+	require.NoError(t, err)
+	a, err := wf.GetDotNetAssembly()
+	require.NoError(t, err)
+	assert.Equal(t, "TestService", a.Assembly.Name)
+}
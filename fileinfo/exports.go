@@ -0,0 +1,168 @@
+package fileinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// maxExports bounds the export address and name tables of malformed images.
+const maxExports = 0x10000
+
+// Exports is the export directory of an image.
+type Exports struct {
+	// Name is the DLL name recorded by the linker.
+	Name          string
+	TimeDateStamp uint32
+	// Functions are ordered by ordinal. A function exported under several names has an entry per name.
+	Functions []ExportedFunction
+}
+
+// ExportedFunction is an exported function or variable.
+type ExportedFunction struct {
+	// Name is empty for functions exported by ordinal only.
+	Name    string
+	Ordinal uint16
+	// RVA is the address of the function, zero for forwarders.
+	RVA uint32
+	// Forwarder is the "library.function" or "library.#ordinal" the export is forwarded to,
+	// e.g. "NTDLL.RtlAllocateHeap".
+	Forwarder string
+}
+
+// ForwarderLibrary returns the DLL a forwarded export is forwarded to with a .dll extension,
+// empty for exports that are not forwarded.
+func (f ExportedFunction) ForwarderLibrary() string {
+	dot := strings.LastIndexByte(f.Forwarder, '.')
+	if dot <= 0 {
+		return ""
+	}
+	return f.Forwarder[:dot] + ".dll"
+}
+
+// Find returns the function exported under the name, compared case-sensitively as the loader does.
+// It returns nil when there is no such export.
+func (e *Exports) Find(name string) *ExportedFunction {
+	for i := range e.Functions {
+		if e.Functions[i].Name == name {
+			return &e.Functions[i]
+		}
+	}
+	return nil
+}
+
+// Forwarded returns the exports that are forwarded to other DLLs.
+func (e *Exports) Forwarded() []ExportedFunction {
+	var forwarded []ExportedFunction
+	for _, f := range e.Functions {
+		if f.Forwarder != "" {
+			forwarded = append(forwarded, f)
+		}
+	}
+	return forwarded
+}
+
+// ReadExports reads the export directory of the PE image in r, size is the total image size.
+// It returns nil when the image exports nothing.
+func ReadExports(r io.ReaderAt, size int64) (*Exports, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.exports()
+}
+
+func readExportsFile(path string) (*Exports, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.exports()
+}
+
+// exports reads IMAGE_EXPORT_DIRECTORY with its address, name and name ordinal tables.
+// Addresses inside the export directory point at forwarder strings rather than code.
+func (p *peImage) exports() (*Exports, error) {
+	dir, ok := p.dataDirectory(imageDirectoryEntryExport)
+	if !ok || dir.Size == 0 {
+		return nil, nil
+	}
+	d, err := p.readRVA(dir.VirtualAddress, 40)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export directory: %w", err)
+	}
+	nameRVA := binary.LittleEndian.Uint32(d[12:])
+	base := binary.LittleEndian.Uint32(d[16:])
+	numberOfFunctions := binary.LittleEndian.Uint32(d[20:])
+	numberOfNames := binary.LittleEndian.Uint32(d[24:])
+	addressOfFunctions := binary.LittleEndian.Uint32(d[28:])
+	addressOfNames := binary.LittleEndian.Uint32(d[32:])
+	addressOfNameOrdinals := binary.LittleEndian.Uint32(d[36:])
+	if numberOfFunctions > maxExports || numberOfNames > maxExports {
+		return nil, fmt.Errorf("export directory has too many entries: %d functions, %d names", numberOfFunctions, numberOfNames)
+	}
+
+	exports := &Exports{TimeDateStamp: binary.LittleEndian.Uint32(d[4:])}
+	if nameRVA != 0 {
+		if exports.Name, err = p.readStringRVA(nameRVA, maxImportNameLength); err != nil {
+			return nil, fmt.Errorf("failed to read export DLL name: %w", err)
+		}
+	}
+	if numberOfFunctions == 0 {
+		return exports, nil
+	}
+	addresses, err := p.readRVA(addressOfFunctions, numberOfFunctions*4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export address table: %w", err)
+	}
+
+	names := map[uint32][]string{}
+	if numberOfNames > 0 {
+		nameRVAs, err := p.readRVA(addressOfNames, numberOfNames*4)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export name table: %w", err)
+		}
+		ordinals, err := p.readRVA(addressOfNameOrdinals, numberOfNames*2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export ordinal table: %w", err)
+		}
+		for i := range numberOfNames {
+			index := uint32(binary.LittleEndian.Uint16(ordinals[i*2:]))
+			name, err := p.readStringRVA(binary.LittleEndian.Uint32(nameRVAs[i*4:]), maxImportNameLength)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read export name %d: %w", i, err)
+			}
+			names[index] = append(names[index], name)
+		}
+	}
+
+	for i := range numberOfFunctions {
+		rva := binary.LittleEndian.Uint32(addresses[i*4:])
+		if rva == 0 {
+			// unused ordinal
+			continue
+		}
+		f := ExportedFunction{Ordinal: uint16(base + i), RVA: rva}
+		if rva >= dir.VirtualAddress && rva-dir.VirtualAddress < dir.Size {
+			if f.Forwarder, err = p.readStringRVA(rva, maxImportNameLength); err != nil {
+				return nil, fmt.Errorf("failed to read forwarder of ordinal %d: %w", f.Ordinal, err)
+			}
+			f.RVA = 0
+		}
+		if len(names[i]) == 0 {
+			exports.Functions = append(exports.Functions, f)
+			continue
+		}
+		slices.Sort(names[i])
+		for _, name := range names[i] {
+			f.Name = name
+			exports.Functions = append(exports.Functions, f)
+		}
+	}
+	return exports, nil
+}
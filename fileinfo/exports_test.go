@@ -0,0 +1,109 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testImageWithExports builds a DLL exporting ordinals 5 to 8: Open and its alias OpenFile at 5,
+// nothing at 6, the ordinal-only 7 and Alloc forwarded to NTDLL at 8.
+func testImageWithExports(t *testing.T, pe32 bool) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.pe32 = pe32
+	b.characteristics |= pe.IMAGE_FILE_DLL
+	b.addSection(".text", make([]byte, 0x40), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	text := uint32(0x1000)
+	rva := b.nextRVA()
+
+	// directory, 4 addresses, 3 name pointers, 3 name ordinals, then the strings
+	const addresses, names, ordinals, stringsStart = 40, 56, 68, 76
+	data := make([]byte, stringsStart)
+	addString := func(s string) uint32 {
+		offset := uint32(len(data))
+		data = append(data, s+"\x00"...)
+		return rva + offset
+	}
+	dllName, forwarder := addString("test.dll"), addString("NTDLL.RtlAllocateHeap")
+	binary.LittleEndian.PutUint32(data[4:], 0x5f5e0ff)
+	binary.LittleEndian.PutUint32(data[12:], dllName)
+	binary.LittleEndian.PutUint32(data[16:], 5)
+	binary.LittleEndian.PutUint32(data[20:], 4)
+	binary.LittleEndian.PutUint32(data[24:], 3)
+	binary.LittleEndian.PutUint32(data[28:], rva+addresses)
+	binary.LittleEndian.PutUint32(data[32:], rva+names)
+	binary.LittleEndian.PutUint32(data[36:], rva+ordinals)
+
+	binary.LittleEndian.PutUint32(data[addresses:], text+0x10)
+	binary.LittleEndian.PutUint32(data[addresses+8:], text+0x20)
+	binary.LittleEndian.PutUint32(data[addresses+12:], forwarder)
+	// The name table is sorted, the name ordinals are indexes into the address table.
+	for i, export := range []struct {
+		name  string
+		index uint16
+	}{{"Alloc", 3}, {"Open", 0}, {"OpenFile", 0}} {
+		name := addString(export.name)
+		binary.LittleEndian.PutUint32(data[names+i*4:], name)
+		binary.LittleEndian.PutUint16(data[ordinals+i*2:], export.index)
+	}
+
+	b.addSection(".edata", data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryExport, rva, uint32(len(data)))
+	return b.build(t)
+}
+
+func TestReadExports(t *testing.T) {
+	for _, pe32 := range []bool{false, true} {
+		image := testImageWithExports(t, pe32)
+		exports, err := ReadExports(bytes.NewReader(image), int64(len(image)))
+		require.NoError(t, err)
+
+		assert.Equal(t, "test.dll", exports.Name)
+		assert.Equal(t, uint32(0x5f5e0ff), exports.TimeDateStamp)
+		assert.Equal(t, []ExportedFunction{
+			{Name: "Open", Ordinal: 5, RVA: 0x1010},
+			{Name: "OpenFile", Ordinal: 5, RVA: 0x1010},
+			{Ordinal: 7, RVA: 0x1020},
+			{Name: "Alloc", Ordinal: 8, Forwarder: "NTDLL.RtlAllocateHeap"},
+		}, exports.Functions)
+
+		require.NotNil(t, exports.Find("OpenFile"))
+		assert.Nil(t, exports.Find("openfile"))
+		forwarded := exports.Forwarded()
+		require.Len(t, forwarded, 1)
+		assert.Equal(t, "NTDLL.dll", forwarded[0].ForwarderLibrary())
+		assert.Empty(t, exports.Functions[0].ForwarderLibrary())
+	}
+}
+
+func TestReadExportsNone(t *testing.T) {
+	image := testUnsignedImage(t, false)
+	exports, err := ReadExports(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Nil(t, exports)
+}
+
+func TestReadExportsTooManyEntries(t *testing.T) {
+	b := newTestPE()
+	data := make([]byte, 40)
+	binary.LittleEndian.PutUint32(data[20:], maxExports+1)
+	rva := b.addSection(".edata", data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryExport, rva, 40)
+	image := b.build(t)
+
+	_, err := ReadExports(bytes.NewReader(image), int64(len(image)))
+	require.ErrorContains(t, err, "export directory has too many entries")
+}
+
+func TestWinFileInfoExports(t *testing.T) {
+	wf, err := NewWinFileInfo(testWriteFile(t, "test.dll", testImageWithExports(t, false)))
+	require.NoError(t, err)
+	exports, err := wf.Exports()
+	require.NoError(t, err)
+	assert.Len(t, exports.Functions, 4)
+}
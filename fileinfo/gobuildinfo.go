@@ -0,0 +1,184 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/pe"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"time"
+)
+
+// goBuildInfoMagic starts the build info blob the Go linker writes at the start of the data section.
+var goBuildInfoMagic = []byte("\xff Go buildinf:")
+
+const (
+	// goBuildInfoAlign is the alignment of the build info blob.
+	goBuildInfoAlign = 16
+	// goBuildInfoSearchSize is how much of the data section is searched for the blob.
+	goBuildInfoSearchSize = 64 * 1024
+)
+
+// GoModule is a module the binary was built from.
+type GoModule struct {
+	Path    string
+	Version string
+	// Sum is the go.sum checksum, e.g. "h1:...". It is empty for the main module and replaced modules.
+	Sum string
+	// Replace is the module the go.mod replace directive substituted, nil when not replaced.
+	Replace *GoModule
+}
+
+// GoBuildSetting is a key-value build setting, e.g. "vcs.revision" or "-ldflags".
+type GoBuildSetting struct {
+	Key   string
+	Value string
+}
+
+// GoBuildInfo is the build information the Go toolchain embeds in binaries since Go 1.18.
+type GoBuildInfo struct {
+	// GoVersion is the toolchain that built the binary, e.g. "go1.22.3".
+	GoVersion string
+	// Path is the import path of the main package.
+	Path string
+	// Main is the main module, its version is "(devel)" for builds outside of a module proxy.
+	Main GoModule
+	Deps []GoModule
+	// Settings are all build settings in the order the toolchain recorded them.
+	Settings []GoBuildSetting
+
+	// VCS is the version control system of the main module, e.g. "git".
+	VCS string
+	// VCSRevision is the commit the binary was built from, empty when built without VCS stamping.
+	VCSRevision string
+	// VCSTime is the commit time of VCSRevision.
+	VCSTime time.Time
+	// VCSModified reports whether the working tree had uncommitted changes.
+	VCSModified bool
+	GOOS        string
+	GOARCH      string
+	CGOEnabled  bool
+}
+
+// Setting returns the value of the build setting with the given key, empty when it is not set.
+func (b *GoBuildInfo) Setting(key string) string {
+	for _, s := range b.Settings {
+		if s.Key == key {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// Dependency returns the dependency with the given module path, nil when the binary does not use it.
+func (b *GoBuildInfo) Dependency(path string) *GoModule {
+	for i := range b.Deps {
+		if b.Deps[i].Path == path {
+			return &b.Deps[i]
+		}
+	}
+	return nil
+}
+
+// ReadGoBuildInfo reads the Go build information of the PE image in r, size is the total image size.
+// It works on any OS. It returns nil for images not built by the Go toolchain.
+func ReadGoBuildInfo(r io.ReaderAt, size int64) (*GoBuildInfo, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.goBuildInfo()
+}
+
+func readGoBuildInfoFile(path string) (*GoBuildInfo, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.goBuildInfo()
+}
+
+// goBuildInfo looks for the build info magic to tell Go binaries from others before debug/buildinfo
+// decodes the blob, as debug/buildinfo does not export its "not a Go executable" error.
+func (p *peImage) goBuildInfo() (*GoBuildInfo, error) {
+	found, err := p.hasGoBuildInfo()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	info, err := buildinfo.Read(p.r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Go build info: %w", err)
+	}
+	return newGoBuildInfo(info), nil
+}
+
+// hasGoBuildInfo searches the start of the first writable data section, where the Go linker
+// places the build info, for the 16-byte aligned magic.
+func (p *peImage) hasGoBuildInfo() (bool, error) {
+	const dataSection = pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ | pe.IMAGE_SCN_MEM_WRITE
+	const align32Bytes = 0x00600000
+	for _, s := range p.file.Sections {
+		if s.VirtualAddress == 0 || s.Size == 0 || s.Characteristics&^align32Bytes != dataSection {
+			continue
+		}
+		data := make([]byte, min(s.Size, s.VirtualSize, goBuildInfoSearchSize))
+		if _, err := s.ReadAt(data, 0); err != nil {
+			return false, fmt.Errorf("failed to read section %s: %w", s.Name, err)
+		}
+		for pos := 0; pos+len(goBuildInfoMagic) <= len(data); pos += goBuildInfoAlign {
+			if bytes.HasPrefix(data[pos:], goBuildInfoMagic) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, nil
+}
+
+func newGoBuildInfo(info *debug.BuildInfo) *GoBuildInfo {
+	b := &GoBuildInfo{
+		GoVersion: info.GoVersion,
+		Path:      info.Path,
+		Main:      newGoModule(&info.Main),
+	}
+	for _, dep := range info.Deps {
+		b.Deps = append(b.Deps, newGoModule(dep))
+	}
+	for _, s := range info.Settings {
+		b.Settings = append(b.Settings, GoBuildSetting{Key: s.Key, Value: s.Value})
+		switch s.Key {
+		case "vcs":
+			b.VCS = s.Value
+		case "vcs.revision":
+			b.VCSRevision = s.Value
+		case "vcs.time":
+			// RFC 3339 as written by the go command, left zero when malformed.
+			b.VCSTime, _ = time.Parse(time.RFC3339, s.Value)
+		case "vcs.modified":
+			b.VCSModified = s.Value == "true"
+		case "GOOS":
+			b.GOOS = s.Value
+		case "GOARCH":
+			b.GOARCH = s.Value
+		case "CGO_ENABLED":
+			b.CGOEnabled = s.Value == "1"
+		}
+	}
+	return b
+}
+
+func newGoModule(m *debug.Module) GoModule {
+	module := GoModule{Path: m.Path, Version: m.Version, Sum: m.Sum}
+	if m.Replace != nil {
+		replace := newGoModule(m.Replace)
+		module.Replace = &replace
+	}
+	return module
+}
@@ -0,0 +1,125 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGoBuildInfo is the build info of a service built from a git checkout.
+var testGoBuildInfo = debug.BuildInfo{
+	GoVersion: "go1.22.3",
+	Path:      "example.com/tools/cmd/agent",
+	Main:      debug.Module{Path: "example.com/tools", Version: "v1.4.0"},
+	Deps: []*debug.Module{
+		{Path: "github.com/stretchr/testify", Version: "v1.9.0", Sum: "h1:HtqpIVDClZ4nwg75+f6Lvsy/wHu+3BoSGCbBAcpTsTg="},
+		{Path: "golang.org/x/sys", Version: "v0.20.0", Replace: &debug.Module{Path: "../sys", Version: "(devel)"}},
+	},
+	Settings: []debug.BuildSetting{
+		{Key: "-ldflags", Value: "-s -w"},
+		{Key: "CGO_ENABLED", Value: "0"},
+		{Key: "GOARCH", Value: "amd64"},
+		{Key: "GOOS", Value: "windows"},
+		{Key: "vcs", Value: "git"},
+		{Key: "vcs.revision", Value: "0123456789abcdef0123456789abcdef01234567"},
+		{Key: "vcs.time", Value: "2024-05-06T07:08:09Z"},
+		{Key: "vcs.modified", Value: "true"},
+	},
+}
+
+// testGoImage builds an image with the build info blob in the Go 1.18+ inline format near the start
+// of .data, after 32 bytes of other data.
+func testGoImage(t *testing.T, pe32 bool) []byte {
+	t.Helper()
+	// The go command wraps the module info in 16 byte sentinels.
+	modinfo := "0w\xaf\x0c\x92t\b\x02A\xe1\xc1\a\xe6\xd6\x18\xe6" + testGoBuildInfo.String() + "\xf92C1\x86\x18 r\x00\x82B\x10A\x16\xd8\xf2"
+	data := make([]byte, 32)
+	blob := append([]byte{}, goBuildInfoMagic...)
+	blob = append(blob, 8, 2)
+	blob = append(blob, make([]byte, 16)...)
+	blob = binary.AppendUvarint(blob, uint64(len(testGoBuildInfo.GoVersion)))
+	blob = append(blob, testGoBuildInfo.GoVersion...)
+	blob = binary.AppendUvarint(blob, uint64(len(modinfo)))
+	blob = append(blob, modinfo...)
+	data = append(data, blob...)
+
+	b := newTestPE()
+	b.pe32 = pe32
+	b.addSection(".text", make([]byte, 0x40), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	b.addSection(".rdata", make([]byte, 0x40), pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.addSection(".data", data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ|pe.IMAGE_SCN_MEM_WRITE)
+	return b.build(t)
+}
+
+func TestReadGoBuildInfo(t *testing.T) {
+	for _, pe32 := range []bool{false, true} {
+		image := testGoImage(t, pe32)
+		info, err := ReadGoBuildInfo(bytes.NewReader(image), int64(len(image)))
+		require.NoError(t, err)
+		require.NotNil(t, info)
+
+		assert.Equal(t, "go1.22.3", info.GoVersion)
+		assert.Equal(t, "example.com/tools/cmd/agent", info.Path)
+		assert.Equal(t, GoModule{Path: "example.com/tools", Version: "v1.4.0"}, info.Main)
+		assert.Equal(t, []GoModule{
+			{Path: "github.com/stretchr/testify", Version: "v1.9.0", Sum: "h1:HtqpIVDClZ4nwg75+f6Lvsy/wHu+3BoSGCbBAcpTsTg="},
+			{Path: "golang.org/x/sys", Version: "v0.20.0", Replace: &GoModule{Path: "../sys", Version: "(devel)"}},
+		}, info.Deps)
+		require.NotNil(t, info.Dependency("golang.org/x/sys"))
+		assert.Nil(t, info.Dependency("golang.org/x/net"))
+
+		assert.Len(t, info.Settings, 8)
+		assert.Equal(t, "-s -w", info.Setting("-ldflags"))
+		assert.Empty(t, info.Setting("-tags"))
+		assert.Equal(t, "git", info.VCS)
+		assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", info.VCSRevision)
+		assert.Equal(t, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), info.VCSTime)
+		assert.True(t, info.VCSModified)
+		assert.Equal(t, "windows", info.GOOS)
+		assert.Equal(t, "amd64", info.GOARCH)
+		assert.False(t, info.CGOEnabled)
+	}
+}
+
+func TestReadGoBuildInfoNotGo(t *testing.T) {
+	image := testUnsignedImage(t, false)
+	info, err := ReadGoBuildInfo(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Nil(t, info)
+
+	// A writable data section without the magic.
+	b := newTestPE()
+	b.addSection(".data", make([]byte, 0x100), pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ|pe.IMAGE_SCN_MEM_WRITE)
+	image = b.build(t)
+	info, err = ReadGoBuildInfo(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Nil(t, info)
+}
+
+func TestReadGoBuildInfoTruncated(t *testing.T) {
+	data := make([]byte, 64)
+	copy(data, goBuildInfoMagic)
+	data[14], data[15] = 8, 2
+	// The version string claims 1024 bytes, more than the section holds.
+	data[32], data[33] = 0x80, 0x08
+	b := newTestPE()
+	b.addSection(".data", data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ|pe.IMAGE_SCN_MEM_WRITE)
+	image := b.build(t)
+
+	_, err := ReadGoBuildInfo(bytes.NewReader(image), int64(len(image)))
+	require.ErrorContains(t, err, "failed to decode Go build info")
+}
+
+func TestWinFileInfoGetGoBuildInfo(t *testing.T) {
+	wf, err := NewWinFileInfo(testWriteFile(t, "agent.exe", testGoImage(t, false)))
+	require.NoError(t, err)
+	info, err := wf.GetGoBuildInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/tools", info.Main.Path)
+}
@@ -0,0 +1,367 @@
+package fileinfo
+
+import (
+	"debug/pe"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Machine is the target machine of an image (IMAGE_FILE_MACHINE_*).
+type Machine uint16
+
+const (
+	MachineUnknown Machine = 0x0000
+	MachineI386    Machine = 0x014c
+	MachineARM     Machine = 0x01c0
+	MachineARMNT   Machine = 0x01c4
+	MachineIA64    Machine = 0x0200
+	MachineCHPEX86 Machine = 0x3a64
+	MachineARM64EC Machine = 0xa641
+	MachineARM64X  Machine = 0xa64e
+	MachineAMD64   Machine = 0x8664
+	MachineARM64   Machine = 0xaa64
+)
+
+var machineNames = map[Machine]string{
+	MachineUnknown: "unknown",
+	MachineI386:    "x86",
+	MachineARM:     "ARM",
+	MachineARMNT:   "ARM Thumb-2",
+	MachineIA64:    "IA64",
+	MachineCHPEX86: "CHPE x86",
+	MachineARM64EC: "ARM64EC",
+	MachineARM64X:  "ARM64X",
+	MachineAMD64:   "x64",
+	MachineARM64:   "ARM64",
+}
+
+func (m Machine) String() string {
+	if name, ok := machineNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", uint16(m))
+}
+
+// Subsystem is the subsystem required to run the image (IMAGE_SUBSYSTEM_*).
+type Subsystem uint16
+
+var subsystemNames = map[Subsystem]string{
+	pe.IMAGE_SUBSYSTEM_UNKNOWN:                  "unknown",
+	pe.IMAGE_SUBSYSTEM_NATIVE:                   "native",
+	pe.IMAGE_SUBSYSTEM_WINDOWS_GUI:              "Windows GUI",
+	pe.IMAGE_SUBSYSTEM_WINDOWS_CUI:              "Windows console",
+	pe.IMAGE_SUBSYSTEM_OS2_CUI:                  "OS/2 console",
+	pe.IMAGE_SUBSYSTEM_POSIX_CUI:                "POSIX console",
+	pe.IMAGE_SUBSYSTEM_NATIVE_WINDOWS:           "native Win9x driver",
+	pe.IMAGE_SUBSYSTEM_WINDOWS_CE_GUI:           "Windows CE GUI",
+	pe.IMAGE_SUBSYSTEM_EFI_APPLICATION:          "EFI application",
+	pe.IMAGE_SUBSYSTEM_EFI_BOOT_SERVICE_DRIVER:  "EFI boot service driver",
+	pe.IMAGE_SUBSYSTEM_EFI_RUNTIME_DRIVER:       "EFI runtime driver",
+	pe.IMAGE_SUBSYSTEM_EFI_ROM:                  "EFI ROM",
+	pe.IMAGE_SUBSYSTEM_XBOX:                     "Xbox",
+	pe.IMAGE_SUBSYSTEM_WINDOWS_BOOT_APPLICATION: "Windows boot application",
+}
+
+func (s Subsystem) String() string {
+	if name, ok := subsystemNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", uint16(s))
+}
+
+// Characteristics are the COFF file header flags, see the pe.IMAGE_FILE_* constants.
+type Characteristics uint16
+
+var characteristicNames = []struct {
+	flag uint16
+	name string
+}{
+	{pe.IMAGE_FILE_RELOCS_STRIPPED, "RELOCS_STRIPPED"},
+	{pe.IMAGE_FILE_EXECUTABLE_IMAGE, "EXECUTABLE_IMAGE"},
+	{pe.IMAGE_FILE_LINE_NUMS_STRIPPED, "LINE_NUMS_STRIPPED"},
+	{pe.IMAGE_FILE_LOCAL_SYMS_STRIPPED, "LOCAL_SYMS_STRIPPED"},
+	{pe.IMAGE_FILE_AGGRESIVE_WS_TRIM, "AGGRESSIVE_WS_TRIM"},
+	{pe.IMAGE_FILE_LARGE_ADDRESS_AWARE, "LARGE_ADDRESS_AWARE"},
+	{pe.IMAGE_FILE_BYTES_REVERSED_LO, "BYTES_REVERSED_LO"},
+	{pe.IMAGE_FILE_32BIT_MACHINE, "32BIT_MACHINE"},
+	{pe.IMAGE_FILE_DEBUG_STRIPPED, "DEBUG_STRIPPED"},
+	{pe.IMAGE_FILE_REMOVABLE_RUN_FROM_SWAP, "REMOVABLE_RUN_FROM_SWAP"},
+	{pe.IMAGE_FILE_NET_RUN_FROM_SWAP, "NET_RUN_FROM_SWAP"},
+	{pe.IMAGE_FILE_SYSTEM, "SYSTEM"},
+	{pe.IMAGE_FILE_DLL, "DLL"},
+	{pe.IMAGE_FILE_UP_SYSTEM_ONLY, "UP_SYSTEM_ONLY"},
+	{pe.IMAGE_FILE_BYTES_REVERSED_HI, "BYTES_REVERSED_HI"},
+}
+
+// Has reports whether all bits of flag are set.
+func (c Characteristics) Has(flag uint16) bool {
+	return uint16(c)&flag == flag
+}
+
+// Names returns the names of the set flags, e.g. "EXECUTABLE_IMAGE".
+func (c Characteristics) Names() []string {
+	var names []string
+	for _, f := range characteristicNames {
+		if c.Has(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func (c Characteristics) String() string {
+	return strings.Join(c.Names(), "|")
+}
+
+// DllCharacteristics are the optional header DllCharacteristics flags,
+// see the pe.IMAGE_DLLCHARACTERISTICS_* constants.
+type DllCharacteristics uint16
+
+var dllCharacteristicNames = []struct {
+	flag uint16
+	name string
+}{
+	{pe.IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA, "HIGH_ENTROPY_VA"},
+	{pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE, "DYNAMIC_BASE"},
+	{pe.IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY, "FORCE_INTEGRITY"},
+	{pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT, "NX_COMPAT"},
+	{pe.IMAGE_DLLCHARACTERISTICS_NO_ISOLATION, "NO_ISOLATION"},
+	{pe.IMAGE_DLLCHARACTERISTICS_NO_SEH, "NO_SEH"},
+	{pe.IMAGE_DLLCHARACTERISTICS_NO_BIND, "NO_BIND"},
+	{pe.IMAGE_DLLCHARACTERISTICS_APPCONTAINER, "APPCONTAINER"},
+	{pe.IMAGE_DLLCHARACTERISTICS_WDM_DRIVER, "WDM_DRIVER"},
+	{pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF, "GUARD_CF"},
+	{pe.IMAGE_DLLCHARACTERISTICS_TERMINAL_SERVER_AWARE, "TERMINAL_SERVER_AWARE"},
+}
+
+// Has reports whether all bits of flag are set.
+func (c DllCharacteristics) Has(flag uint16) bool {
+	return uint16(c)&flag == flag
+}
+
+// Names returns the names of the set flags, e.g. "NX_COMPAT".
+func (c DllCharacteristics) Names() []string {
+	var names []string
+	for _, f := range dllCharacteristicNames {
+		if c.Has(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func (c DllCharacteristics) String() string {
+	return strings.Join(c.Names(), "|")
+}
+
+// HeaderVersion is a major.minor version of the optional header.
+type HeaderVersion struct {
+	Major uint16
+	Minor uint16
+}
+
+func (v HeaderVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Section is an entry of the section table.
+type Section struct {
+	Name            string
+	VirtualAddress  uint32
+	VirtualSize     uint32
+	RawOffset       uint32
+	RawSize         uint32
+	Characteristics uint32
+}
+
+// Permissions returns the memory protection of the section as "rwx" with "-" for missing rights.
+func (s Section) Permissions() string {
+	perms := []byte("---")
+	if s.Characteristics&pe.IMAGE_SCN_MEM_READ != 0 {
+		perms[0] = 'r'
+	}
+	if s.Characteristics&pe.IMAGE_SCN_MEM_WRITE != 0 {
+		perms[1] = 'w'
+	}
+	if s.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0 {
+		perms[2] = 'x'
+	}
+	return string(perms)
+}
+
+// Headers summarizes the COFF and optional headers of a PE image.
+type Headers struct {
+	// Machine is the machine type of the COFF header.
+	Machine Machine
+	// Architecture is the architecture of the code. It differs from Machine for hybrid images,
+	// which have CHPE metadata in the load configuration: x64 images are ARM64EC, ARM64 images
+	// are ARM64X and x86 images are CHPE x86.
+	Architecture Machine
+	// PE32Plus reports whether the image has a PE32+ (64-bit) optional header.
+	PE32Plus           bool
+	Subsystem          Subsystem
+	Characteristics    Characteristics
+	DllCharacteristics DllCharacteristics
+
+	// TimeDateStamp is the raw COFF time stamp.
+	TimeDateStamp uint32
+	// Timestamp is TimeDateStamp as a time, it is meaningless when ReproducibleTimestamp is set.
+	Timestamp time.Time
+	// ReproducibleTimestamp reports whether TimeDateStamp is a hash of the image rather than the link
+	// time, because the image has a REPRO debug entry (/Brepro).
+	ReproducibleTimestamp bool
+	// FutureTimestamp reports whether Timestamp is after the time the headers were read, which
+	// usually means it is a hash as well. Unlike the other fields it depends on the current time.
+	FutureTimestamp bool
+
+	LinkerVersion          HeaderVersion
+	OperatingSystemVersion HeaderVersion
+	ImageVersion           HeaderVersion
+	SubsystemVersion       HeaderVersion
+
+	ImageBase     uint64
+	SizeOfImage   uint32
+	SizeOfHeaders uint32
+	// EntryPoint is the RVA of the entry point, zero for most DLLs without DllMain and for resource-only images.
+	EntryPoint uint32
+	// EntryPointSection is the name of the section containing the entry point.
+	EntryPointSection string
+	Sections          []Section
+
+	// Checksum is the CheckSum stored in the optional header.
+	Checksum uint32
+	// ComputedChecksum is the checksum recomputed over the file the way CheckSumMappedFile does.
+	ComputedChecksum uint32
+}
+
+// ChecksumValid reports whether the stored checksum matches the file. Only drivers and some
+// system DLLs are required to have a valid checksum, most executables store zero.
+func (h *Headers) ChecksumValid() bool {
+	return h.Checksum == h.ComputedChecksum
+}
+
+// ReadHeaders reads the header summary of the PE image in r, size is the total image size.
+func ReadHeaders(r io.ReaderAt, size int64) (*Headers, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.headers()
+}
+
+func readHeadersFile(path string) (*Headers, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.headers()
+}
+
+func (p *peImage) headers() (*Headers, error) {
+	fh := p.file.FileHeader
+	h := &Headers{
+		Machine:         Machine(fh.Machine),
+		Characteristics: Characteristics(fh.Characteristics),
+		TimeDateStamp:   fh.TimeDateStamp,
+		Timestamp:       time.Unix(int64(fh.TimeDateStamp), 0).UTC(),
+		PE32Plus:        p.is64(),
+		ImageBase:       p.imageBase(),
+	}
+	switch oh := p.file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		h.Subsystem = Subsystem(oh.Subsystem)
+		h.DllCharacteristics = DllCharacteristics(oh.DllCharacteristics)
+		h.LinkerVersion = HeaderVersion{uint16(oh.MajorLinkerVersion), uint16(oh.MinorLinkerVersion)}
+		h.OperatingSystemVersion = HeaderVersion{oh.MajorOperatingSystemVersion, oh.MinorOperatingSystemVersion}
+		h.ImageVersion = HeaderVersion{oh.MajorImageVersion, oh.MinorImageVersion}
+		h.SubsystemVersion = HeaderVersion{oh.MajorSubsystemVersion, oh.MinorSubsystemVersion}
+		h.SizeOfImage, h.SizeOfHeaders, h.EntryPoint, h.Checksum = oh.SizeOfImage, oh.SizeOfHeaders, oh.AddressOfEntryPoint, oh.CheckSum
+	case *pe.OptionalHeader64:
+		h.Subsystem = Subsystem(oh.Subsystem)
+		h.DllCharacteristics = DllCharacteristics(oh.DllCharacteristics)
+		h.LinkerVersion = HeaderVersion{uint16(oh.MajorLinkerVersion), uint16(oh.MinorLinkerVersion)}
+		h.OperatingSystemVersion = HeaderVersion{oh.MajorOperatingSystemVersion, oh.MinorOperatingSystemVersion}
+		h.ImageVersion = HeaderVersion{oh.MajorImageVersion, oh.MinorImageVersion}
+		h.SubsystemVersion = HeaderVersion{oh.MajorSubsystemVersion, oh.MinorSubsystemVersion}
+		h.SizeOfImage, h.SizeOfHeaders, h.EntryPoint, h.Checksum = oh.SizeOfImage, oh.SizeOfHeaders, oh.AddressOfEntryPoint, oh.CheckSum
+	}
+
+	for _, s := range p.file.Sections {
+		section := Section{
+			Name:            s.Name,
+			VirtualAddress:  s.VirtualAddress,
+			VirtualSize:     s.VirtualSize,
+			RawOffset:       s.Offset,
+			RawSize:         s.Size,
+			Characteristics: s.Characteristics,
+		}
+		h.Sections = append(h.Sections, section)
+		if h.EntryPoint != 0 && h.EntryPoint >= s.VirtualAddress && h.EntryPoint-s.VirtualAddress < max(s.VirtualSize, s.Size) {
+			h.EntryPointSection = s.Name
+		}
+	}
+
+	// The load config and debug directories only refine the summary, when they fail to parse the
+	// architecture is the machine and the time stamp is not known to be reproducible.
+	h.Architecture = h.Machine
+	if config, err := p.loadConfig(); err == nil && config != nil && config.chpeMetadata != 0 {
+		switch h.Machine {
+		case MachineAMD64:
+			h.Architecture = MachineARM64EC
+		case MachineARM64:
+			h.Architecture = MachineARM64X
+		case MachineI386:
+			h.Architecture = MachineCHPEX86
+		}
+	}
+
+	if debug, err := p.debugDirectory(); err == nil {
+		for _, entry := range debug {
+			if entry.Type == DebugTypeRepro {
+				h.ReproducibleTimestamp = true
+			}
+		}
+	}
+	h.FutureTimestamp = h.Timestamp.After(time.Now())
+
+	checksum, err := p.computeChecksum()
+	if err != nil {
+		return nil, err
+	}
+	h.ComputedChecksum = checksum
+	return h, nil
+}
+
+// computeChecksum computes the image checksum: the 16-bit one's complement sum of the file with
+// the CheckSum field treated as zero, plus the file size.
+func (p *peImage) computeChecksum() (uint32, error) {
+	checksumOffset := p.checksumOffset()
+	var sum uint64
+	buf := make([]byte, 64*1024)
+	for offset := int64(0); offset < p.size; {
+		n := int(min(int64(len(buf)), p.size-offset))
+		if _, err := p.r.ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to read file: %w", err)
+		}
+		for i := 0; i < n; i += 2 {
+			at := offset + int64(i)
+			if at == checksumOffset || at == checksumOffset+2 {
+				continue
+			}
+			word := uint64(buf[i])
+			if i+1 < n {
+				word |= uint64(buf[i+1]) << 8
+			}
+			sum += word
+			sum = (sum & 0xffff) + (sum >> 16)
+		}
+		offset += int64(n)
+	}
+	sum = (sum & 0xffff) + (sum >> 16)
+	return uint32(sum&0xffff) + uint32(p.size), nil
+}
@@ -0,0 +1,220 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLoadConfig encodes an IMAGE_LOAD_CONFIG_DIRECTORY of the given size with the pointer
+// fields set at their offsets.
+func testLoadConfig(size int, pe32 bool, pointers map[int]uint64) []byte {
+	data := make([]byte, size)
+	binary.LittleEndian.PutUint32(data, uint32(size))
+	for offset, v := range pointers {
+		if pe32 {
+			binary.LittleEndian.PutUint32(data[offset:], uint32(v))
+		} else {
+			binary.LittleEndian.PutUint64(data[offset:], v)
+		}
+	}
+	return data
+}
+
+// testDebugDirectory encodes IMAGE_DEBUG_DIRECTORY entries of the given types without data.
+func testDebugDirectory(types ...DebugType) []byte {
+	var data []byte
+	for _, typ := range types {
+		entry := make([]byte, 28)
+		binary.LittleEndian.PutUint32(entry[12:], uint32(typ))
+		data = append(data, entry...)
+	}
+	return data
+}
+
+// testChecksum computes the PE checksum of image with a straightforward 32-bit sum.
+func testChecksum(image []byte) uint32 {
+	checksum, _ := testPEOffsets(image)
+	data := bytes.Clone(image)
+	binary.LittleEndian.PutUint32(data[checksum:], 0)
+	if len(data)%2 != 0 {
+		data = append(data, 0)
+	}
+	var sum uint32
+	for i := 0; i < len(data); i += 2 {
+		sum += uint32(binary.LittleEndian.Uint16(data[i:]))
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return (sum&0xffff+sum>>16)&0xffff + uint32(len(image))
+}
+
+func TestReadHeaders(t *testing.T) {
+	b := newTestPE()
+	b.timeDateStamp = uint32(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Unix())
+	b.dllCharacteristics = pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE | pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT | pe.IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA
+	text := b.addSection(".text", bytes.Repeat([]byte{0xc3}, 0x300), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	b.addSection(".data", []byte("data"), pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ|pe.IMAGE_SCN_MEM_WRITE)
+	b.entryPoint = text + 0x10
+	image := b.build(t)
+
+	h, err := ReadHeaders(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Equal(t, MachineAMD64, h.Machine)
+	assert.Equal(t, MachineAMD64, h.Architecture)
+	assert.Equal(t, "x64", h.Machine.String())
+	assert.True(t, h.PE32Plus)
+	assert.Equal(t, Subsystem(pe.IMAGE_SUBSYSTEM_WINDOWS_CUI), h.Subsystem)
+	assert.Equal(t, "Windows console", h.Subsystem.String())
+	assert.True(t, h.Characteristics.Has(pe.IMAGE_FILE_EXECUTABLE_IMAGE))
+	assert.False(t, h.Characteristics.Has(pe.IMAGE_FILE_DLL))
+	assert.Equal(t, "EXECUTABLE_IMAGE|LARGE_ADDRESS_AWARE", h.Characteristics.String())
+	assert.Equal(t, []string{"HIGH_ENTROPY_VA", "DYNAMIC_BASE", "NX_COMPAT"}, h.DllCharacteristics.Names())
+
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), h.Timestamp)
+	assert.False(t, h.ReproducibleTimestamp)
+	assert.Equal(t, "14.0", h.LinkerVersion.String())
+	assert.Equal(t, HeaderVersion{Major: 6}, h.OperatingSystemVersion)
+	assert.Equal(t, uint64(0x140000000), h.ImageBase)
+	assert.Equal(t, uint32(0x3000), h.SizeOfImage)
+	assert.Equal(t, text+0x10, h.EntryPoint)
+	assert.Equal(t, ".text", h.EntryPointSection)
+
+	require.Len(t, h.Sections, 2)
+	assert.Equal(t, Section{
+		Name:            ".text",
+		VirtualAddress:  text,
+		VirtualSize:     0x300,
+		RawOffset:       0x200,
+		RawSize:         0x400,
+		Characteristics: pe.IMAGE_SCN_CNT_CODE | pe.IMAGE_SCN_MEM_EXECUTE | pe.IMAGE_SCN_MEM_READ,
+	}, h.Sections[0])
+	assert.Equal(t, "r-x", h.Sections[0].Permissions())
+	assert.Equal(t, "rw-", h.Sections[1].Permissions())
+
+	assert.Equal(t, uint32(0), h.Checksum)
+	assert.Equal(t, testChecksum(image), h.ComputedChecksum)
+	assert.False(t, h.ChecksumValid())
+}
+
+func TestReadHeadersChecksum(t *testing.T) {
+	image := testUnsignedImage(t, true)
+	// An odd file size exercises the trailing byte.
+	image = append(image, 0x7f)
+	checksum, _ := testPEOffsets(image)
+	binary.LittleEndian.PutUint32(image[checksum:], testChecksum(image))
+
+	h, err := ReadHeaders(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.False(t, h.PE32Plus)
+	assert.True(t, h.ChecksumValid())
+	assert.NotZero(t, h.Checksum)
+
+	image[len(image)-1] ^= 0xff
+	h, err = ReadHeaders(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.False(t, h.ChecksumValid())
+}
+
+func TestReadHeadersHybrid(t *testing.T) {
+	tests := map[string]struct {
+		machine uint16
+		pe32    bool
+		want    Machine
+	}{
+		"ARM64EC":  {machine: pe.IMAGE_FILE_MACHINE_AMD64, want: MachineARM64EC},
+		"ARM64X":   {machine: pe.IMAGE_FILE_MACHINE_ARM64, want: MachineARM64X},
+		"CHPE x86": {machine: pe.IMAGE_FILE_MACHINE_I386, pe32: true, want: MachineCHPEX86},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := newTestPE()
+			b.machine, b.pe32 = tt.machine, tt.pe32
+			offsets, size, base := loadConfigOffsets64, 320, uint64(0x140000000)
+			if tt.pe32 {
+				offsets, size, base = loadConfigOffsets32, 192, 0x400000
+			}
+			rva := b.nextRVA()
+			config := testLoadConfig(size, tt.pe32, map[int]uint64{offsets.chpeMetadata: base + uint64(rva) + 0x100})
+			b.addSection(".rdata", config, pe.IMAGE_SCN_MEM_READ)
+			b.setDirectory(imageDirectoryEntryLoadConfig, rva, uint32(size))
+			image := b.build(t)
+
+			h, err := ReadHeaders(bytes.NewReader(image), int64(len(image)))
+			require.NoError(t, err)
+			assert.Equal(t, Machine(tt.machine), h.Machine)
+			assert.Equal(t, tt.want, h.Architecture)
+			assert.Equal(t, name, h.Architecture.String())
+		})
+	}
+
+	// A load config without the CHPE field is not hybrid.
+	b := newTestPE()
+	rva := b.addSection(".rdata", testLoadConfig(148, false, nil), pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryLoadConfig, rva, 148)
+	image := b.build(t)
+	h, err := ReadHeaders(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Equal(t, MachineAMD64, h.Architecture)
+}
+
+func TestReadHeadersMalformedDirectories(t *testing.T) {
+	// A load config Size past the section and a debug directory outside of the sections.
+	config := testLoadConfig(148, false, nil)
+	binary.LittleEndian.PutUint32(config, 0x7fffffff)
+	b := newTestPE()
+	b.addSection(".text", []byte("\xc3"), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	rva := b.addSection(".rdata", config, pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryLoadConfig, rva, 148)
+	b.setDirectory(imageDirectoryEntryDebug, 0x7fff0000, 28)
+	image := b.build(t)
+
+	h, err := ReadHeaders(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Equal(t, MachineAMD64, h.Machine)
+	assert.Equal(t, MachineAMD64, h.Architecture)
+	assert.Len(t, h.Sections, 2)
+	assert.False(t, h.ReproducibleTimestamp)
+}
+
+func TestReadHeadersReproducible(t *testing.T) {
+	b := newTestPE()
+	b.timeDateStamp = 0x9e3779b9
+	debug := testDebugDirectory(2, DebugTypeRepro)
+	rva := b.addSection(".rdata", debug, pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryDebug, rva, uint32(len(debug)))
+	image := b.build(t)
+
+	h, err := ReadHeaders(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.True(t, h.ReproducibleTimestamp)
+	assert.Equal(t, uint32(0x9e3779b9), h.TimeDateStamp)
+
+	// A time stamp in the future is reported separately from the REPRO entry.
+	b = newTestPE()
+	b.timeDateStamp = 0xfe000000
+	image = b.build(t)
+	h, err = ReadHeaders(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.False(t, h.ReproducibleTimestamp)
+	assert.True(t, h.FutureTimestamp)
+}
+
+func TestMachineString(t *testing.T) {
+	assert.Equal(t, "ARM64", MachineARM64.String())
+	assert.Equal(t, "0x1234", Machine(0x1234).String())
+	assert.Equal(t, "unknown (99)", Subsystem(99).String())
+	assert.Equal(t, "", DllCharacteristics(0).String())
+}
+
+func TestWinFileInfoHeaders(t *testing.T) {
+	wf, err := NewWinFileInfo(testWriteFile(t, "app.exe", testUnsignedImage(t, false)))
+	require.NoError(t, err)
+	h, err := wf.Headers()
+	require.NoError(t, err)
+	assert.Equal(t, MachineAMD64, h.Machine)
+}
@@ -0,0 +1,334 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// grpIconDirEntry is the binary layout of GRPICONDIRENTRY, an image of an RT_GROUP_ICON resource.
+// The .ico ICONDIRENTRY has the same fields with a file offset instead of the resource ID.
+type grpIconDirEntry struct {
+	Width      uint8
+	Height     uint8
+	ColorCount uint8
+	Reserved   uint8
+	Planes     uint16
+	BitCount   uint16
+	BytesInRes uint32
+	ID         uint16
+}
+
+// IconImage is one image of an icon, stored either as a PNG or as a DIB (BITMAPINFOHEADER,
+// color bitmap and AND mask) the way .ico files store them.
+type IconImage struct {
+	// ResourceID is the ID of the RT_ICON resource holding the image.
+	ResourceID uint16
+	Width      int
+	Height     int
+	ColorCount uint8
+	Planes     uint16
+	BitCount   uint16
+	// PNG reports whether Data is a PNG file rather than a DIB.
+	PNG  bool
+	Data []byte
+
+	// entry is the directory entry as stored in the group.
+	entry grpIconDirEntry
+}
+
+// Icon is an RT_GROUP_ICON resource with its images.
+type Icon struct {
+	Name     ResourceID
+	Language uint16
+	Images   []*IconImage
+}
+
+// ICO returns the icon as a .ico file.
+func (i *Icon) ICO() []byte {
+	var buf bytes.Buffer
+	// ICONDIR: reserved, type 1 for icons and the image count
+	_ = binary.Write(&buf, binary.LittleEndian, [3]uint16{0, 1, uint16(len(i.Images))})
+	offset := uint32(6 + 16*len(i.Images))
+	for _, img := range i.Images {
+		e := img.entry
+		_ = binary.Write(&buf, binary.LittleEndian, struct {
+			Width, Height, ColorCount, Reserved uint8
+			Planes, BitCount                    uint16
+			BytesInRes, ImageOffset             uint32
+		}{e.Width, e.Height, e.ColorCount, e.Reserved, e.Planes, e.BitCount, uint32(len(img.Data)), offset})
+		offset += uint32(len(img.Data))
+	}
+	for _, img := range i.Images {
+		buf.Write(img.Data)
+	}
+	return buf.Bytes()
+}
+
+// Largest returns the image with the most pixels, preferring the higher color depth.
+// It returns nil for an icon without images.
+func (i *Icon) Largest() *IconImage {
+	var largest *IconImage
+	for _, img := range i.Images {
+		if largest == nil || img.Width*img.Height > largest.Width*largest.Height ||
+			img.Width*img.Height == largest.Width*largest.Height && img.BitCount > largest.BitCount {
+			largest = img
+		}
+	}
+	return largest
+}
+
+// LargestPNG returns the largest image encoded as PNG. PNG images are returned as stored,
+// DIB images are converted.
+func (i *Icon) LargestPNG() ([]byte, error) {
+	img := i.Largest()
+	if img == nil {
+		return nil, errors.New("icon has no images")
+	}
+	return img.EncodePNG()
+}
+
+// EncodePNG returns the image as a PNG file, PNG images are returned as stored.
+func (img *IconImage) EncodePNG() ([]byte, error) {
+	if img.PNG {
+		return img.Data, nil
+	}
+	decoded, err := img.Decode()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, decoded); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes the image, PNG images with image/png and DIB images from their color bitmap
+// and AND mask. DIBs with 1, 4, 8, 24 and 32 bits per pixel are supported.
+func (img *IconImage) Decode() (image.Image, error) {
+	if img.PNG {
+		decoded, err := png.Decode(bytes.NewReader(img.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode PNG icon: %w", err)
+		}
+		return decoded, nil
+	}
+	return decodeIconDIB(img.Data)
+}
+
+// decodeIconDIB converts an icon DIB: a BITMAPINFOHEADER whose height covers both bitmaps,
+// the palette, the bottom-up color bitmap and the 1 bit AND mask, rows padded to 32 bits.
+func decodeIconDIB(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, errors.New("icon bitmap header is truncated")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:]))
+	compression := binary.LittleEndian.Uint32(data[16:])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:]))
+	if headerSize < 40 || headerSize > len(data) {
+		return nil, fmt.Errorf("invalid icon bitmap header size %d", headerSize)
+	}
+	if width <= 0 || height <= 0 || width > 1024 || height > 1024 {
+		return nil, fmt.Errorf("invalid icon bitmap size %dx%d", width, height)
+	}
+	pos := headerSize
+	switch {
+	case compression == 0: // BI_RGB
+	case compression == 3 && bitCount == 32: // BI_BITFIELDS, the masks follow a BITMAPINFOHEADER
+		if headerSize == 40 {
+			pos += 12
+		}
+	default:
+		return nil, fmt.Errorf("unsupported icon bitmap compression %d", compression)
+	}
+
+	var palette []color.NRGBA
+	switch bitCount {
+	case 1, 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+		if pos+colorsUsed*4 > len(data) {
+			return nil, errors.New("icon bitmap palette is truncated")
+		}
+		for i := 0; i < colorsUsed; i++ {
+			q := data[pos+i*4:]
+			palette = append(palette, color.NRGBA{R: q[2], G: q[1], B: q[0], A: 0xff})
+		}
+		pos += colorsUsed * 4
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported icon bitmap depth %d", bitCount)
+	}
+
+	stride := (width*bitCount + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	xor := data[pos:]
+	if len(xor) < stride*height {
+		return nil, errors.New("icon bitmap is truncated")
+	}
+	mask := xor[stride*height:]
+	hasMask := len(mask) >= maskStride*height
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		// Rows are stored bottom-up.
+		row := xor[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 1, 4, 8:
+				bit := x * bitCount
+				index := int(row[bit/8]>>(8-bitCount-bit%8)) & (1<<bitCount - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xff}
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			}
+			out.SetNRGBA(x, y, c)
+		}
+	}
+
+	// The AND mask marks transparent pixels unless a 32 bit image has its own alpha channel.
+	if !hasAlpha {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				transparent := false
+				if hasMask {
+					row := mask[(height-1-y)*maskStride:]
+					transparent = row[x/8]&(0x80>>(x%8)) != 0
+				}
+				a := uint8(0xff)
+				if transparent {
+					a = 0
+				}
+				out.Pix[out.PixOffset(x, y)+3] = a
+			}
+		}
+	}
+	return out, nil
+}
+
+// ReadIcons reads the RT_GROUP_ICON resources of the PE image in r with their RT_ICON images,
+// size is the total image size. The first icon is the one Explorer shows for the file.
+func ReadIcons(r io.ReaderAt, size int64) ([]*Icon, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.icons()
+}
+
+func readIconsFile(path string) ([]*Icon, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.icons()
+}
+
+func (p *peImage) icons() ([]*Icon, error) {
+	resources, err := p.resources()
+	if err != nil {
+		return nil, err
+	}
+	images := resources.OfType(ResourceTypeIcon.ID())
+	var icons []*Icon
+	for _, group := range resources.OfType(ResourceTypeGroupIcon.ID()) {
+		icon, err := p.icon(group, images)
+		if err != nil {
+			return nil, fmt.Errorf("icon %s: %w", group.Name, err)
+		}
+		icons = append(icons, icon)
+	}
+	return icons, nil
+}
+
+// icon reads the GRPICONDIR of the group and the RT_ICON image of every entry,
+// in the group's language if available.
+func (p *peImage) icon(group Resource, images Resources) (*Icon, error) {
+	data, err := p.readResource(group)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 6 {
+		return nil, errors.New("icon group is truncated")
+	}
+	if typ := binary.LittleEndian.Uint16(data[2:]); typ != 1 {
+		return nil, fmt.Errorf("unexpected icon group type %d", typ)
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	if 6+count*14 > len(data) {
+		return nil, errors.New("icon group has more entries than fit in the resource")
+	}
+	icon := &Icon{Name: group.Name, Language: group.Language}
+	for i := 0; i < count; i++ {
+		var entry grpIconDirEntry
+		if err := binary.Read(bytes.NewReader(data[6+i*14:]), binary.LittleEndian, &entry); err != nil {
+			return nil, fmt.Errorf("failed to read icon group entry: %w", err)
+		}
+		candidates := images.Find(ResourceTypeIcon.ID(), ResourceIntID(entry.ID))
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("icon image %d is missing", entry.ID)
+		}
+		resource := candidates[0]
+		for _, c := range candidates {
+			if c.Language == group.Language {
+				resource = c
+				break
+			}
+		}
+		imageData, err := p.readResource(resource)
+		if err != nil {
+			return nil, err
+		}
+		icon.Images = append(icon.Images, newIconImage(entry, imageData))
+	}
+	return icon, nil
+}
+
+func newIconImage(entry grpIconDirEntry, data []byte) *IconImage {
+	img := &IconImage{
+		ResourceID: entry.ID,
+		Width:      int(entry.Width),
+		Height:     int(entry.Height),
+		ColorCount: entry.ColorCount,
+		Planes:     entry.Planes,
+		BitCount:   entry.BitCount,
+		PNG:        bytes.HasPrefix(data, pngSignature),
+		Data:       data,
+		entry:      entry,
+	}
+	// A zero width or height in the directory stands for 256 pixels.
+	if img.Width == 0 {
+		img.Width = 256
+	}
+	if img.Height == 0 {
+		img.Height = 256
+	}
+	// The IHDR chunk of PNG images has the actual size.
+	if img.PNG && len(data) >= 24 {
+		img.Width = int(binary.BigEndian.Uint32(data[16:]))
+		img.Height = int(binary.BigEndian.Uint32(data[20:]))
+	}
+	return img
+}
@@ -0,0 +1,215 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testIconDIB encodes a square icon DIB. 32 bit images store the color as BGRA,
+// other depths use a grayscale palette with the pixel index x%colors.
+// The AND mask makes the top left pixel transparent.
+func testIconDIB(t *testing.T, size, bitCount int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	colors := 0
+	if bitCount <= 8 {
+		colors = 1 << bitCount
+	}
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, struct {
+		Size                     uint32
+		Width, Height            int32
+		Planes, BitCount         uint16
+		Compression, SizeImage   uint32
+		XPelsPerMeter            int32
+		YPelsPerMeter            int32
+		ColorsUsed, ColorsImport uint32
+	}{Size: 40, Width: int32(size), Height: int32(2 * size), Planes: 1, BitCount: uint16(bitCount)}))
+	for i := 0; i < colors; i++ {
+		v := byte(i * 255 / (colors - 1))
+		buf.Write([]byte{v, v, v, 0})
+	}
+	stride := (size*bitCount + 31) / 32 * 4
+	for y := size - 1; y >= 0; y-- {
+		row := make([]byte, stride)
+		for x := 0; x < size; x++ {
+			switch bitCount {
+			case 32:
+				copy(row[x*4:], []byte{byte(x), byte(y), 0x80, 0xff})
+			case 24:
+				copy(row[x*3:], []byte{byte(x), byte(y), 0x80})
+			default:
+				index := x % colors
+				bit := x * bitCount
+				row[bit/8] |= byte(index << (8 - bitCount - bit%8))
+			}
+		}
+		buf.Write(row)
+	}
+	maskStride := (size + 31) / 32 * 4
+	for y := size - 1; y >= 0; y-- {
+		row := make([]byte, maskStride)
+		if y == 0 {
+			row[0] = 0x80
+		}
+		buf.Write(row)
+	}
+	return buf.Bytes()
+}
+
+func testIconPNG(t *testing.T, size int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	img.SetNRGBA(1, 2, color.NRGBA{R: 1, G: 2, B: 3, A: 4})
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// testIconGroup encodes a GRPICONDIR for square images of the given sizes, IDs and depths.
+func testIconGroup(sizes []int, ids []uint16, bitCounts []uint16, lengths []int) []byte {
+	out := binary.LittleEndian.AppendUint16(nil, 0)
+	out = binary.LittleEndian.AppendUint16(out, 1)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(ids)))
+	for i := range ids {
+		out = append(out, byte(sizes[i]), byte(sizes[i]), 0, 0)
+		out = binary.LittleEndian.AppendUint16(out, 1)
+		out = binary.LittleEndian.AppendUint16(out, bitCounts[i])
+		out = binary.LittleEndian.AppendUint32(out, uint32(lengths[i]))
+		out = binary.LittleEndian.AppendUint16(out, ids[i])
+	}
+	return out
+}
+
+func TestReadIcons(t *testing.T) {
+	small := testIconDIB(t, 16, 4)
+	medium := testIconDIB(t, 32, 32)
+	large := testIconPNG(t, 256)
+	group := testIconGroup([]int{16, 32, 0}, []uint16{1, 2, 3}, []uint16{4, 32, 32}, []int{len(small), len(medium), len(large)})
+	exe := testImageWithResources(t, false,
+		testResource{typ: uint16(ResourceTypeIcon), name: uint16(1), lang: 0x409, data: small},
+		testResource{typ: uint16(ResourceTypeIcon), name: uint16(2), lang: 0x409, data: medium},
+		testResource{typ: uint16(ResourceTypeIcon), name: uint16(3), lang: 0x409, data: large},
+		testResource{typ: uint16(ResourceTypeGroupIcon), name: "MAINICON", lang: 0x409, data: group},
+	)
+
+	icons, err := ReadIcons(bytes.NewReader(exe), int64(len(exe)))
+	require.NoError(t, err)
+	require.Len(t, icons, 1)
+	icon := icons[0]
+	assert.Equal(t, ResourceName("MAINICON"), icon.Name)
+	require.Len(t, icon.Images, 3)
+	assert.Equal(t, 16, icon.Images[0].Width)
+	assert.False(t, icon.Images[0].PNG)
+	assert.True(t, icon.Images[2].PNG)
+	assert.Equal(t, 256, icon.Images[2].Height)
+
+	largest := icon.Largest()
+	assert.Equal(t, uint16(3), largest.ResourceID)
+	data, err := icon.LargestPNG()
+	require.NoError(t, err)
+	assert.Equal(t, large, data)
+
+	// The rebuilt .ico has a directory entry for each image followed by the image data.
+	ico := icon.ICO()
+	assert.Equal(t, []byte{0, 0, 1, 0, 3, 0}, ico[:6])
+	offset := binary.LittleEndian.Uint32(ico[6+2*16+12:])
+	assert.Equal(t, uint32(len(large)), binary.LittleEndian.Uint32(ico[6+2*16+8:]))
+	assert.Equal(t, large, ico[offset:offset+uint32(len(large))])
+	assert.Equal(t, byte(0), ico[6+2*16], "256 pixel images are stored with width 0")
+	assert.Equal(t, uint32(6+3*16), binary.LittleEndian.Uint32(ico[6+12:]))
+	assert.Len(t, ico, 6+3*16+len(small)+len(medium)+len(large))
+}
+
+func TestDecodeIconDIB(t *testing.T) {
+	for _, bitCount := range []int{1, 4, 8, 24, 32} {
+		t.Run(fmt.Sprintf("%d bit", bitCount), func(t *testing.T) {
+			img, err := decodeIconDIB(testIconDIB(t, 16, bitCount))
+			require.NoError(t, err)
+			require.Equal(t, image.Rect(0, 0, 16, 16), img.Bounds())
+
+			c := color.NRGBAModel.Convert(img.At(3, 5)).(color.NRGBA)
+			switch bitCount {
+			case 24, 32:
+				assert.Equal(t, color.NRGBA{R: 0x80, G: 5, B: 3, A: 0xff}, c)
+			default:
+				colors := 1 << bitCount
+				v := byte(3 % colors * 255 / (colors - 1))
+				assert.Equal(t, color.NRGBA{R: v, G: v, B: v, A: 0xff}, c)
+			}
+			corner := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA)
+			if bitCount == 32 {
+				assert.Equal(t, uint8(0xff), corner.A, "the alpha channel takes precedence over the mask")
+			} else {
+				assert.Equal(t, uint8(0), corner.A)
+			}
+		})
+	}
+}
+
+func TestIconImageEncodePNG(t *testing.T) {
+	img := newIconImage(grpIconDirEntry{Width: 16, Height: 16, BitCount: 8, ID: 1}, testIconDIB(t, 16, 8))
+	data, err := img.EncodePNG()
+	require.NoError(t, err)
+	decoded, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 16, 16), decoded.Bounds())
+}
+
+func TestDecodeIconDIBInvalid(t *testing.T) {
+	valid := testIconDIB(t, 16, 8)
+	depth16 := bytes.Clone(valid)
+	binary.LittleEndian.PutUint16(depth16[14:], 16)
+	rle := bytes.Clone(valid)
+	binary.LittleEndian.PutUint32(rle[16:], 1)
+
+	for name, data := range map[string][]byte{
+		"truncated header": valid[:20],
+		"truncated bitmap": valid[:40+256*4+10],
+		"16 bit":           depth16,
+		"rle":              rle,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeIconDIB(data)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestReadIconsMissingImage(t *testing.T) {
+	group := testIconGroup([]int{16}, []uint16{9}, []uint16{32}, []int{100})
+	exe := testImageWithResources(t, false,
+		testResource{typ: uint16(ResourceTypeGroupIcon), name: uint16(1), data: group},
+	)
+	_, err := ReadIcons(bytes.NewReader(exe), int64(len(exe)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "icon image 9 is missing")
+}
+
+func TestGetIcons(t *testing.T) {
+	dib := testIconDIB(t, 32, 24)
+	exe := testImageWithResources(t, true,
+		testResource{typ: uint16(ResourceTypeIcon), name: uint16(1), lang: 0x407, data: testIconDIB(t, 32, 8)},
+		testResource{typ: uint16(ResourceTypeIcon), name: uint16(1), lang: 0x409, data: dib},
+		testResource{typ: uint16(ResourceTypeGroupIcon), name: uint16(100), lang: 0x409, data: testIconGroup([]int{32}, []uint16{1}, []uint16{24}, []int{len(dib)})},
+	)
+	wf, err := NewWinFileInfo(testWriteFile(t, "app.exe", exe))
+	require.NoError(t, err)
+	icons, err := wf.GetIcons()
+	require.NoError(t, err)
+	require.Len(t, icons, 1)
+	assert.Equal(t, dib, icons[0].Images[0].Data, "the image in the group's language is used")
+
+	wf, err = NewWinFileInfo(testWriteFile(t, "plain.exe", testUnsignedImage(t, false)))
+	require.NoError(t, err)
+	icons, err = wf.GetIcons()
+	require.NoError(t, err)
+	assert.Empty(t, icons)
+}
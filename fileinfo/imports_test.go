@@ -0,0 +1,222 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto/md5"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testImport is an imported DLL, functions are names or uint16 ordinals.
+type testImport struct {
+	library   string
+	functions []any
+}
+
+// testImportSection lays out the descriptors, name tables, hint/name entries and DLL names of the
+// imports in a section mapped at rva. delay selects ImgDelayDescr instead of IMAGE_IMPORT_DESCRIPTOR.
+func testImportSection(pe32, delay bool, rva uint32, imports ...testImport) []byte {
+	descriptorSize, width := 20, 8
+	if delay {
+		descriptorSize = 32
+	}
+	if pe32 {
+		width = 4
+	}
+	thunks := (len(imports) + 1) * descriptorSize
+	heapStart := thunks
+	for _, imp := range imports {
+		heapStart += (len(imp.functions) + 1) * width
+	}
+	data := make([]byte, heapStart)
+	addString := func(prefix []byte, s string) uint32 {
+		offset := uint32(len(data))
+		data = append(data, prefix...)
+		data = append(data, s...)
+		data = append(data, 0)
+		if len(data)%2 != 0 {
+			data = append(data, 0)
+		}
+		return rva + offset
+	}
+
+	for i, imp := range imports {
+		table := rva + uint32(thunks)
+		name := addString(nil, imp.library)
+		d := data[i*descriptorSize:]
+		if delay {
+			binary.LittleEndian.PutUint32(d, 1) // dlattrRva
+			binary.LittleEndian.PutUint32(d[4:], name)
+			binary.LittleEndian.PutUint32(d[12:], table)
+			binary.LittleEndian.PutUint32(d[16:], table)
+		} else {
+			binary.LittleEndian.PutUint32(d, table)
+			binary.LittleEndian.PutUint32(d[12:], name)
+			binary.LittleEndian.PutUint32(d[16:], table)
+		}
+		for j, f := range imp.functions {
+			var thunk uint64
+			switch f := f.(type) {
+			case uint16:
+				thunk = uint64(f) | 1<<63
+				if pe32 {
+					thunk = uint64(f) | 1<<31
+				}
+			case string:
+				thunk = uint64(addString([]byte{byte(j), 0}, f))
+			}
+			if pe32 {
+				binary.LittleEndian.PutUint32(data[thunks+j*width:], uint32(thunk))
+			} else {
+				binary.LittleEndian.PutUint64(data[thunks+j*width:], thunk)
+			}
+		}
+		thunks += (len(imp.functions) + 1) * width
+	}
+	return data
+}
+
+var testImports = []testImport{
+	{library: "KERNEL32.dll", functions: []any{"CreateFileW", "CloseHandle"}},
+	{library: "WS2_32.dll", functions: []any{uint16(23), uint16(300)}},
+	{library: "vcruntime140.dll", functions: []any{"memcpy"}},
+}
+
+func testImageWithImports(t *testing.T, pe32 bool) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.pe32 = pe32
+	if pe32 {
+		b.machine = pe.IMAGE_FILE_MACHINE_I386
+	}
+	rva := b.nextRVA()
+	idata := testImportSection(pe32, false, rva, testImports...)
+	b.addSection(".idata", idata, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryImport, rva, uint32((len(testImports)+1)*20))
+
+	rva = b.nextRVA()
+	didat := testImportSection(pe32, true, rva, testImport{library: "USER32.dll", functions: []any{"MessageBoxW", uint16(2000)}})
+	b.addSection(".didat", didat, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryDelayImport, rva, 64)
+	return b.build(t)
+}
+
+func TestReadImports(t *testing.T) {
+	for _, pe32 := range []bool{false, true} {
+		image := testImageWithImports(t, pe32)
+		imports, err := ReadImports(bytes.NewReader(image), int64(len(image)))
+		require.NoError(t, err)
+
+		require.Len(t, imports.Libraries, 3)
+		assert.Equal(t, ImportedLibrary{
+			Name:      "KERNEL32.dll",
+			Functions: []ImportedFunction{{Name: "CreateFileW"}, {Name: "CloseHandle", Hint: 1}},
+		}, imports.Libraries[0])
+		assert.Equal(t, []ImportedFunction{{Ordinal: 23}, {Ordinal: 300}}, imports.Libraries[1].Functions)
+		assert.True(t, imports.Libraries[1].Functions[0].ByOrdinal())
+		assert.Equal(t, "#300", imports.Libraries[1].Functions[1].String())
+
+		require.Len(t, imports.DelayLoaded, 1)
+		assert.Equal(t, "USER32.dll", imports.DelayLoaded[0].Name)
+		assert.Equal(t, []ImportedFunction{{Name: "MessageBoxW"}, {Ordinal: 2000}}, imports.DelayLoaded[0].Functions)
+		assert.Empty(t, imports.Bound)
+
+		assert.NotNil(t, imports.Library("kernel32.DLL"))
+		assert.Nil(t, imports.Library("mscoree.dll"))
+		assert.True(t, imports.Has("kernel32.dll", "CreateFileW"))
+		assert.True(t, imports.Has("user32.dll", "MessageBoxW"))
+		assert.True(t, imports.Has("ws2_32.dll", "#23"))
+		assert.False(t, imports.Has("kernel32.dll", "createfilew"))
+	}
+}
+
+func TestImphash(t *testing.T) {
+	image := testImageWithImports(t, false)
+	imports, err := ReadImports(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+
+	sum := md5.Sum([]byte("kernel32.createfilew,kernel32.closehandle,ws2_32.socket,ws2_32.ord300,vcruntime140.memcpy"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), imports.Imphash())
+
+	// The hash does not depend on the image format.
+	image = testImageWithImports(t, true)
+	imports32, err := ReadImports(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Equal(t, imports.Imphash(), imports32.Imphash())
+
+	assert.Empty(t, (&Imports{}).Imphash())
+	assert.Equal(t, "ord7", ordinalName("kernel32.dll", 7))
+	assert.Equal(t, "SysFreeString", ordinalName("OLEAUT32.dll", 6))
+	assert.Equal(t, "WSAStartup", ordinalName("wsock32.dll", 115))
+}
+
+func TestImphashOrdinals(t *testing.T) {
+	b := newTestPE()
+	rva := b.nextRVA()
+	imports := []testImport{
+		{library: "WSOCK32.dll", functions: []any{uint16(60), uint16(98)}},
+		{library: "OLEAUT32.dll", functions: []any{uint16(114), uint16(6)}},
+	}
+	b.addSection(".idata", testImportSection(false, false, rva, imports...), pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryImport, rva, uint32((len(imports)+1)*20))
+	image := b.build(t)
+	i, err := ReadImports(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+
+	// pefile hashes "wsock32.wsaioctl,wsock32.getaddrinfo,oleaut32.varbstrfromdate,oleaut32.sysfreestring".
+	assert.Equal(t, "7d507ab23d4053f0cdef21e9e6f4be1f", i.Imphash())
+}
+
+func TestReadBoundImports(t *testing.T) {
+	// Two descriptors, the first with one forwarder reference, the null entry and the names.
+	dir := make([]byte, 4*8)
+	names := []byte("KERNEL32.dll\x00NTDLL.DLL\x00USER32.dll\x00")
+	put := func(pos int, timeDateStamp uint32, name, count uint16) {
+		binary.LittleEndian.PutUint32(dir[pos:], timeDateStamp)
+		binary.LittleEndian.PutUint16(dir[pos+4:], uint16(len(dir))+name)
+		binary.LittleEndian.PutUint16(dir[pos+6:], count)
+	}
+	put(0, 0x5f000001, 0, 1)
+	put(8, 0x5f000002, 13, 0)
+	put(16, 0x5f000003, 23, 0)
+	dir = append(dir, names...)
+
+	b := newTestPE()
+	rva := b.addSection(".rdata", dir, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryBoundImport, rva, uint32(len(dir)))
+	image := b.build(t)
+
+	imports, err := ReadImports(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Equal(t, []BoundImport{
+		{Name: "KERNEL32.dll", TimeDateStamp: 0x5f000001, Forwarders: []BoundImport{{Name: "NTDLL.DLL", TimeDateStamp: 0x5f000002}}},
+		{Name: "USER32.dll", TimeDateStamp: 0x5f000003},
+	}, imports.Bound)
+	assert.Empty(t, imports.Libraries)
+}
+
+func TestReadImportsMalformed(t *testing.T) {
+	b := newTestPE()
+	descriptors := make([]byte, 40)
+	binary.LittleEndian.PutUint32(descriptors[12:], 0x7fff0000)
+	rva := b.addSection(".idata", descriptors, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryImport, rva, 40)
+	image := b.build(t)
+
+	_, err := ReadImports(bytes.NewReader(image), int64(len(image)))
+	require.ErrorContains(t, err, "failed to read name of imported library 0")
+}
+
+func TestWinFileInfoImports(t *testing.T) {
+	wf, err := NewWinFileInfo(testWriteFile(t, "app.exe", testImageWithImports(t, false)))
+	require.NoError(t, err)
+	imports, err := wf.Imports()
+	require.NoError(t, err)
+	assert.True(t, imports.Has("vcruntime140.dll", "memcpy"))
+	assert.Len(t, imports.Imphash(), 32)
+}
@@ -0,0 +1,480 @@
+package fileinfo
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// InstallerFamily is an installer authoring tool or self-extracting archive format.
+type InstallerFamily string
+
+const (
+	InstallerNSIS              InstallerFamily = "NSIS"
+	InstallerInnoSetup         InstallerFamily = "Inno Setup"
+	InstallerInstallShield     InstallerFamily = "InstallShield"
+	InstallerWiXBurn           InstallerFamily = "WiX Burn"
+	InstallerAdvancedInstaller InstallerFamily = "Advanced Installer"
+	Installer7zSFX             InstallerFamily = "7-Zip SFX"
+	InstallerZIPSFX            InstallerFamily = "ZIP SFX"
+	InstallerCABSFX            InstallerFamily = "CAB SFX"
+)
+
+// Installer is an installer family recognized in an executable.
+type Installer struct {
+	Family     InstallerFamily
+	Confidence Confidence
+	// Version is the version of the installer tool when known, e.g. the NSIS or Inno Setup version or
+	// the Burn engine version.
+	Version string
+	// Evidence describes what the verdict is based on.
+	Evidence []string
+	// SilentSwitches are the command line switches of an unattended installation without UI.
+	SilentSwitches []string
+	// Notes describes related switches, such as for the installation directory or logging.
+	Notes string
+	// Payloads are the names of the files embedded in the installer, when its format is decoded: the
+	// embedded payloads of a Burn bundle and the files of ZIP and CAB self-extractors.
+	Payloads []string
+}
+
+// installerSwitches are the silent switches and notes of each family.
+var installerSwitches = map[InstallerFamily]struct {
+	switches []string
+	notes    string
+}{
+	InstallerNSIS: {
+		[]string{"/S"},
+		"Switches are case-sensitive. /D=<dir> sets the installation directory and must be the last switch, unquoted.",
+	},
+	InstallerInnoSetup: {
+		[]string{"/VERYSILENT", "/SUPPRESSMSGBOXES", "/NORESTART", "/SP-"},
+		"/DIR=<dir> sets the installation directory, /LOG=<file> writes a log.",
+	},
+	InstallerInstallShield: {
+		[]string{"/s", `/v"/qn"`},
+		`/v passes options to the embedded MSI package of Basic MSI projects. InstallScript projects need a response file recorded with /r and replayed with /s /f1"<file>".`,
+	},
+	InstallerWiXBurn: {
+		[]string{"/quiet", "/norestart"},
+		"/log <file> writes a log, /layout <dir> downloads and extracts all payloads.",
+	},
+	InstallerAdvancedInstaller: {
+		[]string{"/exenoui", "/qn"},
+		"/extract <dir> extracts the embedded MSI package, further options are passed to msiexec.",
+	},
+	Installer7zSFX: {
+		[]string{"-y"},
+		"The configuration block of the SFX module may run an installer, which has its own switches.",
+	},
+	InstallerZIPSFX: {
+		nil,
+		"The archive can be extracted with any ZIP tool, silent switches depend on the SFX module.",
+	},
+	InstallerCABSFX: {
+		[]string{"/Q"},
+		"IExpress packages extract to the directory given by /T:<dir> with /C, without running the installer.",
+	},
+}
+
+// Markers searched for in the sections.
+const (
+	// nsisVersionMarker is the description in the manifest of the NSIS stub, followed by the version.
+	nsisVersionMarker = "Nullsoft Install System v"
+	// installShieldMarker is in the strings of the InstallShield setup launcher.
+	installShieldMarker = "InstallShield"
+	// advancedInstallerMarker is in the version information and strings of the Advanced Installer bootstrapper.
+	advancedInstallerMarker = "Advanced Installer"
+	// winZipSFXMarker is in the strings of the WinZip Self-Extractor stub.
+	winZipSFXMarker = "WinZip Self-Extractor"
+)
+
+// Markers searched for in the overlay.
+const (
+	// innoSetupDataMarker starts the setup data of Inno Setup, followed by the version.
+	innoSetupDataMarker = "Inno Setup Setup Data ("
+	// installShieldStreamMarker starts the files of InstallShield 2008 and later setup launchers.
+	installShieldStreamMarker = "ISSetupStream"
+	// advancedInstallerSFXMarker is in the footer of the data the Advanced Installer bootstrapper appends.
+	advancedInstallerSFXMarker = "ADVINSTSFX"
+	// sevenZipConfigMarker starts the configuration block of the 7-Zip SFX modules.
+	sevenZipConfigMarker = ";!@Install@!UTF-8!"
+)
+
+const (
+	// innoSetupLoaderSignature starts the offset table of the Inno Setup loader, which is the RCDATA
+	// resource #11111 since Inno Setup 5.1.5.
+	innoSetupLoaderSignature = "rDlPtS"
+	innoSetupLoaderResource  = 11111
+	// innoSetupLegacySignature is the offset table signature older loaders store at file offset 0x30.
+	innoSetupLegacySignature = "Inno"
+
+	// burnSectionMagic starts the .wixburn section of Burn bundles.
+	burnSectionMagic = 0x00f14300
+	// burnContainerFormatCabinet is the container format of Burn, each container is a cabinet.
+	burnContainerFormatCabinet = 1
+	// burnManifestFile is the name of the bundle manifest in the UX container.
+	burnManifestFile = "0"
+
+	// installerOverlayScanSize is how much of the start of the overlay is searched for markers.
+	installerOverlayScanSize = 16 << 20
+	// installerOverlayTailSize is how much of the end of the overlay is searched for footers.
+	installerOverlayTailSize = 4096
+	// maxCabinetHeaderSize limits the data read for the file list of an embedded cabinet.
+	maxCabinetHeaderSize = 1 << 20
+)
+
+// installerClues are the parts of an image the installer detectors look at.
+type installerClues struct {
+	markers        map[string]int64
+	overlayMarkers map[string]int64
+	resources      Resources
+	overlay        *Overlay
+	overlayStart   int64
+	overlayEnd     int64
+}
+
+// DetectInstallers classifies the installer of the PE image in r, size is the total image size, from
+// its sections, resources and overlay. Each installer comes with the switches of an unattended
+// installation. Installers are sorted by confidence, highest first. It returns nil when nothing was
+// recognized.
+func DetectInstallers(r io.ReaderAt, size int64) ([]Installer, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.installers()
+}
+
+func detectInstallersFile(path string) ([]Installer, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.installers()
+}
+
+func (p *peImage) installers() ([]Installer, error) {
+	var c installerClues
+	var err error
+	if c.markers, err = p.scanSections(nsisVersionMarker, installShieldMarker, advancedInstallerMarker, winZipSFXMarker); err != nil {
+		return nil, err
+	}
+	// A clue that fails to parse is left out, the other clues may still tell the installer. Markers
+	// found before an overlay read fails are kept.
+	c.resources, _ = p.resources()
+	c.overlay, _ = p.overlay()
+	c.overlayStart, c.overlayEnd = p.overlayRange()
+	c.overlayMarkers = map[string]int64{}
+	markers := []string{nsisSignature, innoSetupDataMarker, installShieldStreamMarker, installShieldMarker, advancedInstallerSFXMarker, sevenZipConfigMarker}
+	_ = p.scanRange(c.overlayStart, min(c.overlayEnd, c.overlayStart+installerOverlayScanSize), markers, c.overlayMarkers)
+	_ = p.scanRange(max(c.overlayStart, c.overlayEnd-installerOverlayTailSize), c.overlayEnd, []string{advancedInstallerSFXMarker}, c.overlayMarkers)
+
+	var installers []Installer
+	for _, detect := range []func(*installerClues) (*Installer, error){
+		p.detectNSIS,
+		p.detectInnoSetup,
+		p.detectInstallShield,
+		p.detectWiXBurn,
+		p.detectAdvancedInstaller,
+		p.detect7zSFX,
+		p.detectZIPSFX,
+		p.detectCABSFX,
+	} {
+		installer, err := detect(&c)
+		if err != nil {
+			return nil, err
+		}
+		if installer != nil {
+			s := installerSwitches[installer.Family]
+			installer.SilentSwitches, installer.Notes = s.switches, s.notes
+			installers = append(installers, *installer)
+		}
+	}
+	slices.SortStableFunc(installers, func(a, b Installer) int {
+		return int(b.Confidence) - int(a.Confidence)
+	})
+	return installers, nil
+}
+
+// markerVersion reads the version number following a marker found at the offset, e.g. "3.08" after
+// "Nullsoft Install System v".
+func (p *peImage) markerVersion(offset int64, marker string) (string, error) {
+	start := offset + int64(len(marker))
+	length := min(32, p.size-start)
+	if length <= 0 {
+		return "", nil
+	}
+	data, err := p.readAt(start, length)
+	if err != nil {
+		return "", err
+	}
+	end := 0
+	for end < len(data) && (data[end] == '.' || data[end] >= '0' && data[end] <= '9') {
+		end++
+	}
+	return strings.TrimRight(string(data[:end]), "."), nil
+}
+
+// detectNSIS looks for the first header NSIS appends to its stub: flags, the 0xDEADBEEF signature and
+// "NullsoftInst". The stub reserves an uninitialized .ndata section and names the NSIS version in its
+// manifest.
+func (p *peImage) detectNSIS(c *installerClues) (*Installer, error) {
+	i := &Installer{Family: InstallerNSIS}
+	if offset, ok := c.overlayMarkers[nsisSignature]; ok {
+		i.Confidence = ConfidenceHigh
+		i.Evidence = append(i.Evidence, fmt.Sprintf("NSIS first header at offset 0x%x", offset-4))
+	}
+	if p.hasSection(".ndata") {
+		i.Confidence = max(i.Confidence, ConfidenceMedium)
+		i.Evidence = append(i.Evidence, ".ndata section")
+	}
+	if i.Confidence == 0 {
+		return nil, nil
+	}
+	if offset, ok := c.markers[nsisVersionMarker]; ok {
+		version, err := p.markerVersion(offset, nsisVersionMarker)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NSIS version: %w", err)
+		}
+		i.Version = version
+	}
+	return i, nil
+}
+
+// detectInnoSetup looks for the offset table of the Inno Setup loader, which locates the setup data
+// in the overlay, and for the header of the setup data with the Inno Setup version.
+func (p *peImage) detectInnoSetup(c *installerClues) (*Installer, error) {
+	i := &Installer{Family: InstallerInnoSetup}
+	for _, r := range c.resources.Find(ResourceTypeRCData.ID(), ResourceIntID(innoSetupLoaderResource)) {
+		data, err := p.readResource(r)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(string(data), innoSetupLoaderSignature) {
+			i.Confidence = ConfidenceHigh
+			i.Evidence = append(i.Evidence, fmt.Sprintf("setup loader offset table in RCDATA resource #%d", innoSetupLoaderResource))
+			break
+		}
+	}
+	if i.Confidence == 0 && p.size >= 0x34 {
+		data, err := p.readAt(0x30, 4)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DOS header: %w", err)
+		}
+		if string(data) == innoSetupLegacySignature {
+			i.Confidence = ConfidenceHigh
+			i.Evidence = append(i.Evidence, "setup loader offset table at offset 0x30")
+		}
+	}
+	if offset, ok := c.overlayMarkers[innoSetupDataMarker]; ok {
+		version, err := p.markerVersion(offset, innoSetupDataMarker)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Inno Setup version: %w", err)
+		}
+		i.Confidence = ConfidenceHigh
+		i.Version = version
+		i.Evidence = append(i.Evidence, fmt.Sprintf("setup data header at offset 0x%x", offset))
+	}
+	if i.Confidence == 0 {
+		return nil, nil
+	}
+	return i, nil
+}
+
+// detectInstallShield looks for the files the InstallShield setup launcher appends, which start with
+// "ISSetupStream" since InstallShield 2008 and with "InstallShield" before.
+func (p *peImage) detectInstallShield(c *installerClues) (*Installer, error) {
+	i := &Installer{Family: InstallerInstallShield}
+	for _, marker := range []string{installShieldStreamMarker, installShieldMarker} {
+		if offset, ok := c.overlayMarkers[marker]; ok && offset == c.overlayStart {
+			i.Confidence = ConfidenceHigh
+			i.Evidence = append(i.Evidence, fmt.Sprintf("%s overlay at offset 0x%x", marker, offset))
+			break
+		}
+	}
+	if offset, ok := c.markers[installShieldMarker]; ok {
+		i.Confidence = max(i.Confidence, ConfidenceMedium)
+		i.Evidence = append(i.Evidence, fmt.Sprintf("string %s at offset 0x%x", installShieldMarker, offset))
+	}
+	if i.Confidence == 0 {
+		return nil, nil
+	}
+	return i, nil
+}
+
+// burnManifest is the part of the Burn bundle manifest listing the payloads.
+type burnManifest struct {
+	EngineVersion string        `xml:"EngineVersion,attr"`
+	Payloads      []burnPayload `xml:"Payload"`
+}
+
+type burnPayload struct {
+	FilePath  string `xml:"FilePath,attr"`
+	Packaging string `xml:"Packaging,attr"`
+}
+
+// detectWiXBurn reads the .wixburn section of Burn bundles: the magic, the format version, the bundle
+// ID, the size of the engine stub, the original checksum and signature, the container format and the
+// sizes of the containers. The UX container follows the stub and holds the bundle manifest, which
+// lists the payloads embedded in the attached container.
+func (p *peImage) detectWiXBurn(*installerClues) (*Installer, error) {
+	var section []byte
+	for _, s := range p.file.Sections {
+		if s.Name == ".wixburn" {
+			var err error
+			if section, err = p.readAt(int64(s.Offset), min(int64(s.Size), 0x1000)); err != nil {
+				return nil, fmt.Errorf("failed to read section %s: %w", s.Name, err)
+			}
+			break
+		}
+	}
+	if section == nil {
+		return nil, nil
+	}
+	i := &Installer{Family: InstallerWiXBurn, Confidence: ConfidenceMedium, Evidence: []string{".wixburn section"}}
+	if len(section) < 48 || binary.LittleEndian.Uint32(section) != burnSectionMagic {
+		return i, nil
+	}
+	i.Confidence = ConfidenceHigh
+	i.Evidence = append(i.Evidence, "bundle "+formatGUID(section[8:24]))
+	stubSize := int64(binary.LittleEndian.Uint32(section[24:]))
+	format := binary.LittleEndian.Uint32(section[40:])
+	containers := binary.LittleEndian.Uint32(section[44:])
+	if format != burnContainerFormatCabinet || containers == 0 || len(section) < 52 {
+		i.Evidence = append(i.Evidence, fmt.Sprintf("unsupported container format %d", format))
+		return i, nil
+	}
+	uxSize := int64(binary.LittleEndian.Uint32(section[48:]))
+	if uxSize > maxCabinetExtractSize || stubSize+uxSize > p.size {
+		i.Evidence = append(i.Evidence, "UX container is outside of the file")
+		return i, nil
+	}
+	data, err := p.readAt(stubSize, uxSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Burn UX container: %w", err)
+	}
+	cab, err := parseCabinet(data)
+	if err == nil {
+		data, err = cab.extract(burnManifestFile)
+	}
+	if err != nil {
+		i.Evidence = append(i.Evidence, fmt.Sprintf("bundle manifest not readable: %v", err))
+		return i, nil
+	}
+	var manifest burnManifest
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		i.Evidence = append(i.Evidence, fmt.Sprintf("bundle manifest not readable: %v", err))
+		return i, nil
+	}
+	i.Version = manifest.EngineVersion
+	for _, payload := range manifest.Payloads {
+		if payload.Packaging == "embedded" {
+			i.Payloads = append(i.Payloads, payload.FilePath)
+		}
+	}
+	return i, nil
+}
+
+// detectAdvancedInstaller looks for the footer the Advanced Installer bootstrapper appends after its
+// files and for the tool name in its strings.
+func (p *peImage) detectAdvancedInstaller(c *installerClues) (*Installer, error) {
+	i := &Installer{Family: InstallerAdvancedInstaller}
+	if offset, ok := c.overlayMarkers[advancedInstallerSFXMarker]; ok {
+		i.Confidence = ConfidenceHigh
+		i.Evidence = append(i.Evidence, fmt.Sprintf("%s footer at offset 0x%x", advancedInstallerSFXMarker, offset))
+	}
+	if offset, ok := c.markers[advancedInstallerMarker]; ok {
+		i.Confidence = max(i.Confidence, ConfidenceMedium)
+		i.Evidence = append(i.Evidence, fmt.Sprintf("string %s at offset 0x%x", advancedInstallerMarker, offset))
+	}
+	if i.Confidence == 0 {
+		return nil, nil
+	}
+	return i, nil
+}
+
+// detect7zSFX looks for a 7z archive appended to the SFX module, optionally after a configuration block.
+func (p *peImage) detect7zSFX(c *installerClues) (*Installer, error) {
+	i := &Installer{Family: Installer7zSFX}
+	if offset, ok := c.overlayMarkers[sevenZipConfigMarker]; ok {
+		i.Confidence = ConfidenceHigh
+		i.Evidence = append(i.Evidence, fmt.Sprintf("SFX configuration block at offset 0x%x", offset))
+	}
+	if c.overlay != nil && c.overlay.Format == OverlayFormat7z {
+		i.Confidence = ConfidenceHigh
+		i.Evidence = append(i.Evidence, "7z archive appended as overlay")
+	}
+	if i.Confidence == 0 {
+		return nil, nil
+	}
+	return i, nil
+}
+
+// detectZIPSFX looks for a ZIP archive appended as overlay and lists its files. Other packagers such as
+// Launch4j append ZIP archives too, so without the WinZip stub the confidence is medium.
+func (p *peImage) detectZIPSFX(c *installerClues) (*Installer, error) {
+	i := &Installer{Family: InstallerZIPSFX}
+	if offset, ok := c.markers[winZipSFXMarker]; ok {
+		i.Confidence = ConfidenceHigh
+		i.Evidence = append(i.Evidence, fmt.Sprintf("string %s at offset 0x%x", winZipSFXMarker, offset))
+	}
+	if c.overlay == nil || c.overlay.Format != OverlayFormatZIP {
+		if i.Confidence == 0 {
+			return nil, nil
+		}
+		return i, nil
+	}
+	i.Confidence = max(i.Confidence, ConfidenceMedium)
+	i.Evidence = append(i.Evidence, "ZIP archive appended as overlay")
+	// The central directory is at the end of the archive, the reader accounts for the stub before it.
+	archive, err := zip.NewReader(io.NewSectionReader(p.r, 0, c.overlayEnd), c.overlayEnd)
+	if err != nil {
+		i.Evidence = append(i.Evidence, fmt.Sprintf("ZIP archive not readable: %v", err))
+		return i, nil
+	}
+	for _, f := range archive.File {
+		i.Payloads = append(i.Payloads, f.Name)
+	}
+	return i, nil
+}
+
+// detectCABSFX looks for a cabinet appended as overlay or stored in the CABINET resource of IExpress
+// packages and lists its files. Burn bundles append cabinets too, they are left to detectWiXBurn.
+func (p *peImage) detectCABSFX(c *installerClues) (*Installer, error) {
+	i := &Installer{Family: InstallerCABSFX}
+	var cab []byte
+	if resources := c.resources.Find(ResourceTypeRCData.ID(), ResourceName("CABINET")); len(resources) > 0 {
+		var err error
+		if cab, err = p.readRVA(resources[0].DataRVA, min(resources[0].Size, maxCabinetHeaderSize)); err != nil {
+			i.Confidence = ConfidenceMedium
+			i.Evidence = append(i.Evidence, fmt.Sprintf("IExpress CABINET resource not readable: %v", err))
+			return i, nil
+		}
+		i.Confidence = ConfidenceHigh
+		i.Evidence = append(i.Evidence, "IExpress CABINET resource")
+	} else if c.overlay != nil && c.overlay.Format == OverlayFormatCAB && !p.hasSection(".wixburn") {
+		var err error
+		if cab, err = p.readAt(c.overlay.Offset, min(c.overlay.Ranges[0].Size, maxCabinetHeaderSize)); err != nil {
+			return nil, fmt.Errorf("failed to read overlay: %w", err)
+		}
+		i.Confidence = ConfidenceHigh
+		i.Evidence = append(i.Evidence, "cabinet appended as overlay")
+	} else {
+		return nil, nil
+	}
+	parsed, err := parseCabinet(cab)
+	if err != nil {
+		i.Evidence = append(i.Evidence, fmt.Sprintf("cabinet not readable: %v", err))
+		return i, nil
+	}
+	i.Payloads = parsed.names()
+	return i, nil
+}
@@ -0,0 +1,227 @@
+package fileinfo
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func detectTestInstallers(t *testing.T, image []byte) []Installer {
+	t.Helper()
+	installers, err := DetectInstallers(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	return installers
+}
+
+func TestDetectInstallersNSIS(t *testing.T) {
+	header := append([]byte{0, 0, 0, 0}, nsisSignature+"\x00\x10\x00\x00"...)
+	ri := testRuntimeImage{
+		rdata:    "<description>Nullsoft Install System v3.08</description>",
+		sections: []string{".ndata"},
+		overlay:  header,
+	}
+	installers := detectTestInstallers(t, ri.build(t))
+	require.Len(t, installers, 1)
+	assert.Equal(t, Installer{
+		Family:         InstallerNSIS,
+		Confidence:     ConfidenceHigh,
+		Version:        "3.08",
+		Evidence:       []string{fmt.Sprintf("NSIS first header at offset 0x%x", ri.overlayOffset(t)), ".ndata section"},
+		SilentSwitches: []string{"/S"},
+		Notes:          installerSwitches[InstallerNSIS].notes,
+	}, installers[0])
+
+	// The stub alone, without the installer data.
+	installers = detectTestInstallers(t, testRuntimeImage{sections: []string{".ndata"}}.build(t))
+	require.Len(t, installers, 1)
+	assert.Equal(t, ConfidenceMedium, installers[0].Confidence)
+	assert.Empty(t, installers[0].Version)
+}
+
+func TestDetectInstallersCorruptResources(t *testing.T) {
+	// A resource directory claiming more entries than it holds next to the NSIS data.
+	rsrc := make([]byte, 0x20)
+	binary.LittleEndian.PutUint16(rsrc[12:], 0xffff)
+	b := newTestPE()
+	b.addSection(".text", []byte("\xc3"), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	rva := b.addSection(".rsrc", rsrc, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryResource, rva, uint32(len(rsrc)))
+	b.overlay = append([]byte{0, 0, 0, 0}, nsisSignature+"\x00\x10\x00\x00"...)
+	image := b.build(t)
+	_, err := ReadResources(bytes.NewReader(image), int64(len(image)))
+	require.Error(t, err)
+
+	installers := detectTestInstallers(t, image)
+	require.Len(t, installers, 1)
+	assert.Equal(t, InstallerNSIS, installers[0].Family)
+	assert.Equal(t, ConfidenceHigh, installers[0].Confidence)
+}
+
+func TestDetectInstallersInnoSetup(t *testing.T) {
+	ri := testRuntimeImage{
+		resources: []testResource{
+			{typ: uint16(ResourceTypeRCData), name: uint16(innoSetupLoaderResource), lang: 0, data: []byte("rDlPtS\xcd\xe6\xd7\x7b\x0b\x2a\x01\x00\x00\x00")},
+		},
+		overlay: []byte("zlb\x1a compressed setup-1\x00Inno Setup Setup Data (6.2.2) (u)\x00"),
+	}
+	installers := detectTestInstallers(t, ri.build(t))
+	require.Len(t, installers, 1)
+	i := installers[0]
+	assert.Equal(t, InstallerInnoSetup, i.Family)
+	assert.Equal(t, ConfidenceHigh, i.Confidence)
+	assert.Equal(t, "6.2.2", i.Version)
+	assert.Equal(t, "setup loader offset table in RCDATA resource #11111", i.Evidence[0])
+	assert.Equal(t, []string{"/VERYSILENT", "/SUPPRESSMSGBOXES", "/NORESTART", "/SP-"}, i.SilentSwitches)
+	assert.Contains(t, i.Notes, "/DIR=")
+}
+
+func TestDetectInstallersInstallShield(t *testing.T) {
+	ri := testRuntimeImage{rdata: "InstallShield Setup Launcher", overlay: []byte("ISSetupStream\x00\x01\x00")}
+	installers := detectTestInstallers(t, ri.build(t))
+	require.Len(t, installers, 1)
+	i := installers[0]
+	assert.Equal(t, InstallerInstallShield, i.Family)
+	assert.Equal(t, ConfidenceHigh, i.Confidence)
+	assert.Len(t, i.Evidence, 2)
+	assert.Equal(t, []string{"/s", `/v"/qn"`}, i.SilentSwitches)
+
+	// The name in the strings of the launcher alone.
+	installers = detectTestInstallers(t, testRuntimeImage{rdata: "InstallShield"}.build(t))
+	require.Len(t, installers, 1)
+	assert.Equal(t, ConfidenceMedium, installers[0].Confidence)
+}
+
+// testBurnManifest lists the UX payload and two packages, one downloaded.
+const testBurnManifest = `<?xml version="1.0" encoding="utf-8"?>
+<BurnManifest xmlns="http://wixtoolset.org/schemas/v4/2008/Burn" EngineVersion="4.0.5.0" ProtocolVersion="1">
+  <UX><Payload Id="WixStdbaPayload" FilePath="wixstdba.dll" Packaging="embedded" SourcePath="u0" /></UX>
+  <Container Id="WixAttachedContainer" FileSize="4096" Attached="yes" AttachedIndex="1" />
+  <Payload Id="AgentMsi" FilePath="Agent.msi" Packaging="embedded" SourcePath="a0" Container="WixAttachedContainer" />
+  <Payload Id="VCRedist" FilePath="redist\vc_redist.x64.exe" Packaging="embedded" SourcePath="a1" Container="WixAttachedContainer" />
+  <Payload Id="DotNet" FilePath="redist\windowsdesktop-runtime.exe" Packaging="external" DownloadUrl="https://example.com/runtime.exe" />
+</BurnManifest>`
+
+// testBurnImage builds a bundle with the UX container holding the manifest appended to the engine.
+func testBurnImage(t *testing.T, magic uint32, format uint32) []byte {
+	t.Helper()
+	ux := testCabinet(t, cabinetCompressionMSZIP, 0x100,
+		testCabinetFile{burnManifestFile, []byte(testBurnManifest)},
+		testCabinetFile{"u0", []byte("MZ bootstrapper application")})
+	section := binary.LittleEndian.AppendUint32(nil, magic)
+	section = binary.LittleEndian.AppendUint32(section, 2)
+	section = append(section, 0xb9, 0xdb, 0x44, 0x38, 0x17, 0x20, 0x67, 0x49, 0xbe, 0x7a, 0xa4, 0xa2, 0xc2, 0x04, 0x30, 0xfa)
+	section = append(section, make([]byte, 16)...)
+	section = binary.LittleEndian.AppendUint32(section, format)
+	section = binary.LittleEndian.AppendUint32(section, 2)
+	section = binary.LittleEndian.AppendUint32(section, uint32(len(ux)))
+	section = binary.LittleEndian.AppendUint32(section, 0)
+
+	b := newTestPE()
+	b.addSection(".text", []byte("\xc3"), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	b.addSection(".wixburn", section, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.overlay = ux
+	stubSize := uint32(len(b.build(t)) - len(ux))
+	binary.LittleEndian.PutUint32(section[24:], stubSize)
+	return b.build(t)
+}
+
+func TestDetectInstallersWiXBurn(t *testing.T) {
+	installers := detectTestInstallers(t, testBurnImage(t, burnSectionMagic, burnContainerFormatCabinet))
+	// The appended UX container is not a CAB self-extractor.
+	require.Len(t, installers, 1)
+	assert.Equal(t, Installer{
+		Family:         InstallerWiXBurn,
+		Confidence:     ConfidenceHigh,
+		Version:        "4.0.5.0",
+		Evidence:       []string{".wixburn section", "bundle 3844DBB9-2017-4967-BE7A-A4A2C20430FA"},
+		SilentSwitches: []string{"/quiet", "/norestart"},
+		Notes:          installerSwitches[InstallerWiXBurn].notes,
+		Payloads:       []string{"Agent.msi", `redist\vc_redist.x64.exe`},
+	}, installers[0])
+
+	installers = detectTestInstallers(t, testBurnImage(t, burnSectionMagic, 2))
+	require.Len(t, installers, 1)
+	assert.Contains(t, installers[0].Evidence, "unsupported container format 2")
+	assert.Empty(t, installers[0].Payloads)
+
+	installers = detectTestInstallers(t, testBurnImage(t, 0, burnContainerFormatCabinet))
+	require.Len(t, installers, 1)
+	assert.Equal(t, ConfidenceMedium, installers[0].Confidence)
+}
+
+func TestDetectInstallersAdvancedInstaller(t *testing.T) {
+	ri := testRuntimeImage{rdata: "Advanced Installer 21.0", overlay: append(bytes.Repeat([]byte{0x5a}, 0x200), "ADVINSTSFX\x00\x00"...)}
+	installers := detectTestInstallers(t, ri.build(t))
+	require.Len(t, installers, 1)
+	assert.Equal(t, InstallerAdvancedInstaller, installers[0].Family)
+	assert.Equal(t, ConfidenceHigh, installers[0].Confidence)
+	assert.Equal(t, []string{"/exenoui", "/qn"}, installers[0].SilentSwitches)
+}
+
+func TestDetectInstallers7zSFX(t *testing.T) {
+	ri := testRuntimeImage{overlay: []byte(sevenZipConfigMarker + "\r\nRunProgram=\"setup.exe\"\r\n;!@InstallEnd@!" + sevenZipSignature)}
+	installers := detectTestInstallers(t, ri.build(t))
+	require.Len(t, installers, 1)
+	assert.Equal(t, Installer7zSFX, installers[0].Family)
+	assert.Equal(t, ConfidenceHigh, installers[0].Confidence)
+	assert.Equal(t, []string{"-y"}, installers[0].SilentSwitches)
+}
+
+func TestDetectInstallersZIPSFX(t *testing.T) {
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, name := range []string{"setup.exe", "data/agent.cab"} {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	installers := detectTestInstallers(t, testRuntimeImage{overlay: archive.Bytes()}.build(t))
+	require.Len(t, installers, 1)
+	i := installers[0]
+	assert.Equal(t, InstallerZIPSFX, i.Family)
+	assert.Equal(t, ConfidenceMedium, i.Confidence)
+	assert.Equal(t, []string{"setup.exe", "data/agent.cab"}, i.Payloads)
+	assert.Empty(t, i.SilentSwitches)
+
+	installers = detectTestInstallers(t, testRuntimeImage{rdata: "WinZip Self-Extractor", overlay: archive.Bytes()}.build(t))
+	require.Len(t, installers, 1)
+	assert.Equal(t, ConfidenceHigh, installers[0].Confidence)
+}
+
+func TestDetectInstallersCABSFX(t *testing.T) {
+	cab := testCabinet(t, cabinetCompressionMSZIP, 0x100, testCabinetFile{"agent.msi", []byte("msi")}, testCabinetFile{"setup.inf", []byte("[Version]")})
+	// IExpress stores the cabinet as a resource.
+	ri := testRuntimeImage{resources: []testResource{{typ: uint16(ResourceTypeRCData), name: "CABINET", lang: 0x409, data: cab}}}
+	for _, image := range [][]byte{ri.build(t), testRuntimeImage{overlay: cab}.build(t)} {
+		installers := detectTestInstallers(t, image)
+		require.Len(t, installers, 1)
+		i := installers[0]
+		assert.Equal(t, InstallerCABSFX, i.Family)
+		assert.Equal(t, ConfidenceHigh, i.Confidence)
+		assert.Equal(t, []string{"agent.msi", "setup.inf"}, i.Payloads)
+		assert.Equal(t, []string{"/Q"}, i.SilentSwitches)
+	}
+}
+
+func TestDetectInstallersNative(t *testing.T) {
+	assert.Empty(t, detectTestInstallers(t, testUnsignedImage(t, false)))
+}
+
+func TestWinFileInfoDetectInstallers(t *testing.T) {
+	ri := testRuntimeImage{sections: []string{".ndata"}}
+	wf, err := NewWinFileInfo(testWriteFile(t, "setup.exe", ri.build(t)))
+	require.NoError(t, err)
+	installers, err := wf.DetectInstallers()
+	require.NoError(t, err)
+	require.Len(t, installers, 1)
+	assert.Equal(t, InstallerNSIS, installers[0].Family)
+}
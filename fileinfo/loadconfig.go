@@ -0,0 +1,79 @@
+package fileinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// loadConfig holds the IMAGE_LOAD_CONFIG_DIRECTORY fields used by the package.
+// Fields beyond the Size the linker wrote are zero.
+type loadConfig struct {
+	size uint32
+	// securityCookie is the virtual address of the /GS stack cookie.
+	securityCookie uint64
+	// seHandlerTable and seHandlerCount describe the SafeSEH handler table of x86 images.
+	seHandlerTable uint64
+	seHandlerCount uint64
+	// guardFlags are the IMAGE_GUARD_* control flow guard flags.
+	guardFlags uint32
+	// chpeMetadata is the virtual address of the hybrid (CHPE, ARM64EC and ARM64X) metadata.
+	chpeMetadata uint64
+}
+
+// loadConfigOffsets are the offsets of the fields in IMAGE_LOAD_CONFIG_DIRECTORY32 and 64.
+type loadConfigOffsets struct {
+	securityCookie int
+	seHandlerTable int
+	seHandlerCount int
+	guardFlags     int
+	chpeMetadata   int
+}
+
+var (
+	loadConfigOffsets32 = loadConfigOffsets{securityCookie: 60, seHandlerTable: 64, seHandlerCount: 68, guardFlags: 88, chpeMetadata: 124}
+	loadConfigOffsets64 = loadConfigOffsets{securityCookie: 88, seHandlerTable: 96, seHandlerCount: 104, guardFlags: 144, chpeMetadata: 200}
+)
+
+// loadConfig reads the load configuration directory, nil when the image has none.
+func (p *peImage) loadConfig() (*loadConfig, error) {
+	dir, ok := p.dataDirectory(imageDirectoryEntryLoadConfig)
+	if !ok || dir.Size == 0 {
+		return nil, nil
+	}
+	head, err := p.readRVA(dir.VirtualAddress, 4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read load config: %w", err)
+	}
+	// The Size field of the structure is authoritative, the directory size is not always accurate.
+	size := binary.LittleEndian.Uint32(head)
+	data, err := p.readRVA(dir.VirtualAddress, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read load config: %w", err)
+	}
+
+	offsets, width := loadConfigOffsets32, 4
+	if p.is64() {
+		offsets, width = loadConfigOffsets64, 8
+	}
+	pointer := func(offset int) uint64 {
+		if offset+width > len(data) {
+			return 0
+		}
+		if width == 8 {
+			return binary.LittleEndian.Uint64(data[offset:])
+		}
+		return uint64(binary.LittleEndian.Uint32(data[offset:]))
+	}
+	var guardFlags uint32
+	if offsets.guardFlags+4 <= len(data) {
+		guardFlags = binary.LittleEndian.Uint32(data[offsets.guardFlags:])
+	}
+	return &loadConfig{
+		size:           size,
+		securityCookie: pointer(offsets.securityCookie),
+		seHandlerTable: pointer(offsets.seHandlerTable),
+		seHandlerCount: pointer(offsets.seHandlerCount),
+		guardFlags:     guardFlags,
+		chpeMetadata:   pointer(offsets.chpeMetadata),
+	}, nil
+}
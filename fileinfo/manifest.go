@@ -0,0 +1,200 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Manifest resource IDs, see "Using Side-by-side Assemblies as a Resource".
+// https://learn.microsoft.com/en-us/windows/win32/sbscs/using-side-by-side-assemblies-as-a-resource
+const (
+	// ManifestCreateProcess is the manifest used when the executable is started.
+	ManifestCreateProcess uint16 = 1
+	// ManifestIsolationAware is the manifest of a DLL used when it is loaded.
+	ManifestIsolationAware uint16 = 2
+	// ManifestIsolationAwareNoStaticImport is the DLL manifest activated for calls, not for static imports.
+	ManifestIsolationAwareNoStaticImport uint16 = 3
+)
+
+// supportedOSVersions maps the compatibility supportedOS GUIDs to Windows versions.
+// https://learn.microsoft.com/en-us/windows/win32/sbscs/application-manifests#supportedos
+var supportedOSVersions = map[string]string{
+	"{e2011457-1546-43c5-a5fe-008deee3d3f0}": "Windows Vista",
+	"{35138b9a-5d96-4fbd-8e2d-a2440225f93a}": "Windows 7",
+	"{4a2f28e3-53b9-4441-ba9c-d69d4a4a6e38}": "Windows 8",
+	"{1f676c76-80e1-4239-95bb-83d0f6d0da78}": "Windows 8.1",
+	"{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}": "Windows 10/11",
+}
+
+// AssemblyIdentity identifies a side-by-side assembly.
+type AssemblyIdentity struct {
+	Type                  string `xml:"type,attr"`
+	Name                  string `xml:"name,attr"`
+	Version               string `xml:"version,attr"`
+	ProcessorArchitecture string `xml:"processorArchitecture,attr"`
+	PublicKeyToken        string `xml:"publicKeyToken,attr"`
+	Language              string `xml:"language,attr"`
+}
+
+// SupportedOS is a compatibility supportedOS entry.
+type SupportedOS struct {
+	// ID is the GUID as written in the manifest.
+	ID string
+	// Windows is the Windows version the GUID stands for, empty for unknown GUIDs.
+	Windows string
+}
+
+// Manifest is an application or assembly manifest embedded as an RT_MANIFEST resource.
+type Manifest struct {
+	// ResourceID is the RT_MANIFEST resource ID, see ManifestCreateProcess and the related constants.
+	ResourceID uint16
+	Language   uint16
+	// XML is the raw manifest.
+	XML []byte
+
+	AssemblyIdentity *AssemblyIdentity
+	// RequestedExecutionLevel is asInvoker, highestAvailable or requireAdministrator,
+	// empty when the manifest has no trustInfo.
+	RequestedExecutionLevel string
+	UIAccess                bool
+	DependentAssemblies     []AssemblyIdentity
+	SupportedOS             []SupportedOS
+	// DPIAware is the dpiAware setting, e.g. "true" or "true/pm".
+	DPIAware string
+	// DPIAwareness is the dpiAwareness setting, e.g. "PerMonitorV2, PerMonitor", which takes precedence over DPIAware.
+	DPIAwareness   string
+	LongPathAware  bool
+	ActiveCodePage string
+}
+
+// manifestXML is the subset of the manifest schema decoded into Manifest. Element names are matched
+// in any namespace, as settings are spread over asm.v1, asm.v2, asm.v3 and the WindowsSettings namespaces.
+type manifestXML struct {
+	AssemblyIdentity *AssemblyIdentity `xml:"assemblyIdentity"`
+	ExecutionLevel   []struct {
+		Level    string `xml:"level,attr"`
+		UIAccess string `xml:"uiAccess,attr"`
+	} `xml:"trustInfo>security>requestedPrivileges>requestedExecutionLevel"`
+	DependentAssemblies []AssemblyIdentity `xml:"dependency>dependentAssembly>assemblyIdentity"`
+	SupportedOS         []struct {
+		ID string `xml:"Id,attr"`
+	} `xml:"compatibility>application>supportedOS"`
+	WindowsSettings []struct {
+		DPIAware       string `xml:"dpiAware"`
+		DPIAwareness   string `xml:"dpiAwareness"`
+		LongPathAware  string `xml:"longPathAware"`
+		ActiveCodePage string `xml:"activeCodePage"`
+	} `xml:"application>windowsSettings"`
+}
+
+// ParseManifest parses the XML of an application or assembly manifest, e.g. a .manifest file.
+func ParseManifest(data []byte) (*Manifest, error) {
+	text, err := decodeManifestText(data)
+	if err != nil {
+		return nil, err
+	}
+	var doc manifestXML
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	// The text is already decoded, the declared encoding is ignored.
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	m := &Manifest{
+		XML:                 data,
+		AssemblyIdentity:    doc.AssemblyIdentity,
+		DependentAssemblies: doc.DependentAssemblies,
+	}
+	if len(doc.ExecutionLevel) > 0 {
+		m.RequestedExecutionLevel = strings.TrimSpace(doc.ExecutionLevel[0].Level)
+		m.UIAccess = manifestBool(doc.ExecutionLevel[0].UIAccess)
+	}
+	for _, supported := range doc.SupportedOS {
+		id := strings.ToLower(strings.TrimSpace(supported.ID))
+		m.SupportedOS = append(m.SupportedOS, SupportedOS{ID: supported.ID, Windows: supportedOSVersions[id]})
+	}
+	// Settings may be split across several windowsSettings elements, one per namespace.
+	for _, s := range doc.WindowsSettings {
+		if v := strings.TrimSpace(s.DPIAware); v != "" {
+			m.DPIAware = v
+		}
+		if v := strings.TrimSpace(s.DPIAwareness); v != "" {
+			m.DPIAwareness = v
+		}
+		if v := strings.TrimSpace(s.LongPathAware); v != "" {
+			m.LongPathAware = manifestBool(v)
+		}
+		if v := strings.TrimSpace(s.ActiveCodePage); v != "" {
+			m.ActiveCodePage = v
+		}
+	}
+	return m, nil
+}
+
+// decodeManifestText decodes the manifest according to its byte order mark, UTF-8 without one.
+func decodeManifestText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return decodeUTF16(data[2:], binary.LittleEndian)
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return decodeUTF16(data[2:], binary.BigEndian)
+	}
+	return string(bytes.TrimPrefix(data, []byte{0xef, 0xbb, 0xbf})), nil
+}
+
+func manifestBool(v string) bool {
+	return strings.EqualFold(strings.TrimSpace(v), "true")
+}
+
+// ReadManifests reads and parses the RT_MANIFEST resources with IDs 1 to 3 from the PE image in r,
+// size is the total image size. The manifests are ordered by resource ID, the result is empty
+// when the image has no manifest.
+func ReadManifests(r io.ReaderAt, size int64) ([]*Manifest, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.manifests()
+}
+
+func readManifestsFile(path string) ([]*Manifest, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.manifests()
+}
+
+func (p *peImage) manifests() ([]*Manifest, error) {
+	resources, err := p.findResources(ResourceTypeManifest)
+	if err != nil {
+		return nil, err
+	}
+	var manifests []*Manifest
+	for _, id := range []uint16{ManifestCreateProcess, ManifestIsolationAware, ManifestIsolationAwareNoStaticImport} {
+		for _, resource := range resources.Find(ResourceTypeManifest.ID(), ResourceIntID(id)) {
+			data, err := p.readResource(resource)
+			if err != nil {
+				return nil, err
+			}
+			m, err := ParseManifest(data)
+			if err != nil {
+				return nil, fmt.Errorf("manifest %d: %w", id, err)
+			}
+			m.ResourceID = id
+			m.Language = resource.Language
+			manifests = append(manifests, m)
+		}
+	}
+	return manifests, nil
+}
@@ -0,0 +1,149 @@
+package fileinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0">
+  <assemblyIdentity type="win32" name="Contoso.Agent" version="1.2.3.4" processorArchitecture="amd64"/>
+  <trustInfo xmlns="urn:schemas-microsoft-com:asm.v3">
+    <security>
+      <requestedPrivileges>
+        <requestedExecutionLevel level="requireAdministrator" uiAccess="false"/>
+      </requestedPrivileges>
+    </security>
+  </trustInfo>
+  <dependency>
+    <dependentAssembly>
+      <assemblyIdentity type="win32" name="Microsoft.Windows.Common-Controls" version="6.0.0.0"
+        processorArchitecture="*" publicKeyToken="6595b64144ccf1df" language="*"/>
+    </dependentAssembly>
+  </dependency>
+  <dependency>
+    <dependentAssembly>
+      <assemblyIdentity type="win32" name="Microsoft.VC90.CRT" version="9.0.21022.8"
+        processorArchitecture="amd64" publicKeyToken="1fc8b3b9a1e18e3b"/>
+    </dependentAssembly>
+  </dependency>
+  <compatibility xmlns="urn:schemas-microsoft-com:compatibility.v1">
+    <application>
+      <supportedOS Id="{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}"/>
+      <supportedOS Id="{1F676C76-80E1-4239-95BB-83D0F6D0DA78}"/>
+      <supportedOS Id="{35138b9a-5d96-4fbd-8e2d-a2440225f93a}"/>
+      <supportedOS Id="{00000000-0000-0000-0000-000000000000}"/>
+    </application>
+  </compatibility>
+  <application xmlns="urn:schemas-microsoft-com:asm.v3">
+    <windowsSettings>
+      <dpiAware xmlns="http://schemas.microsoft.com/SMI/2005/WindowsSettings">true/pm</dpiAware>
+      <dpiAwareness xmlns="http://schemas.microsoft.com/SMI/2016/WindowsSettings">PerMonitorV2, PerMonitor</dpiAwareness>
+    </windowsSettings>
+    <windowsSettings xmlns:ws2="http://schemas.microsoft.com/SMI/2016/WindowsSettings">
+      <ws2:longPathAware> True </ws2:longPathAware>
+      <activeCodePage xmlns="http://schemas.microsoft.com/SMI/2019/WindowsSettings">UTF-8</activeCodePage>
+    </windowsSettings>
+  </application>
+</assembly>`
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+
+	require.NotNil(t, m.AssemblyIdentity)
+	assert.Equal(t, "Contoso.Agent", m.AssemblyIdentity.Name)
+	assert.Equal(t, "1.2.3.4", m.AssemblyIdentity.Version)
+	assert.Equal(t, "requireAdministrator", m.RequestedExecutionLevel)
+	assert.False(t, m.UIAccess)
+
+	assert.Equal(t, []AssemblyIdentity{
+		{Type: "win32", Name: "Microsoft.Windows.Common-Controls", Version: "6.0.0.0", ProcessorArchitecture: "*", PublicKeyToken: "6595b64144ccf1df", Language: "*"},
+		{Type: "win32", Name: "Microsoft.VC90.CRT", Version: "9.0.21022.8", ProcessorArchitecture: "amd64", PublicKeyToken: "1fc8b3b9a1e18e3b"},
+	}, m.DependentAssemblies)
+
+	assert.Equal(t, []SupportedOS{
+		{ID: "{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}", Windows: "Windows 10/11"},
+		{ID: "{1F676C76-80E1-4239-95BB-83D0F6D0DA78}", Windows: "Windows 8.1"},
+		{ID: "{35138b9a-5d96-4fbd-8e2d-a2440225f93a}", Windows: "Windows 7"},
+		{ID: "{00000000-0000-0000-0000-000000000000}"},
+	}, m.SupportedOS)
+
+	assert.Equal(t, "true/pm", m.DPIAware)
+	assert.Equal(t, "PerMonitorV2, PerMonitor", m.DPIAwareness)
+	assert.True(t, m.LongPathAware)
+	assert.Equal(t, "UTF-8", m.ActiveCodePage)
+}
+
+func TestParseManifestMinimal(t *testing.T) {
+	m, err := ParseManifest([]byte(`<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0"/>`))
+	require.NoError(t, err)
+	assert.Nil(t, m.AssemblyIdentity)
+	assert.Empty(t, m.RequestedExecutionLevel)
+	assert.Empty(t, m.DependentAssemblies)
+	assert.False(t, m.LongPathAware)
+}
+
+func TestParseManifestEncodings(t *testing.T) {
+	utf16 := append([]byte{0xff, 0xfe}, testUTF16LE(`<?xml version="1.0" encoding="UTF-16"?>`+testManifest[len(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`):])...)
+	for name, data := range map[string][]byte{
+		"utf-8 bom": append([]byte{0xef, 0xbb, 0xbf}, testManifest...),
+		"utf-16le":  utf16,
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := ParseManifest(data)
+			require.NoError(t, err)
+			assert.Equal(t, "requireAdministrator", m.RequestedExecutionLevel)
+		})
+	}
+
+	_, err := ParseManifest([]byte("<assembly>"))
+	require.Error(t, err)
+}
+
+func TestReadManifests(t *testing.T) {
+	dllManifest := `<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0">
+  <trustInfo xmlns="urn:schemas-microsoft-com:asm.v2"><security><requestedPrivileges>
+    <requestedExecutionLevel level="asInvoker" uiAccess="true"/>
+  </requestedPrivileges></security></trustInfo>
+</assembly>`
+	image := testImageWithResources(t, false,
+		testResource{typ: uint16(ResourceTypeManifest), name: uint16(2), lang: 0x409, data: []byte(dllManifest)},
+		testResource{typ: uint16(ResourceTypeManifest), name: uint16(1), lang: 0x409, data: []byte(testManifest)},
+		testResource{typ: uint16(ResourceTypeManifest), name: uint16(7), lang: 0x409, data: []byte("not a manifest")},
+	)
+
+	manifests, err := ReadManifests(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+	assert.Equal(t, ManifestCreateProcess, manifests[0].ResourceID)
+	assert.Equal(t, uint16(0x409), manifests[0].Language)
+	assert.Equal(t, []byte(testManifest), manifests[0].XML)
+	assert.Equal(t, ManifestIsolationAware, manifests[1].ResourceID)
+	assert.Equal(t, "asInvoker", manifests[1].RequestedExecutionLevel)
+	assert.True(t, manifests[1].UIAccess)
+
+	wf, err := NewWinFileInfo(testWriteFile(t, "app.exe", image))
+	require.NoError(t, err)
+	manifests, err = wf.GetManifests()
+	require.NoError(t, err)
+	assert.Len(t, manifests, 2)
+
+	wf, err = NewWinFileInfo(testWriteFile(t, "plain.exe", testUnsignedImage(t, false)))
+	require.NoError(t, err)
+	manifests, err = wf.GetManifests()
+	require.NoError(t, err)
+	assert.Empty(t, manifests)
+}
+
+func TestReadManifestsInvalid(t *testing.T) {
+	image := testImageWithResources(t, false,
+		testResource{typ: uint16(ResourceTypeManifest), name: uint16(1), data: []byte("<assembly><trustInfo>")},
+	)
+	_, err := ReadManifests(bytes.NewReader(image), int64(len(image)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "manifest 1")
+}
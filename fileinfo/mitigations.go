@@ -0,0 +1,228 @@
+package fileinfo
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// imageGuardCFInstrumented is IMAGE_GUARD_CF_INSTRUMENTED of the load config GuardFlags.
+	imageGuardCFInstrumented = 0x100
+	// imageDllCharacteristicsExCETCompat is IMAGE_DLLCHARACTERISTICS_EX_CET_COMPAT.
+	imageDllCharacteristicsExCETCompat = 0x01
+)
+
+// Names of the mitigations checked by the mitigation report.
+const (
+	MitigationASLR           = "ASLR"
+	MitigationHighEntropyVA  = "HighEntropyVA"
+	MitigationDEP            = "DEP"
+	MitigationCFG            = "CFG"
+	MitigationSafeSEH        = "SafeSEH"
+	MitigationGS             = "GS"
+	MitigationCET            = "CET"
+	MitigationForceIntegrity = "ForceIntegrity"
+	MitigationAppContainer   = "AppContainer"
+)
+
+// MitigationStatus is the outcome of a mitigation check.
+type MitigationStatus int
+
+const (
+	MitigationPass MitigationStatus = iota
+	MitigationFail
+	// MitigationNotApplicable is reported when the mitigation does not apply to the image,
+	// e.g. SafeSEH on x64 or HighEntropyVA on 32-bit images.
+	MitigationNotApplicable
+)
+
+func (s MitigationStatus) String() string {
+	switch s {
+	case MitigationPass:
+		return "pass"
+	case MitigationFail:
+		return "fail"
+	case MitigationNotApplicable:
+		return "n/a"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(s))
+	}
+}
+
+// Mitigation is the result of one exploit mitigation check.
+type Mitigation struct {
+	Name   string
+	Status MitigationStatus
+	Reason string
+}
+
+// MitigationReport lists the exploit mitigations of an image in the order they are checked:
+// ASLR, HighEntropyVA, DEP, CFG, SafeSEH, GS, CET, ForceIntegrity and AppContainer.
+type MitigationReport struct {
+	Mitigations []Mitigation
+}
+
+// Get returns the result of the named check, nil for unknown names.
+func (r *MitigationReport) Get(name string) *Mitigation {
+	for i := range r.Mitigations {
+		if r.Mitigations[i].Name == name {
+			return &r.Mitigations[i]
+		}
+	}
+	return nil
+}
+
+// Failed returns the checks that failed.
+func (r *MitigationReport) Failed() []Mitigation {
+	var failed []Mitigation
+	for _, m := range r.Mitigations {
+		if m.Status == MitigationFail {
+			failed = append(failed, m)
+		}
+	}
+	return failed
+}
+
+// ReadMitigations checks the exploit mitigations of the PE image in r, size is the total image size.
+// A malformed load config or debug directory fails the checks that read it rather than the report.
+func ReadMitigations(r io.ReaderAt, size int64) (*MitigationReport, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.mitigations()
+}
+
+func readMitigationsFile(path string) (*MitigationReport, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.mitigations()
+}
+
+func (p *peImage) mitigations() (*MitigationReport, error) {
+	h, err := p.headers()
+	if err != nil {
+		return nil, err
+	}
+	// The errors are the reasons of the checks that need the directories.
+	config, configErr := p.loadConfig()
+	exCharacteristics, exErr := p.exDllCharacteristics()
+
+	report := &MitigationReport{}
+	add := func(name string, status MitigationStatus, reason string) {
+		report.Mitigations = append(report.Mitigations, Mitigation{Name: name, Status: status, Reason: reason})
+	}
+	dll := h.DllCharacteristics
+
+	switch {
+	case !dll.Has(pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE):
+		add(MitigationASLR, MitigationFail, "DYNAMIC_BASE is not set")
+	case h.Characteristics.Has(pe.IMAGE_FILE_RELOCS_STRIPPED):
+		add(MitigationASLR, MitigationFail, "DYNAMIC_BASE is set but relocations are stripped")
+	default:
+		add(MitigationASLR, MitigationPass, "DYNAMIC_BASE is set")
+	}
+
+	switch {
+	case !h.PE32Plus:
+		add(MitigationHighEntropyVA, MitigationNotApplicable, "32-bit image")
+	case !dll.Has(pe.IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA):
+		add(MitigationHighEntropyVA, MitigationFail, "HIGH_ENTROPY_VA is not set")
+	case !dll.Has(pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE):
+		add(MitigationHighEntropyVA, MitigationFail, "HIGH_ENTROPY_VA is set without DYNAMIC_BASE")
+	default:
+		add(MitigationHighEntropyVA, MitigationPass, "HIGH_ENTROPY_VA is set")
+	}
+
+	if dll.Has(pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT) {
+		add(MitigationDEP, MitigationPass, "NX_COMPAT is set")
+	} else {
+		add(MitigationDEP, MitigationFail, "NX_COMPAT is not set")
+	}
+
+	switch {
+	case !dll.Has(pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF):
+		add(MitigationCFG, MitigationFail, "GUARD_CF is not set")
+	case configErr != nil:
+		add(MitigationCFG, MitigationFail, configErr.Error())
+	case config == nil:
+		add(MitigationCFG, MitigationFail, "GUARD_CF is set but the image has no load config")
+	case config.guardFlags&imageGuardCFInstrumented == 0:
+		add(MitigationCFG, MitigationFail, "GUARD_CF is set but the load config is not CF instrumented")
+	default:
+		add(MitigationCFG, MitigationPass, "GUARD_CF is set and the load config is CF instrumented")
+	}
+
+	switch {
+	case h.Machine != MachineI386:
+		add(MitigationSafeSEH, MitigationNotApplicable, fmt.Sprintf("SafeSEH only applies to x86, image is %s", h.Machine))
+	case dll.Has(pe.IMAGE_DLLCHARACTERISTICS_NO_SEH):
+		add(MitigationSafeSEH, MitigationNotApplicable, "NO_SEH is set, the image has no exception handlers")
+	case configErr != nil:
+		add(MitigationSafeSEH, MitigationFail, configErr.Error())
+	case config == nil || config.seHandlerTable == 0:
+		add(MitigationSafeSEH, MitigationFail, "the load config has no SEH handler table")
+	default:
+		add(MitigationSafeSEH, MitigationPass, fmt.Sprintf("the load config has %d registered SEH handlers", config.seHandlerCount))
+	}
+
+	switch {
+	case configErr != nil:
+		add(MitigationGS, MitigationFail, configErr.Error())
+	case config != nil && config.securityCookie != 0:
+		add(MitigationGS, MitigationPass, "the load config has a security cookie")
+	default:
+		add(MitigationGS, MitigationFail, "the image has no security cookie in its load config")
+	}
+
+	switch {
+	case h.Machine != MachineI386 && h.Machine != MachineAMD64:
+		add(MitigationCET, MitigationNotApplicable, fmt.Sprintf("CET shadow stacks only apply to x86 and x64, image is %s", h.Machine))
+	case exErr != nil:
+		add(MitigationCET, MitigationFail, exErr.Error())
+	case exCharacteristics&imageDllCharacteristicsExCETCompat != 0:
+		add(MitigationCET, MitigationPass, "CET_COMPAT is set in the extended DLL characteristics")
+	default:
+		add(MitigationCET, MitigationFail, "CET_COMPAT is not set")
+	}
+
+	if dll.Has(pe.IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY) {
+		add(MitigationForceIntegrity, MitigationPass, "FORCE_INTEGRITY is set")
+	} else {
+		add(MitigationForceIntegrity, MitigationFail, "FORCE_INTEGRITY is not set")
+	}
+
+	if dll.Has(pe.IMAGE_DLLCHARACTERISTICS_APPCONTAINER) {
+		add(MitigationAppContainer, MitigationPass, "APPCONTAINER is set")
+	} else {
+		add(MitigationAppContainer, MitigationFail, "APPCONTAINER is not set")
+	}
+	return report, nil
+}
+
+// exDllCharacteristics returns the IMAGE_DLLCHARACTERISTICS_EX_* flags stored in the
+// IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS debug entry, zero when the image has none.
+func (p *peImage) exDllCharacteristics() (uint32, error) {
+	entries, err := p.debugDirectory()
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if entry.Type != DebugTypeExDllCharacteristics || entry.SizeOfData < 4 {
+			continue
+		}
+		data, err := p.debugData(entry)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read extended DLL characteristics: %w", err)
+		}
+		return binary.LittleEndian.Uint32(data), nil
+	}
+	return 0, nil
+}
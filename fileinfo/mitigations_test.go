@@ -0,0 +1,149 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHardenedImage builds an image with a load config and an extended DLL characteristics
+// debug entry in its .rdata section.
+func testHardenedImage(t *testing.T, machine uint16, pe32 bool, dllCharacteristics uint16, config map[int]uint64, guardFlags uint32, exCharacteristics uint32) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.machine, b.pe32, b.dllCharacteristics = machine, pe32, dllCharacteristics
+	b.addSection(".text", []byte{0xc3}, pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+
+	rva := b.nextRVA()
+	offsets, size := loadConfigOffsets64, 320
+	if pe32 {
+		offsets, size = loadConfigOffsets32, 192
+	}
+	rdata := testLoadConfig(size, pe32, config)
+	binary.LittleEndian.PutUint32(rdata[offsets.guardFlags:], guardFlags)
+
+	debug := testDebugDirectory(DebugTypeExDllCharacteristics)
+	binary.LittleEndian.PutUint32(debug[16:], 4)
+	binary.LittleEndian.PutUint32(debug[20:], rva+uint32(size)+28)
+	rdata = append(rdata, debug...)
+	rdata = binary.LittleEndian.AppendUint32(rdata, exCharacteristics)
+
+	b.addSection(".rdata", rdata, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryLoadConfig, rva, uint32(size))
+	b.setDirectory(imageDirectoryEntryDebug, rva+uint32(size), 28)
+	return b.build(t)
+}
+
+func testMitigationStatuses(t *testing.T, image []byte) map[string]MitigationStatus {
+	t.Helper()
+	report, err := ReadMitigations(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	statuses := map[string]MitigationStatus{}
+	for _, m := range report.Mitigations {
+		assert.NotEmpty(t, m.Reason, m.Name)
+		statuses[m.Name] = m.Status
+	}
+	return statuses
+}
+
+func TestMitigationsHardened(t *testing.T) {
+	image := testHardenedImage(t, pe.IMAGE_FILE_MACHINE_AMD64, false,
+		pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE|pe.IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA|pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT|
+			pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF|pe.IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY|pe.IMAGE_DLLCHARACTERISTICS_APPCONTAINER,
+		map[int]uint64{loadConfigOffsets64.securityCookie: 0x140003000},
+		imageGuardCFInstrumented|0x500, imageDllCharacteristicsExCETCompat)
+
+	assert.Equal(t, map[string]MitigationStatus{
+		MitigationASLR:           MitigationPass,
+		MitigationHighEntropyVA:  MitigationPass,
+		MitigationDEP:            MitigationPass,
+		MitigationCFG:            MitigationPass,
+		MitigationSafeSEH:        MitigationNotApplicable,
+		MitigationGS:             MitigationPass,
+		MitigationCET:            MitigationPass,
+		MitigationForceIntegrity: MitigationPass,
+		MitigationAppContainer:   MitigationPass,
+	}, testMitigationStatuses(t, image))
+}
+
+func TestMitigationsUnprotected(t *testing.T) {
+	b := newTestPE()
+	b.characteristics |= pe.IMAGE_FILE_RELOCS_STRIPPED
+	b.dllCharacteristics = pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE | pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF
+	image := b.build(t)
+
+	report, err := ReadMitigations(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.Len(t, report.Mitigations, 9)
+	assert.Len(t, report.Failed(), 8)
+	assert.Equal(t, "DYNAMIC_BASE is set but relocations are stripped", report.Get(MitigationASLR).Reason)
+	assert.Equal(t, "GUARD_CF is set but the image has no load config", report.Get(MitigationCFG).Reason)
+	assert.Equal(t, MitigationNotApplicable, report.Get(MitigationSafeSEH).Status)
+	assert.Nil(t, report.Get("Unknown"))
+}
+
+func TestMitigationsMalformedDirectories(t *testing.T) {
+	image := testHardenedImage(t, pe.IMAGE_FILE_MACHINE_AMD64, false,
+		pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE|pe.IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA|pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT|
+			pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF|pe.IMAGE_DLLCHARACTERISTICS_APPCONTAINER,
+		map[int]uint64{loadConfigOffsets64.securityCookie: 0x140003000},
+		imageGuardCFInstrumented, imageDllCharacteristicsExCETCompat)
+	// A load config Size past the section and extended DLL characteristics outside of the sections.
+	rdata := 0x200 + testFileAlignment
+	binary.LittleEndian.PutUint32(image[rdata:], 0x7fffffff)
+	binary.LittleEndian.PutUint32(image[rdata+320+20:], 0x7fff0000)
+
+	report, err := ReadMitigations(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	for _, name := range []string{MitigationASLR, MitigationHighEntropyVA, MitigationDEP, MitigationAppContainer} {
+		assert.Equal(t, MitigationPass, report.Get(name).Status, name)
+	}
+	for _, name := range []string{MitigationCFG, MitigationGS} {
+		assert.Equal(t, MitigationFail, report.Get(name).Status, name)
+		assert.Contains(t, report.Get(name).Reason, "failed to read load config", name)
+	}
+	assert.Equal(t, MitigationNotApplicable, report.Get(MitigationSafeSEH).Status)
+	assert.Equal(t, MitigationFail, report.Get(MitigationCET).Status)
+	assert.Contains(t, report.Get(MitigationCET).Reason, "failed to read extended DLL characteristics")
+}
+
+func TestMitigationsX86(t *testing.T) {
+	safeSEH := testHardenedImage(t, pe.IMAGE_FILE_MACHINE_I386, true,
+		pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE|pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT|pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF,
+		map[int]uint64{loadConfigOffsets32.securityCookie: 0x403000, loadConfigOffsets32.seHandlerTable: 0x402000, loadConfigOffsets32.seHandlerCount: 3},
+		0, 0)
+	statuses := testMitigationStatuses(t, safeSEH)
+	assert.Equal(t, MitigationNotApplicable, statuses[MitigationHighEntropyVA])
+	assert.Equal(t, MitigationPass, statuses[MitigationSafeSEH])
+	assert.Equal(t, MitigationPass, statuses[MitigationGS])
+	assert.Equal(t, MitigationFail, statuses[MitigationCFG], "GUARD_CF without CF instrumented load config")
+	assert.Equal(t, MitigationFail, statuses[MitigationCET])
+
+	noSafeSEH := testHardenedImage(t, pe.IMAGE_FILE_MACHINE_I386, true, pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE, nil, 0, 0)
+	assert.Equal(t, MitigationFail, testMitigationStatuses(t, noSafeSEH)[MitigationSafeSEH])
+
+	noSEH := testHardenedImage(t, pe.IMAGE_FILE_MACHINE_I386, true, pe.IMAGE_DLLCHARACTERISTICS_NO_SEH, nil, 0, 0)
+	assert.Equal(t, MitigationNotApplicable, testMitigationStatuses(t, noSEH)[MitigationSafeSEH])
+}
+
+func TestMitigationsARM64(t *testing.T) {
+	image := testHardenedImage(t, pe.IMAGE_FILE_MACHINE_ARM64, false, pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT, nil, 0, imageDllCharacteristicsExCETCompat)
+	report, err := ReadMitigations(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	cet := report.Get(MitigationCET)
+	assert.Equal(t, MitigationNotApplicable, cet.Status)
+	assert.Equal(t, "CET shadow stacks only apply to x86 and x64, image is ARM64", cet.Reason)
+	assert.Equal(t, "n/a", cet.Status.String())
+}
+
+func TestWinFileInfoMitigations(t *testing.T) {
+	wf, err := NewWinFileInfo(testWriteFile(t, "app.exe", testUnsignedImage(t, false)))
+	require.NoError(t, err)
+	report, err := wf.Mitigations()
+	require.NoError(t, err)
+	assert.Equal(t, MitigationFail, report.Get(MitigationDEP).Status)
+}
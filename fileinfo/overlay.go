@@ -0,0 +1,125 @@
+package fileinfo
+
+import (
+	"bytes"
+	"io"
+)
+
+// OverlayFormat is the format of the data an overlay starts with.
+type OverlayFormat string
+
+const (
+	OverlayFormatUnknown      OverlayFormat = ""
+	OverlayFormatZIP          OverlayFormat = "ZIP"
+	OverlayFormat7z           OverlayFormat = "7z"
+	OverlayFormatCAB          OverlayFormat = "CAB"
+	OverlayFormatRAR          OverlayFormat = "RAR"
+	OverlayFormatCompoundFile OverlayFormat = "OLE compound file"
+	OverlayFormatNSIS         OverlayFormat = "NSIS"
+	OverlayFormatPE           OverlayFormat = "PE"
+)
+
+// Signatures of the archive formats installers and self-extractors append.
+const (
+	zipSignature          = "PK\x03\x04"
+	sevenZipSignature     = "7z\xbc\xaf\x27\x1c"
+	cabinetSignature      = "MSCF"
+	rarSignature          = "Rar!\x1a\x07"
+	compoundFileSignature = "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"
+	// nsisSignature follows the flags of the NSIS first header.
+	nsisSignature = "\xef\xbe\xad\xdeNullsoftInst"
+)
+
+// overlayHeadSize is how much of the overlay overlayFormat looks at, the longest signature is the
+// NSIS one after the 4 byte flags.
+const overlayHeadSize = 4 + len(nsisSignature)
+
+// OverlayRange is a range of overlay data in the file.
+type OverlayRange struct {
+	Offset int64
+	Size   int64
+}
+
+// Overlay is the data appended to a PE file after its last section, which the loader does not map.
+// The certificate table is not part of the overlay.
+type Overlay struct {
+	// Offset is the file offset of the first overlay byte.
+	Offset int64
+	// Size is the total size of the ranges.
+	Size int64
+	// Ranges are the overlay ranges in file order. Data appended after the certificate table, as some
+	// tools do after signing, is a second range.
+	Ranges []OverlayRange
+	// Format is the format of the data at Offset, OverlayFormatUnknown when not recognized.
+	Format OverlayFormat
+}
+
+// ReadOverlay finds the overlay of the PE image in r, size is the total image size. It returns nil
+// when the image has no overlay.
+func ReadOverlay(r io.ReaderAt, size int64) (*Overlay, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.overlay()
+}
+
+func readOverlayFile(path string) (*Overlay, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.overlay()
+}
+
+func (p *peImage) overlay() (*Overlay, error) {
+	start, end := p.overlayRange()
+	o := &Overlay{}
+	if end > start {
+		o.Ranges = append(o.Ranges, OverlayRange{Offset: start, Size: end - start})
+	}
+	if dir, ok := p.dataDirectory(imageDirectoryEntrySecurity); ok && dir.Size > 0 && int64(dir.VirtualAddress) >= start {
+		if certificateEnd := int64(dir.VirtualAddress) + int64(dir.Size); certificateEnd < p.size {
+			o.Ranges = append(o.Ranges, OverlayRange{Offset: certificateEnd, Size: p.size - certificateEnd})
+		}
+	}
+	if len(o.Ranges) == 0 {
+		return nil, nil
+	}
+	o.Offset = o.Ranges[0].Offset
+	for _, r := range o.Ranges {
+		o.Size += r.Size
+	}
+	head, err := p.readAt(o.Offset, min(o.Ranges[0].Size, int64(overlayHeadSize)))
+	if err != nil {
+		return nil, err
+	}
+	o.Format = overlayFormat(head)
+	return o, nil
+}
+
+// overlayFormat recognizes the format from the first bytes of the overlay.
+func overlayFormat(head []byte) OverlayFormat {
+	for _, f := range []struct {
+		signature string
+		format    OverlayFormat
+	}{
+		{zipSignature, OverlayFormatZIP},
+		{sevenZipSignature, OverlayFormat7z},
+		{cabinetSignature, OverlayFormatCAB},
+		{rarSignature, OverlayFormatRAR},
+		{compoundFileSignature, OverlayFormatCompoundFile},
+		{"MZ", OverlayFormatPE},
+	} {
+		if bytes.HasPrefix(head, []byte(f.signature)) {
+			return f.format
+		}
+	}
+	if len(head) >= 4 && bytes.HasPrefix(head[4:], []byte(nsisSignature)) {
+		return OverlayFormatNSIS
+	}
+	return OverlayFormatUnknown
+}
@@ -0,0 +1,97 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOverlay(t *testing.T) {
+	ri := testRuntimeImage{overlay: []byte(sevenZipSignature + "\x00\x04 archive")}
+	image := ri.build(t)
+	overlay, err := ReadOverlay(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.NotNil(t, overlay)
+	offset := ri.overlayOffset(t)
+	assert.Equal(t, &Overlay{
+		Offset: offset,
+		Size:   int64(len(ri.overlay)),
+		Ranges: []OverlayRange{{Offset: offset, Size: int64(len(ri.overlay))}},
+		Format: OverlayFormat7z,
+	}, overlay)
+}
+
+func TestReadOverlayNSIS(t *testing.T) {
+	ri := testRuntimeImage{overlay: append([]byte{0, 0, 0, 0}, nsisSignature+"\x00\x10\x00\x00 installer data"...)}
+	image := ri.build(t)
+	overlay, err := ReadOverlay(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.NotNil(t, overlay)
+	assert.Equal(t, ri.overlayOffset(t), overlay.Offset)
+	assert.Equal(t, OverlayFormatNSIS, overlay.Format)
+}
+
+func TestReadOverlaySigned(t *testing.T) {
+	// A tool appended data after the certificate table of the signed file.
+	data := []byte(zipSignature + " archive")
+	certificate := make([]byte, 0x100)
+	trailer := []byte("appended after signing")
+	b := newTestPE()
+	b.addSection(".text", []byte("\xc3"), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	b.overlay = append(append(append([]byte{}, data...), certificate...), trailer...)
+	start := int64(len(b.build(t)) - len(b.overlay))
+	b.setDirectory(imageDirectoryEntrySecurity, uint32(start)+uint32(len(data)), uint32(len(certificate)))
+	image := b.build(t)
+
+	overlay, err := ReadOverlay(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.NotNil(t, overlay)
+	assert.Equal(t, []OverlayRange{
+		{Offset: start, Size: int64(len(data))},
+		{Offset: start + int64(len(data)+len(certificate)), Size: int64(len(trailer))},
+	}, overlay.Ranges)
+	assert.Equal(t, int64(len(data)+len(trailer)), overlay.Size)
+	assert.Equal(t, OverlayFormatZIP, overlay.Format)
+
+	// Only the certificate table follows the sections.
+	b.overlay = certificate
+	b.setDirectory(imageDirectoryEntrySecurity, uint32(len(b.build(t))-len(certificate)), uint32(len(certificate)))
+	image = b.build(t)
+	overlay, err = ReadOverlay(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Nil(t, overlay)
+}
+
+func TestReadOverlayNone(t *testing.T) {
+	image := testUnsignedImage(t, false)
+	overlay, err := ReadOverlay(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Nil(t, overlay)
+}
+
+func TestOverlayFormat(t *testing.T) {
+	for head, format := range map[string]OverlayFormat{
+		"PK\x03\x04\x14\x00":               OverlayFormatZIP,
+		"MSCF\x00\x00\x00\x00":             OverlayFormatCAB,
+		"Rar!\x1a\x07\x01\x00":             OverlayFormatRAR,
+		compoundFileSignature:              OverlayFormatCompoundFile,
+		"MZ\x90\x00":                       OverlayFormatPE,
+		"\x00\x00\x00\x00" + nsisSignature: OverlayFormatNSIS,
+		"zlb\x1a":                          OverlayFormatUnknown,
+		"":                                 OverlayFormatUnknown,
+	} {
+		assert.Equal(t, format, overlayFormat([]byte(head)), "%q", head)
+	}
+}
+
+func TestWinFileInfoGetOverlay(t *testing.T) {
+	ri := testRuntimeImage{overlay: []byte("MSCF")}
+	wf, err := NewWinFileInfo(testWriteFile(t, "setup.exe", ri.build(t)))
+	require.NoError(t, err)
+	overlay, err := wf.GetOverlay()
+	require.NoError(t, err)
+	assert.Equal(t, OverlayFormatCAB, overlay.Format)
+}
@@ -0,0 +1,285 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// pageHashPageSize is the size of the pages covered by page hashes.
+const pageHashPageSize = 4096
+
+var (
+	oidSpcPageHashesV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 3, 1}
+	oidSpcPageHashesV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 3, 2}
+
+	// spcSerializedObjectPageHashes is the SpcSerializedObject class ID used for page hashes.
+	spcSerializedObjectPageHashes = []byte{
+		0xa6, 0xb5, 0x86, 0xd5, 0xb4, 0xa1, 0x24, 0x66,
+		0xae, 0x05, 0xa2, 0x17, 0xda, 0x8e, 0x60, 0xd6,
+	}
+)
+
+// ErrNoPageHashes is returned when page hashes are verified for a file whose signatures have none.
+var ErrNoPageHashes = errors.New("signature has no page hashes")
+
+// PageHashes is the table of per-page hashes signed in SpcPeImageData. The first page is
+// the image headers, the other pages are 4 KiB pages of the section data.
+type PageHashes struct {
+	// Version is 1 for SHA-1 (SpcPageHashesV1) and 2 for SHA-256 (SpcPageHashesV2) tables.
+	Version       int
+	HashAlgorithm crypto.Hash
+	Pages         []PageHash
+}
+
+// PageHash is the hash of the page starting at Offset in the file.
+type PageHash struct {
+	Offset int64
+	Hash   []byte
+}
+
+// PageHashVerification is the result of verifying page hashes.
+type PageHashVerification struct {
+	Version       int
+	HashAlgorithm crypto.Hash
+	// Pages is the number of pages checked.
+	Pages int
+	// Mismatches are the pages whose content does not match the signed hash.
+	Mismatches []*PageMismatch
+}
+
+// Valid reports whether every page matches its signed hash.
+func (v *PageHashVerification) Valid() bool {
+	return len(v.Mismatches) == 0
+}
+
+// PageMismatch is a page that does not match its signed hash.
+type PageMismatch struct {
+	// Offset is the file offset of the page.
+	Offset int64
+	// Section is the section containing the page, "headers" for the first page.
+	Section  string
+	Expected []byte
+	// Computed is empty when the page could not be hashed, see Reason.
+	Computed []byte
+	Reason   string
+}
+
+// spcPeImageData is the data of SpcIndirectDataContent for PE images:
+//
+//	SpcPeImageData ::= SEQUENCE {
+//	    flags  SpcPeImageFlags DEFAULT { includeResources },
+//	    file   [0] EXPLICIT SpcLink OPTIONAL
+//	}
+type spcPeImageData struct {
+	Flags asn1.BitString `asn1:"optional"`
+	File  asn1.RawValue  `asn1:"optional,explicit,tag:0"`
+}
+
+// parsePageHashes extracts the page hashes from a DER encoded SpcPeImageData. Page hashes are
+// stored in the SpcLink moniker as a serialized object. It returns nil when there are none.
+func parsePageHashes(der []byte) (*PageHashes, error) {
+	var data spcPeImageData
+	if _, err := asn1.Unmarshal(der, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse SpcPeImageData: %w", err)
+	}
+	if len(data.File.Bytes) == 0 {
+		return nil, nil
+	}
+	var link asn1.RawValue
+	if _, err := asn1.Unmarshal(data.File.Bytes, &link); err != nil {
+		return nil, fmt.Errorf("failed to parse SpcLink: %w", err)
+	}
+	// moniker [1] IMPLICIT SpcSerializedObject ::= SEQUENCE { classId OCTET STRING, serializedData OCTET STRING }
+	if link.Class != asn1.ClassContextSpecific || link.Tag != 1 {
+		return nil, nil
+	}
+	var classID, serialized []byte
+	rest, err := asn1.Unmarshal(link.Bytes, &classID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SpcSerializedObject: %w", err)
+	}
+	if _, err := asn1.Unmarshal(rest, &serialized); err != nil {
+		return nil, fmt.Errorf("failed to parse SpcSerializedObject: %w", err)
+	}
+	if !bytes.Equal(classID, spcSerializedObjectPageHashes) {
+		return nil, nil
+	}
+
+	var set asn1.RawValue
+	if _, err := asn1.Unmarshal(serialized, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse page hashes: %w", err)
+	}
+	attrs, err := parseAttributes(set.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page hashes: %w", err)
+	}
+	for _, attr := range attrs {
+		ph := &PageHashes{}
+		switch {
+		case attr.Type.Equal(oidSpcPageHashesV1):
+			ph.Version, ph.HashAlgorithm = 1, crypto.SHA1
+		case attr.Type.Equal(oidSpcPageHashesV2):
+			ph.Version, ph.HashAlgorithm = 2, crypto.SHA256
+		default:
+			continue
+		}
+		if len(attr.Values) == 0 {
+			return nil, errors.New("page hashes attribute has no value")
+		}
+		var table []byte
+		if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &table); err != nil {
+			return nil, fmt.Errorf("failed to parse page hash table: %w", err)
+		}
+		entrySize := 4 + ph.HashAlgorithm.Size()
+		if len(table)%entrySize != 0 {
+			return nil, fmt.Errorf("page hash table size %d is not a multiple of %d", len(table), entrySize)
+		}
+		for i := 0; i < len(table); i += entrySize {
+			ph.Pages = append(ph.Pages, PageHash{
+				Offset: int64(binary.LittleEndian.Uint32(table[i:])),
+				Hash:   table[i+4 : i+entrySize],
+			})
+		}
+		return ph, nil
+	}
+	return nil, nil
+}
+
+// verifyPageHashes hashes every page listed in ph the way the signer did and compares
+// it with the signed hash.
+func (p *peImage) verifyPageHashes(ph *PageHashes) (*PageHashVerification, error) {
+	result := &PageHashVerification{Version: ph.Version, HashAlgorithm: ph.HashAlgorithm}
+	sections := make([]*peSectionExtent, 0, len(p.file.Sections))
+	for _, s := range p.file.Sections {
+		if s.Size > 0 {
+			sections = append(sections, &peSectionExtent{name: s.Name, start: int64(s.Offset), end: int64(s.Offset) + int64(s.Size)})
+		}
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].start < sections[j].start })
+
+	for i, page := range ph.Pages {
+		// The table ends with the end offset of the last section and an all zero hash.
+		if i == len(ph.Pages)-1 && i > 0 && isZero(page.Hash) {
+			break
+		}
+		result.Pages++
+		mismatch := &PageMismatch{Offset: page.Offset, Section: "headers", Expected: page.Hash}
+		var section *peSectionExtent
+		if page.Offset != 0 {
+			idx := sort.Search(len(sections), func(i int) bool { return sections[i].end > page.Offset })
+			if idx == len(sections) || page.Offset < sections[idx].start {
+				mismatch.Section = ""
+				mismatch.Reason = "page is outside of the section data"
+				result.Mismatches = append(result.Mismatches, mismatch)
+				continue
+			}
+			section = sections[idx]
+			mismatch.Section = section.name
+		}
+		computed, err := p.pageHash(ph.HashAlgorithm, page.Offset, section)
+		if err != nil {
+			mismatch.Reason = err.Error()
+			result.Mismatches = append(result.Mismatches, mismatch)
+			continue
+		}
+		if !bytes.Equal(computed, page.Hash) {
+			mismatch.Computed = computed
+			mismatch.Reason = "page does not match the signed hash"
+			result.Mismatches = append(result.Mismatches, mismatch)
+		}
+	}
+	return result, nil
+}
+
+type peSectionExtent struct {
+	name       string
+	start, end int64
+}
+
+// pageHash computes the hash of the page at offset in section, or of the header page when
+// section is nil. The header page skips the checksum and the certificate table entry like
+// the image hash, every page is zero padded to the page size.
+func (p *peImage) pageHash(h crypto.Hash, offset int64, section *peSectionExtent) ([]byte, error) {
+	hh := h.New()
+	var length int64
+	if section == nil {
+		checksum := p.checksumOffset()
+		securityEntry := p.dataDirectoryOffset(imageDirectoryEntrySecurity)
+		headersEnd := int64(p.sizeOfHeaders())
+		if headersEnd < securityEntry+8 || headersEnd > p.size {
+			return nil, fmt.Errorf("invalid SizeOfHeaders 0x%x", headersEnd)
+		}
+		for _, r := range [][2]int64{{0, checksum}, {checksum + 4, securityEntry}, {securityEntry + 8, headersEnd}} {
+			if err := p.hashRange(hh, r[0], r[1]); err != nil {
+				return nil, err
+			}
+		}
+		length = headersEnd
+	} else {
+		length = min(pageHashPageSize, section.end-offset)
+		if err := p.hashRange(hh, offset, offset+length); err != nil {
+			return nil, err
+		}
+	}
+	if length < pageHashPageSize {
+		hh.Write(make([]byte, pageHashPageSize-length))
+	}
+	return hh.Sum(nil), nil
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// pageHashes returns the strongest page hash table of the signatures of c that verify, the primary
+// signature wins ties. Nested signatures are unsigned attributes anyone can add, so their own signer
+// signature has to hold. A file digest mismatch does not disqualify a table, telling which pages
+// differ is what page hashes are for.
+func (p *peImage) pageHashes(c *Certificates) (*PageHashes, error) {
+	var best *PageHashes
+	for _, sig := range c.Signatures {
+		if sig.PageHashes == nil || (best != nil && sig.PageHashes.Version <= best.Version) {
+			continue
+		}
+		v, err := p.verifySignedData(sig.signedData)
+		if err != nil {
+			return nil, err
+		}
+		if v.Valid() || (v.Status == SignatureHashMismatch && sig.signedData.verifySigner() == nil) {
+			best = sig.PageHashes
+		}
+	}
+	return best, nil
+}
+
+func verifyPageHashes(path string) (*PageHashVerification, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	certs, err := extractCertificates(img)
+	if err != nil {
+		return nil, err
+	}
+	ph, err := img.pageHashes(certs)
+	if err != nil {
+		return nil, err
+	}
+	if ph == nil {
+		return nil, ErrNoPageHashes
+	}
+	return img.verifyPageHashes(ph)
+}
@@ -0,0 +1,211 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPageHashTable computes the page hash table of an image the way signing tools do.
+func testPageHashTable(t *testing.T, image []byte, h crypto.Hash) []byte {
+	t.Helper()
+	f, err := pe.NewFile(bytes.NewReader(image))
+	require.NoError(t, err)
+	checksum, security := testPEOffsets(image)
+	var headersEnd int
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		headersEnd = int(oh.SizeOfHeaders)
+	case *pe.OptionalHeader64:
+		headersEnd = int(oh.SizeOfHeaders)
+	}
+
+	pageHash := func(data []byte) []byte {
+		hh := h.New()
+		hh.Write(data)
+		hh.Write(make([]byte, pageHashPageSize-len(data)))
+		return hh.Sum(nil)
+	}
+	var table []byte
+	table = binary.LittleEndian.AppendUint32(table, 0)
+	// The header page is padded to the page size from SizeOfHeaders, the skipped
+	// checksum and certificate table entry are not compensated.
+	header := h.New()
+	header.Write(image[:checksum])
+	header.Write(image[checksum+4 : security])
+	header.Write(image[security+8 : headersEnd])
+	header.Write(make([]byte, pageHashPageSize-headersEnd))
+	table = append(table, header.Sum(nil)...)
+	end := 0
+	for _, s := range f.Sections {
+		for off := int(s.Offset); off < int(s.Offset+s.Size); off += pageHashPageSize {
+			table = binary.LittleEndian.AppendUint32(table, uint32(off))
+			table = append(table, pageHash(image[off:min(off+pageHashPageSize, int(s.Offset+s.Size))])...)
+		}
+		end = int(s.Offset + s.Size)
+	}
+	table = binary.LittleEndian.AppendUint32(table, uint32(end))
+	return append(table, make([]byte, h.Size())...)
+}
+
+// testPageHashIndirectData returns SpcIndirectDataContent with a page hash table in SpcPeImageData.
+func testPageHashIndirectData(t *testing.T, h crypto.Hash, imageDigest []byte, version int, table []byte) []byte {
+	t.Helper()
+	oid := oidSpcPageHashesV1
+	if version == 2 {
+		oid = oidSpcPageHashesV2
+	}
+	serialized := derTagged(t, asn1.ClassUniversal, asn1.TagSet, testAttribute(t, oid, derMarshal(t, table)))
+	moniker := derTagged(t, asn1.ClassContextSpecific, 1, derConcat(derMarshal(t, spcSerializedObjectPageHashes), derMarshal(t, serialized)))
+	peImageData := derSequence(t, derMarshal(t, asn1.BitString{}), derTagged(t, asn1.ClassContextSpecific, 0, moniker))
+	digestAlg := derSequence(t, derMarshal(t, testDigestOIDs[h]), derMarshal(t, asn1.NullRawValue))
+	return derSequence(t,
+		derSequence(t, derMarshal(t, oidSpcPeImageData), peImageData),
+		derSequence(t, digestAlg, derMarshal(t, imageDigest)),
+	)
+}
+
+// testMultiPageImage returns an unsigned image spanning several pages.
+func testMultiPageImage(t *testing.T) []byte {
+	t.Helper()
+	b := newTestPE()
+	code := bytes.Repeat([]byte("\x90\x90\xc3 code page "), 1000)
+	b.addSection(".text", code, 0x60000020)
+	b.addSection(".data", []byte("some initialized data"), 0xc0000040)
+	return b.build(t)
+}
+
+// testPageHashedImage returns an image spanning several pages signed with a page hash table.
+func testPageHashedImage(t *testing.T, pki *testPKI, version int) []byte {
+	t.Helper()
+	image := testMultiPageImage(t)
+
+	pageHash, h := crypto.SHA1, crypto.SHA1
+	if version == 2 {
+		pageHash, h = crypto.SHA256, crypto.SHA256
+	}
+	prepared := testPreparePE(image)
+	return testSignPEWith(t, image, testSignOptions{
+		hash:    h,
+		content: testPageHashIndirectData(t, h, testImageDigest(prepared, h), version, testPageHashTable(t, prepared, pageHash)),
+		certs:   []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer:  pki.leaf,
+		key:     pki.leafKey,
+	})
+}
+
+func TestParsePageHashes(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	for _, version := range []int{1, 2} {
+		image := testPageHashedImage(t, pki, version)
+		img := testOpenPE(t, image)
+		certs, err := extractCertificates(img)
+		require.NoError(t, err)
+		ph := certs.Signatures[0].PageHashes
+		require.NotNil(t, ph)
+		assert.Equal(t, version, ph.Version)
+		// headers, 4 pages of .text, 1 page of .data and the terminator
+		require.Len(t, ph.Pages, 7)
+		assert.Equal(t, int64(0), ph.Pages[0].Offset)
+
+		v, err := img.verifyPageHashes(ph)
+		require.NoError(t, err)
+		assert.True(t, v.Valid(), "%+v", v.Mismatches)
+		assert.Equal(t, 6, v.Pages)
+
+		sig, err := img.verifySignature()
+		require.NoError(t, err)
+		assert.True(t, sig.Valid(), sig.Reason)
+	}
+}
+
+func TestVerifyPageHashesReportsPatchedPage(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	image := testPageHashedImage(t, pki, 2)
+	// patch the second page of .text, which starts at 0x200
+	image[0x200+pageHashPageSize+10] ^= 0xff
+
+	img := testOpenPE(t, image)
+	certs, err := extractCertificates(img)
+	require.NoError(t, err)
+	ph, err := img.pageHashes(certs)
+	require.NoError(t, err)
+	v, err := img.verifyPageHashes(ph)
+	require.NoError(t, err)
+	require.Len(t, v.Mismatches, 1)
+	assert.Equal(t, int64(0x200+pageHashPageSize), v.Mismatches[0].Offset)
+	assert.Equal(t, ".text", v.Mismatches[0].Section)
+	assert.NotEmpty(t, v.Mismatches[0].Computed)
+
+	sig, err := img.verifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureHashMismatch, sig.Status)
+}
+
+func TestVerifyPageHashesForgedNestedSignature(t *testing.T) {
+	pki, forger := newTestPKI(t, "Test Publisher"), newTestPKI(t, "Forger")
+	image := testMultiPageImage(t)
+	prepared := testPreparePE(image)
+	// A stronger table that flags the second page of .text, signed with a key that does not belong
+	// to the certificate it names.
+	patched := append([]byte{}, prepared...)
+	patched[0x200+pageHashPageSize+10] ^= 0xff
+	nested := buildTestSignedData(t, testSignOptions{
+		hash:    crypto.SHA256,
+		content: testPageHashIndirectData(t, crypto.SHA256, testImageDigest(prepared, crypto.SHA256), 2, testPageHashTable(t, patched, crypto.SHA256)),
+		certs:   []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer:  pki.leaf,
+		key:     forger.leafKey,
+	})
+	image = testSignPEWith(t, image, testSignOptions{
+		hash:    crypto.SHA1,
+		content: testPageHashIndirectData(t, crypto.SHA1, testImageDigest(prepared, crypto.SHA1), 1, testPageHashTable(t, prepared, crypto.SHA1)),
+		certs:   []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer:  pki.leaf,
+		key:     pki.leafKey,
+		unsigned: func([]byte) [][]byte {
+			return [][]byte{testAttribute(t, oidNestedSignature, nested)}
+		},
+	})
+
+	img := testOpenPE(t, image)
+	certs, err := extractCertificates(img)
+	require.NoError(t, err)
+	require.Len(t, certs.Signatures, 2)
+	require.NotNil(t, certs.Signatures[1].PageHashes)
+	ph, err := img.pageHashes(certs)
+	require.NoError(t, err)
+	require.NotNil(t, ph)
+	assert.Equal(t, 1, ph.Version)
+	v, err := img.verifyPageHashes(ph)
+	require.NoError(t, err)
+	assert.True(t, v.Valid(), "%+v", v.Mismatches)
+
+	// The forged table would have flagged a clean page.
+	v, err = img.verifyPageHashes(certs.Signatures[1].PageHashes)
+	require.NoError(t, err)
+	assert.Len(t, v.Mismatches, 1)
+}
+
+func TestVerifyPageHashesFromFile(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	wf, err := NewWinFileInfo(testWriteFile(t, "paged.sys", testPageHashedImage(t, pki, 1)))
+	require.NoError(t, err)
+	v, err := wf.VerifyPageHashes()
+	require.NoError(t, err)
+	assert.True(t, v.Valid())
+	assert.Equal(t, crypto.SHA1, v.HashAlgorithm)
+
+	wf, err = NewWinFileInfo(testWriteFile(t, "plain.exe", testSignPE(t, testUnsignedImage(t, false), pki, crypto.SHA256)))
+	require.NoError(t, err)
+	_, err = wf.VerifyPageHashes()
+	assert.True(t, errors.Is(err, ErrNoPageHashes))
+}
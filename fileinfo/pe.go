@@ -0,0 +1,283 @@
+package fileinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// Data directory indexes used by the package.
+const (
+	imageDirectoryEntryExport      = 0  // IMAGE_DIRECTORY_ENTRY_EXPORT
+	imageDirectoryEntryImport      = 1  // IMAGE_DIRECTORY_ENTRY_IMPORT
+	imageDirectoryEntryResource    = 2  // IMAGE_DIRECTORY_ENTRY_RESOURCE
+	imageDirectoryEntrySecurity    = 4  // IMAGE_DIRECTORY_ENTRY_SECURITY
+	imageDirectoryEntryDebug       = 6  // IMAGE_DIRECTORY_ENTRY_DEBUG
+	imageDirectoryEntryLoadConfig  = 10 // IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG
+	imageDirectoryEntryBoundImport = 11 // IMAGE_DIRECTORY_ENTRY_BOUND_IMPORT
+	imageDirectoryEntryDelayImport = 13 // IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT
+	imageDirectoryEntryCLR         = 14 // IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR
+)
+
+// peImage is a PE file parsed with debug/pe together with the underlying reader.
+// It gives access to raw header offsets that debug/pe does not expose, which are
+// needed for Authenticode hashing and for reading data outside of sections.
+type peImage struct {
+	r    io.ReaderAt
+	size int64
+	file *pe.File
+	// optionalHeaderOffset is the file offset of the optional header.
+	optionalHeaderOffset int64
+	closer               io.Closer
+}
+
+// openPEImage parses the PE headers read from r, size is the total file size.
+func openPEImage(r io.ReaderAt, size int64) (*peImage, error) {
+	file, err := pe.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PE file: %w", err)
+	}
+	if file.OptionalHeader == nil {
+		return nil, errors.New("PE file has no optional header")
+	}
+	var lfanew [4]byte
+	if _, err := r.ReadAt(lfanew[:], 0x3c); err != nil {
+		return nil, fmt.Errorf("failed to read PE header offset: %w", err)
+	}
+	// PE signature (4 bytes) followed by the COFF file header (20 bytes)
+	optionalHeaderOffset := int64(binary.LittleEndian.Uint32(lfanew[:])) + 4 + 20
+	return &peImage{
+		r:                    r,
+		size:                 size,
+		file:                 file,
+		optionalHeaderOffset: optionalHeaderOffset,
+	}, nil
+}
+
+// openPEImageFile opens the file at path and parses its PE headers.
+// The caller must close the returned image.
+func openPEImageFile(path string) (*peImage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	img, err := openPEImage(file, info.Size())
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	img.closer = file
+	return img, nil
+}
+
+// Close releases the underlying file, if the image was opened from a path.
+func (p *peImage) Close() error {
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+// is64 reports whether the image has a PE32+ optional header.
+func (p *peImage) is64() bool {
+	_, ok := p.file.OptionalHeader.(*pe.OptionalHeader64)
+	return ok
+}
+
+// dataDirectory returns the data directory entry at index and whether it is present.
+func (p *peImage) dataDirectory(index int) (pe.DataDirectory, bool) {
+	var dirs []pe.DataDirectory
+	switch oh := p.file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dirs = oh.DataDirectory[:min(oh.NumberOfRvaAndSizes, uint32(len(oh.DataDirectory)))]
+	case *pe.OptionalHeader64:
+		dirs = oh.DataDirectory[:min(oh.NumberOfRvaAndSizes, uint32(len(oh.DataDirectory)))]
+	}
+	if index >= len(dirs) {
+		return pe.DataDirectory{}, false
+	}
+	return dirs[index], true
+}
+
+// checksumOffset returns the file offset of the CheckSum field of the optional header.
+func (p *peImage) checksumOffset() int64 {
+	return p.optionalHeaderOffset + 64
+}
+
+// dataDirectoryOffset returns the file offset of the data directory entry at index.
+func (p *peImage) dataDirectoryOffset(index int) int64 {
+	if p.is64() {
+		return p.optionalHeaderOffset + 112 + int64(index)*8
+	}
+	return p.optionalHeaderOffset + 96 + int64(index)*8
+}
+
+// sizeOfHeaders returns the SizeOfHeaders field of the optional header.
+func (p *peImage) sizeOfHeaders() uint32 {
+	switch oh := p.file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		return oh.SizeOfHeaders
+	case *pe.OptionalHeader64:
+		return oh.SizeOfHeaders
+	}
+	return 0
+}
+
+// imageBase returns the preferred load address of the image.
+func (p *peImage) imageBase() uint64 {
+	switch oh := p.file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		return uint64(oh.ImageBase)
+	case *pe.OptionalHeader64:
+		return oh.ImageBase
+	}
+	return 0
+}
+
+// readAt reads exactly length bytes at the given file offset.
+func (p *peImage) readAt(offset int64, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 || offset+length > p.size {
+		return nil, fmt.Errorf("range 0x%x+0x%x is outside of the file", offset, length)
+	}
+	buf := make([]byte, length)
+	if _, err := p.r.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// rvaToOffset maps a relative virtual address to the file offset of its raw data.
+func (p *peImage) rvaToOffset(rva uint32) (int64, error) {
+	if rva < p.sizeOfHeaders() {
+		return int64(rva), nil
+	}
+	for _, s := range p.file.Sections {
+		if rva >= s.VirtualAddress && rva-s.VirtualAddress < s.Size {
+			return int64(s.Offset) + int64(rva-s.VirtualAddress), nil
+		}
+	}
+	return 0, fmt.Errorf("RVA 0x%x is not backed by file data", rva)
+}
+
+// readRVA reads length bytes at the given relative virtual address.
+func (p *peImage) readRVA(rva uint32, length uint32) ([]byte, error) {
+	offset, err := p.rvaToOffset(rva)
+	if err != nil {
+		return nil, err
+	}
+	return p.readAt(offset, int64(length))
+}
+
+// readStringRVA reads the NUL terminated string at the given relative virtual address,
+// at most maxLength bytes long.
+func (p *peImage) readStringRVA(rva uint32, maxLength int) (string, error) {
+	offset, err := p.rvaToOffset(rva)
+	if err != nil {
+		return "", err
+	}
+	if offset < 0 || offset >= p.size {
+		return "", fmt.Errorf("string at RVA 0x%x is outside of the file", rva)
+	}
+	data, err := p.readAt(offset, min(int64(maxLength)+1, p.size-offset))
+	if err != nil {
+		return "", err
+	}
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", fmt.Errorf("string at RVA 0x%x is not terminated", rva)
+	}
+	return string(data[:end]), nil
+}
+
+// certificateTable returns the raw attribute certificate table and its file offset.
+// It returns nil data when the image has no certificate table.
+func (p *peImage) certificateTable() ([]byte, int64, error) {
+	dir, ok := p.dataDirectory(imageDirectoryEntrySecurity)
+	if !ok || dir.Size == 0 {
+		return nil, 0, nil
+	}
+	// The security directory holds a file offset rather than an RVA.
+	data, err := p.readAt(int64(dir.VirtualAddress), int64(dir.Size))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read certificate table: %w", err)
+	}
+	return data, int64(dir.VirtualAddress), nil
+}
+
+// hasSection reports whether the image has a section with the given name.
+func (p *peImage) hasSection(name string) bool {
+	return slices.ContainsFunc(p.file.Sections, func(s *pe.Section) bool {
+		return s.Name == name
+	})
+}
+
+// scanChunkSize is the size of the chunks scanSections reads.
+const scanChunkSize = 1 << 20
+
+// scanSections searches the raw data of the sections for the markers and returns the file offset
+// of the first occurrence of each marker found.
+func (p *peImage) scanSections(markers ...string) (map[string]int64, error) {
+	found := map[string]int64{}
+	for _, s := range p.file.Sections {
+		if err := p.scanRange(int64(s.Offset), int64(s.Offset)+int64(s.Size), markers, found); err != nil {
+			return nil, fmt.Errorf("failed to read section %s: %w", s.Name, err)
+		}
+	}
+	return found, nil
+}
+
+// scanRange searches the file range for the markers not found yet and adds their file offsets to
+// found. Chunks overlap by the longest marker, so markers crossing a chunk boundary are found too.
+func (p *peImage) scanRange(start, end int64, markers []string, found map[string]int64) error {
+	longest := 0
+	for _, m := range markers {
+		longest = max(longest, len(m))
+	}
+	end = min(end, p.size)
+	for pos := start; pos < end && len(found) < len(markers); {
+		length := min(scanChunkSize, end-pos)
+		data, err := p.readAt(pos, length)
+		if err != nil {
+			return err
+		}
+		for _, m := range markers {
+			if _, ok := found[m]; ok {
+				continue
+			}
+			if i := bytes.Index(data, []byte(m)); i >= 0 {
+				found[m] = pos + int64(i)
+			}
+		}
+		if pos+length >= end {
+			break
+		}
+		pos += length - int64(longest) + 1
+	}
+	return nil
+}
+
+// overlayRange returns the file range of the data appended after the last section. The certificate
+// table signing appends at the end of the file is not part of the overlay.
+func (p *peImage) overlayRange() (start, end int64) {
+	start = int64(p.sizeOfHeaders())
+	for _, s := range p.file.Sections {
+		if s.Size > 0 {
+			start = max(start, int64(s.Offset)+int64(s.Size))
+		}
+	}
+	start, end = min(start, p.size), p.size
+	if dir, ok := p.dataDirectory(imageDirectoryEntrySecurity); ok && dir.Size > 0 && int64(dir.VirtualAddress) >= start {
+		end = min(end, int64(dir.VirtualAddress))
+	}
+	return start, end
+}
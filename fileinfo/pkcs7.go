@@ -0,0 +1,419 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+	"unicode/utf16"
+)
+
+// Object identifiers used by PKCS#7 SignedData and Authenticode.
+var (
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSpcIndirectData = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidSpcSpOpusInfo   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 12}
+
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidDigestMD5    = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 5}
+	oidDigestSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// Attribute is a PKCS#9 attribute attached to a SignerInfo.
+// Values holds the DER encoded members of the attribute value set.
+type Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue
+}
+
+// SpcSpOpusInfo is the Authenticode publisher information attribute
+// (1.3.6.1.4.1.311.2.1.12) shown by Windows in the UAC and signature dialogs.
+type SpcSpOpusInfo struct {
+	ProgramName string
+	MoreInfoURL string
+}
+
+// SignerInfo describes the signer of a PKCS#7 SignedData structure.
+type SignerInfo struct {
+	// Certificate is the leaf signing certificate located among the embedded certificates
+	// by issuer and serial number (or subject key identifier). It is nil when the
+	// signing certificate is not embedded.
+	Certificate *x509.Certificate
+	// Issuer and SerialNumber identify the signing certificate.
+	Issuer       pkix.Name
+	SerialNumber *big.Int
+	// SubjectKeyID identifies the signing certificate for version 3 signer infos.
+	SubjectKeyID []byte
+	// DigestAlgorithm is the hash used for the signed content; zero when the
+	// algorithm is not recognized, see DigestAlgorithmOID.
+	DigestAlgorithm    crypto.Hash
+	DigestAlgorithmOID asn1.ObjectIdentifier
+	// ContentType is the type of the signed content, SPC_INDIRECT_DATA for Authenticode.
+	ContentType asn1.ObjectIdentifier
+	// MessageDigest is the digest of the signed content from the signed attributes.
+	MessageDigest []byte
+	// SignedAttributes are all authenticated attributes of the signer.
+	SignedAttributes []Attribute
+	// OpusInfo is the SpcSpOpusInfo signed attribute, nil when not present.
+	OpusInfo *SpcSpOpusInfo
+	// SigningTime is the signer's self-reported signing time attribute, zero when not present.
+	// Unlike a Timestamp it is not vouched for by a third party.
+	SigningTime time.Time
+	// UnsignedAttributes are all unauthenticated attributes of the signer.
+	UnsignedAttributes []Attribute
+	// Timestamp is the countersignature timestamp of the signature, nil when the signature is not timestamped.
+	Timestamp *Timestamp
+	// TimestampErr is the error parsing the unsigned attributes or the timestamp, Timestamp is nil then.
+	TimestampErr error
+
+	rawIssuer []byte
+	// rawSignedAttributes is the DER encoding of the implicitly tagged signed attributes.
+	rawSignedAttributes []byte
+	signature           []byte
+}
+
+// Attribute returns the first signed attribute with the given type or nil.
+func (s *SignerInfo) Attribute(oid asn1.ObjectIdentifier) *Attribute {
+	return findAttribute(s.SignedAttributes, oid)
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	SID                       asn1.RawValue
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// signedData is a parsed PKCS#7 SignedData with a single signer, as used by Authenticode.
+type signedData struct {
+	certificates []*x509.Certificate
+	contentType  asn1.ObjectIdentifier
+	// content is the DER encoding of the encapsulated content, e.g. SpcIndirectDataContent.
+	content []byte
+	signer  *SignerInfo
+}
+
+// parseSignedData parses a DER encoded PKCS#7 ContentInfo wrapping SignedData.
+// Trailing bytes, such as the WIN_CERTIFICATE alignment padding, are ignored.
+func parseSignedData(data []byte) (*signedData, error) {
+	var ci pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(data, &ci); err != nil {
+		return nil, fmt.Errorf("failed to parse content info: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unexpected content type %v", ci.ContentType)
+	}
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("failed to parse signed data: %w", err)
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, errors.New("signed data has no signer info")
+	}
+
+	certs, err := parseCertificateSet(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := parseSignerInfo(&sd.SignerInfos[0], certs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signer info: %w", err)
+	}
+	if signer.ContentType == nil {
+		signer.ContentType = sd.ContentInfo.ContentType
+	}
+	return &signedData{
+		certificates: certs,
+		contentType:  sd.ContentInfo.ContentType,
+		content:      sd.ContentInfo.Content.Bytes,
+		signer:       signer,
+	}, nil
+}
+
+// parseCertificateSet parses the implicitly tagged SET OF CertificateChoices.
+// Attribute certificates and other non X.509 choices are skipped.
+func parseCertificateSet(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for len(data) > 0 {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(data, &raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate set: %w", err)
+		}
+		data = rest
+		if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagSequence {
+			continue
+		}
+		cert, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			// A single malformed certificate should not hide the rest of the bag.
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+func parseSignerInfo(si *pkcs7SignerInfo, certs []*x509.Certificate) (*SignerInfo, error) {
+	signer := &SignerInfo{
+		DigestAlgorithmOID:  si.DigestAlgorithm.Algorithm,
+		DigestAlgorithm:     hashForOID(si.DigestAlgorithm.Algorithm),
+		rawSignedAttributes: si.AuthenticatedAttributes.FullBytes,
+		signature:           si.EncryptedDigest,
+	}
+
+	switch {
+	case si.SID.Class == asn1.ClassUniversal && si.SID.Tag == asn1.TagSequence:
+		var ias pkcs7IssuerAndSerial
+		if _, err := asn1.Unmarshal(si.SID.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("failed to parse issuer and serial number: %w", err)
+		}
+		var rdn pkix.RDNSequence
+		if _, err := asn1.Unmarshal(ias.Issuer.FullBytes, &rdn); err != nil {
+			return nil, fmt.Errorf("failed to parse issuer: %w", err)
+		}
+		signer.Issuer.FillFromRDNSequence(&rdn)
+		signer.SerialNumber = ias.SerialNumber
+		signer.rawIssuer = ias.Issuer.FullBytes
+	case si.SID.Class == asn1.ClassContextSpecific && si.SID.Tag == 0:
+		signer.SubjectKeyID = si.SID.Bytes
+	default:
+		return nil, fmt.Errorf("unsupported signer identifier tag %d", si.SID.Tag)
+	}
+	signer.Certificate = findSignerCertificate(signer, certs)
+
+	attrs, err := parseAttributes(si.AuthenticatedAttributes.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signed attributes: %w", err)
+	}
+	signer.SignedAttributes = attrs
+
+	if attr := findAttribute(attrs, oidAttributeContentType); attr != nil && len(attr.Values) > 0 {
+		var ct asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &ct); err != nil {
+			return nil, fmt.Errorf("failed to parse content type attribute: %w", err)
+		}
+		signer.ContentType = ct
+	}
+	if attr := findAttribute(attrs, oidAttributeMessageDigest); attr != nil && len(attr.Values) > 0 {
+		var digest []byte
+		if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &digest); err != nil {
+			return nil, fmt.Errorf("failed to parse message digest attribute: %w", err)
+		}
+		signer.MessageDigest = digest
+	}
+	if attr := findAttribute(attrs, oidSpcSpOpusInfo); attr != nil && len(attr.Values) > 0 {
+		opus, err := parseOpusInfo(attr.Values[0].FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SpcSpOpusInfo: %w", err)
+		}
+		signer.OpusInfo = opus
+	}
+	if attr := findAttribute(attrs, oidAttributeSigningTime); attr != nil && len(attr.Values) > 0 {
+		if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &signer.SigningTime); err != nil {
+			return nil, fmt.Errorf("failed to parse signing time attribute: %w", err)
+		}
+	}
+
+	// Unsigned attributes are not covered by the signature, anyone can add them to a signed file.
+	// A malformed one must not fail the signature, the signer then reads as not timestamped.
+	unsigned, err := parseAttributes(si.UnauthenticatedAttributes.Bytes)
+	if err != nil {
+		signer.TimestampErr = fmt.Errorf("failed to parse unsigned attributes: %w", err)
+		return signer, nil
+	}
+	signer.UnsignedAttributes = unsigned
+	if signer.Timestamp, err = parseTimestamp(signer, certs); err != nil {
+		signer.TimestampErr = fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+	return signer, nil
+}
+
+// findSignerCertificate returns the certificate identified by the signer's
+// issuer and serial number or subject key identifier.
+func findSignerCertificate(signer *SignerInfo, certs []*x509.Certificate) *x509.Certificate {
+	for _, cert := range certs {
+		if signer.SerialNumber != nil {
+			if cert.SerialNumber.Cmp(signer.SerialNumber) == 0 && bytes.Equal(cert.RawIssuer, signer.rawIssuer) {
+				return cert
+			}
+			continue
+		}
+		if len(signer.SubjectKeyID) > 0 && bytes.Equal(cert.SubjectKeyId, signer.SubjectKeyID) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// parseAttributes parses the content of an implicitly tagged SET OF Attribute.
+func parseAttributes(data []byte) ([]Attribute, error) {
+	var attrs []Attribute
+	for len(data) > 0 {
+		var a pkcs7Attribute
+		rest, err := asn1.Unmarshal(data, &a)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+
+		attr := Attribute{Type: a.Type}
+		values := a.Values.Bytes
+		for len(values) > 0 {
+			var v asn1.RawValue
+			values, err = asn1.Unmarshal(values, &v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse value of attribute %v: %w", a.Type, err)
+			}
+			attr.Values = append(attr.Values, v)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+func findAttribute(attrs []Attribute, oid asn1.ObjectIdentifier) *Attribute {
+	for i := range attrs {
+		if attrs[i].Type.Equal(oid) {
+			return &attrs[i]
+		}
+	}
+	return nil
+}
+
+// parseOpusInfo parses SpcSpOpusInfo:
+//
+//	SpcSpOpusInfo ::= SEQUENCE {
+//	    programName  [0] EXPLICIT SpcString OPTIONAL,
+//	    moreInfo     [1] EXPLICIT SpcLink OPTIONAL
+//	}
+func parseOpusInfo(der []byte) (*SpcSpOpusInfo, error) {
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &seq); err != nil {
+		return nil, err
+	}
+	if seq.Class != asn1.ClassUniversal || seq.Tag != asn1.TagSequence {
+		return nil, errors.New("SpcSpOpusInfo is not a sequence")
+	}
+	info := &SpcSpOpusInfo{}
+	rest := seq.Bytes
+	for len(rest) > 0 {
+		var field asn1.RawValue
+		var err error
+		rest, err = asn1.Unmarshal(rest, &field)
+		if err != nil {
+			return nil, err
+		}
+		if field.Class != asn1.ClassContextSpecific {
+			continue
+		}
+		var inner asn1.RawValue
+		if _, err := asn1.Unmarshal(field.Bytes, &inner); err != nil {
+			return nil, err
+		}
+		switch field.Tag {
+		case 0:
+			info.ProgramName = decodeSpcString(inner)
+		case 1:
+			info.MoreInfoURL = decodeSpcLink(inner)
+		}
+	}
+	return info, nil
+}
+
+// decodeSpcString decodes SpcString ::= CHOICE { unicode [0] IMPLICIT BMPSTRING, ascii [1] IMPLICIT IA5STRING }.
+func decodeSpcString(v asn1.RawValue) string {
+	if v.Class != asn1.ClassContextSpecific {
+		return ""
+	}
+	switch v.Tag {
+	case 0:
+		return decodeBMPString(v.Bytes)
+	case 1:
+		return string(v.Bytes)
+	}
+	return ""
+}
+
+// decodeSpcLink decodes SpcLink ::= CHOICE { url [0] IMPLICIT IA5STRING, moniker [1] ..., file [2] EXPLICIT SpcString }.
+func decodeSpcLink(v asn1.RawValue) string {
+	if v.Class != asn1.ClassContextSpecific {
+		return ""
+	}
+	switch v.Tag {
+	case 0:
+		return string(v.Bytes)
+	case 2:
+		var file asn1.RawValue
+		if _, err := asn1.Unmarshal(v.Bytes, &file); err != nil {
+			return ""
+		}
+		return decodeSpcString(file)
+	}
+	return ""
+}
+
+// decodeBMPString decodes big-endian UTF-16 as used by the ASN.1 BMPString type.
+func decodeBMPString(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(u))
+}
+
+// hashForOID maps a digest algorithm identifier to a crypto.Hash.
+// It returns zero for unknown algorithms.
+func hashForOID(oid asn1.ObjectIdentifier) crypto.Hash {
+	switch {
+	case oid.Equal(oidDigestMD5):
+		return crypto.MD5
+	case oid.Equal(oidDigestSHA1):
+		return crypto.SHA1
+	case oid.Equal(oidDigestSHA256):
+		return crypto.SHA256
+	case oid.Equal(oidDigestSHA384):
+		return crypto.SHA384
+	case oid.Equal(oidDigestSHA512):
+		return crypto.SHA512
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignedDataIdentifiesLeafSigner(t *testing.T) {
+	pki := newTestPKI(t, "Leaf Publisher")
+	der := buildTestSignedData(t, testSignOptions{
+		hash:    crypto.SHA256,
+		content: testSpcIndirectData(t, crypto.SHA256, make([]byte, 32)),
+		// roots and intermediates come first on purpose
+		certs:  []*x509.Certificate{pki.root, pki.intermediate, pki.leaf},
+		signer: pki.leaf,
+		key:    pki.leafKey,
+		opus:   &SpcSpOpusInfo{ProgramName: "Test Program", MoreInfoURL: "https://example.com"},
+	})
+
+	sd, err := parseSignedData(der)
+	require.NoError(t, err)
+	require.Len(t, sd.certificates, 3)
+
+	signer := sd.signer
+	require.NotNil(t, signer)
+	require.NotNil(t, signer.Certificate)
+	assert.Equal(t, pki.leaf.Raw, signer.Certificate.Raw)
+	assert.Equal(t, "Test Code Signing CA", signer.Issuer.CommonName)
+	assert.Equal(t, 0, signer.SerialNumber.Cmp(pki.leaf.SerialNumber))
+	assert.Equal(t, crypto.SHA256, signer.DigestAlgorithm)
+	assert.True(t, signer.ContentType.Equal(oidSpcIndirectData))
+	assert.Len(t, signer.MessageDigest, 32)
+	assert.Len(t, signer.SignedAttributes, 3)
+	assert.NotNil(t, signer.Attribute(oidSpcSpOpusInfo))
+
+	require.NotNil(t, signer.OpusInfo)
+	assert.Equal(t, "Test Program", signer.OpusInfo.ProgramName)
+	assert.Equal(t, "https://example.com", signer.OpusInfo.MoreInfoURL)
+}
+
+func TestParseSignedDataRejectsGarbage(t *testing.T) {
+	_, err := parseSignedData([]byte{0x30, 0x82, 0x01, 0x00, 0x01})
+	require.Error(t, err)
+}
+
+func TestCertificatesSignedByIgnoresNonSigners(t *testing.T) {
+	pki := newTestPKI(t, "Leaf Publisher")
+	// A self-signed certificate with the same common name must not satisfy SignedBy.
+	impostor, _ := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Impostor"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+
+	der := buildTestSignedData(t, testSignOptions{
+		content: testSpcIndirectData(t, crypto.SHA256, make([]byte, 32)),
+		certs:   []*x509.Certificate{impostor, pki.intermediate, pki.leaf},
+		signer:  pki.leaf,
+		key:     pki.leafKey,
+	})
+	certs, err := parseCertificateTable(testWinCertificate(der))
+	require.NoError(t, err)
+	require.Len(t, certs.Certificates, 3)
+
+	assert.True(t, certs.SignedBy("leaf publisher"))
+	assert.False(t, certs.SignedBy("Impostor"))
+	assert.False(t, certs.SignedBy("Test Code Signing CA"))
+
+	assert.True(t, certs.ValidAtSignedBy("Leaf Publisher", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, certs.ValidAtSignedBy("Leaf Publisher", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestCertificatesEmpty(t *testing.T) {
+	certs, err := parseCertificateTable(nil)
+	require.NoError(t, err)
+	assert.Nil(t, certs.Signer)
+	assert.False(t, certs.SignedBy("anyone"))
+}
+
+// testWinCertificate wraps PKCS#7 data into an 8-byte aligned WIN_CERTIFICATE entry.
+func testWinCertificate(pkcs7 []byte) []byte {
+	length := 8 + len(pkcs7)
+	entry := make([]byte, (length+7)&^7)
+	binary.LittleEndian.PutUint32(entry[0:], uint32(length))
+	binary.LittleEndian.PutUint16(entry[4:], 0x0200) // WIN_CERT_REVISION_2_0
+	binary.LittleEndian.PutUint16(entry[6:], 0x0002) // WIN_CERT_TYPE_PKCS_SIGNED_DATA
+	copy(entry[8:], pkcs7)
+	return entry
+}
@@ -0,0 +1,326 @@
+package fileinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// ResourceType is a predefined resource type ID (RT_*).
+// https://learn.microsoft.com/en-us/windows/win32/menurc/resource-types
+type ResourceType uint16
+
+const (
+	ResourceTypeCursor       ResourceType = 1  // RT_CURSOR
+	ResourceTypeBitmap       ResourceType = 2  // RT_BITMAP
+	ResourceTypeIcon         ResourceType = 3  // RT_ICON
+	ResourceTypeMenu         ResourceType = 4  // RT_MENU
+	ResourceTypeDialog       ResourceType = 5  // RT_DIALOG
+	ResourceTypeString       ResourceType = 6  // RT_STRING
+	ResourceTypeFontDir      ResourceType = 7  // RT_FONTDIR
+	ResourceTypeFont         ResourceType = 8  // RT_FONT
+	ResourceTypeAccelerator  ResourceType = 9  // RT_ACCELERATOR
+	ResourceTypeRCData       ResourceType = 10 // RT_RCDATA
+	ResourceTypeMessageTable ResourceType = 11 // RT_MESSAGETABLE
+	ResourceTypeGroupCursor  ResourceType = 12 // RT_GROUP_CURSOR
+	ResourceTypeGroupIcon    ResourceType = 14 // RT_GROUP_ICON
+	ResourceTypeVersion      ResourceType = 16 // RT_VERSION
+	ResourceTypeDlgInclude   ResourceType = 17 // RT_DLGINCLUDE
+	ResourceTypePlugPlay     ResourceType = 19 // RT_PLUGPLAY
+	ResourceTypeVxD          ResourceType = 20 // RT_VXD
+	ResourceTypeAniCursor    ResourceType = 21 // RT_ANICURSOR
+	ResourceTypeAniIcon      ResourceType = 22 // RT_ANIICON
+	ResourceTypeHTML         ResourceType = 23 // RT_HTML
+	ResourceTypeManifest     ResourceType = 24 // RT_MANIFEST
+)
+
+var resourceTypeNames = map[ResourceType]string{
+	ResourceTypeCursor:       "RT_CURSOR",
+	ResourceTypeBitmap:       "RT_BITMAP",
+	ResourceTypeIcon:         "RT_ICON",
+	ResourceTypeMenu:         "RT_MENU",
+	ResourceTypeDialog:       "RT_DIALOG",
+	ResourceTypeString:       "RT_STRING",
+	ResourceTypeFontDir:      "RT_FONTDIR",
+	ResourceTypeFont:         "RT_FONT",
+	ResourceTypeAccelerator:  "RT_ACCELERATOR",
+	ResourceTypeRCData:       "RT_RCDATA",
+	ResourceTypeMessageTable: "RT_MESSAGETABLE",
+	ResourceTypeGroupCursor:  "RT_GROUP_CURSOR",
+	ResourceTypeGroupIcon:    "RT_GROUP_ICON",
+	ResourceTypeVersion:      "RT_VERSION",
+	ResourceTypeDlgInclude:   "RT_DLGINCLUDE",
+	ResourceTypePlugPlay:     "RT_PLUGPLAY",
+	ResourceTypeVxD:          "RT_VXD",
+	ResourceTypeAniCursor:    "RT_ANICURSOR",
+	ResourceTypeAniIcon:      "RT_ANIICON",
+	ResourceTypeHTML:         "RT_HTML",
+	ResourceTypeManifest:     "RT_MANIFEST",
+}
+
+func (t ResourceType) String() string {
+	if name, ok := resourceTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("#%d", uint16(t))
+}
+
+// ID returns the resource ID of the predefined type.
+func (t ResourceType) ID() ResourceID {
+	return ResourceID{ID: uint16(t)}
+}
+
+// ResourceID identifies a resource type or name, either by numeric ID or by string.
+// Name is empty for numeric IDs.
+type ResourceID struct {
+	ID   uint16
+	Name string
+}
+
+// ResourceIntID returns the numeric resource ID, the equivalent of MAKEINTRESOURCE.
+func ResourceIntID(id uint16) ResourceID {
+	return ResourceID{ID: id}
+}
+
+// ResourceName returns the resource ID for a string name.
+func ResourceName(name string) ResourceID {
+	return ResourceID{Name: name}
+}
+
+// IsNamed reports whether the ID is a string name rather than a number.
+func (id ResourceID) IsNamed() bool {
+	return id.Name != ""
+}
+
+// Equal reports whether both IDs identify the same resource, names are compared case-insensitively
+// as the resource compiler and FindResource do.
+func (id ResourceID) Equal(other ResourceID) bool {
+	if id.IsNamed() || other.IsNamed() {
+		return strings.EqualFold(id.Name, other.Name)
+	}
+	return id.ID == other.ID
+}
+
+// String formats named IDs as the name and numeric IDs as "#<id>", the notation used by FindResource.
+func (id ResourceID) String() string {
+	if id.IsNamed() {
+		return id.Name
+	}
+	return fmt.Sprintf("#%d", id.ID)
+}
+
+// Resource is a data entry of the PE resource directory.
+type Resource struct {
+	Type     ResourceID
+	Name     ResourceID
+	Language uint16
+	// Size is the size of the resource data in bytes.
+	Size uint32
+	// CodePage is the code page declared for the data, it is usually 0.
+	CodePage uint32
+	// DataRVA is the relative virtual address of the resource data.
+	DataRVA uint32
+}
+
+// TypeName returns the RT_* name of predefined types or the type name or ID otherwise.
+func (r Resource) TypeName() string {
+	if r.Type.IsNamed() {
+		return r.Type.Name
+	}
+	return ResourceType(r.Type.ID).String()
+}
+
+// Resources is the list of resources in directory order.
+type Resources []Resource
+
+// OfType returns the resources of the given type.
+func (rs Resources) OfType(typ ResourceID) Resources {
+	var found Resources
+	for _, r := range rs {
+		if r.Type.Equal(typ) {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// Find returns the resources with the given type and name in every language.
+func (rs Resources) Find(typ, name ResourceID) Resources {
+	var found Resources
+	for _, r := range rs.OfType(typ) {
+		if r.Name.Equal(name) {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// ReadResources lists every resource of the PE image in r, size is the total image size.
+// It returns an empty list when the image has no resource directory.
+func ReadResources(r io.ReaderAt, size int64) (Resources, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.resources()
+}
+
+// ReadResourceData reads the raw data of a resource listed by ReadResources.
+func ReadResourceData(r io.ReaderAt, size int64, resource Resource) ([]byte, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.readResource(resource)
+}
+
+func readResourcesFile(path string) (Resources, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.resources()
+}
+
+func readResourceDataFile(path string, resource Resource) ([]byte, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.readResource(resource)
+}
+
+// resourceTree walks the three levels (type, name, language) of the resource directory.
+type resourceTree struct {
+	p       *peImage
+	data    []byte
+	visited map[uint32]bool
+	leaves  Resources
+}
+
+// resources returns every data entry of the resource directory in directory order.
+// It returns nil when the image has no resources.
+func (p *peImage) resources() (Resources, error) {
+	dir, ok := p.dataDirectory(imageDirectoryEntryResource)
+	if !ok || dir.Size == 0 {
+		return nil, nil
+	}
+	data, err := p.readRVA(dir.VirtualAddress, dir.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource directory: %w", err)
+	}
+	tree := &resourceTree{p: p, data: data, visited: map[uint32]bool{}}
+	if err := tree.walk(0, 0, Resource{}); err != nil {
+		return nil, err
+	}
+	return tree.leaves, nil
+}
+
+// walk reads the IMAGE_RESOURCE_DIRECTORY at offset. Directories are only visited once,
+// so that a malformed tree pointing back at its parents cannot loop.
+func (t *resourceTree) walk(offset uint32, level int, leaf Resource) error {
+	if t.visited[offset] {
+		return fmt.Errorf("resource directory at 0x%x is referenced more than once", offset)
+	}
+	t.visited[offset] = true
+	if int(offset)+16 > len(t.data) {
+		return fmt.Errorf("resource directory at 0x%x is outside of the resource section", offset)
+	}
+	named := int(binary.LittleEndian.Uint16(t.data[offset+12:]))
+	ids := int(binary.LittleEndian.Uint16(t.data[offset+14:]))
+	entries := offset + 16
+	if int(entries)+(named+ids)*8 > len(t.data) {
+		return fmt.Errorf("resource directory at 0x%x has more entries than fit in the resource section", offset)
+	}
+
+	for i := 0; i < named+ids; i++ {
+		entry := t.data[int(entries)+i*8:]
+		nameField := binary.LittleEndian.Uint32(entry)
+		target := binary.LittleEndian.Uint32(entry[4:])
+
+		var id ResourceID
+		if nameField&0x80000000 != 0 {
+			name, err := t.name(nameField &^ 0x80000000)
+			if err != nil {
+				return err
+			}
+			id.Name = name
+		} else {
+			id.ID = uint16(nameField)
+		}
+		switch level {
+		case 0:
+			leaf.Type = id
+		case 1:
+			leaf.Name = id
+		default:
+			leaf.Language = id.ID
+		}
+
+		if target&0x80000000 != 0 {
+			if level >= 2 {
+				return errors.New("resource directory is nested more than three levels deep")
+			}
+			if err := t.walk(target&^0x80000000, level+1, leaf); err != nil {
+				return err
+			}
+			continue
+		}
+		if level != 2 {
+			// Data entries are expected at the language level only, skip misplaced ones.
+			continue
+		}
+		if int(target)+16 > len(t.data) {
+			return fmt.Errorf("resource data entry at 0x%x is outside of the resource section", target)
+		}
+		leaf.DataRVA = binary.LittleEndian.Uint32(t.data[target:])
+		leaf.Size = binary.LittleEndian.Uint32(t.data[target+4:])
+		leaf.CodePage = binary.LittleEndian.Uint32(t.data[target+8:])
+		t.leaves = append(t.leaves, leaf)
+	}
+	return nil
+}
+
+// name reads an IMAGE_RESOURCE_DIR_STRING_U at offset.
+func (t *resourceTree) name(offset uint32) (string, error) {
+	if int(offset)+2 > len(t.data) {
+		return "", fmt.Errorf("resource name at 0x%x is outside of the resource section", offset)
+	}
+	length := int(binary.LittleEndian.Uint16(t.data[offset:]))
+	start := int(offset) + 2
+	if start+length*2 > len(t.data) {
+		return "", fmt.Errorf("resource name at 0x%x is outside of the resource section", offset)
+	}
+	u := make([]uint16, length)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(t.data[start+i*2:])
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// readResource reads the data of a resource.
+func (p *peImage) readResource(resource Resource) ([]byte, error) {
+	data, err := p.readRVA(resource.DataRVA, resource.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s resource %s: %w", resource.TypeName(), resource.Name, err)
+	}
+	return data, nil
+}
+
+// findResources returns the resources of the given predefined type.
+func (p *peImage) findResources(typ ResourceType) (Resources, error) {
+	resources, err := p.resources()
+	if err != nil {
+		return nil, err
+	}
+	return resources.OfType(typ.ID()), nil
+}
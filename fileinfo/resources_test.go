@@ -0,0 +1,125 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadResources(t *testing.T) {
+	manifest := []byte(`<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0"/>`)
+	config := []byte("mode=service")
+	image := testImageWithResources(t, true,
+		testResource{typ: uint16(ResourceTypeManifest), name: uint16(1), lang: 0x409, data: manifest},
+		testResource{typ: uint16(ResourceTypeRCData), name: "CONFIG", lang: 0x409, codePage: 1252, data: config},
+		testResource{typ: uint16(ResourceTypeRCData), name: "CONFIG", lang: 0x407, data: []byte("modus=dienst")},
+		testResource{typ: "TYPELIB", name: uint16(1), lang: 0, data: []byte("MSFT")},
+	)
+
+	resources, err := ReadResources(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.Len(t, resources, 4)
+
+	assert.Equal(t, ResourceTypeManifest.ID(), resources[0].Type)
+	assert.Equal(t, "RT_MANIFEST", resources[0].TypeName())
+	assert.Equal(t, "#1", resources[0].Name.String())
+	assert.Equal(t, uint16(0x409), resources[0].Language)
+	assert.Equal(t, uint32(len(manifest)), resources[0].Size)
+
+	assert.Equal(t, ResourceName("CONFIG"), resources[1].Name)
+	assert.True(t, resources[1].Name.IsNamed())
+	assert.Equal(t, uint32(1252), resources[1].CodePage)
+	assert.Equal(t, "TYPELIB", resources[3].TypeName())
+
+	found := resources.Find(ResourceTypeRCData.ID(), ResourceName("config"))
+	require.Len(t, found, 2)
+	assert.Equal(t, uint16(0x407), found[1].Language)
+	assert.Empty(t, resources.Find(ResourceTypeRCData.ID(), ResourceIntID(1)))
+	assert.Len(t, resources.OfType(ResourceName("typelib")), 1)
+
+	data, err := ReadResourceData(bytes.NewReader(image), int64(len(image)), found[0])
+	require.NoError(t, err)
+	assert.Equal(t, config, data)
+}
+
+func TestWinFileInfoResources(t *testing.T) {
+	image := testImageWithResources(t, false,
+		testResource{typ: uint16(ResourceTypeRCData), name: uint16(101), lang: 0x409, data: []byte("payload")},
+	)
+	wf, err := NewWinFileInfo(testWriteFile(t, "app.exe", image))
+	require.NoError(t, err)
+
+	resources, err := wf.Resources()
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	data, err := wf.ReadResource(resources[0])
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), data)
+
+	resources[0].Size = 1 << 30
+	_, err = wf.ReadResource(resources[0])
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RT_RCDATA resource #101")
+}
+
+func TestReadResourcesWithoutDirectory(t *testing.T) {
+	image := testUnsignedImage(t, false)
+	resources, err := ReadResources(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+}
+
+func TestResourceTypeString(t *testing.T) {
+	assert.Equal(t, "RT_GROUP_ICON", ResourceTypeGroupIcon.String())
+	assert.Equal(t, "#240", ResourceType(240).String())
+	assert.True(t, ResourceName("Config").Equal(ResourceName("CONFIG")))
+	assert.False(t, ResourceName("1").Equal(ResourceIntID(1)))
+}
+
+// testMalformedResources builds an image with a raw resource section.
+func testMalformedResources(t *testing.T, rsrc []byte) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.addSection(".text", []byte("\xc3"), 0x60000020)
+	rva := b.addSection(".rsrc", rsrc, 0x40000040)
+	b.setDirectory(imageDirectoryEntryResource, rva, uint32(len(rsrc)))
+	return b.build(t)
+}
+
+func TestReadResourcesMalformed(t *testing.T) {
+	// The root directory has a single entry pointing back at the root.
+	loop := make([]byte, 24)
+	binary.LittleEndian.PutUint16(loop[14:], 1)
+	binary.LittleEndian.PutUint32(loop[16:], uint32(ResourceTypeVersion))
+	binary.LittleEndian.PutUint32(loop[20:], 0x80000000)
+
+	outOfBounds := bytes.Clone(loop)
+	binary.LittleEndian.PutUint32(outOfBounds[20:], 0x80001000)
+
+	tooManyEntries := bytes.Clone(loop)
+	binary.LittleEndian.PutUint16(tooManyEntries[14:], 0xffff)
+
+	badName := bytes.Clone(loop)
+	binary.LittleEndian.PutUint32(badName[16:], 0x80000fff)
+
+	tests := map[string]struct {
+		rsrc []byte
+		err  string
+	}{
+		"loop":             {rsrc: loop, err: "referenced more than once"},
+		"out of bounds":    {rsrc: outOfBounds, err: "outside of the resource section"},
+		"too many entries": {rsrc: tooManyEntries, err: "more entries than fit"},
+		"bad name":         {rsrc: badName, err: "resource name at 0xfff"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			image := testMalformedResources(t, tt.rsrc)
+			_, err := ReadResources(bytes.NewReader(image), int64(len(image)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
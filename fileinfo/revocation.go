@@ -0,0 +1,424 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// maxRevocationResponseSize limits the size of downloaded CRLs and OCSP responses.
+	maxRevocationResponseSize = 64 << 20
+	// defaultMaxResponseAge is the default RevocationOptions.MaxResponseAge.
+	defaultMaxResponseAge = 7 * 24 * time.Hour
+	// revocationClockSkew is how far the this update of a response may be in the future.
+	revocationClockSkew = 5 * time.Minute
+)
+
+// RevocationMode decides how certificates with an unknown revocation status are treated.
+type RevocationMode int
+
+const (
+	// RevocationSoftFail accepts certificates whose revocation status cannot be determined,
+	// e.g. because the responders are unreachable. Only revoked certificates fail the check.
+	RevocationSoftFail RevocationMode = iota
+	// RevocationHardFail requires a definitive good status for every certificate.
+	RevocationHardFail
+)
+
+// RevocationStatus is the revocation status of a single certificate.
+type RevocationStatus int
+
+const (
+	// RevocationGood means a CRL or OCSP response confirmed the certificate is not revoked.
+	RevocationGood RevocationStatus = iota
+	// RevocationRevoked means the certificate is revoked.
+	RevocationRevoked
+	// RevocationUnknown means no CRL or OCSP response could be obtained for the certificate.
+	RevocationUnknown
+)
+
+func (s RevocationStatus) String() string {
+	switch s {
+	case RevocationGood:
+		return "Good"
+	case RevocationRevoked:
+		return "Revoked"
+	case RevocationUnknown:
+		return "Unknown"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(s))
+	}
+}
+
+// RevocationOptions configures a RevocationChecker.
+type RevocationOptions struct {
+	// Client is used to fetch CRLs and query OCSP responders, http.DefaultClient when nil.
+	Client *http.Client
+	// CacheDir stores downloaded CRLs and OCSP responses until their next update.
+	// Caching is disabled when empty.
+	CacheDir string
+	// CRLFiles are pre-seeded DER or PEM encoded CRLs. They are consulted before the network
+	// and are used regardless of their next update, since they are explicitly provided.
+	CRLFiles []string
+	// Offline disables network access, only CRLFiles and the cache are consulted.
+	// Use it together with CRLFiles on air-gapped systems.
+	Offline bool
+	// Mode decides whether an unknown status fails the check.
+	Mode RevocationMode
+	// CurrentTime is the time responses are checked against, the current time when zero.
+	CurrentTime time.Time
+	// MaxResponseAge limits the age of fetched CRLs and OCSP responses that have no next update,
+	// measured from their this update. It is 7 days when zero. Responses with a next update are
+	// used until then, so that a replayed stale response is rejected.
+	MaxResponseAge time.Duration
+}
+
+// CertificateRevocation is the revocation status of a certificate.
+type CertificateRevocation struct {
+	Certificate *x509.Certificate
+	Status      RevocationStatus
+	// Source is the CRL file, CRL distribution point or OCSP responder that provided the status.
+	Source string
+	// RevokedAt and RevocationReason are set for revoked certificates, the reason is an RFC 5280 CRLReason code.
+	RevokedAt        time.Time
+	RevocationReason int
+	// Reason explains an unknown status.
+	Reason string
+}
+
+// RevocationCheck is the result of checking the revocation status of a certificate chain.
+type RevocationCheck struct {
+	Mode RevocationMode
+	// Certificates are the results for every certificate of the chain except the root, leaf first.
+	Certificates []*CertificateRevocation
+}
+
+// Passed reports whether no certificate is revoked and, in hard-fail mode, every status is known.
+func (r *RevocationCheck) Passed() bool {
+	for _, c := range r.Certificates {
+		if c.Status == RevocationRevoked || (c.Status == RevocationUnknown && r.Mode == RevocationHardFail) {
+			return false
+		}
+	}
+	return true
+}
+
+// RevocationChecker checks certificates against CRLs and OCSP responders.
+// It is safe for concurrent use and keeps downloaded CRLs in memory for reuse.
+type RevocationChecker struct {
+	opts   RevocationOptions
+	seeded []*x509.RevocationList
+
+	mu   sync.Mutex
+	crls map[crlCacheKey]*x509.RevocationList
+}
+
+// crlCacheKey identifies a CRL by its distribution point and the issuer it was verified against.
+type crlCacheKey struct {
+	url    string
+	issuer [sha256.Size]byte
+}
+
+// NewRevocationChecker creates a checker and loads the pre-seeded CRL files.
+func NewRevocationChecker(opts RevocationOptions) (*RevocationChecker, error) {
+	checker := &RevocationChecker{opts: opts, crls: map[crlCacheKey]*x509.RevocationList{}}
+	if checker.opts.Client == nil {
+		checker.opts.Client = http.DefaultClient
+	}
+	for _, path := range opts.CRLFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CRL: %w", err)
+		}
+		crl, err := parseCRL(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRL %s: %w", path, err)
+		}
+		checker.seeded = append(checker.seeded, crl)
+	}
+	if opts.CacheDir != "" {
+		if err := os.MkdirAll(opts.CacheDir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create revocation cache: %w", err)
+		}
+	}
+	return checker, nil
+}
+
+// CheckChain checks every certificate of chain, ordered leaf first and root last as in
+// ChainVerification.Chain, against the CRLs and OCSP responses of its issuer.
+// The root itself is trusted and not checked.
+func (rc *RevocationChecker) CheckChain(chain []*x509.Certificate) (*RevocationCheck, error) {
+	if len(chain) < 2 {
+		return nil, errors.New("revocation checking needs the certificate and its issuer")
+	}
+	result := &RevocationCheck{Mode: rc.opts.Mode}
+	for i := 0; i < len(chain)-1; i++ {
+		result.Certificates = append(result.Certificates, rc.Check(chain[i], chain[i+1]))
+	}
+	return result, nil
+}
+
+// Check determines the revocation status of cert issued by issuer. Pre-seeded CRLs are
+// consulted first, then the OCSP responders and the CRL distribution points of cert.
+func (rc *RevocationChecker) Check(cert, issuer *x509.Certificate) *CertificateRevocation {
+	result := &CertificateRevocation{Certificate: cert, Status: RevocationUnknown}
+	for i, crl := range rc.seeded {
+		if bytes.Equal(crl.RawIssuer, issuer.RawSubject) && crl.CheckSignatureFrom(issuer) == nil {
+			applyCRL(result, crl, rc.opts.CRLFiles[i])
+			return result
+		}
+	}
+
+	var reasons []error
+	for _, server := range cert.OCSPServer {
+		resp, err := rc.ocsp(server, cert, issuer)
+		if err != nil {
+			reasons = append(reasons, fmt.Errorf("OCSP %s: %w", server, err))
+			continue
+		}
+		switch resp.Status {
+		case ocsp.Good:
+			result.Status = RevocationGood
+		case ocsp.Revoked:
+			result.Status = RevocationRevoked
+			result.RevokedAt = resp.RevokedAt
+			result.RevocationReason = resp.RevocationReason
+		default:
+			reasons = append(reasons, fmt.Errorf("OCSP %s: responder does not know the certificate", server))
+			continue
+		}
+		result.Source = server
+		return result
+	}
+	for _, url := range cert.CRLDistributionPoints {
+		crl, err := rc.crl(url, issuer)
+		if err != nil {
+			reasons = append(reasons, fmt.Errorf("CRL %s: %w", url, err))
+			continue
+		}
+		applyCRL(result, crl, url)
+		return result
+	}
+
+	if len(reasons) == 0 {
+		result.Reason = "certificate has no OCSP responder or CRL distribution point"
+	} else {
+		result.Reason = errors.Join(reasons...).Error()
+	}
+	return result
+}
+
+// CheckRevocation checks the revocation status of the certificates of a built chain.
+func (v *ChainVerification) CheckRevocation(checker *RevocationChecker) (*RevocationCheck, error) {
+	return checker.CheckChain(v.Chain)
+}
+
+func applyCRL(result *CertificateRevocation, crl *x509.RevocationList, source string) {
+	result.Source = source
+	result.Status = RevocationGood
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(result.Certificate.SerialNumber) == 0 {
+			result.Status = RevocationRevoked
+			result.RevokedAt = entry.RevocationTime
+			result.RevocationReason = entry.ReasonCode
+			return
+		}
+	}
+}
+
+func (rc *RevocationChecker) now() time.Time {
+	if rc.opts.CurrentTime.IsZero() {
+		return time.Now()
+	}
+	return rc.opts.CurrentTime
+}
+
+// fresh reports whether a response with the given next update can still be used.
+// Responses without a next update are never cached.
+func (rc *RevocationChecker) fresh(nextUpdate time.Time) bool {
+	return !nextUpdate.IsZero() && rc.now().Before(nextUpdate)
+}
+
+// current checks that a fetched response is within its validity period. Responses without a next
+// update must not be older than MaxResponseAge.
+func (rc *RevocationChecker) current(thisUpdate, nextUpdate time.Time) error {
+	now := rc.now()
+	if thisUpdate.After(now.Add(revocationClockSkew)) {
+		return fmt.Errorf("response is not valid before %s", thisUpdate.Format(time.RFC3339))
+	}
+	if !nextUpdate.IsZero() {
+		if !now.Before(nextUpdate) {
+			return fmt.Errorf("response expired at %s", nextUpdate.Format(time.RFC3339))
+		}
+		return nil
+	}
+	maxAge := rc.opts.MaxResponseAge
+	if maxAge == 0 {
+		maxAge = defaultMaxResponseAge
+	}
+	if now.Sub(thisUpdate) > maxAge {
+		return fmt.Errorf("response from %s without next update is older than %s", thisUpdate.Format(time.RFC3339), maxAge)
+	}
+	return nil
+}
+
+func (rc *RevocationChecker) crl(url string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	key := crlCacheKey{url: url, issuer: sha256.Sum256(issuer.Raw)}
+	rc.mu.Lock()
+	crl, ok := rc.crls[key]
+	rc.mu.Unlock()
+	if ok && rc.fresh(crl.NextUpdate) {
+		return crl, nil
+	}
+
+	cachePath := rc.cachePath("crl", []byte(url))
+	if data, err := rc.readCache(cachePath); err == nil {
+		if crl, err := parseCRL(data); err == nil && crl.CheckSignatureFrom(issuer) == nil && rc.fresh(crl.NextUpdate) {
+			rc.remember(key, crl)
+			return crl, nil
+		}
+	}
+	if rc.opts.Offline {
+		return nil, errors.New("no cached CRL in offline mode")
+	}
+
+	data, err := rc.fetch(http.MethodGet, url, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	crl, err = parseCRL(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("CRL is not signed by the issuer: %w", err)
+	}
+	if err := rc.current(crl.ThisUpdate, crl.NextUpdate); err != nil {
+		return nil, err
+	}
+	rc.remember(key, crl)
+	rc.writeCache(cachePath, data, crl.NextUpdate)
+	return crl, nil
+}
+
+func (rc *RevocationChecker) remember(key crlCacheKey, crl *x509.RevocationList) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.crls[key] = crl
+}
+
+func (rc *RevocationChecker) ocsp(server string, cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	cachePath := rc.cachePath("ocsp", append(append([]byte(server), issuer.RawSubjectPublicKeyInfo...), cert.SerialNumber.Bytes()...))
+	if data, err := rc.readCache(cachePath); err == nil {
+		if resp, err := ocsp.ParseResponseForCert(data, cert, issuer); err == nil && rc.fresh(resp.NextUpdate) {
+			return resp, nil
+		}
+	}
+	if rc.opts.Offline {
+		return nil, errors.New("no cached response in offline mode")
+	}
+
+	req, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	data, err := rc.fetch(http.MethodPost, server, "application/ocsp-request", req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ocsp.ParseResponseForCert(data, cert, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if err := rc.current(resp.ThisUpdate, resp.NextUpdate); err != nil {
+		return nil, err
+	}
+	rc.writeCache(cachePath, data, resp.NextUpdate)
+	return resp, nil
+}
+
+func (rc *RevocationChecker) fetch(method, url, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := rc.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRevocationResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRevocationResponseSize {
+		return nil, errors.New("response is too large")
+	}
+	return data, nil
+}
+
+func (rc *RevocationChecker) cachePath(kind string, key []byte) string {
+	if rc.opts.CacheDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256(key)
+	return filepath.Join(rc.opts.CacheDir, kind+"-"+hex.EncodeToString(sum[:]))
+}
+
+func (rc *RevocationChecker) readCache(path string) ([]byte, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(path)
+}
+
+// writeCache stores a response that has a next update, failures only disable caching.
+func (rc *RevocationChecker) writeCache(path string, data []byte, nextUpdate time.Time) {
+	if path == "" || !rc.fresh(nextUpdate) {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// parseCRL parses a DER or PEM encoded CRL.
+func parseCRL(data []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	return x509.ParseRevocationList(data)
+}
@@ -0,0 +1,320 @@
+package fileinfo
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testRevocationServer is a CRL distribution point and OCSP responder for certificates issued by ca.
+type testRevocationServer struct {
+	*httptest.Server
+	ca      *x509.Certificate
+	caKey   *ecdsa.PrivateKey
+	revoked map[int64]bool
+	// now is the time responses are issued at, testValidationTime when zero.
+	now time.Time
+	// noNextUpdate omits the next update from OCSP responses.
+	noNextUpdate bool
+	// requests counts the requests served.
+	requests atomic.Int32
+}
+
+func newTestRevocationServer(t *testing.T, root *x509.Certificate, rootKey *ecdsa.PrivateKey) *testRevocationServer {
+	s := &testRevocationServer{revoked: map[int64]bool{}}
+	s.ca, s.caKey = newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Revocation CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, root, rootKey)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ca.crl", func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		_, _ = w.Write(s.crl(t))
+	})
+	mux.HandleFunc("POST /ocsp", func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+		thisUpdate, nextUpdate := s.updates()
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   thisUpdate,
+			NextUpdate:   nextUpdate,
+		}
+		if s.revoked[req.SerialNumber.Int64()] {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = testValidationTime.AddDate(0, -2, 0)
+			template.RevocationReason = ocsp.KeyCompromise
+		}
+		resp, err := ocsp.CreateResponse(s.ca, s.ca, template, s.caKey)
+		require.NoError(t, err)
+		_, _ = w.Write(resp)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// updates returns the this and next update of responses, a month before and after now.
+func (s *testRevocationServer) updates() (thisUpdate, nextUpdate time.Time) {
+	now := s.now
+	if now.IsZero() {
+		now = testValidationTime
+	}
+	if s.noNextUpdate {
+		return now.AddDate(0, -1, 0), time.Time{}
+	}
+	return now.AddDate(0, -1, 0), now.AddDate(0, 1, 0)
+}
+
+func (s *testRevocationServer) crl(t *testing.T) []byte {
+	thisUpdate, nextUpdate := s.updates()
+	if nextUpdate.IsZero() {
+		// x509.CreateRevocationList requires a next update.
+		nextUpdate = thisUpdate.AddDate(0, 2, 0)
+	}
+	list := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: thisUpdate,
+		NextUpdate: nextUpdate,
+	}
+	for serial := range s.revoked {
+		list.RevokedCertificateEntries = append(list.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: testValidationTime.AddDate(0, -2, 0),
+			ReasonCode:     1,
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, list, s.ca, s.caKey)
+	require.NoError(t, err)
+	return der
+}
+
+// issue issues a code signing certificate pointing at the server's OCSP responder or CRL.
+func (s *testRevocationServer) issue(t *testing.T, name string, useOCSP, revoked bool) *x509.Certificate {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	if useOCSP {
+		template.OCSPServer = []string{s.URL + "/ocsp"}
+	} else {
+		template.CRLDistributionPoints = []string{s.URL + "/ca.crl"}
+	}
+	cert, _ := newTestCertificate(t, template, s.ca, s.caKey)
+	if revoked {
+		s.revoked[cert.SerialNumber.Int64()] = true
+	}
+	return cert
+}
+
+func newTestRevocationChecker(t *testing.T, opts RevocationOptions) *RevocationChecker {
+	if opts.CurrentTime.IsZero() {
+		opts.CurrentTime = testValidationTime
+	}
+	checker, err := NewRevocationChecker(opts)
+	require.NoError(t, err)
+	return checker
+}
+
+func TestRevocationStatus(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	server := newTestRevocationServer(t, pki.root, pki.rootKey)
+	checker := newTestRevocationChecker(t, RevocationOptions{Client: server.Client()})
+
+	tests := []struct {
+		name    string
+		useOCSP bool
+		revoked bool
+		want    RevocationStatus
+	}{
+		{"ocsp good", true, false, RevocationGood},
+		{"ocsp revoked", true, true, RevocationRevoked},
+		{"crl good", false, false, RevocationGood},
+		{"crl revoked", false, true, RevocationRevoked},
+	}
+	// Issue all certificates first, the checker keeps the CRL until its next update.
+	certs := make([]*x509.Certificate, len(tests))
+	for i, tt := range tests {
+		certs[i] = server.issue(t, tt.name, tt.useOCSP, tt.revoked)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checker.Check(certs[i], server.ca)
+			assert.Equal(t, tt.want, result.Status, result.Reason)
+			assert.Contains(t, result.Source, server.URL)
+			if tt.revoked {
+				assert.True(t, testValidationTime.AddDate(0, -2, 0).Equal(result.RevokedAt))
+				assert.Equal(t, 1, result.RevocationReason)
+			}
+		})
+	}
+}
+
+func TestRevocationChainModes(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	server := newTestRevocationServer(t, pki.root, pki.rootKey)
+	good := server.issue(t, "Good Publisher", true, false)
+	revoked := server.issue(t, "Revoked Publisher", false, true)
+
+	soft := newTestRevocationChecker(t, RevocationOptions{Client: server.Client()})
+	hard := newTestRevocationChecker(t, RevocationOptions{Client: server.Client(), Mode: RevocationHardFail})
+
+	// The revocation CA itself has no CRL distribution point, so its status is unknown.
+	check, err := soft.CheckChain([]*x509.Certificate{good, server.ca, pki.root})
+	require.NoError(t, err)
+	require.Len(t, check.Certificates, 2)
+	assert.Equal(t, RevocationGood, check.Certificates[0].Status)
+	assert.Equal(t, RevocationUnknown, check.Certificates[1].Status)
+	assert.True(t, check.Passed())
+
+	check, err = hard.CheckChain([]*x509.Certificate{good, server.ca, pki.root})
+	require.NoError(t, err)
+	assert.False(t, check.Passed())
+
+	check, err = soft.CheckChain([]*x509.Certificate{revoked, server.ca, pki.root})
+	require.NoError(t, err)
+	assert.False(t, check.Passed())
+
+	chain := &ChainVerification{Status: ChainValid, Chain: []*x509.Certificate{good, server.ca}}
+	check, err = chain.CheckRevocation(hard)
+	require.NoError(t, err)
+	assert.True(t, check.Passed())
+
+	_, err = soft.CheckChain([]*x509.Certificate{good})
+	require.Error(t, err)
+}
+
+func TestRevocationUnreachable(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	server := newTestRevocationServer(t, pki.root, pki.rootKey)
+	cert := server.issue(t, "Test Publisher", true, false)
+	server.Close()
+
+	checker := newTestRevocationChecker(t, RevocationOptions{Client: server.Client()})
+	result := checker.Check(cert, server.ca)
+	assert.Equal(t, RevocationUnknown, result.Status)
+	assert.Contains(t, result.Reason, "/ocsp")
+}
+
+func TestRevocationCache(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	server := newTestRevocationServer(t, pki.root, pki.rootKey)
+	viaOCSP := server.issue(t, "OCSP Publisher", true, false)
+	viaCRL := server.issue(t, "CRL Publisher", false, true)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	checker := newTestRevocationChecker(t, RevocationOptions{Client: server.Client(), CacheDir: cacheDir})
+	assert.Equal(t, RevocationGood, checker.Check(viaOCSP, server.ca).Status)
+	assert.Equal(t, RevocationRevoked, checker.Check(viaCRL, server.ca).Status)
+	assert.Equal(t, int32(2), server.requests.Load())
+
+	// A new checker answers from the on-disk cache without the network.
+	offline := newTestRevocationChecker(t, RevocationOptions{CacheDir: cacheDir, Offline: true})
+	assert.Equal(t, RevocationGood, offline.Check(viaOCSP, server.ca).Status)
+	assert.Equal(t, RevocationRevoked, offline.Check(viaCRL, server.ca).Status)
+	assert.Equal(t, int32(2), server.requests.Load())
+
+	// Cached responses expire at their next update.
+	server.now = testValidationTime.AddDate(0, 2, 0)
+	expired := newTestRevocationChecker(t, RevocationOptions{
+		Client:      server.Client(),
+		CacheDir:    cacheDir,
+		CurrentTime: testValidationTime.AddDate(0, 2, 0),
+	})
+	assert.Equal(t, RevocationGood, expired.Check(viaOCSP, server.ca).Status)
+	assert.Equal(t, int32(3), server.requests.Load())
+}
+
+func TestRevocationSeededCRL(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	server := newTestRevocationServer(t, pki.root, pki.rootKey)
+	good := server.issue(t, "Good Publisher", true, false)
+	revoked := server.issue(t, "Revoked Publisher", true, true)
+	crlFile := filepath.Join(t.TempDir(), "ca.crl")
+	require.NoError(t, os.WriteFile(crlFile, server.crl(t), 0o600))
+	server.Close()
+
+	checker := newTestRevocationChecker(t, RevocationOptions{
+		CRLFiles:    []string{crlFile},
+		Offline:     true,
+		Mode:        RevocationHardFail,
+		CurrentTime: time.Now(),
+	})
+	result := checker.Check(good, server.ca)
+	assert.Equal(t, RevocationGood, result.Status, result.Reason)
+	assert.Equal(t, crlFile, result.Source)
+	assert.Equal(t, RevocationRevoked, checker.Check(revoked, server.ca).Status)
+
+	// Certificates of other issuers are not covered by the seeded CRL.
+	result = checker.Check(pki.leaf, pki.intermediate)
+	assert.Equal(t, RevocationUnknown, result.Status)
+
+	_, err := NewRevocationChecker(RevocationOptions{CRLFiles: []string{filepath.Join(t.TempDir(), "missing.crl")}})
+	require.Error(t, err)
+}
+
+func TestRevocationStaleResponses(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	server := newTestRevocationServer(t, pki.root, pki.rootKey)
+	viaOCSP := server.issue(t, "OCSP Publisher", true, false)
+	viaCRL := server.issue(t, "CRL Publisher", false, false)
+
+	// Replayed responses expired before the validation time.
+	server.now = testValidationTime.AddDate(0, -3, 0)
+	checker := newTestRevocationChecker(t, RevocationOptions{Client: server.Client()})
+	for _, cert := range []*x509.Certificate{viaOCSP, viaCRL} {
+		result := checker.Check(cert, server.ca)
+		assert.Equal(t, RevocationUnknown, result.Status)
+		assert.Contains(t, result.Reason, "response expired at")
+	}
+
+	// Responses issued after the validation time.
+	server.now = testValidationTime.AddDate(0, 2, 0)
+	result := checker.Check(viaOCSP, server.ca)
+	assert.Equal(t, RevocationUnknown, result.Status)
+	assert.Contains(t, result.Reason, "response is not valid before")
+
+	// Without a next update the age of the response is limited.
+	server.now, server.noNextUpdate = testValidationTime, true
+	result = checker.Check(viaOCSP, server.ca)
+	assert.Equal(t, RevocationUnknown, result.Status)
+	assert.Contains(t, result.Reason, "without next update is older than")
+	lenient := newTestRevocationChecker(t, RevocationOptions{Client: server.Client(), MaxResponseAge: 60 * 24 * time.Hour})
+	assert.Equal(t, RevocationGood, lenient.Check(viaOCSP, server.ca).Status)
+	assert.Equal(t, RevocationGood, lenient.Check(viaCRL, server.ca).Status)
+}
+
+func TestRevocationCRLCachedPerIssuer(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	server := newTestRevocationServer(t, pki.root, pki.rootKey)
+	cert := server.issue(t, "CRL Publisher", false, false)
+	checker := newTestRevocationChecker(t, RevocationOptions{Client: server.Client()})
+	assert.Equal(t, RevocationGood, checker.Check(cert, server.ca).Status)
+
+	// The CRL kept in memory was verified against another issuer.
+	result := checker.Check(cert, pki.intermediate)
+	assert.Equal(t, RevocationUnknown, result.Status)
+	assert.Contains(t, result.Reason, "CRL is not signed by the issuer")
+}
@@ -0,0 +1,244 @@
+package fileinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// The Rich header starts with "DanS" and ends with "Rich" followed by the XOR key, it sits
+// between the DOS stub and the PE header.
+const (
+	richSignature = 0x68636952 // "Rich"
+	dansSignature = 0x536e6144 // "DanS"
+)
+
+// richProducts names the tools of the Visual Studio 2015 and later toolsets, whose product IDs
+// have not changed since, and the special IDs the linker uses.
+var richProducts = map[uint16]string{
+	0x0000: "Unmarked",
+	0x0001: "Import0",
+	0x00fd: "AliasObj1400",
+	0x00fe: "Cvtpgd1400",
+	0x00ff: "Cvtres1400",
+	0x0100: "Export1400",
+	0x0101: "Implib1400",
+	0x0102: "Linker1400",
+	0x0103: "Masm1400",
+	0x0104: "Utc1900_C",
+	0x0105: "Utc1900_CPP",
+	0x0106: "Utc1900_CVTCIL_C",
+	0x0107: "Utc1900_CVTCIL_CPP",
+	0x0108: "Utc1900_LTCG_C",
+	0x0109: "Utc1900_LTCG_CPP",
+	0x010a: "Utc1900_LTCG_MSIL",
+	0x010b: "Utc1900_POGO_I_C",
+	0x010c: "Utc1900_POGO_I_CPP",
+	0x010d: "Utc1900_POGO_O_C",
+	0x010e: "Utc1900_POGO_O_CPP",
+}
+
+// richLinkerProducts are the product IDs of the linkers of the known toolsets.
+var richLinkerProducts = map[uint16]bool{
+	0x0004: true, // Linker600
+	0x0091: true, // Linker900
+	0x009d: true, // Linker1000
+	0x00cc: true, // Linker1100
+	0x00de: true, // Linker1200
+	0x0102: true, // Linker1400
+}
+
+// RichEntry is a tool that contributed objects to the image: the product ID and build of the
+// tool and the number of objects it produced.
+type RichEntry struct {
+	ProductID uint16
+	Build     uint16
+	Count     uint32
+	// Product names the tool, e.g. "Utc1900_CPP" for the C++ compiler of Visual Studio 2015 and later.
+	// It is empty for unknown product IDs.
+	Product string
+	// VisualStudio is the Visual Studio version that shipped the tool, empty for unknown product IDs.
+	VisualStudio string
+}
+
+// RichHeader is the undocumented header the Microsoft linker writes after the DOS stub.
+type RichHeader struct {
+	// Offset is the file offset of the header.
+	Offset int64
+	// Key is the XOR mask stored after "Rich", it is a checksum of the DOS header and the entries.
+	Key uint32
+	// Checksum is the recomputed checksum.
+	Checksum uint32
+	Entries  []RichEntry
+}
+
+// ChecksumValid reports whether the stored key matches the recomputed checksum. A mismatch means
+// the DOS header or the Rich header were modified after linking.
+func (h *RichHeader) ChecksumValid() bool {
+	return h.Key == h.Checksum
+}
+
+// Linker returns the entry of the linker that produced the image, nil when it is not known.
+func (h *RichHeader) Linker() *RichEntry {
+	for i := range h.Entries {
+		if richLinkerProducts[h.Entries[i].ProductID] {
+			return &h.Entries[i]
+		}
+	}
+	return nil
+}
+
+// VisualStudio returns the Visual Studio version of the linker, or of the newest tool when the
+// linker is not known. It is empty when no tool is known.
+func (h *RichHeader) VisualStudio() string {
+	if linker := h.Linker(); linker != nil {
+		return linker.VisualStudio
+	}
+	var newest *RichEntry
+	for i, e := range h.Entries {
+		if e.VisualStudio == "" {
+			continue
+		}
+		if newest == nil || e.ProductID > newest.ProductID || e.ProductID == newest.ProductID && e.Build > newest.Build {
+			newest = &h.Entries[i]
+		}
+	}
+	if newest == nil {
+		return ""
+	}
+	return newest.VisualStudio
+}
+
+// Redistributable returns the Visual C++ redistributable matching the toolset of VisualStudio,
+// the one an image linked against the DLL runtime needs. Visual Studio 2015 and later share one.
+func (h *RichHeader) Redistributable() string {
+	switch vs := h.VisualStudio(); vs {
+	case "":
+		return ""
+	case "Visual Studio 2015", "Visual Studio 2017", "Visual Studio 2019", "Visual Studio 2022":
+		return "Visual C++ 2015-2022 Redistributable"
+	default:
+		return "Visual C++ " + vs[len("Visual Studio "):] + " Redistributable"
+	}
+}
+
+// richVisualStudio maps a product ID to the Visual Studio version that introduced it. The compilers of
+// Visual Studio 2015 to 2022 share their product IDs and are told apart by the build number.
+func richVisualStudio(productID, build uint16) string {
+	switch {
+	case productID >= 0x00fd:
+		switch {
+		case build < 25000:
+			return "Visual Studio 2015"
+		case build < 27500:
+			return "Visual Studio 2017"
+		case build < 30700:
+			return "Visual Studio 2019"
+		default:
+			return "Visual Studio 2022"
+		}
+	case productID >= 0x00d9:
+		return "Visual Studio 2013"
+	case productID >= 0x00c7:
+		return "Visual Studio 2012"
+	case productID >= 0x0098:
+		return "Visual Studio 2010"
+	case productID >= 0x0083:
+		return "Visual Studio 2008"
+	case productID >= 0x006d:
+		return "Visual Studio 2005"
+	case productID >= 0x005a:
+		return "Visual Studio 2003"
+	case productID >= 0x0019:
+		return "Visual Studio 2002"
+	case productID >= 0x0002:
+		return "Visual Studio 6.0"
+	}
+	return ""
+}
+
+// ReadRichHeader decodes the Rich header of the PE image in r, size is the total image size.
+// It returns nil when the image has none, as with images not linked by the Microsoft linker.
+func ReadRichHeader(r io.ReaderAt, size int64) (*RichHeader, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.richHeader()
+}
+
+func readRichHeaderFile(path string) (*RichHeader, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.richHeader()
+}
+
+// richHeader finds "Rich" before the PE header and walks back over the XOR masked entries to "DanS".
+// The three masked zero DWORDs after "DanS" are padding.
+func (p *peImage) richHeader() (*RichHeader, error) {
+	// optionalHeaderOffset follows the PE signature and the COFF file header.
+	lfanew := p.optionalHeaderOffset - 24
+	if lfanew <= 0x40 {
+		return nil, nil
+	}
+	data, err := p.readAt(0, lfanew)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DOS stub: %w", err)
+	}
+	rich := -1
+	for pos := 0x40; pos+8 <= len(data); pos += 4 {
+		if binary.LittleEndian.Uint32(data[pos:]) == richSignature {
+			rich = pos
+			break
+		}
+	}
+	if rich < 0 {
+		return nil, nil
+	}
+	key := binary.LittleEndian.Uint32(data[rich+4:])
+	start := -1
+	for pos := rich - 4; pos >= 0; pos -= 4 {
+		if binary.LittleEndian.Uint32(data[pos:])^key == dansSignature {
+			start = pos
+			break
+		}
+	}
+	if start < 0 {
+		return nil, errors.New("rich header has no DanS marker")
+	}
+	if (rich-start)%8 != 0 || rich-start < 16 {
+		return nil, errors.New("rich header is malformed")
+	}
+
+	h := &RichHeader{Offset: int64(start), Key: key}
+	checksum := uint32(start)
+	for i, b := range data[:start] {
+		// e_lfanew is not part of the checksum.
+		if i >= 0x3c && i < 0x40 {
+			continue
+		}
+		checksum += bits.RotateLeft32(uint32(b), i)
+	}
+	for pos := start + 16; pos < rich; pos += 8 {
+		compID := binary.LittleEndian.Uint32(data[pos:]) ^ key
+		count := binary.LittleEndian.Uint32(data[pos+4:]) ^ key
+		checksum += bits.RotateLeft32(compID, int(count))
+		productID, build := uint16(compID>>16), uint16(compID)
+		h.Entries = append(h.Entries, RichEntry{
+			ProductID:    productID,
+			Build:        build,
+			Count:        count,
+			Product:      richProducts[productID],
+			VisualStudio: richVisualStudio(productID, build),
+		})
+	}
+	h.Checksum = checksum
+	return h, nil
+}
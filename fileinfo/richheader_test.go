@@ -0,0 +1,131 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRichHeader builds a Rich header for a stub placed at 0x80 by testPE, entries are
+// compid and count pairs. The key is computed the way the linker does.
+func testRichHeader(entries ...[2]uint32) []byte {
+	dos := make([]byte, 0x80)
+	copy(dos, "MZ")
+	key := uint32(0x80)
+	for i, b := range dos {
+		if i < 0x3c || i >= 0x40 {
+			key += uint32(b)<<(i%32) | uint32(b)>>((32-i%32)%32)
+		}
+	}
+	for _, e := range entries {
+		n := e[1] % 32
+		key += e[0]<<n | e[0]>>((32-n)%32)
+	}
+
+	header := binary.LittleEndian.AppendUint32(nil, dansSignature^key)
+	for range 3 {
+		header = binary.LittleEndian.AppendUint32(header, key)
+	}
+	for _, e := range entries {
+		header = binary.LittleEndian.AppendUint32(header, e[0]^key)
+		header = binary.LittleEndian.AppendUint32(header, e[1]^key)
+	}
+	header = binary.LittleEndian.AppendUint32(header, richSignature)
+	header = binary.LittleEndian.AppendUint32(header, key)
+	return append(header, make([]byte, 8)...)
+}
+
+func testImageWithRichHeader(t *testing.T, entries ...[2]uint32) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.dosStub = testRichHeader(entries...)
+	return b.build(t)
+}
+
+var testRichEntries = [][2]uint32{
+	{0x0001_0000, 87},         // Import0, 87 imported functions
+	{0x0105_7809, 12},         // Utc1900_CPP 30729
+	{0x0104_7809, 3},          // Utc1900_C 30729
+	{0x0103_7809, 1},          // Masm1400 30729
+	{0x00ff_85dd, 1},          // Cvtres1400 34269
+	{0x0102_85dd, 1},          // Linker1400 34269
+	{0x009d_9d1b, 0x00000021}, // Linker1000 40219, listed after the current linker
+}
+
+func TestReadRichHeader(t *testing.T) {
+	image := testImageWithRichHeader(t, testRichEntries...)
+	h, err := ReadRichHeader(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	require.NotNil(t, h)
+
+	assert.Equal(t, int64(0x80), h.Offset)
+	assert.True(t, h.ChecksumValid(), "key 0x%x, checksum 0x%x", h.Key, h.Checksum)
+	require.Len(t, h.Entries, 7)
+	assert.Equal(t, RichEntry{ProductID: 1, Count: 87, Product: "Import0"}, h.Entries[0])
+	assert.Equal(t, RichEntry{
+		ProductID: 0x0105, Build: 30729, Count: 12, Product: "Utc1900_CPP", VisualStudio: "Visual Studio 2022",
+	}, h.Entries[1])
+	assert.Equal(t, "Visual Studio 2010", h.Entries[6].VisualStudio)
+	assert.Empty(t, h.Entries[6].Product)
+
+	require.NotNil(t, h.Linker())
+	assert.Equal(t, uint16(34269), h.Linker().Build)
+	assert.Equal(t, "Visual Studio 2022", h.VisualStudio())
+	assert.Equal(t, "Visual C++ 2015-2022 Redistributable", h.Redistributable())
+}
+
+func TestReadRichHeaderTampered(t *testing.T) {
+	image := testImageWithRichHeader(t, testRichEntries...)
+	// Flip a bit of the DOS header that is covered by the checksum.
+	image[0x10] ^= 1
+	h, err := ReadRichHeader(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.False(t, h.ChecksumValid())
+	assert.Len(t, h.Entries, 7)
+}
+
+func TestReadRichHeaderMissing(t *testing.T) {
+	image := testUnsignedImage(t, false)
+	h, err := ReadRichHeader(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Nil(t, h)
+
+	b := newTestPE()
+	b.dosStub = append(make([]byte, 16), "Rich\x00\x00\x00\x00"...)
+	image = b.build(t)
+	_, err = ReadRichHeader(bytes.NewReader(image), int64(len(image)))
+	require.EqualError(t, err, "rich header has no DanS marker")
+}
+
+func TestRichVisualStudio(t *testing.T) {
+	for _, tt := range []struct {
+		productID, build uint16
+		want             string
+	}{
+		{0x0000, 0, ""},
+		{0x0001, 0, ""},
+		{0x0004, 8447, "Visual Studio 6.0"},
+		{0x0091, 21022, "Visual Studio 2008"},
+		{0x00de, 21005, "Visual Studio 2013"},
+		{0x0105, 24215, "Visual Studio 2015"},
+		{0x0105, 27051, "Visual Studio 2017"},
+		{0x0105, 30159, "Visual Studio 2019"},
+		{0x0102, 33145, "Visual Studio 2022"},
+	} {
+		assert.Equal(t, tt.want, richVisualStudio(tt.productID, tt.build), "0x%04x %d", tt.productID, tt.build)
+	}
+	h := &RichHeader{Entries: []RichEntry{{ProductID: 0x0091, VisualStudio: "Visual Studio 2008"}}}
+	assert.Equal(t, "Visual C++ 2008 Redistributable", h.Redistributable())
+	assert.Empty(t, (&RichHeader{}).Redistributable())
+}
+
+func TestWinFileInfoGetRichHeader(t *testing.T) {
+	wf, err := NewWinFileInfo(testWriteFile(t, "app.exe", testImageWithRichHeader(t, testRichEntries...)))
+	require.NoError(t, err)
+	h, err := wf.GetRichHeader()
+	require.NoError(t, err)
+	assert.True(t, h.ChecksumValid())
+}
@@ -0,0 +1,652 @@
+package fileinfo
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Confidence is how certain a runtime verdict is.
+type Confidence int
+
+const (
+	// ConfidenceLow means only weak hints were found, such as a marker string other programs may contain.
+	ConfidenceLow Confidence = iota + 1
+	// ConfidenceMedium means markers specific to the runtime were found, but not its own data structures.
+	ConfidenceMedium
+	// ConfidenceHigh means a data structure of the runtime or packager was found and decoded.
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "Low"
+	case ConfidenceMedium:
+		return "Medium"
+	case ConfidenceHigh:
+		return "High"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(c))
+	}
+}
+
+// Runtime is a runtime or packager an executable was built with.
+type Runtime string
+
+const (
+	RuntimePyInstaller      Runtime = "PyInstaller"
+	RuntimePy2exe           Runtime = "py2exe"
+	RuntimeDotNet           Runtime = ".NET"
+	RuntimeDotNetSingleFile Runtime = ".NET single-file"
+	RuntimeDotNetNativeAOT  Runtime = ".NET Native AOT"
+	RuntimeElectron         Runtime = "Electron"
+	RuntimeNode             Runtime = "Node.js"
+	RuntimeLaunch4j         Runtime = "Launch4j"
+	RuntimeJPackage         Runtime = "jpackage"
+	RuntimeGo               Runtime = "Go"
+)
+
+// RuntimeVerdict is a runtime or packager recognized in an executable.
+type RuntimeVerdict struct {
+	Runtime    Runtime
+	Confidence Confidence
+	// Version is the version of the runtime when known: the Python version of PyInstaller and py2exe,
+	// the target framework or runtime version of .NET and the toolchain of Go, e.g. "go1.22.3".
+	Version string
+	// Evidence describes what the verdict is based on.
+	Evidence []string
+	// BundledFiles is the manifest of a .NET single-file bundle.
+	BundledFiles []BundledFile
+}
+
+// BundleFileType is the type of a file in a .NET single-file bundle.
+type BundleFileType uint8
+
+const (
+	BundleFileUnknown BundleFileType = iota
+	BundleFileAssembly
+	BundleFileNativeBinary
+	BundleFileDepsJSON
+	BundleFileRuntimeConfigJSON
+	BundleFileSymbols
+)
+
+func (t BundleFileType) String() string {
+	switch t {
+	case BundleFileUnknown:
+		return "Unknown"
+	case BundleFileAssembly:
+		return "Assembly"
+	case BundleFileNativeBinary:
+		return "Native Binary"
+	case BundleFileDepsJSON:
+		return "deps.json"
+	case BundleFileRuntimeConfigJSON:
+		return "runtimeconfig.json"
+	case BundleFileSymbols:
+		return "Symbols"
+	default:
+		return fmt.Sprintf("Unknown (%d)", uint8(t))
+	}
+}
+
+// BundledFile is an entry of the manifest of a .NET single-file bundle.
+type BundledFile struct {
+	// Path is the path relative to the application directory.
+	Path string
+	Type BundleFileType
+	// Offset is the file offset of the data in the executable.
+	Offset int64
+	Size   int64
+	// CompressedSize is the size of the compressed data, zero when the file is stored uncompressed.
+	CompressedSize int64
+}
+
+// Markers searched for in the sections.
+const (
+	// goBuildIDMarker starts the build ID the Go linker writes at the start of the text section.
+	goBuildIDMarker = "\xff Go build ID: \""
+	// meipassMarker is the environment variable of the PyInstaller bootloader.
+	meipassMarker = "_MEIPASS"
+	// electronMarker is the environment variable that turns an Electron app into a plain Node.js runtime.
+	electronMarker = "ELECTRON_RUN_AS_NODE"
+	// launch4jMarker prefixes the command line options of the Launch4j launcher, e.g. --l4j-debug.
+	launch4jMarker = "--l4j-"
+	// jpackageMarker is the system property the jpackage launcher passes to the JVM.
+	jpackageMarker = "jpackage.app-path"
+	// jliMarker is the launcher entry point of the Java runtime that Java launchers call.
+	jliMarker = "JLI_Launch"
+)
+
+// dotNetBundleSignature is the SHA-256 of ".net core bundle". The apphost stores it after the 8 byte
+// offset of the bundle header, which the SDK fills in when it bundles the application.
+var dotNetBundleSignature = string([]byte{
+	0x8b, 0x12, 0x02, 0xb9, 0x6a, 0x61, 0x20, 0x38, 0x72, 0x7b, 0x93, 0x02, 0x14, 0xd7, 0xa0, 0x32,
+	0x13, 0xf5, 0xb9, 0xe6, 0xef, 0xae, 0x33, 0x18, 0xee, 0x3b, 0x2d, 0xce, 0x24, 0xb3, 0x6a, 0xae,
+})
+
+const (
+	// pyInstallerMagic starts the cookie at the end of the PyInstaller archive.
+	pyInstallerMagic = "MEI\x0c\x0b\x0a\x0b\x0e"
+	// pyInstallerCookieSize is the size of the cookie of PyInstaller 2.1 and later, with the Python
+	// library name.
+	pyInstallerCookieSize = 88
+	// pyInstallerSearchSize is how much of the end of the overlay is searched for the cookie.
+	pyInstallerSearchSize = 4096
+	// maxBundleManifestSize limits the data read for a .NET bundle header and manifest.
+	maxBundleManifestSize = 4 << 20
+	// maxBundledFiles limits the number of .NET bundle manifest entries.
+	maxBundledFiles = 0x10000
+	// maxRuntimeConfigSize limits the size of a bundled runtimeconfig.json.
+	maxRuntimeConfigSize = 1 << 20
+)
+
+// runtimeClues are the parts of an image the runtime detectors look at.
+type runtimeClues struct {
+	markers      map[string]int64
+	imports      *Imports
+	exports      *Exports
+	resources    Resources
+	overlayStart int64
+	overlayEnd   int64
+}
+
+// hasExport reports whether the image exports the name.
+func (c *runtimeClues) hasExport(name string) bool {
+	return c.exports != nil && c.exports.Find(name) != nil
+}
+
+// overlayPrefix reports whether the overlay starts with the prefix.
+func (c *runtimeClues) overlayPrefix(p *peImage, prefix string) (bool, error) {
+	if c.overlayEnd-c.overlayStart < int64(len(prefix)) {
+		return false, nil
+	}
+	data, err := p.readAt(c.overlayStart, int64(len(prefix)))
+	if err != nil {
+		return false, fmt.Errorf("failed to read overlay: %w", err)
+	}
+	return string(data) == prefix, nil
+}
+
+// DetectRuntimes classifies the runtimes and packagers of the PE image in r, size is the total image
+// size, from its section names, overlay, imports, exports, resources and embedded archives. Verdicts are
+// sorted by confidence, highest first. It returns nil when nothing was recognized.
+func DetectRuntimes(r io.ReaderAt, size int64) ([]RuntimeVerdict, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.runtimes()
+}
+
+func detectRuntimesFile(path string) ([]RuntimeVerdict, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.runtimes()
+}
+
+func (p *peImage) runtimes() ([]RuntimeVerdict, error) {
+	var c runtimeClues
+	var err error
+	if c.markers, err = p.scanSections(goBuildIDMarker, meipassMarker, electronMarker, launch4jMarker, jpackageMarker, jliMarker, dotNetBundleSignature); err != nil {
+		return nil, err
+	}
+	// A directory that fails to parse is left out, the other clues may still tell the runtime.
+	c.imports, _ = p.imports()
+	c.exports, _ = p.exports()
+	c.resources, _ = p.resources()
+	c.overlayStart, c.overlayEnd = p.overlayRange()
+
+	var verdicts []RuntimeVerdict
+	for _, detect := range []func(*runtimeClues) (*RuntimeVerdict, error){
+		p.detectPyInstaller,
+		p.detectPy2exe,
+		p.detectDotNet,
+		p.detectDotNetSingleFile,
+		p.detectNativeAOT,
+		p.detectNode,
+		p.detectLaunch4j,
+		p.detectJPackage,
+		p.detectGo,
+	} {
+		verdict, err := detect(&c)
+		if err != nil {
+			return nil, err
+		}
+		if verdict != nil {
+			verdicts = append(verdicts, *verdict)
+		}
+	}
+	slices.SortStableFunc(verdicts, func(a, b RuntimeVerdict) int {
+		return int(b.Confidence) - int(a.Confidence)
+	})
+	return verdicts, nil
+}
+
+// detectPyInstaller looks for the cookie at the end of the CArchive PyInstaller appends to its
+// bootloader: the magic, the archive length, the table of contents offset and length, the Python
+// version as major*100+minor (major*10+minor before Python 3.10) and the Python DLL name.
+func (p *peImage) detectPyInstaller(c *runtimeClues) (*RuntimeVerdict, error) {
+	v := &RuntimeVerdict{Runtime: RuntimePyInstaller}
+	var tail []byte
+	start := max(c.overlayStart, c.overlayEnd-pyInstallerSearchSize)
+	if start < c.overlayEnd {
+		var err error
+		if tail, err = p.readAt(start, c.overlayEnd-start); err != nil {
+			return nil, fmt.Errorf("failed to read overlay: %w", err)
+		}
+	}
+	if i := bytes.LastIndex(tail, []byte(pyInstallerMagic)); i >= 0 && len(tail)-i >= 24 {
+		cookie := tail[i:]
+		v.Confidence = ConfidenceHigh
+		v.Evidence = append(v.Evidence, fmt.Sprintf("PyInstaller archive cookie at offset 0x%x", start+int64(i)))
+		pyVersion := binary.BigEndian.Uint32(cookie[20:])
+		if pyVersion >= 100 {
+			v.Version = fmt.Sprintf("%d.%d", pyVersion/100, pyVersion%100)
+		} else if pyVersion > 0 {
+			v.Version = fmt.Sprintf("%d.%d", pyVersion/10, pyVersion%10)
+		}
+		if len(cookie) >= pyInstallerCookieSize {
+			if library, _, _ := bytes.Cut(cookie[24:pyInstallerCookieSize], []byte{0}); len(library) > 0 {
+				v.Evidence = append(v.Evidence, "Python library "+string(library))
+			}
+		}
+	}
+	if offset, ok := c.markers[meipassMarker]; ok {
+		v.Confidence = max(v.Confidence, ConfidenceMedium)
+		v.Evidence = append(v.Evidence, fmt.Sprintf("bootloader string %s at offset 0x%x", meipassMarker, offset))
+	}
+	if v.Confidence == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// detectPy2exe looks for the PYTHONSCRIPT resource holding the compiled scripts of py2exe and takes
+// the Python version from the imported or embedded pythonXY.dll.
+func (p *peImage) detectPy2exe(c *runtimeClues) (*RuntimeVerdict, error) {
+	if len(c.resources.OfType(ResourceName("PYTHONSCRIPT"))) == 0 {
+		return nil, nil
+	}
+	v := &RuntimeVerdict{
+		Runtime:    RuntimePy2exe,
+		Confidence: ConfidenceHigh,
+		Evidence:   []string{"PYTHONSCRIPT resource"},
+	}
+	var libraries []string
+	if c.imports != nil {
+		for _, l := range c.imports.Libraries {
+			libraries = append(libraries, l.Name)
+		}
+	}
+	for _, r := range c.resources {
+		libraries = append(libraries, r.Name.Name)
+	}
+	for _, library := range libraries {
+		if version := pythonDLLVersion(library); version != "" {
+			v.Version = version
+			v.Evidence = append(v.Evidence, "Python library "+library)
+			break
+		}
+	}
+	if ok, err := c.overlayPrefix(p, zipSignature); err != nil {
+		return nil, err
+	} else if ok {
+		v.Evidence = append(v.Evidence, "ZIP archive appended as overlay")
+	}
+	return v, nil
+}
+
+// pythonDLLVersion returns the Python version of a pythonXY.dll name, e.g. "3.11" for python311.dll.
+// It is empty for other names and for python3.dll, the version independent stable ABI.
+func pythonDLLVersion(name string) string {
+	name = strings.ToLower(name)
+	digits, ok := strings.CutPrefix(strings.TrimSuffix(strings.TrimSuffix(name, ".dll"), "_d"), "python")
+	if !ok || len(digits) < 2 || strings.Trim(digits, "0123456789") != "" {
+		return ""
+	}
+	return digits[:1] + "." + digits[1:]
+}
+
+// detectDotNet reports managed images, the CLR header flags and the target framework. A CLR header
+// that fails to parse still marks a managed image, with less confidence.
+func (p *peImage) detectDotNet(*runtimeClues) (*RuntimeVerdict, error) {
+	assembly, err := p.dotNetAssembly()
+	if err != nil {
+		return &RuntimeVerdict{
+			Runtime:    RuntimeDotNet,
+			Confidence: ConfidenceMedium,
+			Evidence:   []string{fmt.Sprintf("CLR header not readable: %v", err)},
+		}, nil
+	}
+	if assembly == nil {
+		return nil, nil
+	}
+	v := &RuntimeVerdict{
+		Runtime:    RuntimeDotNet,
+		Confidence: ConfidenceHigh,
+		Version:    assembly.TargetFramework,
+		Evidence:   []string{"CLR header, metadata version " + assembly.RuntimeVersion},
+	}
+	if assembly.TargetFramework != "" {
+		v.Evidence = append(v.Evidence, "TargetFrameworkAttribute "+assembly.TargetFramework)
+	}
+	if assembly.MixedMode {
+		v.Evidence = append(v.Evidence, "mixed-mode image")
+	}
+	if assembly.ReadyToRun {
+		v.Evidence = append(v.Evidence, "ReadyToRun code")
+	}
+	return v, nil
+}
+
+// detectDotNetSingleFile reports the bundle the apphost points to. A bundle header that fails to
+// parse still marks a bundle, with less confidence.
+func (p *peImage) detectDotNetSingleFile(c *runtimeClues) (*RuntimeVerdict, error) {
+	signature, ok := c.markers[dotNetBundleSignature]
+	if !ok || signature < 8 {
+		return nil, nil
+	}
+	placeholder, err := p.readAt(signature-8, 8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle header offset: %w", err)
+	}
+	offset := int64(binary.LittleEndian.Uint64(placeholder))
+	if offset == 0 {
+		// An apphost of a framework dependent application, which runs the application DLL next to it.
+		return nil, nil
+	}
+	v, err := p.dotNetBundle(offset)
+	if err != nil {
+		return &RuntimeVerdict{
+			Runtime:    RuntimeDotNetSingleFile,
+			Confidence: ConfidenceMedium,
+			Evidence:   []string{fmt.Sprintf("bundle header offset 0x%x, bundle not readable: %v", offset, err)},
+		}, nil
+	}
+	return v, nil
+}
+
+// dotNetBundle reads the bundle header at offset: the format version, the number of files, the
+// bundle ID, since version 2 the locations of deps.json and runtimeconfig.json and flags, then a
+// manifest entry per file.
+func (p *peImage) dotNetBundle(offset int64) (*RuntimeVerdict, error) {
+	if offset < 0 || offset >= p.size {
+		return nil, fmt.Errorf("bundle header offset 0x%x is outside of the file", offset)
+	}
+	data, err := p.readAt(offset, min(p.size-offset, maxBundleManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle header: %w", err)
+	}
+	b := &bundleReader{data: data}
+	major, minor, count := b.uint32(), b.uint32(), b.uint32()
+	id := b.string()
+	var runtimeConfig, runtimeConfigSize int64
+	if major >= 2 {
+		b.int64() // deps.json offset
+		b.int64() // deps.json size
+		runtimeConfig, runtimeConfigSize = b.int64(), b.int64()
+		b.int64() // flags
+	}
+	if b.err != nil {
+		return nil, fmt.Errorf("failed to read bundle header: %w", b.err)
+	}
+	if count > maxBundledFiles {
+		return nil, errors.New("bundle manifest has too many entries")
+	}
+	v := &RuntimeVerdict{
+		Runtime:    RuntimeDotNetSingleFile,
+		Confidence: ConfidenceHigh,
+		Evidence: []string{
+			fmt.Sprintf("bundle header at offset 0x%x, version %d.%d, bundle ID %s, %d files", offset, major, minor, id, count),
+		},
+	}
+	for range count {
+		f := BundledFile{Offset: b.int64(), Size: b.int64()}
+		if major >= 6 {
+			f.CompressedSize = b.int64()
+		}
+		f.Type = BundleFileType(b.byte())
+		f.Path = b.string()
+		if b.err != nil {
+			return nil, fmt.Errorf("failed to read bundle manifest: %w", b.err)
+		}
+		v.BundledFiles = append(v.BundledFiles, f)
+	}
+
+	if runtimeConfigSize > 0 && runtimeConfigSize <= maxRuntimeConfigSize {
+		config, err := p.readAt(runtimeConfig, runtimeConfigSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundled runtimeconfig.json: %w", err)
+		}
+		if version := runtimeConfigVersion(config); version != "" {
+			v.Version = version
+			v.Evidence = append(v.Evidence, "runtimeconfig.json runtime "+version)
+		}
+	}
+	return v, nil
+}
+
+// runtimeConfigJSON is the part of runtimeconfig.json naming the runtime. Self-contained applications
+// list the included frameworks, framework dependent ones the frameworks they require.
+type runtimeConfigJSON struct {
+	RuntimeOptions struct {
+		TFM                string                   `json:"tfm"`
+		Framework          *runtimeConfigFramework  `json:"framework"`
+		Frameworks         []runtimeConfigFramework `json:"frameworks"`
+		IncludedFrameworks []runtimeConfigFramework `json:"includedFrameworks"`
+	} `json:"runtimeOptions"`
+}
+
+type runtimeConfigFramework struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// runtimeConfigVersion returns the Microsoft.NETCore.App version of a runtimeconfig.json, e.g. "8.0.1",
+// or the target framework moniker when no version is given. It is empty for malformed files, which
+// the host would reject but which do not make the bundle less of a bundle.
+func runtimeConfigVersion(data []byte) string {
+	var config runtimeConfigJSON
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ""
+	}
+	options := config.RuntimeOptions
+	frameworks := slices.Concat(options.IncludedFrameworks, options.Frameworks)
+	if options.Framework != nil {
+		frameworks = append(frameworks, *options.Framework)
+	}
+	for _, f := range frameworks {
+		if f.Name == "Microsoft.NETCore.App" && f.Version != "" {
+			return f.Version
+		}
+	}
+	return options.TFM
+}
+
+// bundleReader reads the little-endian fields and the length prefixed UTF-8 strings of .NET's
+// BinaryWriter. The first error sticks and further reads return zero values.
+type bundleReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (b *bundleReader) next(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(b.data)-b.pos {
+		b.err = errors.New("bundle data is truncated")
+		return nil
+	}
+	b.pos += n
+	return b.data[b.pos-n : b.pos]
+}
+
+func (b *bundleReader) byte() byte {
+	if d := b.next(1); d != nil {
+		return d[0]
+	}
+	return 0
+}
+
+func (b *bundleReader) uint32() uint32 {
+	if d := b.next(4); d != nil {
+		return binary.LittleEndian.Uint32(d)
+	}
+	return 0
+}
+
+func (b *bundleReader) int64() int64 {
+	if d := b.next(8); d != nil {
+		return int64(binary.LittleEndian.Uint64(d))
+	}
+	return 0
+}
+
+// string reads a string prefixed by its length as a 7-bit encoded integer, which is a uvarint.
+func (b *bundleReader) string() string {
+	if b.err != nil {
+		return ""
+	}
+	length, n := binary.Uvarint(b.data[b.pos:])
+	if n <= 0 || length > uint64(len(b.data)) {
+		b.err = errors.New("bundle string is truncated")
+		return ""
+	}
+	b.pos += n
+	return string(b.next(int(length)))
+}
+
+// detectNativeAOT looks for the debug header export and the sections of the Native AOT compiler,
+// which leaves no CLR header in the image.
+func (p *peImage) detectNativeAOT(c *runtimeClues) (*RuntimeVerdict, error) {
+	v := &RuntimeVerdict{Runtime: RuntimeDotNetNativeAOT}
+	if c.hasExport("DotNetRuntimeDebugHeader") {
+		v.Confidence = ConfidenceHigh
+		v.Evidence = append(v.Evidence, "DotNetRuntimeDebugHeader export")
+	}
+	for _, name := range []string{".managed", "hydrated"} {
+		if p.hasSection(name) {
+			v.Confidence = max(v.Confidence, ConfidenceMedium)
+			v.Evidence = append(v.Evidence, name+" section")
+		}
+	}
+	if v.Confidence == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// detectNode tells Electron apps, which embed Node.js, from Node.js itself. Both export N-API for
+// native addons, Node.js single executable applications carry the application in a resource. The
+// ASAR archive of an Electron app is a separate file, so Electron is never more than medium.
+func (p *peImage) detectNode(c *runtimeClues) (*RuntimeVerdict, error) {
+	var evidence []string
+	if c.hasExport("napi_module_register") {
+		evidence = append(evidence, "N-API exports")
+	}
+	if offset, ok := c.markers[electronMarker]; ok {
+		return &RuntimeVerdict{
+			Runtime:    RuntimeElectron,
+			Confidence: ConfidenceMedium,
+			Evidence:   append([]string{fmt.Sprintf("string %s at offset 0x%x", electronMarker, offset)}, evidence...),
+		}, nil
+	}
+	v := &RuntimeVerdict{Runtime: RuntimeNode, Confidence: ConfidenceMedium, Evidence: evidence}
+	if len(c.resources.Find(ResourceTypeRCData.ID(), ResourceName("NODE_SEA_BLOB"))) > 0 {
+		v.Confidence = ConfidenceHigh
+		v.Evidence = append(v.Evidence, "NODE_SEA_BLOB resource of a single executable application")
+	}
+	if len(v.Evidence) == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// detectLaunch4j looks for the options of the Launch4j launcher, which usually has the JAR appended.
+// The verdict is high when the central directory of the appended JAR is read.
+func (p *peImage) detectLaunch4j(c *runtimeClues) (*RuntimeVerdict, error) {
+	offset, ok := c.markers[launch4jMarker]
+	if !ok {
+		return nil, nil
+	}
+	v := &RuntimeVerdict{
+		Runtime:    RuntimeLaunch4j,
+		Confidence: ConfidenceMedium,
+		Evidence:   []string{fmt.Sprintf("launcher option prefix %s at offset 0x%x", launch4jMarker, offset)},
+	}
+	if ok, err := c.overlayPrefix(p, zipSignature); err != nil || !ok {
+		return v, err
+	}
+	// The central directory is at the end of the JAR, the reader accounts for the launcher before it.
+	jar, err := zip.NewReader(io.NewSectionReader(p.r, 0, c.overlayEnd), c.overlayEnd)
+	if err != nil {
+		v.Evidence = append(v.Evidence, fmt.Sprintf("JAR appended as overlay not readable: %v", err))
+		return v, nil
+	}
+	v.Confidence = ConfidenceHigh
+	v.Evidence = append(v.Evidence, fmt.Sprintf("JAR appended as overlay, %d entries", len(jar.File)))
+	return v, nil
+}
+
+// detectJPackage looks for the system property the jpackage launcher sets, the launcher loads the
+// bundled Java runtime through JLI_Launch. The application and the runtime are separate files, so
+// jpackage is never more than medium.
+func (p *peImage) detectJPackage(c *runtimeClues) (*RuntimeVerdict, error) {
+	offset, ok := c.markers[jpackageMarker]
+	if !ok {
+		return nil, nil
+	}
+	v := &RuntimeVerdict{
+		Runtime:    RuntimeJPackage,
+		Confidence: ConfidenceMedium,
+		Evidence:   []string{fmt.Sprintf("system property %s at offset 0x%x", jpackageMarker, offset)},
+	}
+	if _, ok := c.markers[jliMarker]; ok {
+		v.Evidence = append(v.Evidence, "Java launcher entry point "+jliMarker)
+	}
+	return v, nil
+}
+
+// detectGo reads the Go build info, binaries without it, as built before Go 1.18, are recognized by
+// the Go build ID.
+func (p *peImage) detectGo(c *runtimeClues) (*RuntimeVerdict, error) {
+	info, err := p.goBuildInfo()
+	if err != nil {
+		return &RuntimeVerdict{
+			Runtime:    RuntimeGo,
+			Confidence: ConfidenceMedium,
+			Evidence:   []string{fmt.Sprintf("Go build info not readable: %v", err)},
+		}, nil
+	}
+	if info != nil {
+		return &RuntimeVerdict{
+			Runtime:    RuntimeGo,
+			Confidence: ConfidenceHigh,
+			Version:    info.GoVersion,
+			Evidence:   []string{"Go build info of " + info.Path},
+		}, nil
+	}
+	if offset, ok := c.markers[goBuildIDMarker]; ok {
+		return &RuntimeVerdict{
+			Runtime:    RuntimeGo,
+			Confidence: ConfidenceMedium,
+			Evidence:   []string{fmt.Sprintf("Go build ID at offset 0x%x", offset)},
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,400 @@
+package fileinfo
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRuntimeImage describes an image with the parts the runtime detectors look at.
+type testRuntimeImage struct {
+	// rdata is the content of a read-only data section holding marker strings.
+	rdata string
+	// data is the content of a writable data section.
+	data []byte
+	// sections are additional empty sections.
+	sections  []string
+	exports   []string
+	resources []testResource
+	overlay   []byte
+}
+
+func (ri testRuntimeImage) build(t *testing.T) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.addSection(".text", []byte("\xc3 some code"), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	b.addSection(".rdata", []byte(ri.rdata+"\x00"), pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	if ri.data != nil {
+		b.addSection(".data", ri.data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ|pe.IMAGE_SCN_MEM_WRITE)
+	}
+	for _, name := range ri.sections {
+		b.addSection(name, make([]byte, 0x10), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	}
+	if len(ri.exports) > 0 {
+		rva := b.nextRVA()
+		edata := testExportSection(rva, ri.exports...)
+		b.addSection(".edata", edata, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+		b.setDirectory(imageDirectoryEntryExport, rva, uint32(len(edata)))
+	}
+	if len(ri.resources) > 0 {
+		rva := b.nextRVA()
+		rsrc := testResourceSection(t, rva, ri.resources)
+		b.addSection(".rsrc", rsrc, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+		b.setDirectory(imageDirectoryEntryResource, rva, uint32(len(rsrc)))
+	}
+	b.overlay = ri.overlay
+	return b.build(t)
+}
+
+// overlayOffset returns the file offset the overlay of the image starts at.
+func (ri testRuntimeImage) overlayOffset(t *testing.T) int64 {
+	return int64(len(ri.build(t)) - len(ri.overlay))
+}
+
+// testExportSection lays out an export directory exporting the names, all pointing at the section start.
+func testExportSection(rva uint32, names ...string) []byte {
+	n := uint32(len(names))
+	addresses, namePointers, ordinals := uint32(40), 40+4*n, 40+8*n
+	data := make([]byte, ordinals+2*n)
+	binary.LittleEndian.PutUint32(data[16:], 1)
+	binary.LittleEndian.PutUint32(data[20:], n)
+	binary.LittleEndian.PutUint32(data[24:], n)
+	binary.LittleEndian.PutUint32(data[28:], rva+addresses)
+	binary.LittleEndian.PutUint32(data[32:], rva+namePointers)
+	binary.LittleEndian.PutUint32(data[36:], rva+ordinals)
+	for i, name := range names {
+		nameRVA := rva + uint32(len(data))
+		data = append(data, name+"\x00"...)
+		binary.LittleEndian.PutUint32(data[addresses+4*uint32(i):], rva)
+		binary.LittleEndian.PutUint32(data[namePointers+4*uint32(i):], nameRVA)
+		binary.LittleEndian.PutUint16(data[ordinals+2*uint32(i):], uint16(i))
+	}
+	return data
+}
+
+// testPyInstallerCookie encodes the cookie of an archive of the given length.
+func testPyInstallerCookie(length uint32, pyVersion uint32, library string) []byte {
+	cookie := []byte(pyInstallerMagic)
+	cookie = binary.BigEndian.AppendUint32(cookie, length)
+	cookie = binary.BigEndian.AppendUint32(cookie, 0x40)
+	cookie = binary.BigEndian.AppendUint32(cookie, length-0x40-pyInstallerCookieSize)
+	cookie = binary.BigEndian.AppendUint32(cookie, pyVersion)
+	name := make([]byte, 64)
+	copy(name, library)
+	return append(cookie, name...)
+}
+
+func detectTestRuntimes(t *testing.T, image []byte) []RuntimeVerdict {
+	t.Helper()
+	verdicts, err := DetectRuntimes(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	return verdicts
+}
+
+func TestDetectRuntimesPyInstaller(t *testing.T) {
+	archive := append(bytes.Repeat([]byte{0x78}, 0x100), testPyInstallerCookie(0x100+pyInstallerCookieSize, 311, "python311.dll")...)
+	ri := testRuntimeImage{rdata: "_MEIPASS2\x00_PYI_ARCHIVE_FILE", overlay: archive}
+	image := ri.build(t)
+
+	verdicts := detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	v := verdicts[0]
+	assert.Equal(t, RuntimePyInstaller, v.Runtime)
+	assert.Equal(t, ConfidenceHigh, v.Confidence)
+	assert.Equal(t, "3.11", v.Version)
+	assert.Contains(t, v.Evidence[0], "PyInstaller archive cookie at offset")
+	assert.Contains(t, v.Evidence, "Python library python311.dll")
+}
+
+func TestDetectRuntimesPyInstallerSigned(t *testing.T) {
+	// The certificate table follows the archive, the cookie is searched before it.
+	archive := append(bytes.Repeat([]byte{0x78}, 0x100), testPyInstallerCookie(0x100+pyInstallerCookieSize, 38, "python38.dll")...)
+	certificate := make([]byte, 0x1000)
+	b := newTestPE()
+	b.addSection(".text", []byte("\xc3"), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	b.overlay = append(archive, certificate...)
+	offset := len(b.build(t)) - len(certificate)
+	b.setDirectory(imageDirectoryEntrySecurity, uint32(offset), uint32(len(certificate)))
+	image := b.build(t)
+
+	verdicts := detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimePyInstaller, verdicts[0].Runtime)
+	assert.Equal(t, "3.8", verdicts[0].Version)
+}
+
+func TestDetectRuntimesPy2exe(t *testing.T) {
+	image := testRuntimeImage{resources: []testResource{
+		{typ: "PYTHONSCRIPT", name: uint16(1), lang: 0x409, data: []byte("\x12\x34\x56\x78")},
+		{typ: "PYTHONDLL", name: "PYTHON27.DLL", lang: 0x409, data: []byte("MZ")},
+	}, overlay: []byte("PK\x03\x04library.zip")}.build(t)
+
+	verdicts := detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeVerdict{
+		Runtime:    RuntimePy2exe,
+		Confidence: ConfidenceHigh,
+		Version:    "2.7",
+		Evidence:   []string{"PYTHONSCRIPT resource", "Python library PYTHON27.DLL", "ZIP archive appended as overlay"},
+	}, verdicts[0])
+}
+
+func TestPythonDLLVersion(t *testing.T) {
+	for name, version := range map[string]string{
+		"python311.dll":  "3.11",
+		"PYTHON27.DLL":   "2.7",
+		"python38_d.dll": "3.8",
+		"python3.dll":    "",
+		"pythoncom.dll":  "",
+		"kernel32.dll":   "",
+	} {
+		assert.Equal(t, version, pythonDLLVersion(name), name)
+	}
+}
+
+// testDotNetBundle builds an apphost with a single-file bundle of format version 6 in its overlay:
+// the runtimeconfig.json, an assembly and the header with the manifest.
+func testDotNetBundle(t *testing.T, runtimeConfig string) []byte {
+	t.Helper()
+	placeholder := append(make([]byte, 8), dotNetBundleSignature...)
+	ri := testRuntimeImage{data: append(make([]byte, 0x20), placeholder...)}
+	assembly := []byte("MZ managed code")
+	ri.overlay = make([]byte, len(runtimeConfig)+len(assembly)+0x100)
+	base := ri.overlayOffset(t)
+
+	overlay := append([]byte(runtimeConfig), assembly...)
+	header := base + int64(len(overlay))
+	appendString := func(s string) {
+		overlay = binary.AppendUvarint(overlay, uint64(len(s)))
+		overlay = append(overlay, s...)
+	}
+	overlay = binary.LittleEndian.AppendUint32(overlay, 6)
+	overlay = binary.LittleEndian.AppendUint32(overlay, 0)
+	overlay = binary.LittleEndian.AppendUint32(overlay, 2)
+	appendString("bundle-id")
+	for _, v := range []int64{0, 0, base, int64(len(runtimeConfig)), 0} {
+		overlay = binary.LittleEndian.AppendUint64(overlay, uint64(v))
+	}
+	for _, f := range []BundledFile{
+		{Path: "Service.runtimeconfig.json", Type: BundleFileRuntimeConfigJSON, Offset: base, Size: int64(len(runtimeConfig))},
+		{Path: "Service.dll", Type: BundleFileAssembly, Offset: base + int64(len(runtimeConfig)), Size: int64(len(assembly)), CompressedSize: 10},
+	} {
+		for _, v := range []int64{f.Offset, f.Size, f.CompressedSize} {
+			overlay = binary.LittleEndian.AppendUint64(overlay, uint64(v))
+		}
+		overlay = append(overlay, byte(f.Type))
+		appendString(f.Path)
+	}
+	require.LessOrEqual(t, len(overlay), len(ri.overlay))
+	copy(ri.overlay, overlay)
+	binary.LittleEndian.PutUint64(ri.data[0x20:], uint64(header))
+	return ri.build(t)
+}
+
+func TestDetectRuntimesDotNetSingleFile(t *testing.T) {
+	config := `{"runtimeOptions": {"tfm": "net8.0", "includedFrameworks": [{"name": "Microsoft.NETCore.App", "version": "8.0.4"}]}}`
+	verdicts := detectTestRuntimes(t, testDotNetBundle(t, config))
+	require.Len(t, verdicts, 1)
+	v := verdicts[0]
+	assert.Equal(t, RuntimeDotNetSingleFile, v.Runtime)
+	assert.Equal(t, ConfidenceHigh, v.Confidence)
+	assert.Equal(t, "8.0.4", v.Version)
+	assert.Contains(t, v.Evidence[0], "version 6.0, bundle ID bundle-id, 2 files")
+	require.Len(t, v.BundledFiles, 2)
+	assert.Equal(t, "Service.runtimeconfig.json", v.BundledFiles[0].Path)
+	assert.Equal(t, BundleFileRuntimeConfigJSON, v.BundledFiles[0].Type)
+	assert.Equal(t, BundledFile{Path: "Service.dll", Type: BundleFileAssembly, Offset: v.BundledFiles[0].Offset + int64(len(config)), Size: 15, CompressedSize: 10}, v.BundledFiles[1])
+	assert.Equal(t, "Assembly", v.BundledFiles[1].Type.String())
+}
+
+func TestDetectRuntimesDotNetAppHost(t *testing.T) {
+	// Without a bundle the header offset of the apphost stays zero.
+	image := testRuntimeImage{data: append(make([]byte, 8), dotNetBundleSignature...)}.build(t)
+	assert.Empty(t, detectTestRuntimes(t, image))
+}
+
+func TestRuntimeConfigVersion(t *testing.T) {
+	assert.Equal(t, "6.0.0", runtimeConfigVersion([]byte(`{"runtimeOptions": {"tfm": "net6.0", "framework": {"name": "Microsoft.NETCore.App", "version": "6.0.0"}}}`)))
+	assert.Equal(t, "8.0.0", runtimeConfigVersion([]byte(`{"runtimeOptions": {"frameworks": [{"name": "Microsoft.AspNetCore.App", "version": "9.0.0"}, {"name": "Microsoft.NETCore.App", "version": "8.0.0"}]}}`)))
+	assert.Equal(t, "net9.0", runtimeConfigVersion([]byte(`{"runtimeOptions": {"tfm": "net9.0"}}`)))
+	assert.Empty(t, runtimeConfigVersion([]byte(`{"runtimeOptions": `)))
+}
+
+func TestDetectRuntimesDotNet(t *testing.T) {
+	image := testDotNetImage(t, false, clrFlagILOnly, testDotNetMetadata(0), true)
+	verdicts := detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeVerdict{
+		Runtime:    RuntimeDotNet,
+		Confidence: ConfidenceHigh,
+		Version:    ".NETCoreApp,Version=v8.0",
+		Evidence:   []string{"CLR header, metadata version v4.0.30319", "TargetFrameworkAttribute .NETCoreApp,Version=v8.0", "ReadyToRun code"},
+	}, verdicts[0])
+}
+
+func TestDetectRuntimesNativeAOT(t *testing.T) {
+	image := testRuntimeImage{sections: []string{".managed", "hydrated"}, exports: []string{"DotNetRuntimeDebugHeader"}}.build(t)
+	verdicts := detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeVerdict{
+		Runtime:    RuntimeDotNetNativeAOT,
+		Confidence: ConfidenceHigh,
+		Evidence:   []string{"DotNetRuntimeDebugHeader export", ".managed section", "hydrated section"},
+	}, verdicts[0])
+
+	image = testRuntimeImage{sections: []string{".managed"}}.build(t)
+	verdicts = detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, ConfidenceMedium, verdicts[0].Confidence)
+}
+
+func TestDetectRuntimesElectronAndNode(t *testing.T) {
+	napi := []string{"napi_create_function", "napi_module_register"}
+	image := testRuntimeImage{rdata: "ELECTRON_RUN_AS_NODE", exports: napi}.build(t)
+	verdicts := detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeElectron, verdicts[0].Runtime)
+	assert.Equal(t, ConfidenceMedium, verdicts[0].Confidence)
+	assert.Equal(t, "N-API exports", verdicts[0].Evidence[1])
+
+	image = testRuntimeImage{exports: napi}.build(t)
+	verdicts = detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeVerdict{Runtime: RuntimeNode, Confidence: ConfidenceMedium, Evidence: []string{"N-API exports"}}, verdicts[0])
+
+	image = testRuntimeImage{exports: napi, resources: []testResource{
+		{typ: uint16(ResourceTypeRCData), name: "NODE_SEA_BLOB", lang: 0x409, data: []byte("sea")},
+	}}.build(t)
+	verdicts = detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeNode, verdicts[0].Runtime)
+	assert.Equal(t, ConfidenceHigh, verdicts[0].Confidence)
+}
+
+// testJAR returns a JAR holding a manifest and a class.
+func testJAR(t *testing.T) []byte {
+	t.Helper()
+	var jar bytes.Buffer
+	w := zip.NewWriter(&jar)
+	for _, name := range []string{"META-INF/MANIFEST.MF", "com/example/Main.class"} {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return jar.Bytes()
+}
+
+func TestDetectRuntimesJavaLaunchers(t *testing.T) {
+	image := testRuntimeImage{rdata: "--l4j-debug", overlay: testJAR(t)}.build(t)
+	verdicts := detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeLaunch4j, verdicts[0].Runtime)
+	assert.Equal(t, ConfidenceHigh, verdicts[0].Confidence)
+	assert.Equal(t, "JAR appended as overlay, 2 entries", verdicts[0].Evidence[1])
+
+	// A local file header without a central directory.
+	image = testRuntimeImage{rdata: "--l4j-debug", overlay: []byte("PK\x03\x04META-INF/MANIFEST.MF")}.build(t)
+	verdicts = detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, ConfidenceMedium, verdicts[0].Confidence)
+	assert.Contains(t, verdicts[0].Evidence[1], "JAR appended as overlay not readable")
+
+	// The launcher option prefix alone.
+	verdicts = detectTestRuntimes(t, testRuntimeImage{rdata: "--l4j-debug"}.build(t))
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, ConfidenceMedium, verdicts[0].Confidence)
+	assert.Len(t, verdicts[0].Evidence, 1)
+
+	image = testRuntimeImage{rdata: "-Djpackage.app-path=\x00JLI_Launch"}.build(t)
+	verdicts = detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeJPackage, verdicts[0].Runtime)
+	assert.Equal(t, ConfidenceMedium, verdicts[0].Confidence)
+	assert.Equal(t, "Java launcher entry point JLI_Launch", verdicts[0].Evidence[1])
+}
+
+func TestDetectRuntimesGo(t *testing.T) {
+	verdicts := detectTestRuntimes(t, testGoImage(t, false))
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeVerdict{
+		Runtime:    RuntimeGo,
+		Confidence: ConfidenceHigh,
+		Version:    "go1.22.3",
+		Evidence:   []string{"Go build info of example.com/tools/cmd/agent"},
+	}, verdicts[0])
+
+	image := testRuntimeImage{rdata: goBuildIDMarker + `abc/def"`}.build(t)
+	verdicts = detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeGo, verdicts[0].Runtime)
+	assert.Equal(t, ConfidenceMedium, verdicts[0].Confidence)
+}
+
+func TestDetectRuntimesOrder(t *testing.T) {
+	// The bootloader string alone is medium, Launch4j with its JAR high.
+	image := testRuntimeImage{rdata: "_MEIPASS\x00--l4j-debug", overlay: testJAR(t)}.build(t)
+	verdicts := detectTestRuntimes(t, image)
+	require.Len(t, verdicts, 2)
+	assert.Equal(t, RuntimeLaunch4j, verdicts[0].Runtime)
+	assert.Equal(t, RuntimePyInstaller, verdicts[1].Runtime)
+	assert.Equal(t, ConfidenceMedium, verdicts[1].Confidence)
+	assert.Empty(t, verdicts[1].Version)
+}
+
+func TestDetectRuntimesCorruptClues(t *testing.T) {
+	// Import, resource and CLR directories pointing outside of the sections.
+	b := newTestPE()
+	b.addSection(".rdata", []byte(meipassMarker+"\x00"), pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.setDirectory(imageDirectoryEntryImport, 0x7fff0000, 0x28)
+	b.setDirectory(imageDirectoryEntryResource, 0x7fff0000, 0x100)
+	b.setDirectory(imageDirectoryEntryCLR, 0x7fff0000, 72)
+	verdicts := detectTestRuntimes(t, b.build(t))
+	require.Len(t, verdicts, 2)
+	assert.Equal(t, RuntimePyInstaller, verdicts[0].Runtime)
+	assert.Equal(t, RuntimeDotNet, verdicts[1].Runtime)
+	assert.Equal(t, ConfidenceMedium, verdicts[1].Confidence)
+	assert.Contains(t, verdicts[1].Evidence[0], "CLR header not readable")
+
+	// A bundle header offset past the end of the file.
+	data := binary.LittleEndian.AppendUint64(nil, 0x7fff0000)
+	verdicts = detectTestRuntimes(t, testRuntimeImage{data: append(data, dotNetBundleSignature...)}.build(t))
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeDotNetSingleFile, verdicts[0].Runtime)
+	assert.Equal(t, ConfidenceMedium, verdicts[0].Confidence)
+	assert.Contains(t, verdicts[0].Evidence[0], "bundle not readable")
+}
+
+func TestDetectRuntimesNative(t *testing.T) {
+	assert.Empty(t, detectTestRuntimes(t, testUnsignedImage(t, false)))
+}
+
+func TestScanSections(t *testing.T) {
+	// The marker crosses the boundary of the first chunk.
+	data := make([]byte, scanChunkSize+0x100)
+	copy(data[scanChunkSize-4:], "ELECTRON_RUN_AS_NODE")
+	b := newTestPE()
+	b.addSection(".rdata", data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	image := b.build(t)
+	img, err := openPEImage(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+
+	found, err := img.scanSections("ELECTRON_RUN_AS_NODE", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"ELECTRON_RUN_AS_NODE": 0x200 + scanChunkSize - 4}, found)
+}
+
+func TestWinFileInfoDetectRuntimes(t *testing.T) {
+	wf, err := NewWinFileInfo(testWriteFile(t, "agent.exe", testGoImage(t, false)))
+	require.NoError(t, err)
+	verdicts, err := wf.DetectRuntimes()
+	require.NoError(t, err)
+	require.Len(t, verdicts, 1)
+	assert.Equal(t, RuntimeGo, verdicts[0].Runtime)
+}
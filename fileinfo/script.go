@@ -0,0 +1,173 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	scriptSignatureBegin = "SIG # Begin signature block"
+	scriptSignatureEnd   = "SIG # End signature block"
+)
+
+// powerShellExtensions are the file types signed by the PowerShell SIP.
+var powerShellExtensions = []string{".ps1", ".psm1", ".psd1", ".ps1xml", ".psc1", ".cdxml"}
+
+// scriptCommentStyles are the comment markers around signature block lines, "# " for
+// scripts and modules and XML comments for .ps1xml, .psc1 and .cdxml files.
+var scriptCommentStyles = [][2]string{{"# ", ""}, {"<!-- ", " -->"}}
+
+// powerShellScript is a PowerShell script split into the signed text and the signature block.
+type powerShellScript struct {
+	// content is the signed text, everything before the line break preceding the signature block.
+	content string
+	// signature is the DER encoded PKCS#7 signed data, nil for unsigned scripts.
+	signature []byte
+}
+
+func isPowerShellScript(path string) bool {
+	return slices.Contains(powerShellExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+func readPowerShellScript(path string) (*powerShellScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return parsePowerShellScript(data)
+}
+
+// parsePowerShellScript decodes the script text and extracts the base64 encoded signature block.
+// Scripts are read as UTF-16 when they start with a UTF-16 byte order mark and as UTF-8 otherwise.
+func parsePowerShellScript(data []byte) (*powerShellScript, error) {
+	text, err := decodeScriptText(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, style := range scriptCommentStyles {
+		begin := strings.LastIndex(text, style[0]+scriptSignatureBegin+style[1])
+		if begin < 0 {
+			continue
+		}
+		script := &powerShellScript{content: text[:begin]}
+		// The block starts on a new line, the line break is not signed.
+		script.content = strings.TrimSuffix(script.content, "\n")
+		script.content = strings.TrimSuffix(script.content, "\r")
+
+		var encoded strings.Builder
+		lines := strings.Split(text[begin:], "\n")
+		end := false
+		for _, line := range lines[1:] {
+			line = strings.TrimRight(line, "\r")
+			if line == style[0]+scriptSignatureEnd+style[1] {
+				end = true
+				break
+			}
+			if !strings.HasPrefix(line, style[0]) || !strings.HasSuffix(line, style[1]) {
+				return nil, fmt.Errorf("unexpected line in signature block: %q", line)
+			}
+			encoded.WriteString(strings.TrimSuffix(strings.TrimPrefix(line, style[0]), style[1]))
+		}
+		if !end {
+			return nil, errors.New("signature block is not terminated")
+		}
+		script.signature, err = base64.StdEncoding.DecodeString(encoded.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signature block: %w", err)
+		}
+		return script, nil
+	}
+	return &powerShellScript{content: text}, nil
+}
+
+func decodeScriptText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return decodeUTF16(data[2:], binary.LittleEndian)
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return decodeUTF16(data[2:], binary.BigEndian)
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		data = data[3:]
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("script is not valid UTF-8 or UTF-16 text")
+	}
+	return string(data), nil
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) (string, error) {
+	if len(data)%2 != 0 {
+		return "", errors.New("script has an odd number of UTF-16 bytes")
+	}
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// digest hashes the signed text the way the PowerShell SIP does, as UTF-16LE without a byte order mark.
+func (s *powerShellScript) digest(h crypto.Hash) ([]byte, error) {
+	u := utf16.Encode([]rune(s.content))
+	b := make([]byte, 2*len(u))
+	for i, v := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], v)
+	}
+	return digest(h, b), nil
+}
+
+func (s *powerShellScript) signedData() (*signedData, error) {
+	if s.signature == nil {
+		return nil, nil
+	}
+	sd, err := parseSignedData(s.signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PKCS#7 signed data: %w", err)
+	}
+	return sd, nil
+}
+
+func (s *powerShellScript) certificates() (*Certificates, error) {
+	sd, err := s.signedData()
+	if err != nil || sd == nil {
+		return &Certificates{}, err
+	}
+	return newCertificates([]*signedData{sd}), nil
+}
+
+func (s *powerShellScript) verifySignature() (*SignatureVerification, error) {
+	sd, err := s.signedData()
+	if err != nil {
+		return nil, err
+	}
+	if sd == nil {
+		return &SignatureVerification{Status: SignatureNotSigned, Reason: "script has no signature block"}, nil
+	}
+	return verifyIndirectData(sd, s.digest)
+}
+
+func (s *powerShellScript) verifySignatures() ([]*SignatureVerification, error) {
+	certs, err := s.certificates()
+	if err != nil {
+		return nil, err
+	}
+	verifications := make([]*SignatureVerification, 0, len(certs.Signatures))
+	for _, sig := range certs.Signatures {
+		v, err := verifyIndirectData(sig.signedData, s.digest)
+		if err != nil {
+			return nil, err
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications, nil
+}
@@ -0,0 +1,144 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var oidSpcSipInfo = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 30}
+
+func testUTF16LE(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// testSignScript appends a signature block the way Set-AuthenticodeSignature does.
+func testSignScript(t *testing.T, pki *testPKI, script string, style [2]string) string {
+	t.Helper()
+	h := crypto.SHA256
+	digestAlg := derSequence(t, derMarshal(t, testDigestOIDs[h]), derMarshal(t, asn1.NullRawValue))
+	sipInfo := derSequence(t, derMarshal(t, 65536), derMarshal(t, []byte("0123456789abcdef")))
+	content := derSequence(t,
+		derSequence(t, derMarshal(t, oidSpcSipInfo), sipInfo),
+		derSequence(t, digestAlg, derMarshal(t, digest(h, testUTF16LE(script)))),
+	)
+	signature := base64.StdEncoding.EncodeToString(buildTestSignedData(t, testSignOptions{
+		hash:    h,
+		content: content,
+		certs:   []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer:  pki.leaf,
+		key:     pki.leafKey,
+	}))
+
+	var b strings.Builder
+	b.WriteString(script)
+	b.WriteString("\r\n" + style[0] + scriptSignatureBegin + style[1] + "\r\n")
+	for len(signature) > 0 {
+		n := min(64, len(signature))
+		b.WriteString(style[0] + signature[:n] + style[1] + "\r\n")
+		signature = signature[n:]
+	}
+	b.WriteString(style[0] + scriptSignatureEnd + style[1] + "\r\n")
+	return b.String()
+}
+
+const testScript = "param([string]$Name = 'Světe')\r\nWrite-Output \"Hello, $Name\"\r\n"
+
+func TestVerifyScriptSignature(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	signed := testSignScript(t, pki, testScript, scriptCommentStyles[0])
+	xmlSigned := testSignScript(t, pki, "<Types></Types>", scriptCommentStyles[1])
+
+	tests := map[string]struct {
+		name string
+		data []byte
+	}{
+		"utf-8":          {"script.ps1", []byte(signed)},
+		"utf-8 bom":      {"module.psm1", append([]byte{0xef, 0xbb, 0xbf}, signed...)},
+		"utf-16le bom":   {"manifest.psd1", append([]byte{0xff, 0xfe}, testUTF16LE(signed)...)},
+		"xml comments":   {"types.ps1xml", []byte(xmlSigned)},
+		"upper case ext": {"SCRIPT.PS1", []byte(signed)},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			wf, err := NewWinFileInfo(testWriteFile(t, tt.name, tt.data))
+			require.NoError(t, err)
+
+			v, err := wf.VerifySignature()
+			require.NoError(t, err)
+			assert.Equal(t, SignatureValid, v.Status, v.Reason)
+			assert.Equal(t, crypto.SHA256, v.DigestAlgorithm)
+
+			certs, err := wf.GetCertificates()
+			require.NoError(t, err)
+			assert.True(t, certs.SignedBy("Test Publisher"))
+			require.Len(t, certs.Signatures, 1)
+
+			verifications, err := wf.VerifySignatures()
+			require.NoError(t, err)
+			require.Len(t, verifications, 1)
+			assert.True(t, verifications[0].Valid())
+		})
+	}
+}
+
+func TestVerifyScriptSignatureTampered(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	signed := testSignScript(t, pki, testScript, scriptCommentStyles[0])
+	tampered := strings.Replace(signed, "Hello", "Pwned", 1)
+
+	wf, err := NewWinFileInfo(testWriteFile(t, "script.ps1", []byte(tampered)))
+	require.NoError(t, err)
+	v, err := wf.VerifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureHashMismatch, v.Status)
+
+	// Line endings are part of the signed text.
+	wf, err = NewWinFileInfo(testWriteFile(t, "script.ps1", []byte(strings.Replace(signed, "\r\n", "\n", 1))))
+	require.NoError(t, err)
+	v, err = wf.VerifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureHashMismatch, v.Status)
+}
+
+func TestUnsignedScript(t *testing.T) {
+	wf, err := NewWinFileInfo(testWriteFile(t, "script.ps1", []byte(testScript)))
+	require.NoError(t, err)
+
+	v, err := wf.VerifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureNotSigned, v.Status)
+
+	certs, err := wf.GetCertificates()
+	require.NoError(t, err)
+	assert.Empty(t, certs.Certificates)
+	assert.Nil(t, certs.Signer)
+}
+
+func TestParsePowerShellScriptInvalid(t *testing.T) {
+	tests := map[string]string{
+		"not terminated": testScript + "\r\n# SIG # Begin signature block\r\n# MIIB\r\n",
+		"bad base64":     testScript + "\r\n# SIG # Begin signature block\r\n# !!!!\r\n# SIG # End signature block\r\n",
+		"foreign line":   testScript + "\r\n# SIG # Begin signature block\r\nMIIB\r\n# SIG # End signature block\r\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parsePowerShellScript([]byte(data))
+			require.Error(t, err)
+		})
+	}
+	_, err := parsePowerShellScript([]byte{0xff, 0xfe, 0x41})
+	require.Error(t, err)
+}
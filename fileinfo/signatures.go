@@ -0,0 +1,124 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var oidNestedSignature = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 4, 1}
+
+// Signature is a single Authenticode signature of a file. It is either a top level
+// WIN_CERTIFICATE entry or a signature nested in the unsigned attributes
+// (1.3.6.1.4.1.311.2.4.1) of another signature, as used for dual SHA-1/SHA-256 signing.
+type Signature struct {
+	// Nested reports whether the signature is nested in another signature.
+	Nested bool
+	// Signer describes the signer of the signature.
+	Signer *SignerInfo
+	// Certificates are the certificates embedded in this signature.
+	Certificates []*x509.Certificate
+	// DigestAlgorithm is the algorithm of the signed image digest, zero when not recognized.
+	DigestAlgorithm crypto.Hash
+	// Timestamp is the timestamp of the signature, nil when it is not timestamped.
+	Timestamp *Timestamp
+	// PageHashes are the signed per-page hashes, nil when the signature has none.
+	PageHashes *PageHashes
+	// NestedErr is the error parsing signatures nested in this one, which are skipped.
+	NestedErr error
+
+	signedData *signedData
+}
+
+func newSignature(sd *signedData, nested bool) *Signature {
+	sig := &Signature{
+		Nested:          nested,
+		Signer:          sd.signer,
+		Certificates:    sd.certificates,
+		DigestAlgorithm: sd.signer.DigestAlgorithm,
+		Timestamp:       sd.signer.Timestamp,
+		signedData:      sd,
+	}
+	if idc, err := parseIndirectData(sd.content); err == nil {
+		sig.DigestAlgorithm = hashForOID(idc.MessageDigest.DigestAlgorithm.Algorithm)
+		if idc.Data.Type.Equal(oidSpcPeImageData) {
+			// Malformed page hashes only fail page hash verification, not the signature.
+			sig.PageHashes, _ = parsePageHashes(idc.Data.Value.FullBytes)
+		}
+	}
+	return sig
+}
+
+// collectSignatures appends sd and all signatures nested in it to signatures. Nested signatures
+// are unsigned attributes anyone can add, a malformed one is skipped and recorded in NestedErr of
+// the signature it is nested in.
+func collectSignatures(signatures []*Signature, sd *signedData, nested bool) []*Signature {
+	sig := newSignature(sd, nested)
+	signatures = append(signatures, sig)
+	for _, attr := range sd.signer.UnsignedAttributes {
+		if !attr.Type.Equal(oidNestedSignature) {
+			continue
+		}
+		for _, value := range attr.Values {
+			inner, err := parseSignedData(value.FullBytes)
+			if err != nil {
+				sig.NestedErr = errors.Join(sig.NestedErr, fmt.Errorf("failed to parse nested signature: %w", err))
+				continue
+			}
+			signatures = collectSignatures(signatures, inner, true)
+		}
+	}
+	return signatures
+}
+
+// HasSignatureWithDigestAlgorithm reports whether any signature, primary or nested, declares the
+// given digest algorithm. It only checks the algorithm, not that the signature verifies, use
+// WinFileInfo.HasValidSignatureWithDigest to require e.g. at least one valid SHA-256 signature.
+func (c *Certificates) HasSignatureWithDigestAlgorithm(h crypto.Hash) bool {
+	if c == nil {
+		return false
+	}
+	for _, sig := range c.Signatures {
+		if sig.DigestAlgorithm == h {
+			return true
+		}
+	}
+	return false
+}
+
+func verifySignatures(path string) ([]*SignatureVerification, error) {
+	if isPowerShellScript(path) {
+		script, err := readPowerShellScript(path)
+		if err != nil {
+			return nil, err
+		}
+		return script.verifySignatures()
+	}
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.verifySignatures()
+}
+
+// verifySignatures verifies every signature of the image, primary and nested, in the order of Certificates.Signatures.
+func (p *peImage) verifySignatures() ([]*SignatureVerification, error) {
+	certs, err := extractCertificates(p)
+	if err != nil {
+		return nil, err
+	}
+	verifications := make([]*SignatureVerification, 0, len(certs.Signatures))
+	for _, sig := range certs.Signatures {
+		v, err := p.verifySignedData(sig.signedData)
+		if err != nil {
+			return nil, err
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications, nil
+}
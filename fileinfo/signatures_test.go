@@ -0,0 +1,145 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDualSignedImage signs the image with SHA-1 and nests a timestamped SHA-256 signature.
+func testDualSignedImage(t *testing.T, pki *testPKI) []byte {
+	tsa, tsaKey := newTestTSA(t, pki)
+	image := testPreparePE(testUnsignedImage(t, false))
+	nested := buildTestSignedData(t, testSignOptions{
+		hash:     crypto.SHA256,
+		content:  testSpcIndirectData(t, crypto.SHA256, testImageDigest(image, crypto.SHA256)),
+		certs:    []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer:   pki.leaf,
+		key:      pki.leafKey,
+		unsigned: testRFC3161Attribute(t, tsa, tsaKey, pki, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+	})
+	return testSignPEWith(t, image, testSignOptions{
+		hash:   crypto.SHA1,
+		certs:  []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer: pki.leaf,
+		key:    pki.leafKey,
+		unsigned: func([]byte) [][]byte {
+			return [][]byte{testAttribute(t, oidNestedSignature, nested)}
+		},
+	})
+}
+
+func TestNestedSignatures(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	certs, err := extractCertificates(testOpenPE(t, testDualSignedImage(t, pki)))
+	require.NoError(t, err)
+
+	require.Len(t, certs.Signatures, 2)
+	primary, nested := certs.Signatures[0], certs.Signatures[1]
+
+	assert.False(t, primary.Nested)
+	assert.Equal(t, crypto.SHA1, primary.DigestAlgorithm)
+	assert.Nil(t, primary.Timestamp)
+	assert.Same(t, certs.Signer, primary.Signer)
+
+	assert.True(t, nested.Nested)
+	assert.Equal(t, crypto.SHA256, nested.DigestAlgorithm)
+	assert.Equal(t, pki.leaf, nested.Signer.Certificate)
+	require.NotNil(t, nested.Timestamp)
+	assert.Equal(t, 2024, nested.Timestamp.Time.Year())
+
+	assert.True(t, certs.HasSignatureWithDigestAlgorithm(crypto.SHA256))
+	assert.True(t, certs.HasSignatureWithDigestAlgorithm(crypto.SHA1))
+	assert.False(t, certs.HasSignatureWithDigestAlgorithm(crypto.SHA512))
+	assert.Len(t, certs.Certificates, 4)
+}
+
+func TestHasValidSignatureWithDigest(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	wfi, err := NewWinFileInfo(testWriteFile(t, "dual.exe", testDualSignedImage(t, pki)))
+	require.NoError(t, err)
+	ok, err := wfi.HasValidSignatureWithDigest(crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// The nested SHA-256 signature signs another image digest.
+	image := testPreparePE(testUnsignedImage(t, false))
+	nested := buildTestSignedData(t, testSignOptions{
+		hash:    crypto.SHA256,
+		content: testSpcIndirectData(t, crypto.SHA256, make([]byte, 32)),
+		certs:   []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer:  pki.leaf,
+		key:     pki.leafKey,
+	})
+	image = testSignPEWith(t, image, testSignOptions{
+		hash:   crypto.SHA1,
+		certs:  []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer: pki.leaf,
+		key:    pki.leafKey,
+		unsigned: func([]byte) [][]byte {
+			return [][]byte{testAttribute(t, oidNestedSignature, nested)}
+		},
+	})
+	wfi, err = NewWinFileInfo(testWriteFile(t, "mismatch.exe", image))
+	require.NoError(t, err)
+	certs, err := wfi.GetCertificates()
+	require.NoError(t, err)
+	assert.True(t, certs.HasSignatureWithDigestAlgorithm(crypto.SHA256))
+	ok, err = wfi.HasValidSignatureWithDigest(crypto.SHA256)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = wfi.HasValidSignatureWithDigest(crypto.SHA1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestVerifyNestedSignatures(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	path := testWriteFile(t, "dual.exe", testDualSignedImage(t, pki))
+	wfi, err := NewWinFileInfo(path)
+	require.NoError(t, err)
+
+	verifications, err := wfi.VerifySignatures()
+	require.NoError(t, err)
+	require.Len(t, verifications, 2)
+	for _, v := range verifications {
+		assert.Equal(t, SignatureValid, v.Status, v.Reason)
+	}
+	assert.Equal(t, crypto.SHA1, verifications[0].DigestAlgorithm)
+	assert.Equal(t, crypto.SHA256, verifications[1].DigestAlgorithm)
+}
+
+func TestSingleSignatureHasNoSHA256(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	image := testSignPE(t, testUnsignedImage(t, false), pki, crypto.SHA1)
+	certs, err := extractCertificates(testOpenPE(t, image))
+	require.NoError(t, err)
+	require.Len(t, certs.Signatures, 1)
+	assert.False(t, certs.HasSignatureWithDigestAlgorithm(crypto.SHA256))
+}
+
+func TestMalformedNestedSignature(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	// A nested signature attribute was added after signing.
+	image := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		certs:  []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer: pki.leaf,
+		key:    pki.leafKey,
+		unsigned: func([]byte) [][]byte {
+			return [][]byte{testAttribute(t, oidNestedSignature, derMarshal(t, "not a signature"))}
+		},
+	})
+	img := testOpenPE(t, image)
+	certs, err := extractCertificates(img)
+	require.NoError(t, err)
+	require.Len(t, certs.Signatures, 1)
+	require.ErrorContains(t, certs.Signatures[0].NestedErr, "failed to parse nested signature")
+
+	v, err := img.verifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureValid, v.Status, v.Reason)
+}
@@ -0,0 +1,328 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SignerPolicy describes which signing certificates are trusted.
+//
+// All conditions set on a policy must match (AND). AllOf requires every sub-policy
+// to match and AnyOf requires at least one sub-policy to match, so policies can be
+// combined freely. An allow-list of trusted publishers is a policy with one AnyOf
+// entry per publisher. A policy without any condition never matches.
+//
+// Policies can be loaded from JSON or YAML, for example:
+//
+//	anyOf:
+//	  - description: Contoso release signing
+//	    subject:
+//	      organization: Contoso Ltd
+//	      country: US
+//	    issuer:
+//	      commonName: Contoso Code Signing CA
+//	    eku: [codeSigning]
+//	  - thumbprintSha256: 3a7b...e1
+type SignerPolicy struct {
+	// Description is a free-form label, e.g. the publisher name.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// ThumbprintSHA1 and ThumbprintSHA256 are hex encoded certificate fingerprints.
+	// Case, spaces and colons are ignored.
+	ThumbprintSHA1   string `json:"thumbprintSha1,omitempty" yaml:"thumbprintSha1,omitempty"`
+	ThumbprintSHA256 string `json:"thumbprintSha256,omitempty" yaml:"thumbprintSha256,omitempty"`
+	// Subject and Issuer match components of the certificate subject and issuer names.
+	Subject *NamePolicy `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Issuer  *NamePolicy `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	// EKU lists extended key usages the certificate must carry, either by name
+	// (serverAuth, clientAuth, codeSigning, emailProtection, timeStamping, ocspSigning, any)
+	// or as a dotted OID such as 1.3.6.1.4.1.311.61.1.1.
+	EKU []string `json:"eku,omitempty" yaml:"eku,omitempty"`
+
+	AllOf []*SignerPolicy `json:"allOf,omitempty" yaml:"allOf,omitempty"`
+	AnyOf []*SignerPolicy `json:"anyOf,omitempty" yaml:"anyOf,omitempty"`
+}
+
+// NamePolicy matches components of a distinguished name. Every set component must be
+// equal, ignoring case, to one of the values of that component in the name.
+type NamePolicy struct {
+	CommonName         string `json:"commonName,omitempty" yaml:"commonName,omitempty"`
+	Organization       string `json:"organization,omitempty" yaml:"organization,omitempty"`
+	OrganizationalUnit string `json:"organizationalUnit,omitempty" yaml:"organizationalUnit,omitempty"`
+	Country            string `json:"country,omitempty" yaml:"country,omitempty"`
+	Province           string `json:"province,omitempty" yaml:"province,omitempty"`
+	Locality           string `json:"locality,omitempty" yaml:"locality,omitempty"`
+	SerialNumber       string `json:"serialNumber,omitempty" yaml:"serialNumber,omitempty"`
+}
+
+var ekuNames = map[string]asn1.ObjectIdentifier{
+	"serverauth":      {1, 3, 6, 1, 5, 5, 7, 3, 1},
+	"clientauth":      {1, 3, 6, 1, 5, 5, 7, 3, 2},
+	"codesigning":     {1, 3, 6, 1, 5, 5, 7, 3, 3},
+	"emailprotection": {1, 3, 6, 1, 5, 5, 7, 3, 4},
+	"timestamping":    {1, 3, 6, 1, 5, 5, 7, 3, 8},
+	"ocspsigning":     {1, 3, 6, 1, 5, 5, 7, 3, 9},
+	"any":             {2, 5, 29, 37, 0},
+}
+
+var ekuOIDs = map[x509.ExtKeyUsage]asn1.ObjectIdentifier{
+	x509.ExtKeyUsageServerAuth:      ekuNames["serverauth"],
+	x509.ExtKeyUsageClientAuth:      ekuNames["clientauth"],
+	x509.ExtKeyUsageCodeSigning:     ekuNames["codesigning"],
+	x509.ExtKeyUsageEmailProtection: ekuNames["emailprotection"],
+	x509.ExtKeyUsageTimeStamping:    ekuNames["timestamping"],
+	x509.ExtKeyUsageOCSPSigning:     ekuNames["ocspsigning"],
+	x509.ExtKeyUsageAny:             ekuNames["any"],
+}
+
+// LoadSignerPolicy reads a policy from a .json, .yaml or .yml file.
+func LoadSignerPolicy(path string) (*SignerPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signer policy: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseSignerPolicyJSON(data)
+	case ".yaml", ".yml":
+		return ParseSignerPolicyYAML(data)
+	default:
+		return nil, fmt.Errorf("unsupported signer policy file type: %s", path)
+	}
+}
+
+// ParseSignerPolicyJSON parses and validates a JSON encoded policy.
+func ParseSignerPolicyJSON(data []byte) (*SignerPolicy, error) {
+	var policy SignerPolicy
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse signer policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ParseSignerPolicyYAML parses and validates a YAML encoded policy.
+func ParseSignerPolicyYAML(data []byte) (*SignerPolicy, error) {
+	var policy SignerPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse signer policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate checks that the policy and all sub-policies have at least one condition
+// and that thumbprints and EKUs are well formed. A typo must not turn into a policy
+// that silently never, or always, matches.
+func (p *SignerPolicy) Validate() error {
+	if p == nil {
+		return errors.New("signer policy is empty")
+	}
+	if !p.hasConditions() {
+		return fmt.Errorf("signer policy %q has no conditions", p.Description)
+	}
+	if p.ThumbprintSHA1 != "" {
+		if b, err := decodeThumbprint(p.ThumbprintSHA1); err != nil || len(b) != sha1.Size {
+			return fmt.Errorf("invalid SHA-1 thumbprint %q", p.ThumbprintSHA1)
+		}
+	}
+	if p.ThumbprintSHA256 != "" {
+		if b, err := decodeThumbprint(p.ThumbprintSHA256); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid SHA-256 thumbprint %q", p.ThumbprintSHA256)
+		}
+	}
+	for _, name := range []*NamePolicy{p.Subject, p.Issuer} {
+		if name != nil && *name == (NamePolicy{}) {
+			return fmt.Errorf("signer policy %q has an empty name condition", p.Description)
+		}
+	}
+	for _, eku := range p.EKU {
+		if _, err := parseEKU(eku); err != nil {
+			return err
+		}
+	}
+	for _, sub := range append(append([]*SignerPolicy{}, p.AllOf...), p.AnyOf...) {
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *SignerPolicy) hasConditions() bool {
+	return p.ThumbprintSHA1 != "" || p.ThumbprintSHA256 != "" || p.Subject != nil || p.Issuer != nil ||
+		len(p.EKU) > 0 || len(p.AllOf) > 0 || len(p.AnyOf) > 0
+}
+
+// Match reports whether the certificate satisfies the policy.
+func (p *SignerPolicy) Match(cert *x509.Certificate) bool {
+	if p == nil || cert == nil || !p.hasConditions() {
+		return false
+	}
+	if p.ThumbprintSHA1 != "" {
+		sum := sha1.Sum(cert.Raw)
+		if !thumbprintEqual(p.ThumbprintSHA1, sum[:]) {
+			return false
+		}
+	}
+	if p.ThumbprintSHA256 != "" {
+		sum := sha256.Sum256(cert.Raw)
+		if !thumbprintEqual(p.ThumbprintSHA256, sum[:]) {
+			return false
+		}
+	}
+	if p.Subject != nil && !p.Subject.match(cert.Subject) {
+		return false
+	}
+	if p.Issuer != nil && !p.Issuer.match(cert.Issuer) {
+		return false
+	}
+	for _, eku := range p.EKU {
+		if !hasEKU(cert, eku) {
+			return false
+		}
+	}
+	for _, sub := range p.AllOf {
+		if !sub.Match(cert) {
+			return false
+		}
+	}
+	if len(p.AnyOf) > 0 {
+		for _, sub := range p.AnyOf {
+			if sub.Match(cert) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func signedByPolicy(path string, policy *SignerPolicy, opts ChainOptions) (bool, error) {
+	verification, err := verifySignature(path)
+	if err != nil {
+		return false, err
+	}
+	if !verification.Valid() {
+		return false, nil
+	}
+	certs, err := getCertificates(path)
+	if err != nil {
+		return false, err
+	}
+	return certs.signedByPolicy(policy, opts)
+}
+
+// signedByPolicy reports whether the leaf signer of the primary signature chains to a trusted root
+// and satisfies the policy. The caller verifies the signature over the file.
+func (c *Certificates) signedByPolicy(policy *SignerPolicy, opts ChainOptions) (bool, error) {
+	chain, err := c.VerifyChain(opts)
+	if errors.Is(err, ErrNotSigned) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return chain.Valid() && policy.Match(c.signerCertificate()), nil
+}
+
+func (n *NamePolicy) match(name pkix.Name) bool {
+	checks := []struct {
+		want string
+		have []string
+	}{
+		{n.CommonName, []string{name.CommonName}},
+		{n.Organization, name.Organization},
+		{n.OrganizationalUnit, name.OrganizationalUnit},
+		{n.Country, name.Country},
+		{n.Province, name.Province},
+		{n.Locality, name.Locality},
+		{n.SerialNumber, []string{name.SerialNumber}},
+	}
+	for _, check := range checks {
+		if check.want == "" {
+			continue
+		}
+		found := false
+		for _, have := range check.have {
+			if strings.EqualFold(strings.TrimSpace(have), strings.TrimSpace(check.want)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeThumbprint(s string) ([]byte, error) {
+	s = strings.NewReplacer(" ", "", ":", "").Replace(s)
+	return hex.DecodeString(s)
+}
+
+func thumbprintEqual(want string, sum []byte) bool {
+	b, err := decodeThumbprint(want)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(b, sum)
+}
+
+func parseEKU(s string) (asn1.ObjectIdentifier, error) {
+	if oid, ok := ekuNames[strings.ToLower(s)]; ok {
+		return oid, nil
+	}
+	var oid asn1.ObjectIdentifier
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid extended key usage %q", s)
+		}
+		oid = append(oid, n)
+	}
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("invalid extended key usage %q", s)
+	}
+	return oid, nil
+}
+
+func hasEKU(cert *x509.Certificate, eku string) bool {
+	want, err := parseEKU(eku)
+	if err != nil {
+		return false
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if oid, ok := ekuOIDs[usage]; ok && oid.Equal(want) {
+			return true
+		}
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		if oid.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignerPolicyMatch(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	sha1Sum := sha1.Sum(pki.leaf.Raw)
+	sha256Sum := sha256.Sum256(pki.leaf.Raw)
+
+	tests := []struct {
+		name   string
+		policy *SignerPolicy
+		match  bool
+	}{
+		{"subject", &SignerPolicy{Subject: &NamePolicy{Organization: "test publisher", Country: "CZ"}}, true},
+		{"subject mismatch", &SignerPolicy{Subject: &NamePolicy{Organization: "Test Publisher", Country: "US"}}, false},
+		{"issuer", &SignerPolicy{Issuer: &NamePolicy{CommonName: "Test Code Signing CA"}}, true},
+		{"issuer is not subject", &SignerPolicy{Issuer: &NamePolicy{CommonName: "Test Publisher"}}, false},
+		{"sha1 thumbprint", &SignerPolicy{ThumbprintSHA1: strings.ToUpper(hex.EncodeToString(sha1Sum[:]))}, true},
+		{"sha256 thumbprint with colons", &SignerPolicy{ThumbprintSHA256: colonHex(sha256Sum[:])}, true},
+		{"eku by name", &SignerPolicy{EKU: []string{"codeSigning"}}, true},
+		{"eku by oid", &SignerPolicy{EKU: []string{"1.3.6.1.5.5.7.3.3"}}, true},
+		{"missing eku", &SignerPolicy{EKU: []string{"timeStamping"}}, false},
+		{"all of", &SignerPolicy{AllOf: []*SignerPolicy{
+			{Subject: &NamePolicy{CommonName: "Test Publisher"}},
+			{EKU: []string{"timeStamping"}},
+		}}, false},
+		{"any of", &SignerPolicy{AnyOf: []*SignerPolicy{
+			{Subject: &NamePolicy{CommonName: "Someone Else"}},
+			{Subject: &NamePolicy{CommonName: "Test Publisher"}},
+		}}, true},
+		{"conditions and any of", &SignerPolicy{
+			Issuer: &NamePolicy{Organization: "Other"},
+			AnyOf:  []*SignerPolicy{{Subject: &NamePolicy{CommonName: "Test Publisher"}}},
+		}, false},
+		{"empty policy", &SignerPolicy{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.match, tt.policy.Match(pki.leaf))
+		})
+	}
+}
+
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02x", v)
+	}
+	return strings.Join(parts, ":")
+}
+
+func TestSignedByPolicyIgnoresNonSigners(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	impostor, _ := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Publisher", Organization: []string{"Test Publisher"}},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	policy := &SignerPolicy{Subject: &NamePolicy{CommonName: "Test Publisher"}, Issuer: &NamePolicy{CommonName: "Test Publisher"}}
+
+	opts := ChainOptions{Roots: testRootPool(pki.root), CurrentTime: testValidationTime}
+	certs := testSignedCertificates(pki.leaf, impostor, pki.intermediate, pki.leaf)
+	ok, err := certs.signedByPolicy(policy, opts)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = (&Certificates{}).signedByPolicy(policy, opts)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSignedByPolicy(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	policy := &SignerPolicy{
+		Subject: &NamePolicy{CommonName: "Test Publisher"},
+		Issuer:  &NamePolicy{CommonName: "Test Code Signing CA"},
+	}
+	opts := ChainOptions{Roots: testRootPool(pki.root), CurrentTime: testValidationTime}
+
+	wf, err := NewWinFileInfo(testWriteFile(t, "signed.exe", testSignPE(t, testUnsignedImage(t, false), pki, crypto.SHA256)))
+	require.NoError(t, err)
+	ok, err := wf.SignedByPolicy(policy, opts)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// A self-signed certificate spoofs the subject and issuer of the trusted publisher.
+	spoof, spoofKey := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Test Publisher"},
+		Issuer:      pkix.Name{CommonName: "Test Code Signing CA"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, nil, nil)
+	spoofed := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		certs:  []*x509.Certificate{spoof},
+		signer: spoof,
+		key:    spoofKey,
+	})
+	wf, err = NewWinFileInfo(testWriteFile(t, "spoofed.exe", spoofed))
+	require.NoError(t, err)
+	v, err := wf.VerifySignature()
+	require.NoError(t, err)
+	require.True(t, v.Valid(), v.Reason)
+	ok, err = wf.SignedByPolicy(policy, opts)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The signature does not cover the modified file.
+	tampered := testSignPE(t, testUnsignedImage(t, false), pki, crypto.SHA256)
+	copy(tampered[0x200:], "\xcc")
+	wf, err = NewWinFileInfo(testWriteFile(t, "tampered.exe", tampered))
+	require.NoError(t, err)
+	ok, err = wf.SignedByPolicy(policy, opts)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	wf, err = NewWinFileInfo(testWriteFile(t, "unsigned.exe", testUnsignedImage(t, false)))
+	require.NoError(t, err)
+	ok, err = wf.SignedByPolicy(policy, opts)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestParseSignerPolicy(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	sum := sha256.Sum256(pki.leaf.Raw)
+
+	yamlPolicy := `
+description: trusted publishers
+anyOf:
+  - description: test publisher
+    subject:
+      organization: Test Publisher
+      country: CZ
+    issuer:
+      commonName: Test Code Signing CA
+    eku: [codeSigning]
+  - thumbprintSha256: ` + hex.EncodeToString(sum[:]) + `
+`
+	policy, err := LoadSignerPolicy(testWriteFile(t, "policy.yaml", []byte(yamlPolicy)))
+	require.NoError(t, err)
+	require.Len(t, policy.AnyOf, 2)
+	assert.True(t, policy.Match(pki.leaf))
+	assert.True(t, policy.AnyOf[1].Match(pki.leaf))
+	assert.False(t, policy.Match(pki.intermediate))
+
+	jsonPolicy := `{"allOf": [{"subject": {"commonName": "Test Publisher"}}, {"eku": ["codeSigning"]}]}`
+	policy, err = LoadSignerPolicy(testWriteFile(t, "policy.json", []byte(jsonPolicy)))
+	require.NoError(t, err)
+	assert.True(t, policy.Match(pki.leaf))
+}
+
+func TestParseSignerPolicyInvalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":              `{}`,
+		"empty sub-policy":   `{"anyOf": [{}]}`,
+		"unknown field":      `{"subjekt": {"commonName": "x"}}`,
+		"bad thumbprint":     `{"thumbprintSha1": "abcd"}`,
+		"bad eku":            `{"eku": ["codeSigning", "nope"]}`,
+		"empty name matcher": `{"subject": {}}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSignerPolicyJSON([]byte(data))
+			require.Error(t, err)
+		})
+	}
+
+	_, err := ParseSignerPolicyYAML([]byte("subject:\n  commonNam: x\n"))
+	require.Error(t, err)
+	_, err = LoadSignerPolicy(testWriteFile(t, "policy.txt", []byte(`{}`)))
+	require.Error(t, err)
+}
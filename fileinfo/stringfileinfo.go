@@ -0,0 +1,187 @@
+package fileinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Predefined StringFileInfo keys.
+// https://learn.microsoft.com/en-us/windows/win32/menurc/stringfileinfo-block
+const (
+	StringComments         = "Comments"
+	StringCompanyName      = "CompanyName"
+	StringFileDescription  = "FileDescription"
+	StringFileVersion      = "FileVersion"
+	StringInternalName     = "InternalName"
+	StringLegalCopyright   = "LegalCopyright"
+	StringLegalTrademarks  = "LegalTrademarks"
+	StringOriginalFilename = "OriginalFilename"
+	StringPrivateBuild     = "PrivateBuild"
+	StringProductName      = "ProductName"
+	StringProductVersion   = "ProductVersion"
+	StringSpecialBuild     = "SpecialBuild"
+)
+
+// Language and code page fallbacks tried after the declared translations,
+// U.S. English with Unicode, Windows Latin-1 and neutral code pages, then the neutral language.
+var fallbackTranslations = []Translation{
+	{Language: 0x0409, CodePage: 1200},
+	{Language: 0x0409, CodePage: 1252},
+	{Language: 0x0409, CodePage: 0},
+	{Language: 0x0000, CodePage: 1200},
+	{Language: 0x0000, CodePage: 1252},
+	{Language: 0x0000, CodePage: 0},
+}
+
+// Translation is a language and code page pair, e.g. 0x0409 (U.S. English) and 1200 (Unicode).
+type Translation struct {
+	Language uint16
+	CodePage uint16
+}
+
+// String formats the translation the way StringTable keys are written, e.g. "040904b0".
+func (t Translation) String() string {
+	return fmt.Sprintf("%04x%04x", t.Language, t.CodePage)
+}
+
+// StringTable holds the string values of one StringTable block.
+type StringTable struct {
+	// Key is the raw block key, the language and code page as eight hex digits.
+	Key         string
+	Translation Translation
+	Values      map[string]string
+}
+
+// StringFileInfo holds the string tables of the VS_VERSIONINFO resource.
+type StringFileInfo struct {
+	// Translations lists the languages and code pages from VarFileInfo\Translation in file order.
+	Translations []Translation
+	// Tables holds every StringTable block in file order.
+	Tables []*StringTable
+}
+
+// Table returns the string table for the translation or nil if the file has none.
+func (s *StringFileInfo) Table(t Translation) *StringTable {
+	for _, table := range s.Tables {
+		if table.Translation == t {
+			return table
+		}
+	}
+	return nil
+}
+
+// BestTable picks the string table the way Explorer does. The preferred languages are tried first
+// with any code page, then the translations declared in VarFileInfo in order, U.S. English and
+// language neutral tables, and finally the first table. It returns nil when there are no tables.
+func (s *StringFileInfo) BestTable(preferred ...uint16) *StringTable {
+	for _, language := range preferred {
+		for _, table := range s.Tables {
+			if table.Translation.Language == language {
+				return table
+			}
+		}
+	}
+	for _, t := range slices.Concat(s.Translations, fallbackTranslations) {
+		if table := s.Table(t); table != nil {
+			return table
+		}
+	}
+	if len(s.Tables) > 0 {
+		return s.Tables[0]
+	}
+	return nil
+}
+
+// Value returns the value of key from the best table, see BestTable.
+// It returns an empty string when the key is missing.
+func (s *StringFileInfo) Value(key string, preferred ...uint16) string {
+	table := s.BestTable(preferred...)
+	if table == nil {
+		return ""
+	}
+	return table.Values[key]
+}
+
+// ReadStringFileInfo reads the StringFileInfo and VarFileInfo blocks from the VS_VERSIONINFO
+// resource of the PE image in r, size is the total image size. Files that have version info
+// without string tables return an empty StringFileInfo.
+func ReadStringFileInfo(r io.ReaderAt, size int64) (*StringFileInfo, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.stringFileInfo()
+}
+
+func readStringFileInfoFile(path string) (*StringFileInfo, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.stringFileInfo()
+}
+
+func (p *peImage) stringFileInfo() (*StringFileInfo, error) {
+	root, err := p.versionInfo()
+	if err != nil {
+		return nil, err
+	}
+	return parseStringFileInfo(root), nil
+}
+
+func parseStringFileInfo(root *versionBlock) *StringFileInfo {
+	info := &StringFileInfo{}
+	for _, block := range root.children {
+		switch block.key {
+		case "StringFileInfo":
+			for _, tableBlock := range block.children {
+				info.Tables = append(info.Tables, parseStringTable(tableBlock))
+			}
+		case "VarFileInfo":
+			for _, v := range block.children {
+				if v.key != "Translation" {
+					continue
+				}
+				for i := 0; i+4 <= len(v.value); i += 4 {
+					info.Translations = append(info.Translations, Translation{
+						Language: binary.LittleEndian.Uint16(v.value[i:]),
+						CodePage: binary.LittleEndian.Uint16(v.value[i+2:]),
+					})
+				}
+			}
+		}
+	}
+	return info
+}
+
+func parseStringTable(block *versionBlock) *StringTable {
+	table := &StringTable{Key: block.key, Values: map[string]string{}}
+	if v, err := strconv.ParseUint(block.key, 16, 32); err == nil && len(block.key) == 8 {
+		table.Translation = Translation{Language: uint16(v >> 16), CodePage: uint16(v)}
+	}
+	for _, s := range block.children {
+		table.Values[s.key] = decodeVersionString(s.value)
+	}
+	return table
+}
+
+// decodeVersionString decodes a UTF-16LE value up to the first NUL. Values are decoded the same way
+// whether the block is marked as text or binary, as some resource compilers get wType wrong.
+func decodeVersionString(value []byte) string {
+	u := make([]uint16, 0, len(value)/2)
+	for i := 0; i+2 <= len(value); i += 2 {
+		c := binary.LittleEndian.Uint16(value[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
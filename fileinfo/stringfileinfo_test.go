@@ -0,0 +1,124 @@
+package fileinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStringFileInfoImage(t *testing.T, children ...testVersionBlock) []byte {
+	t.Helper()
+	return testImageWithResources(t, false, testVersionResource(testVersionInfo(t, testFileVersion, testProductVersion, children...)))
+}
+
+func TestReadStringFileInfo(t *testing.T) {
+	english := testStringFileInfo("040904b0",
+		StringCompanyName, "Contoso Ltd.",
+		StringProductName, "Contoso Agent",
+		StringFileDescription, "Contoso Agent Service",
+		StringOriginalFilename, "agent.exe",
+		StringLegalCopyright, "© Contoso Ltd. All rights reserved.",
+		"BuildBranch", "release/1.2",
+	)
+	german := testStringFileInfo("040704b0",
+		StringCompanyName, "Contoso GmbH",
+		StringProductName, "Contoso Agent",
+	)
+	// Both tables in one StringFileInfo block, the way rc.exe writes them.
+	stringFileInfo := testVersionBlock{key: "StringFileInfo", text: true, children: append(german.children, english.children...)}
+	image := testStringFileInfoImage(t, stringFileInfo, testVarFileInfo(0x409, 1200, 0x407, 1200))
+
+	info, err := ReadStringFileInfo(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Translation{{0x409, 1200}, {0x407, 1200}}, info.Translations)
+	require.Len(t, info.Tables, 2)
+	assert.Equal(t, "040704b0", info.Tables[0].Key)
+	assert.Equal(t, Translation{Language: 0x407, CodePage: 1200}, info.Tables[0].Translation)
+	assert.Equal(t, "040904b0", info.Tables[1].Translation.String())
+
+	// VarFileInfo lists English first.
+	best := info.BestTable()
+	require.NotNil(t, best)
+	assert.Equal(t, "Contoso Ltd.", best.Values[StringCompanyName])
+	assert.Equal(t, "© Contoso Ltd. All rights reserved.", best.Values[StringLegalCopyright])
+	assert.Equal(t, "release/1.2", best.Values["BuildBranch"])
+	assert.Equal(t, "agent.exe", info.Value(StringOriginalFilename))
+
+	assert.Equal(t, "Contoso GmbH", info.Value(StringCompanyName, 0x407))
+	assert.Equal(t, "Contoso Ltd.", info.Value(StringCompanyName, 0x40c))
+	assert.Empty(t, info.Value(StringComments))
+}
+
+func TestStringFileInfoBestTable(t *testing.T) {
+	table := func(key string) *StringTable {
+		return parseStringTable(&versionBlock{key: key})
+	}
+	tests := map[string]struct {
+		info *StringFileInfo
+		want string
+	}{
+		"declared translation": {
+			info: &StringFileInfo{Translations: []Translation{{0x405, 1250}}, Tables: []*StringTable{table("040904b0"), table("040504e2")}},
+			want: "040504e2",
+		},
+		"translation without table": {
+			info: &StringFileInfo{Translations: []Translation{{0x405, 1250}}, Tables: []*StringTable{table("040704b0"), table("040904e4")}},
+			want: "040904e4",
+		},
+		"neutral language": {
+			info: &StringFileInfo{Tables: []*StringTable{table("040704b0"), table("000004b0")}},
+			want: "000004b0",
+		},
+		"first table": {
+			info: &StringFileInfo{Tables: []*StringTable{table("040704b0"), table("040c04b0")}},
+			want: "040704b0",
+		},
+		"malformed key": {
+			info: &StringFileInfo{Tables: []*StringTable{table("english")}},
+			want: "english",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			best := tt.info.BestTable()
+			require.NotNil(t, best)
+			assert.Equal(t, tt.want, best.Key)
+		})
+	}
+	assert.Nil(t, (&StringFileInfo{}).BestTable())
+	assert.Empty(t, (&StringFileInfo{}).Value(StringCompanyName))
+}
+
+func TestReadStringFileInfoWithoutStrings(t *testing.T) {
+	image := testStringFileInfoImage(t)
+	info, err := ReadStringFileInfo(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Empty(t, info.Tables)
+	assert.Empty(t, info.Translations)
+}
+
+func TestGetStringFileInfo(t *testing.T) {
+	image := testStringFileInfoImage(t, testStringFileInfo("040904b0", StringProductName, "Contoso Agent"))
+	wf, err := NewWinFileInfo(testWriteFile(t, "agent.exe", image))
+	require.NoError(t, err)
+
+	info, err := wf.GetStringFileInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "Contoso Agent", info.Value(StringProductName))
+
+	wf, err = NewWinFileInfo(testWriteFile(t, "plain.exe", testUnsignedImage(t, false)))
+	require.NoError(t, err)
+	_, err = wf.GetStringFileInfo()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no version info found")
+}
+
+func TestDecodeVersionString(t *testing.T) {
+	assert.Equal(t, "abc", decodeVersionString(testText("abc")))
+	assert.Equal(t, "abc", decodeVersionString(append(testText("abc"), testText("def")...)))
+	assert.Equal(t, "", decodeVersionString(nil))
+	assert.Equal(t, "a", decodeVersionString([]byte{'a', 0, 'b'}))
+}
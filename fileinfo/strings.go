@@ -0,0 +1,300 @@
+package fileinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// stringsPerBlock is the number of strings in an RT_STRING resource, string ID n is stored
+// in the block with resource ID n/16+1 at index n%16.
+const stringsPerBlock = 16
+
+// maxStringBlockID is the highest RT_STRING block ID, the block holding string IDs up to 0xffff.
+const maxStringBlockID = 0x10000 / stringsPerBlock
+
+// languageIDs maps the locale names used for MUI directories to Windows language IDs.
+var languageIDs = map[string]uint16{
+	"ar-SA": 0x0401, "cs-CZ": 0x0405, "da-DK": 0x0406, "de-DE": 0x0407, "el-GR": 0x0408,
+	"en-US": 0x0409, "es-ES": 0x0c0a, "fi-FI": 0x040b, "fr-FR": 0x040c, "he-IL": 0x040d,
+	"hu-HU": 0x040e, "it-IT": 0x0410, "ja-JP": 0x0411, "ko-KR": 0x0412, "nl-NL": 0x0413,
+	"nb-NO": 0x0414, "pl-PL": 0x0415, "pt-BR": 0x0416, "ro-RO": 0x0418, "ru-RU": 0x0419,
+	"hr-HR": 0x041a, "sk-SK": 0x041b, "sv-SE": 0x041d, "th-TH": 0x041e, "tr-TR": 0x041f,
+	"uk-UA": 0x0422, "sl-SI": 0x0424, "et-EE": 0x0425, "lv-LV": 0x0426, "lt-LT": 0x0427,
+	"zh-CN": 0x0804, "zh-TW": 0x0404, "en-GB": 0x0809, "pt-PT": 0x0816, "sr-Latn-RS": 0x241a,
+	"bg-BG": 0x0402,
+}
+
+// ResourceStrings holds the RT_STRING resources of a file by language and string ID.
+type ResourceStrings map[uint16]map[uint16]string
+
+// Languages returns the languages that have strings, in ascending order.
+func (s ResourceStrings) Languages() []uint16 {
+	languages := make([]uint16, 0, len(s))
+	for language := range s {
+		languages = append(languages, language)
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// Lookup returns the string with the given ID. The languages are tried in order, then the neutral
+// language and finally any language that has the string, in ascending order of language IDs.
+func (s ResourceStrings) Lookup(id uint16, languages ...uint16) (string, bool) {
+	for _, language := range append(slices.Clone(languages), 0) {
+		if v, ok := s[language][id]; ok {
+			return v, true
+		}
+	}
+	for _, language := range s.Languages() {
+		if v, ok := s[language][id]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// ReadStrings reads the RT_STRING tables of the PE image in r, size is the total image size.
+// Empty strings are not stored in the tables and are not returned.
+func ReadStrings(r io.ReaderAt, size int64) (ResourceStrings, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.resourceStrings()
+}
+
+func readStringsFile(path string) (ResourceStrings, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	return img.resourceStrings()
+}
+
+func (p *peImage) resourceStrings() (ResourceStrings, error) {
+	resources, err := p.findResources(ResourceTypeString)
+	if err != nil {
+		return nil, err
+	}
+	table := ResourceStrings{}
+	for _, resource := range resources {
+		// Block IDs are 1-4096, other blocks would overflow the string IDs and overwrite real entries.
+		if resource.Name.IsNamed() || resource.Name.ID == 0 || resource.Name.ID > maxStringBlockID {
+			continue
+		}
+		data, err := p.readResource(resource)
+		if err != nil {
+			return nil, err
+		}
+		block, err := parseStringBlock(data)
+		if err != nil {
+			return nil, fmt.Errorf("string table %d: %w", resource.Name.ID, err)
+		}
+		if table[resource.Language] == nil {
+			table[resource.Language] = map[uint16]string{}
+		}
+		first := (resource.Name.ID - 1) * stringsPerBlock
+		for i, s := range block {
+			if s != "" {
+				table[resource.Language][first+uint16(i)] = s
+			}
+		}
+	}
+	return table, nil
+}
+
+// parseStringBlock parses the 16 length-prefixed UTF-16 strings of an RT_STRING resource.
+func parseStringBlock(data []byte) ([stringsPerBlock]string, error) {
+	var block [stringsPerBlock]string
+	pos := 0
+	for i := range block {
+		if pos+2 > len(data) {
+			// Linkers may omit trailing empty strings.
+			break
+		}
+		length := int(binary.LittleEndian.Uint16(data[pos:]))
+		pos += 2
+		if pos+length*2 > len(data) {
+			return block, fmt.Errorf("string %d is truncated", i)
+		}
+		u := make([]uint16, length)
+		for j := range u {
+			u[j] = binary.LittleEndian.Uint16(data[pos+j*2:])
+		}
+		block[i] = string(utf16.Decode(u))
+		pos += length * 2
+	}
+	return block, nil
+}
+
+// IndirectStringResolver resolves indirect strings such as "@%SystemRoot%\system32\wuaueng.dll,-106",
+// which service display names, descriptions and many registry values refer to.
+type IndirectStringResolver struct {
+	// Getenv returns the value of an environment variable, os.Getenv when nil.
+	Getenv func(key string) string
+	// Languages are the UI languages tried in order, e.g. "de-DE". MUI satellite files are looked up
+	// in subdirectories named after them next to the file. When empty, the user's preferred UI
+	// languages are used on Windows. "en-US" is always tried last.
+	Languages []string
+}
+
+// IsIndirectString reports whether s is an indirect string reference in the "@path,-id" form.
+func IsIndirectString(s string) bool {
+	_, _, err := parseIndirectString(s)
+	return err == nil
+}
+
+// parseIndirectString splits "@path,-id" into the path and the string ID. A ";..." suffix,
+// such as the version modifier used by SHLoadIndirectString, is ignored.
+func parseIndirectString(s string) (string, uint16, error) {
+	if !strings.HasPrefix(s, "@") {
+		return "", 0, errors.New("indirect string does not start with @")
+	}
+	s = s[1:]
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+	comma := strings.LastIndexByte(s, ',')
+	if comma <= 0 {
+		return "", 0, errors.New("indirect string has no resource ID")
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(s[comma+1:]), 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid resource ID in indirect string: %w", err)
+	}
+	if id < 0 {
+		id = -id
+	}
+	if id > 0xffff {
+		return "", 0, fmt.Errorf("resource ID %d is out of range", id)
+	}
+	return strings.TrimSpace(s[:comma]), uint16(id), nil
+}
+
+// Resolve loads the string an indirect string refers to. The MUI files of the languages are tried
+// first, then the file itself. Strings that are not indirect are returned unchanged.
+func (r *IndirectStringResolver) Resolve(s string) (string, error) {
+	if !strings.HasPrefix(s, "@") {
+		return s, nil
+	}
+	path, id, err := parseIndirectString(s)
+	if err != nil {
+		return "", err
+	}
+	path = r.expand(path)
+	dir, base, sep := splitWindowsPath(path)
+	if dir == "" {
+		// Bare file names are loaded from the system directory.
+		if root := r.getenv("SystemRoot"); root != "" {
+			dir, sep = root+`\System32`, `\`
+			path = dir + sep + base
+		}
+	}
+
+	languages := r.languages()
+	var languageIDList []uint16
+	for _, language := range languages {
+		if languageID, ok := lookupLanguageID(language); ok {
+			languageIDList = append(languageIDList, languageID)
+		}
+	}
+	var candidates []string
+	for _, language := range languages {
+		if dir != "" {
+			candidates = append(candidates, dir+sep+language+sep+base+".mui")
+		}
+	}
+	candidates = append(candidates, path)
+
+	// A candidate that cannot be read, such as a corrupt MUI file, falls back to the next one.
+	var readErrs []error
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err != nil {
+			continue
+		}
+		table, err := readStringsFile(candidate)
+		if err != nil {
+			readErrs = append(readErrs, fmt.Errorf("failed to read strings from %s: %w", candidate, err))
+			continue
+		}
+		if v, ok := table.Lookup(id, languageIDList...); ok {
+			return v, nil
+		}
+	}
+	return "", errors.Join(append([]error{fmt.Errorf("string %d not found in %s", id, path)}, readErrs...)...)
+}
+
+func (r *IndirectStringResolver) getenv(key string) string {
+	if r.Getenv != nil {
+		return r.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+func (r *IndirectStringResolver) languages() []string {
+	languages := r.Languages
+	if len(languages) == 0 {
+		languages = userUILanguages()
+	}
+	if !slices.ContainsFunc(languages, func(l string) bool { return strings.EqualFold(l, "en-US") }) {
+		languages = append(slices.Clone(languages), "en-US")
+	}
+	return languages
+}
+
+// expand replaces %NAME% environment variable references, unknown variables are kept as written.
+func (r *IndirectStringResolver) expand(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, '%')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], '%')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+		name := s[start+1 : end]
+		if v := r.getenv(name); name != "" && v != "" {
+			b.WriteString(s[:start])
+			b.WriteString(v)
+		} else {
+			b.WriteString(s[:end])
+			// keep the closing % as the start of the next reference candidate
+			s = s[end:]
+			continue
+		}
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// splitWindowsPath splits a path at its last separator, accepting both \ and /.
+func splitWindowsPath(path string) (dir, base, sep string) {
+	i := strings.LastIndexAny(path, `\/`)
+	if i < 0 {
+		return "", path, `\`
+	}
+	return path[:i], path[i+1:], path[i : i+1]
+}
+
+func lookupLanguageID(name string) (uint16, bool) {
+	for n, id := range languageIDs {
+		if strings.EqualFold(n, name) {
+			return id, true
+		}
+	}
+	return 0, false
+}
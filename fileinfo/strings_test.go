@@ -0,0 +1,179 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStringBlock encodes an RT_STRING resource with the strings at the given indexes.
+func testStringBlock(strings map[int]string) []byte {
+	var out []byte
+	for i := 0; i < stringsPerBlock; i++ {
+		u := testUTF16LE(strings[i])
+		out = binary.LittleEndian.AppendUint16(out, uint16(len(u)/2))
+		out = append(out, u...)
+	}
+	return out
+}
+
+// testStringResource returns the RT_STRING resource holding the strings with the given IDs,
+// which must all be in the same block.
+func testStringResource(lang uint16, strings map[uint16]string) testResource {
+	block := map[int]string{}
+	var blockID uint16
+	for id, s := range strings {
+		blockID = id/stringsPerBlock + 1
+		block[int(id%stringsPerBlock)] = s
+	}
+	return testResource{typ: uint16(ResourceTypeString), name: blockID, lang: lang, data: testStringBlock(block)}
+}
+
+func TestReadStrings(t *testing.T) {
+	image := testImageWithResources(t, false,
+		testStringResource(0x409, map[uint16]string{100: "Windows Update", 101: "Enables the detection of updates."}),
+		testStringResource(0x407, map[uint16]string{100: "Windows Update", 101: "Ermöglicht das Erkennen von Updates."}),
+		testStringResource(0x409, map[uint16]string{5: "First block"}),
+		// Block 4097 is out of range, its strings would wrap around to the first block.
+		testResource{typ: uint16(ResourceTypeString), name: uint16(maxStringBlockID + 1), lang: 0x409, data: testStringBlock(map[int]string{5: "overflow"})},
+	)
+
+	strings, err := ReadStrings(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{0x407, 0x409}, strings.Languages())
+	assert.Equal(t, map[uint16]string{100: "Windows Update", 101: "Enables the detection of updates.", 5: "First block"}, strings[0x409])
+
+	v, ok := strings.Lookup(101, 0x407)
+	assert.True(t, ok)
+	assert.Equal(t, "Ermöglicht das Erkennen von Updates.", v)
+	v, ok = strings.Lookup(5, 0x407)
+	assert.True(t, ok)
+	assert.Equal(t, "First block", v)
+	_, ok = strings.Lookup(102)
+	assert.False(t, ok)
+}
+
+func TestParseStringBlock(t *testing.T) {
+	block, err := parseStringBlock(testStringBlock(map[int]string{0: "a", 15: "last"}))
+	require.NoError(t, err)
+	assert.Equal(t, "a", block[0])
+	assert.Equal(t, "last", block[15])
+
+	// Trailing empty strings may be left out.
+	block, err = parseStringBlock([]byte{1, 0, 'x', 0})
+	require.NoError(t, err)
+	assert.Equal(t, "x", block[0])
+
+	_, err = parseStringBlock([]byte{5, 0, 'x', 0})
+	require.Error(t, err)
+}
+
+func TestParseIndirectString(t *testing.T) {
+	tests := map[string]struct {
+		path string
+		id   uint16
+	}{
+		`@%SystemRoot%\system32\wuaueng.dll,-106`: {`%SystemRoot%\system32\wuaueng.dll`, 106},
+		`@shell32.dll,-21787;v2`:                  {`shell32.dll`, 21787},
+		`@C:\Program Files\My, App\app.dll,7`:     {`C:\Program Files\My, App\app.dll`, 7},
+	}
+	for s, tt := range tests {
+		t.Run(s, func(t *testing.T) {
+			path, id, err := parseIndirectString(s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.path, path)
+			assert.Equal(t, tt.id, id)
+			assert.True(t, IsIndirectString(s))
+		})
+	}
+	for _, s := range []string{"Windows Update", "@foo.dll", "@foo.dll,-abc", "@,-1", "@foo.dll,-70000"} {
+		assert.False(t, IsIndirectString(s), s)
+	}
+}
+
+func TestIndirectStringResolver(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "system32")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "de-DE"), 0o700))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "en-US"), 0o700))
+
+	// The binary only has a neutral string, the localized strings are in MUI files.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "svc.dll"), testImageWithResources(t, false,
+		testStringResource(0, map[uint16]string{106: "neutral", 107: "only in binary"}),
+	), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "de-DE", "svc.dll.mui"), testImageWithResources(t, false,
+		testStringResource(0x407, map[uint16]string{106: "Dienst"}),
+	), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "en-US", "svc.dll.mui"), testImageWithResources(t, false,
+		testStringResource(0x409, map[uint16]string{106: "Service", 108: "English only"}),
+	), 0o600))
+
+	env := map[string]string{"SystemRoot": root}
+	resolver := &IndirectStringResolver{
+		Getenv:    func(key string) string { return env[key] },
+		Languages: []string{"fr-FR", "de-DE"},
+	}
+	tests := map[string]string{
+		"@%SystemRoot%/system32/svc.dll,-106": "Dienst",
+		"@%SystemRoot%/system32/svc.dll,-108": "English only",
+		"@%SystemRoot%/system32/svc.dll,-107": "only in binary",
+		"Plain display name":                  "Plain display name",
+	}
+	for s, want := range tests {
+		t.Run(s, func(t *testing.T) {
+			v, err := resolver.Resolve(s)
+			require.NoError(t, err)
+			assert.Equal(t, want, v)
+		})
+	}
+
+	_, err := resolver.Resolve("@%SystemRoot%/system32/svc.dll,-109")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "string 109 not found")
+	_, err = resolver.Resolve("@%Unknown%/svc.dll,-106")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "%Unknown%/svc.dll")
+}
+
+func TestIndirectStringResolverCorruptMUI(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "de-DE"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "svc.dll"), testImageWithResources(t, false,
+		testStringResource(0x409, map[uint16]string{106: "Service"}),
+	), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "de-DE", "svc.dll.mui"), []byte("MZ truncated"), 0o600))
+
+	resolver := &IndirectStringResolver{Languages: []string{"de-DE"}}
+	v, err := resolver.Resolve("@" + filepath.Join(dir, "svc.dll") + ",-106")
+	require.NoError(t, err)
+	assert.Equal(t, "Service", v)
+
+	_, err = resolver.Resolve("@" + filepath.Join(dir, "svc.dll") + ",-107")
+	require.ErrorContains(t, err, "string 107 not found")
+	assert.ErrorContains(t, err, "svc.dll.mui")
+}
+
+func TestIndirectStringResolverExpand(t *testing.T) {
+	env := map[string]string{"SystemRoot": `C:\Windows`, "ProgramFiles": `C:\Program Files`}
+	resolver := &IndirectStringResolver{Getenv: func(key string) string { return env[key] }}
+	assert.Equal(t, `C:\Windows\system32\a.dll`, resolver.expand(`%SystemRoot%\system32\a.dll`))
+	assert.Equal(t, `100%C:\Program Files`, resolver.expand(`100%%ProgramFiles%`))
+	assert.Equal(t, `%Missing%\a.dll`, resolver.expand(`%Missing%\a.dll`))
+	assert.Equal(t, `50% done`, resolver.expand(`50% done`))
+}
+
+func TestGetStrings(t *testing.T) {
+	image := testImageWithResources(t, true, testStringResource(0x409, map[uint16]string{1: "one"}))
+	wf, err := NewWinFileInfo(testWriteFile(t, "app.exe", image))
+	require.NoError(t, err)
+	strings, err := wf.GetStrings()
+	require.NoError(t, err)
+	v, ok := strings.Lookup(1)
+	assert.True(t, ok)
+	assert.Equal(t, "one", v)
+}
@@ -0,0 +1,272 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testFileAlignment    = 0x200
+	testSectionAlignment = 0x1000
+	testLfanew           = 0x80
+)
+
+// testPE builds minimal but well formed PE32 and PE32+ images for tests.
+type testPE struct {
+	pe32               bool
+	machine            uint16
+	characteristics    uint16
+	dllCharacteristics uint16
+	subsystem          uint16
+	timeDateStamp      uint32
+	entryPoint         uint32
+	sections           []testPESection
+	dirs               [16]pe.DataDirectory
+	// dosStub is placed between the DOS header and the PE signature.
+	dosStub []byte
+	// overlay is appended after the last section.
+	overlay []byte
+}
+
+type testPESection struct {
+	name            string
+	data            []byte
+	virtualSize     uint32
+	characteristics uint32
+	rva             uint32
+}
+
+func newTestPE() *testPE {
+	return &testPE{
+		machine:         pe.IMAGE_FILE_MACHINE_AMD64,
+		characteristics: pe.IMAGE_FILE_EXECUTABLE_IMAGE | pe.IMAGE_FILE_LARGE_ADDRESS_AWARE,
+		subsystem:       pe.IMAGE_SUBSYSTEM_WINDOWS_CUI,
+	}
+}
+
+// nextRVA returns the RVA the next added section will be mapped at.
+func (b *testPE) nextRVA() uint32 {
+	if len(b.sections) == 0 {
+		return testSectionAlignment
+	}
+	last := b.sections[len(b.sections)-1]
+	return testAlign(last.rva+max(last.virtualSize, uint32(len(last.data))), testSectionAlignment)
+}
+
+// addSection adds an initialized data section and returns its RVA.
+func (b *testPE) addSection(name string, data []byte, characteristics uint32) uint32 {
+	rva := b.nextRVA()
+	b.sections = append(b.sections, testPESection{
+		name:            name,
+		data:            data,
+		virtualSize:     uint32(len(data)),
+		characteristics: characteristics,
+		rva:             rva,
+	})
+	return rva
+}
+
+func (b *testPE) setDirectory(index int, rva, size uint32) {
+	b.dirs[index] = pe.DataDirectory{VirtualAddress: rva, Size: size}
+}
+
+func (b *testPE) optionalHeaderSize() int {
+	if b.pe32 {
+		return 224
+	}
+	return 240
+}
+
+func (b *testPE) sizeOfHeaders() uint32 {
+	end := testLfanew + len(b.dosStub) + 4 + 20 + b.optionalHeaderSize() + 40*len(b.sections)
+	return testAlign(uint32(end), testFileAlignment)
+}
+
+func (b *testPE) build(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	dos := make([]byte, testLfanew)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], uint32(testLfanew+len(b.dosStub)))
+	buf.Write(dos)
+	buf.Write(b.dosStub)
+	buf.WriteString("PE\x00\x00")
+
+	write := func(v any) {
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, v))
+	}
+	write(pe.FileHeader{
+		Machine:              b.machine,
+		NumberOfSections:     uint16(len(b.sections)),
+		TimeDateStamp:        b.timeDateStamp,
+		SizeOfOptionalHeader: uint16(b.optionalHeaderSize()),
+		Characteristics:      b.characteristics,
+	})
+
+	headers := b.sizeOfHeaders()
+	sizeOfImage := testAlign(headers, testSectionAlignment)
+	if len(b.sections) > 0 {
+		sizeOfImage = b.nextRVA()
+	}
+	if b.pe32 {
+		write(pe.OptionalHeader32{
+			Magic:                       0x10b,
+			MajorLinkerVersion:          14,
+			AddressOfEntryPoint:         b.entryPoint,
+			ImageBase:                   0x400000,
+			SectionAlignment:            testSectionAlignment,
+			FileAlignment:               testFileAlignment,
+			MajorOperatingSystemVersion: 6,
+			MajorSubsystemVersion:       6,
+			SizeOfImage:                 sizeOfImage,
+			SizeOfHeaders:               headers,
+			Subsystem:                   b.subsystem,
+			DllCharacteristics:          b.dllCharacteristics,
+			SizeOfStackReserve:          0x100000,
+			SizeOfStackCommit:           0x1000,
+			SizeOfHeapReserve:           0x100000,
+			SizeOfHeapCommit:            0x1000,
+			NumberOfRvaAndSizes:         16,
+			DataDirectory:               b.dirs,
+		})
+	} else {
+		write(pe.OptionalHeader64{
+			Magic:                       0x20b,
+			MajorLinkerVersion:          14,
+			AddressOfEntryPoint:         b.entryPoint,
+			ImageBase:                   0x140000000,
+			SectionAlignment:            testSectionAlignment,
+			FileAlignment:               testFileAlignment,
+			MajorOperatingSystemVersion: 6,
+			MajorSubsystemVersion:       6,
+			SizeOfImage:                 sizeOfImage,
+			SizeOfHeaders:               headers,
+			Subsystem:                   b.subsystem,
+			DllCharacteristics:          b.dllCharacteristics,
+			SizeOfStackReserve:          0x100000,
+			SizeOfStackCommit:           0x1000,
+			SizeOfHeapReserve:           0x100000,
+			SizeOfHeapCommit:            0x1000,
+			NumberOfRvaAndSizes:         16,
+			DataDirectory:               b.dirs,
+		})
+	}
+
+	offset := headers
+	for _, s := range b.sections {
+		var name [8]uint8
+		copy(name[:], s.name)
+		rawSize := testAlign(uint32(len(s.data)), testFileAlignment)
+		pointer := offset
+		if rawSize == 0 {
+			pointer = 0
+		}
+		write(pe.SectionHeader32{
+			Name:             name,
+			VirtualSize:      s.virtualSize,
+			VirtualAddress:   s.rva,
+			SizeOfRawData:    rawSize,
+			PointerToRawData: pointer,
+			Characteristics:  s.characteristics,
+		})
+		offset += rawSize
+	}
+	buf.Write(make([]byte, int(headers)-buf.Len()))
+	for _, s := range b.sections {
+		buf.Write(s.data)
+		buf.Write(make([]byte, int(testAlign(uint32(len(s.data)), testFileAlignment))-len(s.data)))
+	}
+	buf.Write(b.overlay)
+	return buf.Bytes()
+}
+
+func testAlign(v, alignment uint32) uint32 {
+	return (v + alignment - 1) &^ (alignment - 1)
+}
+
+// testPEOffsets returns the file offsets of the CheckSum field and the security directory entry.
+func testPEOffsets(image []byte) (checksum, security int) {
+	optionalHeader := int(binary.LittleEndian.Uint32(image[0x3c:])) + 4 + 20
+	security = optionalHeader + 112 + 4*8
+	if binary.LittleEndian.Uint16(image[optionalHeader:]) == 0x10b {
+		security = optionalHeader + 96 + 4*8
+	}
+	return optionalHeader + 64, security
+}
+
+// testImageDigest is a straightforward Authenticode digest for images whose
+// sections are laid out contiguously in file order, as testPE produces them.
+func testImageDigest(image []byte, h crypto.Hash) []byte {
+	checksum, security := testPEOffsets(image)
+	end := len(image)
+	if certOffset := binary.LittleEndian.Uint32(image[security:]); certOffset != 0 {
+		end = int(certOffset)
+	}
+	hh := h.New()
+	hh.Write(image[:checksum])
+	hh.Write(image[checksum+4 : security])
+	hh.Write(image[security+8 : end])
+	return hh.Sum(nil)
+}
+
+// testSignPE appends an Authenticode signature made by the PKI leaf to the image.
+func testSignPE(t *testing.T, image []byte, pki *testPKI, h crypto.Hash) []byte {
+	t.Helper()
+	return testSignPEWith(t, image, testSignOptions{
+		hash:   h,
+		certs:  []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer: pki.leaf,
+		key:    pki.leafKey,
+	})
+}
+
+// testPreparePE pads the image for a certificate table and points the security
+// directory to its end, so that testImageDigest returns the digest to sign.
+func testPreparePE(image []byte) []byte {
+	prepared := append([]byte{}, image...)
+	prepared = append(prepared, make([]byte, testAlign(uint32(len(prepared)), 8)-uint32(len(prepared)))...)
+	_, security := testPEOffsets(prepared)
+	binary.LittleEndian.PutUint32(prepared[security:], uint32(len(prepared)))
+	return prepared
+}
+
+// testSignPEWith appends a signature built from opts, filling in the
+// SpcIndirectDataContent with the image digest when opts.content is not set.
+func testSignPEWith(t *testing.T, image []byte, opts testSignOptions) []byte {
+	t.Helper()
+	if opts.hash == 0 {
+		opts.hash = crypto.SHA256
+	}
+	signed := testPreparePE(image)
+	_, security := testPEOffsets(signed)
+	if opts.content == nil {
+		opts.content = testSpcIndirectData(t, opts.hash, testImageDigest(signed, opts.hash))
+	}
+	entry := testWinCertificate(buildTestSignedData(t, opts))
+	binary.LittleEndian.PutUint32(signed[security+4:], uint32(len(entry)))
+	return append(signed, entry...)
+}
+
+// testWriteFile writes data to a file in a temporary directory and returns its path.
+func testWriteFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// testOpenPE parses an in-memory image.
+func testOpenPE(t *testing.T, image []byte) *peImage {
+	t.Helper()
+	img, err := openPEImage(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	return img
+}
@@ -0,0 +1,218 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testResource is a resource of a test image, type and name are either uint16 IDs or strings.
+type testResource struct {
+	typ      any
+	name     any
+	lang     uint16
+	codePage uint32
+	data     []byte
+}
+
+type testResourceNode struct {
+	id       any
+	children []*testResourceNode
+	leaf     *testResource
+}
+
+func (n *testResourceNode) child(id any) *testResourceNode {
+	for _, c := range n.children {
+		if c.id == id {
+			return c
+		}
+	}
+	c := &testResourceNode{id: id}
+	n.children = append(n.children, c)
+	return c
+}
+
+// testResourceSection lays out a resource directory (type, name, language) with its data
+// for a .rsrc section loaded at rva.
+func testResourceSection(t *testing.T, rva uint32, resources []testResource) []byte {
+	t.Helper()
+	root := &testResourceNode{}
+	for i := range resources {
+		r := &resources[i]
+		root.child(r.typ).child(r.name).child(r.lang).leaf = r
+	}
+
+	// directories first, then data entries, names and finally the data
+	var dirs []*testResourceNode
+	var leaves []*testResourceNode
+	var walk func(n *testResourceNode)
+	walk = func(n *testResourceNode) {
+		if n.leaf != nil {
+			leaves = append(leaves, n)
+			return
+		}
+		dirs = append(dirs, n)
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	offsets := map[*testResourceNode]uint32{}
+	offset := uint32(0)
+	for _, d := range dirs {
+		offsets[d] = offset
+		offset += 16 + 8*uint32(len(d.children))
+	}
+	for _, l := range leaves {
+		offsets[l] = offset
+		offset += 16
+	}
+	names := map[string]uint32{}
+	var nameData []byte
+	for _, d := range dirs {
+		for _, c := range d.children {
+			if s, ok := c.id.(string); ok {
+				if _, ok := names[s]; !ok {
+					names[s] = offset + uint32(len(nameData))
+					u := utf16.Encode([]rune(s))
+					nameData = binary.LittleEndian.AppendUint16(nameData, uint16(len(u)))
+					for _, v := range u {
+						nameData = binary.LittleEndian.AppendUint16(nameData, v)
+					}
+				}
+			}
+		}
+	}
+	offset += uint32(len(nameData))
+
+	var buf bytes.Buffer
+	for _, d := range dirs {
+		var named, ids uint16
+		for _, c := range d.children {
+			if _, ok := c.id.(string); ok {
+				named++
+			} else {
+				ids++
+			}
+		}
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, [4]uint32{0, 0, 0, uint32(named) | uint32(ids)<<16}))
+		for _, c := range d.children {
+			var nameField uint32
+			switch id := c.id.(type) {
+			case string:
+				nameField = 0x80000000 | names[id]
+			case uint16:
+				nameField = uint32(id)
+			default:
+				t.Fatalf("unsupported resource id %T", id)
+			}
+			target := offsets[c]
+			if c.leaf == nil {
+				target |= 0x80000000
+			}
+			require.NoError(t, binary.Write(&buf, binary.LittleEndian, [2]uint32{nameField, target}))
+		}
+	}
+	dataOffset := testAlign(offset, 4)
+	var data []byte
+	for _, l := range leaves {
+		at := dataOffset + uint32(len(data))
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, [4]uint32{rva + at, uint32(len(l.leaf.data)), l.leaf.codePage, 0}))
+		data = append(data, l.leaf.data...)
+		data = append(data, make([]byte, int(testAlign(uint32(len(data)), 4))-len(data))...)
+	}
+	buf.Write(nameData)
+	buf.Write(make([]byte, int(dataOffset)-buf.Len()))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// testImageWithResources builds an image with a .text section and the resources in .rsrc.
+func testImageWithResources(t *testing.T, pe32 bool, resources ...testResource) []byte {
+	t.Helper()
+	b := newTestPE()
+	b.pe32 = pe32
+	b.addSection(".text", []byte("\xc3 some code"), 0x60000020)
+	rva := b.nextRVA()
+	rsrc := testResourceSection(t, rva, resources)
+	b.addSection(".rsrc", rsrc, 0x40000040)
+	b.setDirectory(imageDirectoryEntryResource, rva, uint32(len(rsrc)))
+	return b.build(t)
+}
+
+// testVersionBlock encodes a VS_VERSIONINFO block.
+type testVersionBlock struct {
+	key      string
+	text     bool
+	value    []byte
+	children []testVersionBlock
+}
+
+func (b testVersionBlock) encode() []byte {
+	out := make([]byte, 6)
+	for _, v := range utf16.Encode([]rune(b.key + "\x00")) {
+		out = binary.LittleEndian.AppendUint16(out, v)
+	}
+	out = testPad4(out)
+	out = append(out, b.value...)
+	for _, c := range b.children {
+		out = testPad4(out)
+		out = append(out, c.encode()...)
+	}
+	valueLength := len(b.value)
+	var typ uint16
+	if b.text {
+		valueLength /= 2
+		typ = 1
+	}
+	binary.LittleEndian.PutUint16(out, uint16(len(out)))
+	binary.LittleEndian.PutUint16(out[2:], uint16(valueLength))
+	binary.LittleEndian.PutUint16(out[4:], typ)
+	return out
+}
+
+func testPad4(b []byte) []byte {
+	return append(b, make([]byte, int(testAlign(uint32(len(b)), 4))-len(b))...)
+}
+
+// testText encodes a NUL terminated UTF-16LE string value.
+func testText(s string) []byte {
+	var out []byte
+	for _, v := range utf16.Encode([]rune(s + "\x00")) {
+		out = binary.LittleEndian.AppendUint16(out, v)
+	}
+	return out
+}
+
+// testFixedFileInfo encodes VS_FIXEDFILEINFO with the given versions.
+func testFixedFileInfo(t *testing.T, file, product WinFileVersion) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, vsFixedFileInfo{
+		Signature:        vsFixedFileInfoSignature,
+		StrucVersion:     0x10000,
+		FileVersionMS:    uint32(file.Major)<<16 | uint32(file.Minor),
+		FileVersionLS:    uint32(file.Patch)<<16 | uint32(file.Build),
+		ProductVersionMS: uint32(product.Major)<<16 | uint32(product.Minor),
+		ProductVersionLS: uint32(product.Patch)<<16 | uint32(product.Build),
+		FileFlagsMask:    0x3f,
+		FileOS:           0x40004,
+		FileType:         1,
+	}))
+	return buf.Bytes()
+}
+
+// testVersionInfo encodes a VS_VERSIONINFO resource with the fixed info and the given children,
+// such as StringFileInfo and VarFileInfo blocks.
+func testVersionInfo(t *testing.T, file, product WinFileVersion, children ...testVersionBlock) []byte {
+	t.Helper()
+	return testVersionBlock{
+		key:      "VS_VERSION_INFO",
+		value:    testFixedFileInfo(t, file, product),
+		children: children,
+	}.encode()
+}
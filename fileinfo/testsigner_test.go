@@ -0,0 +1,229 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testPKI is a throw-away code-signing hierarchy: root -> intermediate -> leaf.
+type testPKI struct {
+	root            *x509.Certificate
+	rootKey         *ecdsa.PrivateKey
+	intermediate    *x509.Certificate
+	intermediateKey *ecdsa.PrivateKey
+	leaf            *x509.Certificate
+	leafKey         *ecdsa.PrivateKey
+}
+
+var testNotBefore = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestPKI(t *testing.T, leafCN string) *testPKI {
+	t.Helper()
+	p := &testPKI{}
+	p.root, p.rootKey = newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root CA", Organization: []string{"Test"}},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	p.intermediate, p.intermediateKey = newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Code Signing CA", Organization: []string{"Test"}},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, p.root, p.rootKey)
+	p.leaf, p.leafKey = newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: leafCN, Organization: []string{"Test Publisher"}, Country: []string{"CZ"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, p.intermediate, p.intermediateKey)
+	return p
+}
+
+var testSerial int64 = 1000
+
+// newTestCertificate creates a certificate from the template signed by parent,
+// or a self-signed certificate when parent is nil.
+func newTestCertificate(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	testSerial++
+	template.SerialNumber = big.NewInt(testSerial)
+	if template.NotBefore.IsZero() {
+		template.NotBefore = testNotBefore
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = testNotBefore.AddDate(30, 0, 0)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// testSignOptions controls how buildTestSignedData produces a PKCS#7 SignedData.
+type testSignOptions struct {
+	hash        crypto.Hash
+	contentType asn1.ObjectIdentifier
+	// content is the DER encoding of the encapsulated content.
+	content []byte
+	certs   []*x509.Certificate
+	signer  *x509.Certificate
+	key     *ecdsa.PrivateKey
+	opus    *SpcSpOpusInfo
+	// unsigned returns DER encoded unauthenticated attributes for the produced signature value.
+	unsigned func(signature []byte) [][]byte
+}
+
+var testDigestOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.MD5:    oidDigestMD5,
+	crypto.SHA1:   oidDigestSHA1,
+	crypto.SHA256: oidDigestSHA256,
+	crypto.SHA384: oidDigestSHA384,
+	crypto.SHA512: oidDigestSHA512,
+}
+
+// buildTestSignedData produces a DER encoded ContentInfo wrapping SignedData.
+func buildTestSignedData(t *testing.T, opts testSignOptions) []byte {
+	t.Helper()
+	if opts.hash == 0 {
+		opts.hash = crypto.SHA256
+	}
+	if opts.contentType == nil {
+		opts.contentType = oidSpcIndirectData
+	}
+	digestAlg := derMarshal(t, pkix.AlgorithmIdentifier{Algorithm: testDigestOIDs[opts.hash], Parameters: asn1.NullRawValue})
+
+	// Authenticode digests the content octets without the outer tag and length.
+	var content asn1.RawValue
+	_, err := asn1.Unmarshal(opts.content, &content)
+	require.NoError(t, err)
+
+	var attrs [][]byte
+	if opts.opus != nil {
+		attrs = append(attrs, testAttribute(t, oidSpcSpOpusInfo, testOpusInfo(t, opts.opus)))
+	}
+	signerInfo := buildTestSignerInfo(t, opts.hash, opts.signer, opts.key, opts.contentType, content.Bytes, attrs, opts.unsigned)
+
+	var certs [][]byte
+	for _, c := range opts.certs {
+		certs = append(certs, c.Raw)
+	}
+	sd := derSequence(t,
+		derMarshal(t, 1),
+		derTagged(t, asn1.ClassUniversal, asn1.TagSet, digestAlg),
+		derSequence(t, derMarshal(t, opts.contentType), derTagged(t, asn1.ClassContextSpecific, 0, opts.content)),
+		derTagged(t, asn1.ClassContextSpecific, 0, derConcat(certs...)),
+		derTagged(t, asn1.ClassUniversal, asn1.TagSet, signerInfo),
+	)
+	return derSequence(t, derMarshal(t, oidSignedData), derTagged(t, asn1.ClassContextSpecific, 0, sd))
+}
+
+// buildTestSignerInfo signs content and returns the DER encoded SignerInfo. The
+// content type and message digest attributes are followed by the extra signed attrs.
+func buildTestSignerInfo(t *testing.T, hash crypto.Hash, signer *x509.Certificate, key *ecdsa.PrivateKey,
+	contentType asn1.ObjectIdentifier, content []byte, attrs [][]byte, unsigned func([]byte) [][]byte) []byte {
+	t.Helper()
+	h := hash.New()
+	h.Write(content)
+	signedAttrs := derConcat(append([][]byte{
+		testAttribute(t, oidAttributeContentType, derMarshal(t, contentType)),
+		testAttribute(t, oidAttributeMessageDigest, derMarshal(t, h.Sum(nil))),
+	}, attrs...)...)
+
+	h = hash.New()
+	h.Write(derTagged(t, asn1.ClassUniversal, asn1.TagSet, signedAttrs))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, h.Sum(nil))
+	require.NoError(t, err)
+
+	fields := [][]byte{
+		derMarshal(t, 1),
+		derSequence(t, signer.RawIssuer, derMarshal(t, signer.SerialNumber)),
+		derMarshal(t, pkix.AlgorithmIdentifier{Algorithm: testDigestOIDs[hash], Parameters: asn1.NullRawValue}),
+		derTagged(t, asn1.ClassContextSpecific, 0, signedAttrs),
+		derMarshal(t, pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}),
+		derMarshal(t, signature),
+	}
+	if unsigned != nil {
+		fields = append(fields, derTagged(t, asn1.ClassContextSpecific, 1, derConcat(unsigned(signature)...)))
+	}
+	return derSequence(t, fields...)
+}
+
+// testSpcIndirectData encodes SpcIndirectDataContent for a PE image digest.
+func testSpcIndirectData(t *testing.T, h crypto.Hash, imageDigest []byte) []byte {
+	t.Helper()
+	peImageData := derSequence(t, derMarshal(t, asn1.BitString{}))
+	digestAlg := derMarshal(t, pkix.AlgorithmIdentifier{Algorithm: testDigestOIDs[h], Parameters: asn1.NullRawValue})
+	return derSequence(t,
+		derSequence(t, derMarshal(t, oidSpcPeImageData), peImageData),
+		derSequence(t, digestAlg, derMarshal(t, imageDigest)),
+	)
+}
+
+func testAttribute(t *testing.T, oid asn1.ObjectIdentifier, values ...[]byte) []byte {
+	t.Helper()
+	return derSequence(t, derMarshal(t, oid), derTagged(t, asn1.ClassUniversal, asn1.TagSet, derConcat(values...)))
+}
+
+func testOpusInfo(t *testing.T, opus *SpcSpOpusInfo) []byte {
+	t.Helper()
+	var fields [][]byte
+	if opus.ProgramName != "" {
+		var bmp []byte
+		for _, u := range utf16.Encode([]rune(opus.ProgramName)) {
+			bmp = append(bmp, byte(u>>8), byte(u))
+		}
+		fields = append(fields, derTagged(t, asn1.ClassContextSpecific, 0, derPrimitive(t, asn1.ClassContextSpecific, 0, bmp)))
+	}
+	if opus.MoreInfoURL != "" {
+		fields = append(fields, derTagged(t, asn1.ClassContextSpecific, 1, derPrimitive(t, asn1.ClassContextSpecific, 0, []byte(opus.MoreInfoURL))))
+	}
+	return derSequence(t, fields...)
+}
+
+func derMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := asn1.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
+
+func derConcat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func derSequence(t *testing.T, elems ...[]byte) []byte {
+	t.Helper()
+	return derTagged(t, asn1.ClassUniversal, asn1.TagSequence, derConcat(elems...))
+}
+
+func derTagged(t *testing.T, class, tag int, content []byte) []byte {
+	t.Helper()
+	return derMarshal(t, asn1.RawValue{Class: class, Tag: tag, IsCompound: true, Bytes: content})
+}
+
+func derPrimitive(t *testing.T, class, tag int, content []byte) []byte {
+	t.Helper()
+	return derMarshal(t, asn1.RawValue{Class: class, Tag: tag, Bytes: content})
+}
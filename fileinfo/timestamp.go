@@ -0,0 +1,259 @@
+package fileinfo
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	oidAttributeCounterSignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidRFC3161Timestamp          = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+	oidTSTInfo                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+)
+
+// TimestampKind identifies how a signature was timestamped.
+type TimestampKind int
+
+const (
+	// TimestampRFC3161 is an RFC 3161 timestamp token in the 1.3.6.1.4.1.311.3.3.1 unsigned attribute.
+	TimestampRFC3161 TimestampKind = iota + 1
+	// TimestampLegacy is a PKCS#9 countersignature in the 1.2.840.113549.1.9.6 unsigned attribute.
+	TimestampLegacy
+)
+
+func (k TimestampKind) String() string {
+	switch k {
+	case TimestampRFC3161:
+		return "RFC 3161"
+	case TimestampLegacy:
+		return "Legacy"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(k))
+	}
+}
+
+// Timestamp is a countersignature by a time-stamping authority (TSA) vouching that
+// a signature existed at Time. A timestamped signature stays valid after the signing
+// certificate expires, as long as the certificate was valid at Time.
+type Timestamp struct {
+	Kind TimestampKind
+	// Time is the signing time asserted by the TSA.
+	Time time.Time
+	// HashAlgorithm is the algorithm used to hash the timestamped signature.
+	HashAlgorithm crypto.Hash
+	// Signer is the TSA signer.
+	Signer *SignerInfo
+	// Certificates are the certificates available to build the TSA chain. For RFC 3161
+	// tokens these are the token certificates, for legacy countersignatures the
+	// certificates of the countersigned signature.
+	Certificates []*x509.Certificate
+	// Chain is the TSA certificate followed by its issuers found in Certificates.
+	Chain []*x509.Certificate
+
+	// timestamped is the signature value the timestamp countersigns.
+	timestamped []byte
+	// imprint is the RFC 3161 message imprint.
+	imprint []byte
+	token   *signedData
+}
+
+// Verify checks that the timestamp covers the signature it is attached to and that
+// the TSA signature is intact. It does not check that the TSA chain is trusted.
+func (t *Timestamp) Verify() error {
+	if !supportedDigest(t.HashAlgorithm) {
+		return fmt.Errorf("unsupported timestamp hash algorithm %v", t.HashAlgorithm)
+	}
+	switch t.Kind {
+	case TimestampRFC3161:
+		if !bytes.Equal(t.imprint, digest(t.HashAlgorithm, t.timestamped)) {
+			return errors.New("timestamp message imprint does not match the signature")
+		}
+		if err := t.token.verifySigner(); err != nil {
+			return fmt.Errorf("timestamp token: %w", err)
+		}
+		return nil
+	case TimestampLegacy:
+		if err := verifySignerInfo(t.Signer, t.timestamped); err != nil {
+			return fmt.Errorf("countersignature: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown timestamp kind %v", t.Kind)
+	}
+}
+
+// VerifyChain validates the TSA certificate chain against opts.Roots, requiring the time stamping usage.
+func (t *Timestamp) VerifyChain(opts ChainOptions) (*ChainVerification, error) {
+	if opts.Roots == nil {
+		return nil, errors.New("root pool is required")
+	}
+	if t.Signer == nil || t.Signer.Certificate == nil {
+		return nil, errors.New("timestamp signing certificate is not embedded")
+	}
+	if opts.CurrentTime.IsZero() {
+		opts.CurrentTime = t.Time
+	}
+	return verifyChain(t.Signer.Certificate, t.Certificates, opts, x509.ExtKeyUsageTimeStamping), nil
+}
+
+// tstInfo is the RFC 3161 TSTInfo, only the fields up to genTime are needed.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// parseTimestamp parses the timestamp in the unsigned attributes of signer.
+// certs are the certificates of the signed data the signer belongs to.
+func parseTimestamp(signer *SignerInfo, certs []*x509.Certificate) (*Timestamp, error) {
+	if attr := findAttribute(signer.UnsignedAttributes, oidRFC3161Timestamp); attr != nil && len(attr.Values) > 0 {
+		return parseRFC3161Timestamp(attr.Values[0].FullBytes, signer.signature)
+	}
+	if attr := findAttribute(signer.UnsignedAttributes, oidAttributeCounterSignature); attr != nil && len(attr.Values) > 0 {
+		return parseLegacyTimestamp(attr.Values[0].FullBytes, signer.signature, certs)
+	}
+	return nil, nil
+}
+
+func parseRFC3161Timestamp(der []byte, timestamped []byte) (*Timestamp, error) {
+	token, err := parseSignedData(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RFC 3161 token: %w", err)
+	}
+	if !token.contentType.Equal(oidTSTInfo) {
+		return nil, fmt.Errorf("unexpected RFC 3161 token content type %v", token.contentType)
+	}
+	// The encapsulated content is an OCTET STRING holding the DER encoded TSTInfo.
+	var encoded []byte
+	if _, err := asn1.Unmarshal(token.content, &encoded); err != nil {
+		return nil, fmt.Errorf("failed to parse RFC 3161 token content: %w", err)
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(encoded, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse TSTInfo: %w", err)
+	}
+	return &Timestamp{
+		Kind:          TimestampRFC3161,
+		Time:          info.GenTime,
+		HashAlgorithm: hashForOID(info.MessageImprint.HashAlgorithm.Algorithm),
+		Signer:        token.signer,
+		Certificates:  token.certificates,
+		Chain:         issuerChain(token.signer.Certificate, token.certificates),
+		timestamped:   timestamped,
+		imprint:       info.MessageImprint.HashedMessage,
+		token:         token,
+	}, nil
+}
+
+func parseLegacyTimestamp(der []byte, timestamped []byte, certs []*x509.Certificate) (*Timestamp, error) {
+	var si pkcs7SignerInfo
+	if _, err := asn1.Unmarshal(der, &si); err != nil {
+		return nil, fmt.Errorf("failed to parse countersignature: %w", err)
+	}
+	signer, err := parseSignerInfo(&si, certs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse countersignature: %w", err)
+	}
+	if signer.SigningTime.IsZero() {
+		return nil, errors.New("countersignature has no signing time")
+	}
+	return &Timestamp{
+		Kind:          TimestampLegacy,
+		Time:          signer.SigningTime,
+		HashAlgorithm: signer.DigestAlgorithm,
+		Signer:        signer,
+		Certificates:  certs,
+		Chain:         issuerChain(signer.Certificate, certs),
+		timestamped:   timestamped,
+	}, nil
+}
+
+// issuerChain returns cert followed by its issuers found in certs, without checking trust.
+func issuerChain(cert *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	if cert == nil {
+		return nil
+	}
+	chain := []*x509.Certificate{cert}
+	for current := cert; !bytes.Equal(current.RawIssuer, current.RawSubject); {
+		var parent *x509.Certificate
+		for _, candidate := range certs {
+			if bytes.Equal(candidate.RawSubject, current.RawIssuer) && current.CheckSignatureFrom(candidate) == nil {
+				parent = candidate
+				break
+			}
+		}
+		if parent == nil || containsCertificate(chain, parent) {
+			break
+		}
+		chain = append(chain, parent)
+		current = parent
+	}
+	return chain
+}
+
+func containsCertificate(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifiedTimestamp returns the timestamp of the primary signature when it verifies and the TSA
+// chains to a root in opts.Roots with the time stamping usage. A timestamp from an untrusted TSA
+// could assert any time and is ignored.
+func (c *Certificates) verifiedTimestamp(opts ChainOptions) *Timestamp {
+	if c == nil || c.Signer == nil || c.Signer.Timestamp == nil || opts.Roots == nil {
+		return nil
+	}
+	ts := c.Signer.Timestamp
+	if ts.Verify() != nil {
+		return nil
+	}
+	chain, err := ts.VerifyChain(ChainOptions{Roots: opts.Roots, Intermediates: opts.Intermediates})
+	if err != nil || !chain.Valid() {
+		return nil
+	}
+	return ts
+}
+
+// SigningTime returns the trusted signing time from the timestamp of the primary signature.
+// The TSA chain is validated against opts.Roots at the asserted time. It returns false when the
+// signature is not timestamped, the timestamp does not verify or the TSA is not trusted.
+func (c *Certificates) SigningTime(opts ChainOptions) (time.Time, bool) {
+	ts := c.verifiedTimestamp(opts)
+	if ts == nil {
+		return time.Time{}, false
+	}
+	return ts.Time, true
+}
+
+// ValidAtTimestampSignedBy reports whether the leaf signing certificate has the given common name
+// and was within its validity period at the time of the signature's verified timestamp, whose TSA
+// must chain to opts.Roots. Untimestamped signatures do not satisfy the check.
+func (c *Certificates) ValidAtTimestampSignedBy(verifier string, opts ChainOptions) bool {
+	at, ok := c.SigningTime(opts)
+	if !ok {
+		return false
+	}
+	cert := c.signerCertificate()
+	return cert != nil &&
+		strings.EqualFold(cert.Subject.CommonName, verifier) &&
+		!at.Before(cert.NotBefore) && !at.After(cert.NotAfter)
+}
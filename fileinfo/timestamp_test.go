@@ -0,0 +1,236 @@
+package fileinfo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var oidData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+
+// newTestTSA issues a time stamping certificate from the PKI root.
+func newTestTSA(t *testing.T, pki *testPKI) (*x509.Certificate, *ecdsa.PrivateKey) {
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Test TSA"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, pki.root, pki.rootKey)
+}
+
+// testRFC3161Attribute returns an unsigned attribute builder adding an RFC 3161 token.
+func testRFC3161Attribute(t *testing.T, tsa *x509.Certificate, key *ecdsa.PrivateKey, pki *testPKI, at time.Time) func([]byte) [][]byte {
+	return func(signature []byte) [][]byte {
+		h := crypto.SHA256.New()
+		h.Write(signature)
+		info := derSequence(t,
+			derMarshal(t, 1),
+			derMarshal(t, asn1.ObjectIdentifier{1, 2, 3, 4}),
+			derSequence(t,
+				derMarshal(t, pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256, Parameters: asn1.NullRawValue}),
+				derMarshal(t, h.Sum(nil)),
+			),
+			derMarshal(t, 42),
+			testGeneralizedTime(t, at),
+		)
+		token := buildTestSignedData(t, testSignOptions{
+			contentType: oidTSTInfo,
+			content:     derMarshal(t, info),
+			certs:       []*x509.Certificate{tsa, pki.root},
+			signer:      tsa,
+			key:         key,
+		})
+		return [][]byte{testAttribute(t, oidRFC3161Timestamp, token)}
+	}
+}
+
+// testLegacyAttribute returns an unsigned attribute builder adding a PKCS#9 countersignature.
+func testLegacyAttribute(t *testing.T, tsa *x509.Certificate, key *ecdsa.PrivateKey, at time.Time) func([]byte) [][]byte {
+	return func(signature []byte) [][]byte {
+		signingTime, err := asn1.Marshal(at)
+		require.NoError(t, err)
+		attrs := [][]byte{testAttribute(t, oidAttributeSigningTime, signingTime)}
+		counter := buildTestSignerInfo(t, crypto.SHA1, tsa, key, oidData, signature, attrs, nil)
+		return [][]byte{testAttribute(t, oidAttributeCounterSignature, counter)}
+	}
+}
+
+func testGeneralizedTime(t *testing.T, at time.Time) []byte {
+	b, err := asn1.MarshalWithParams(at, "generalized")
+	require.NoError(t, err)
+	return b
+}
+
+func TestParseRFC3161Timestamp(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	tsa, tsaKey := newTestTSA(t, pki)
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	image := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		certs:    []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer:   pki.leaf,
+		key:      pki.leafKey,
+		unsigned: testRFC3161Attribute(t, tsa, tsaKey, pki, at),
+	})
+	certs, err := extractCertificates(testOpenPE(t, image))
+	require.NoError(t, err)
+
+	ts := certs.Signer.Timestamp
+	require.NotNil(t, ts)
+	assert.Equal(t, TimestampRFC3161, ts.Kind)
+	assert.True(t, at.Equal(ts.Time))
+	assert.Equal(t, crypto.SHA256, ts.HashAlgorithm)
+	require.NotNil(t, ts.Signer.Certificate)
+	assert.Equal(t, "Test TSA", ts.Signer.Certificate.Subject.CommonName)
+	require.Len(t, ts.Chain, 2)
+	assert.Equal(t, pki.root, ts.Chain[1])
+	require.NoError(t, ts.Verify())
+
+	signingTime, ok := certs.SigningTime(ChainOptions{Roots: testRootPool(pki.root)})
+	require.True(t, ok)
+	assert.True(t, at.Equal(signingTime))
+
+	chain, err := ts.VerifyChain(ChainOptions{Roots: testRootPool(pki.root)})
+	require.NoError(t, err)
+	assert.Equal(t, ChainValid, chain.Status, chain.Reason)
+}
+
+func TestParseLegacyTimestamp(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	tsa, tsaKey := newTestTSA(t, pki)
+	at := time.Date(2015, 5, 5, 5, 5, 5, 0, time.UTC)
+
+	image := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		certs:    []*x509.Certificate{pki.intermediate, pki.leaf, tsa},
+		signer:   pki.leaf,
+		key:      pki.leafKey,
+		unsigned: testLegacyAttribute(t, tsa, tsaKey, at),
+	})
+	certs, err := extractCertificates(testOpenPE(t, image))
+	require.NoError(t, err)
+
+	ts := certs.Signer.Timestamp
+	require.NotNil(t, ts)
+	assert.Equal(t, TimestampLegacy, ts.Kind)
+	assert.True(t, at.Equal(ts.Time))
+	assert.Equal(t, crypto.SHA1, ts.HashAlgorithm)
+	assert.Equal(t, tsa, ts.Signer.Certificate)
+	require.NoError(t, ts.Verify())
+}
+
+func TestTimestampDoesNotMatchSignature(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	tsa, tsaKey := newTestTSA(t, pki)
+	build := testRFC3161Attribute(t, tsa, tsaKey, pki, testValidationTime)
+
+	image := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		certs:  []*x509.Certificate{pki.leaf},
+		signer: pki.leaf,
+		key:    pki.leafKey,
+		// timestamp a different signature value
+		unsigned: func([]byte) [][]byte { return build([]byte("another signature")) },
+	})
+	certs, err := extractCertificates(testOpenPE(t, image))
+	require.NoError(t, err)
+	require.NotNil(t, certs.Signer.Timestamp)
+	require.Error(t, certs.Signer.Timestamp.Verify())
+	_, ok := certs.SigningTime(ChainOptions{Roots: testRootPool(pki.root)})
+	assert.False(t, ok)
+}
+
+func TestValidityAtTimestamp(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	tsa, tsaKey := newTestTSA(t, pki)
+	// The signing certificate expired in 2021 but the signature was timestamped in 2020.
+	expired, expiredKey := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Expired Publisher"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		NotAfter:    testNotBefore.AddDate(1, 0, 0),
+	}, pki.intermediate, pki.intermediateKey)
+	at := testNotBefore.AddDate(0, 6, 0)
+
+	image := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		certs:    []*x509.Certificate{pki.intermediate, expired},
+		signer:   expired,
+		key:      expiredKey,
+		unsigned: testRFC3161Attribute(t, tsa, tsaKey, pki, at),
+	})
+	certs, err := extractCertificates(testOpenPE(t, image))
+	require.NoError(t, err)
+
+	assert.False(t, certs.ValidAtSignedBy("Expired Publisher", testValidationTime))
+	roots := testRootPool(pki.root)
+	assert.True(t, certs.ValidAtTimestampSignedBy("Expired Publisher", ChainOptions{Roots: roots}))
+	chain, err := certs.VerifyChain(ChainOptions{Roots: roots, CurrentTime: testValidationTime})
+	require.NoError(t, err)
+	assert.Equal(t, ChainExpired, chain.Status)
+
+	chain, err = certs.VerifyChain(ChainOptions{Roots: roots, CurrentTime: testValidationTime, UseTimestamp: true})
+	require.NoError(t, err)
+	assert.Equal(t, ChainValid, chain.Status, chain.Reason)
+}
+
+func TestUntrustedTimestampAuthority(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	// A TSA of another PKI back-dates the signature to when the expired certificate was valid.
+	rogue := newTestPKI(t, "Rogue")
+	tsa, tsaKey := newTestTSA(t, rogue)
+	expired, expiredKey := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Expired Publisher"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		NotAfter:    testNotBefore.AddDate(1, 0, 0),
+	}, pki.intermediate, pki.intermediateKey)
+
+	image := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		certs:    []*x509.Certificate{pki.intermediate, expired},
+		signer:   expired,
+		key:      expiredKey,
+		unsigned: testRFC3161Attribute(t, tsa, tsaKey, rogue, testNotBefore.AddDate(0, 6, 0)),
+	})
+	certs, err := extractCertificates(testOpenPE(t, image))
+	require.NoError(t, err)
+	require.NoError(t, certs.Signer.Timestamp.Verify())
+
+	roots := testRootPool(pki.root)
+	_, ok := certs.SigningTime(ChainOptions{Roots: roots})
+	assert.False(t, ok)
+	assert.False(t, certs.ValidAtTimestampSignedBy("Expired Publisher", ChainOptions{Roots: roots}))
+
+	chain, err := certs.VerifyChain(ChainOptions{Roots: roots, CurrentTime: testValidationTime, UseTimestamp: true})
+	require.NoError(t, err)
+	assert.Equal(t, ChainExpired, chain.Status)
+
+	// Trusting the TSA root makes the timestamp count.
+	_, ok = certs.SigningTime(ChainOptions{Roots: testRootPool(pki.root, rogue.root)})
+	assert.True(t, ok)
+}
+
+func TestMalformedTimestamp(t *testing.T) {
+	pki := newTestPKI(t, "Test Publisher")
+	// The unsigned timestamp attribute was replaced after signing.
+	image := testSignPEWith(t, testUnsignedImage(t, false), testSignOptions{
+		certs:  []*x509.Certificate{pki.intermediate, pki.leaf},
+		signer: pki.leaf,
+		key:    pki.leafKey,
+		unsigned: func([]byte) [][]byte {
+			return [][]byte{testAttribute(t, oidRFC3161Timestamp, derMarshal(t, "not a token"))}
+		},
+	})
+	img := testOpenPE(t, image)
+	certs, err := extractCertificates(img)
+	require.NoError(t, err)
+	assert.Nil(t, certs.Signer.Timestamp)
+	require.ErrorContains(t, certs.Signer.TimestampErr, "failed to parse timestamp")
+	_, ok := certs.SigningTime(ChainOptions{Roots: testRootPool(pki.root)})
+	assert.False(t, ok)
+
+	v, err := img.verifySignature()
+	require.NoError(t, err)
+	assert.Equal(t, SignatureValid, v.Status, v.Reason)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package fileinfo
+
+import "golang.org/x/sys/windows"
+
+// userUILanguages returns the user's preferred UI languages, e.g. "de-DE".
+func userUILanguages() []string {
+	languages, err := windows.GetUserPreferredUILanguages(windows.MUI_LANGUAGE_NAME)
+	if err != nil {
+		return nil
+	}
+	return languages
+}
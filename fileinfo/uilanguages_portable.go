@@ -0,0 +1,8 @@
+//go:build !windows
+
+package fileinfo
+
+// userUILanguages returns no languages outside of Windows, the resolver falls back to "en-US".
+func userUILanguages() []string {
+	return nil
+}
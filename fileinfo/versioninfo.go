@@ -0,0 +1,179 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// vsFixedFileInfoSignature is the dwSignature of VS_FIXEDFILEINFO.
+const vsFixedFileInfoSignature = 0xfeef04bd
+
+// maxVersionInfoDepth limits the nesting of VS_VERSIONINFO blocks, real resources use three levels.
+const maxVersionInfoDepth = 8
+
+// vsFixedFileInfo is the binary layout of VS_FIXEDFILEINFO.
+// https://learn.microsoft.com/en-us/windows/win32/api/verrsrc/ns-verrsrc-vs_fixedfileinfo
+type vsFixedFileInfo struct {
+	Signature        uint32
+	StrucVersion     uint32
+	FileVersionMS    uint32
+	FileVersionLS    uint32
+	ProductVersionMS uint32
+	ProductVersionLS uint32
+	FileFlagsMask    uint32
+	FileFlags        uint32
+	FileOS           uint32
+	FileType         uint32
+	FileSubtype      uint32
+	FileDateMS       uint32
+	FileDateLS       uint32
+}
+
+// versionBlock is a node of a VS_VERSIONINFO resource. Every block has a key, an optional
+// binary or text value and child blocks, e.g. StringFileInfo and VarFileInfo under the root.
+type versionBlock struct {
+	key      string
+	text     bool
+	value    []byte
+	children []*versionBlock
+}
+
+// ReadVersions reads the file and product versions from the VS_VERSIONINFO resource of the
+// PE image in r, size is the total image size. It does not use version.dll and works on any OS.
+func ReadVersions(r io.ReaderAt, size int64) (*Versions, error) {
+	img, err := openPEImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	ffi, err := img.fixedFileInfo()
+	if err != nil {
+		return nil, err
+	}
+	return ffi.versions(), nil
+}
+
+func readVersionsFile(path string) (*Versions, error) {
+	img, err := openPEImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = img.Close()
+	}()
+	ffi, err := img.fixedFileInfo()
+	if err != nil {
+		return nil, err
+	}
+	return ffi.versions(), nil
+}
+
+// versionInfo returns the root block of the first RT_VERSION resource.
+func (p *peImage) versionInfo() (*versionBlock, error) {
+	leaves, err := p.findResources(ResourceTypeVersion)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaves) == 0 {
+		return nil, errors.New("no version info found")
+	}
+	data, err := p.readResource(leaves[0])
+	if err != nil {
+		return nil, err
+	}
+	root, _, err := parseVersionBlock(data, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version info: %w", err)
+	}
+	if root.key != "VS_VERSION_INFO" {
+		return nil, fmt.Errorf("unexpected version info key %q", root.key)
+	}
+	return root, nil
+}
+
+func (p *peImage) fixedFileInfo() (*vsFixedFileInfo, error) {
+	root, err := p.versionInfo()
+	if err != nil {
+		return nil, err
+	}
+	return parseFixedFileInfo(root.value)
+}
+
+func parseFixedFileInfo(value []byte) (*vsFixedFileInfo, error) {
+	var ffi vsFixedFileInfo
+	if len(value) < binary.Size(ffi) {
+		return nil, errors.New("no version info found")
+	}
+	if err := binary.Read(bytes.NewReader(value), binary.LittleEndian, &ffi); err != nil {
+		return nil, fmt.Errorf("failed to read fixed file info: %w", err)
+	}
+	if ffi.Signature != vsFixedFileInfoSignature {
+		return nil, fmt.Errorf("invalid fixed file info signature 0x%x", ffi.Signature)
+	}
+	return &ffi, nil
+}
+
+func (ffi *vsFixedFileInfo) versions() *Versions {
+	return newVersions(ffi.FileVersionMS, ffi.FileVersionLS, ffi.ProductVersionMS, ffi.ProductVersionLS)
+}
+
+// parseVersionBlock parses the block at offset in data and returns it with the offset of its end.
+// Blocks are laid out as wLength, wValueLength, wType, a NUL terminated UTF-16 key, the value and
+// the children, each aligned to 32 bits from the start of the resource.
+func parseVersionBlock(data []byte, offset int, depth int) (*versionBlock, int, error) {
+	if depth > maxVersionInfoDepth {
+		return nil, 0, errors.New("version info is nested too deeply")
+	}
+	if offset+6 > len(data) {
+		return nil, 0, fmt.Errorf("version block at 0x%x is truncated", offset)
+	}
+	length := int(binary.LittleEndian.Uint16(data[offset:]))
+	valueLength := int(binary.LittleEndian.Uint16(data[offset+2:]))
+	block := &versionBlock{text: binary.LittleEndian.Uint16(data[offset+4:]) == 1}
+	if length < 6 {
+		return nil, 0, fmt.Errorf("version block at 0x%x has invalid length %d", offset, length)
+	}
+	// Some linkers round the last block beyond the end of the resource.
+	end := min(offset+length, len(data))
+
+	pos := offset + 6
+	var key []uint16
+	for ; pos+2 <= end; pos += 2 {
+		c := binary.LittleEndian.Uint16(data[pos:])
+		if c == 0 {
+			pos += 2
+			break
+		}
+		key = append(key, c)
+	}
+	block.key = string(utf16.Decode(key))
+
+	pos = min(align4(pos), end)
+	if block.text {
+		// wValueLength counts UTF-16 characters for text values.
+		valueLength *= 2
+	}
+	valueEnd := min(pos+valueLength, end)
+	block.value = data[pos:valueEnd]
+
+	for pos = align4(valueEnd); pos+6 <= end; {
+		if binary.LittleEndian.Uint16(data[pos:]) == 0 {
+			// zero padding after the last child
+			break
+		}
+		child, next, err := parseVersionBlock(data[:end], pos, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		block.children = append(block.children, child)
+		pos = align4(next)
+	}
+	return block, end, nil
+}
+
+func align4(v int) int {
+	return (v + 3) &^ 3
+}
@@ -0,0 +1,101 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testFileVersion    = WinFileVersion{Major: 10, Minor: 0, Patch: 26100, Build: 3624}
+	testProductVersion = WinFileVersion{Major: 10, Minor: 1, Patch: 2, Build: 3}
+)
+
+// testStringFileInfo builds a StringFileInfo block with a single table.
+func testStringFileInfo(table string, pairs ...string) testVersionBlock {
+	t := testVersionBlock{key: table, text: true}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		t.children = append(t.children, testVersionBlock{key: pairs[i], text: true, value: testText(pairs[i+1])})
+	}
+	return testVersionBlock{key: "StringFileInfo", text: true, children: []testVersionBlock{t}}
+}
+
+// testVarFileInfo builds a VarFileInfo block with the given language and code page pairs.
+func testVarFileInfo(translations ...uint16) testVersionBlock {
+	var value []byte
+	for _, v := range translations {
+		value = binary.LittleEndian.AppendUint16(value, v)
+	}
+	return testVersionBlock{key: "VarFileInfo", text: true, children: []testVersionBlock{
+		{key: "Translation", value: value},
+	}}
+}
+
+func testVersionResource(data []byte) testResource {
+	return testResource{typ: uint16(ResourceTypeVersion), name: uint16(1), lang: 0x409, data: data}
+}
+
+func TestReadVersions(t *testing.T) {
+	info := testVersionInfo(t, testFileVersion, testProductVersion,
+		testStringFileInfo("040904b0", "CompanyName", "Contoso", "FileVersion", "10.0.26100.3624"),
+		testVarFileInfo(0x409, 1200),
+	)
+	for name, pe32 := range map[string]bool{"PE32": true, "PE32+": false} {
+		t.Run(name, func(t *testing.T) {
+			image := testImageWithResources(t, pe32,
+				testResource{typ: uint16(ResourceTypeManifest), name: uint16(1), lang: 0x409, data: []byte("<assembly/>")},
+				testVersionResource(info),
+			)
+			versions, err := ReadVersions(bytes.NewReader(image), int64(len(image)))
+			require.NoError(t, err)
+			assert.Equal(t, testFileVersion, versions.FileVersion)
+			assert.Equal(t, testProductVersion, versions.ProductVersion)
+			assert.Equal(t, "10.0.26100.3624", versions.FileVersion.String())
+		})
+	}
+}
+
+func TestReadVersionsFixedInfoOnly(t *testing.T) {
+	image := testImageWithResources(t, false, testVersionResource(testVersionInfo(t, testFileVersion, testProductVersion)))
+	versions, err := ReadVersions(bytes.NewReader(image), int64(len(image)))
+	require.NoError(t, err)
+	assert.Equal(t, testFileVersion, versions.FileVersion)
+}
+
+func TestGetVersionsFromResources(t *testing.T) {
+	image := testImageWithResources(t, false, testVersionResource(testVersionInfo(t, testFileVersion, testProductVersion)))
+	path := testWriteFile(t, "app.exe", image)
+
+	versions, err := readVersionsFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, testProductVersion, versions.ProductVersion)
+}
+
+func TestReadVersionsInvalid(t *testing.T) {
+	badSignature := testVersionInfo(t, testFileVersion, testProductVersion)
+	binary.LittleEndian.PutUint32(badSignature[40:], 0x12345678)
+	noFixedInfo := testVersionBlock{key: "VS_VERSION_INFO", children: []testVersionBlock{testVarFileInfo(0x409, 1200)}}.encode()
+	wrongKey := testVersionBlock{key: "VS_VERSION", value: testFixedFileInfo(t, testFileVersion, testProductVersion)}.encode()
+
+	tests := map[string]struct {
+		image []byte
+		err   string
+	}{
+		"no resources":       {image: testUnsignedImage(t, false), err: "no version info found"},
+		"no version":         {image: testImageWithResources(t, false, testResource{typ: uint16(ResourceTypeManifest), name: uint16(1), data: []byte("<assembly/>")}), err: "no version info found"},
+		"bad signature":      {image: testImageWithResources(t, false, testVersionResource(badSignature)), err: "invalid fixed file info signature"},
+		"no fixed file info": {image: testImageWithResources(t, false, testVersionResource(noFixedInfo)), err: "no version info found"},
+		"wrong key":          {image: testImageWithResources(t, false, testVersionResource(wrongKey)), err: "unexpected version info key"},
+		"truncated":          {image: testImageWithResources(t, false, testVersionResource([]byte{0x10})), err: "truncated"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadVersions(bytes.NewReader(tt.image), int64(len(tt.image)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -1,10 +1,6 @@
 package fileinfo
 
-import (
-	"fmt"
-
-	"golang.org/x/sys/windows"
-)
+import "fmt"
 
 type Versions struct {
 	FileVersion    WinFileVersion
@@ -22,20 +18,20 @@ func (f WinFileVersion) String() string {
 	return fmt.Sprintf("%d.%d.%d.%d", f.Major, f.Minor, f.Patch, f.Build)
 }
 
-// newWinFileInfo creates a new WinFileInfo from the given VS_FIXEDFILEINFO.
-func newWinFileInfo(vsFixedInfo *windows.VS_FIXEDFILEINFO) *Versions {
+// newVersions decodes the version numbers stored in VS_FIXEDFILEINFO,
+// the most significant DWORD holds major and minor, the least significant patch and build.
+func newVersions(fileVersionMS, fileVersionLS, productVersionMS, productVersionLS uint32) *Versions {
 	return &Versions{
-		FileVersion: WinFileVersion{
-			Major: uint16(vsFixedInfo.FileVersionMS >> 16),
-			Minor: uint16(vsFixedInfo.FileVersionMS & 0xffff),
-			Patch: uint16(vsFixedInfo.FileVersionLS >> 16),
-			Build: uint16(vsFixedInfo.FileVersionLS & 0xffff),
-		},
-		ProductVersion: WinFileVersion{
-			Major: uint16(vsFixedInfo.ProductVersionMS >> 16),
-			Minor: uint16(vsFixedInfo.ProductVersionMS & 0xffff),
-			Patch: uint16(vsFixedInfo.ProductVersionLS >> 16),
-			Build: uint16(vsFixedInfo.ProductVersionLS & 0xffff),
-		},
+		FileVersion:    newWinFileVersion(fileVersionMS, fileVersionLS),
+		ProductVersion: newWinFileVersion(productVersionMS, productVersionLS),
+	}
+}
+
+func newWinFileVersion(ms, ls uint32) WinFileVersion {
+	return WinFileVersion{
+		Major: uint16(ms >> 16),
+		Minor: uint16(ms & 0xffff),
+		Patch: uint16(ls >> 16),
+		Build: uint16(ls & 0xffff),
 	}
 }
@@ -1,6 +1,9 @@
+//go:build windows
+
 package fileinfo_test
 
 import (
+	"os"
 	"testing"
 
 	wfi "github.com/miroslav-matejovsky/wintoolkit/fileinfo"
@@ -29,3 +32,21 @@ func TestGetFileVersionInfo(t *testing.T) {
 	assert.Equal(t, expected.FileVersion.Build, fi.FileVersion.Patch)
 	assert.Equal(t, expected.FileVersion.Patch, fi.FileVersion.Build)
 }
+
+func TestReadVersionsMatchesVersionDLL(t *testing.T) {
+	file := `C:\Windows\System32\notepad.exe`
+	wf, err := wfi.NewWinFileInfo(file)
+	require.NoError(t, err)
+	expected, err := wf.GetVersions()
+	require.NoError(t, err)
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	stat, err := f.Stat()
+	require.NoError(t, err)
+
+	versions, err := wfi.ReadVersions(f, stat.Size())
+	require.NoError(t, err)
+	assert.Equal(t, expected, versions)
+}
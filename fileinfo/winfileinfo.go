@@ -2,15 +2,18 @@
 package fileinfo
 
 import (
+	"crypto"
 	"fmt"
 	"os"
-
-	"golang.org/x/sys/windows"
+	"slices"
 )
 
 // WinFileInfo represents a file on the Windows filesystem.
 // This file must exist in the OS
 // afero in memory cannot be used because native Windows APIs are used to retrieve file information.
+// Methods backed by native Windows APIs (GetFileTime, GetFixedFileInfo) are only available on Windows,
+// GetVersions uses version.dll on Windows and parses the PE resources elsewhere,
+// the PE and signature parsing methods work on any OS.
 type WinFileInfo struct {
 	path string
 }
@@ -28,38 +31,268 @@ func NewWinFileInfo(path string) (*WinFileInfo, error) {
 	return &WinFileInfo{path: path}, nil
 }
 
-// GetFileTime retrieves the file time information for the file.
-// It returns a WinFileTime struct containing the file time information.
-func (wf *WinFileInfo) GetFileTime() (*FileTime, error) {
-	return wf.getFileTime()
+// GetCertificates retrieves the embedded certificates and the signer of the file's Authenticode signature.
+// PowerShell scripts (.ps1, .psm1, .psd1, .ps1xml, .psc1, .cdxml) are read from their
+// "# SIG # Begin signature block", other files are parsed as PE images.
+// It returns an empty Certificates for unsigned files or an error if the signature cannot be parsed.
+func (wf *WinFileInfo) GetCertificates() (*Certificates, error) {
+	certs, err := getCertificates(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract certificates: %v", err)
+	}
+	return certs, nil
 }
 
-// GetVersions retrieves the file version information for the file.
-// It returns a WinFileInfo struct containing the file version information.
-func (wf *WinFileInfo) GetVersions() (*Versions, error) {
-	ffi, err := wf.GetFixedFileInfo()
+// VerifySignature verifies that the file still matches its Authenticode signature.
+// It computes the Authenticode PE image hash, or the hash of the UTF-16LE script text for
+// PowerShell scripts, compares it with the digest signed in SpcIndirectDataContent and
+// checks the signer's signature over the signed attributes.
+// Unsigned files are reported with the SignatureNotSigned status rather than an error.
+func (wf *WinFileInfo) VerifySignature() (*SignatureVerification, error) {
+	verification, err := verifySignature(wf.path)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to verify signature: %v", err)
 	}
-	return newWinFileInfo(ffi), nil
+	return verification, nil
 }
 
-// GetFixedFileInfo retrieves the fixed file information for the file.
-// It returns a windows.VS_FIXEDFILEINFO struct containing the fixed file information.
-func (wf *WinFileInfo) GetFixedFileInfo() (*windows.VS_FIXEDFILEINFO, error) {
-	winver, err := initWinVer(wf.path)
+// VerifySignatures verifies every Authenticode signature of the file, including signatures
+// nested in the primary one. The results are in the order of Certificates.Signatures and
+// the slice is empty for unsigned files.
+func (wf *WinFileInfo) VerifySignatures() ([]*SignatureVerification, error) {
+	verifications, err := verifySignatures(wf.path)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to verify signatures: %v", err)
 	}
-	return winver.queryFixedFileInfo()
+	return verifications, nil
 }
 
-// GetCertificates retrieves the embedded certificates from the file.
-// It returns a slice of x509.Certificate pointers or an error if the operation fails.
-func (wf *WinFileInfo) GetCertificates() (*Certificates, error) {
-	certs, err := getCertificates(wf.path)
+// HasValidSignatureWithDigest reports whether any signature of the file, primary or nested, signs
+// the file with the given digest algorithm and verifies against the file, e.g. to require at least
+// one valid SHA-256 signature.
+func (wf *WinFileInfo) HasValidSignatureWithDigest(h crypto.Hash) (bool, error) {
+	verifications, err := verifySignatures(wf.path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract certificates: %v", err)
+		return false, fmt.Errorf("failed to verify signatures: %v", err)
 	}
-	return certs, nil
+	return slices.ContainsFunc(verifications, func(v *SignatureVerification) bool {
+		return v.Valid() && v.DigestAlgorithm == h
+	}), nil
+}
+
+// SignedByPolicy reports whether the file's primary signature verifies, its signer chains to a root
+// in opts.Roots with the code signing usage and the signing certificate satisfies the policy. Names
+// alone prove nothing, a self-signed certificate can carry any subject and issuer.
+func (wf *WinFileInfo) SignedByPolicy(policy *SignerPolicy, opts ChainOptions) (bool, error) {
+	ok, err := signedByPolicy(wf.path, policy, opts)
+	if err != nil {
+		return false, fmt.Errorf("failed to match signer policy: %v", err)
+	}
+	return ok, nil
+}
+
+// FindCatalogSignature looks up the file in security catalogs, for files such as most of System32
+// that are catalog-signed instead of carrying an embedded signature. catalogs are .cat files or
+// directories searched for them, e.g. SystemCatRoot(). It returns nil when no catalog lists the file.
+// Use LoadCatalogs and CatalogIndex.Lookup to check many files without reloading the catalogs.
+func (wf *WinFileInfo) FindCatalogSignature(catalogs ...string) (*CatalogSignature, error) {
+	index, err := LoadCatalogs(catalogs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load catalogs: %v", err)
+	}
+	signature, err := index.Lookup(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up file in catalogs: %v", err)
+	}
+	return signature, nil
+}
+
+// VerifyPageHashes verifies the per-page hashes (SpcPageHashes) signed in the file's Authenticode
+// signature and reports every mismatching page with its section. The SHA-256 table is used when
+// a signature has one, tables of signatures whose signer signature does not verify are ignored.
+// It returns ErrNoPageHashes when no signature has page hashes.
+// The page hashes are only trustworthy when VerifySignature reports a valid signature.
+func (wf *WinFileInfo) VerifyPageHashes() (*PageHashVerification, error) {
+	verification, err := verifyPageHashes(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify page hashes: %w", err)
+	}
+	return verification, nil
+}
+
+// GetStringFileInfo retrieves the string tables (CompanyName, ProductName, FileDescription, ...) and
+// the declared translations of the file's VS_VERSIONINFO resource. It parses the PE resources directly
+// and works on any OS. Use StringFileInfo.Value or BestTable to pick the language Explorer would show.
+func (wf *WinFileInfo) GetStringFileInfo() (*StringFileInfo, error) {
+	info, err := readStringFileInfoFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read string file info: %w", err)
+	}
+	return info, nil
+}
+
+// Resources lists every entry of the file's PE resource directory with its type, name or ID,
+// language, size and code page. Use ReadResource to read the data of an entry.
+func (wf *WinFileInfo) Resources() (Resources, error) {
+	resources, err := readResourcesFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resources: %w", err)
+	}
+	return resources, nil
+}
+
+// ReadResource reads the raw data of a resource returned by Resources.
+func (wf *WinFileInfo) ReadResource(resource Resource) ([]byte, error) {
+	data, err := readResourceDataFile(wf.path, resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource: %w", err)
+	}
+	return data, nil
+}
+
+// GetManifests reads the application or assembly manifests embedded as RT_MANIFEST resources 1 to 3,
+// with the requested execution level, side-by-side dependencies, supported OS versions and
+// Windows settings. It returns an empty slice for files without a manifest.
+func (wf *WinFileInfo) GetManifests() ([]*Manifest, error) {
+	manifests, err := readManifestsFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifests: %w", err)
+	}
+	return manifests, nil
+}
+
+// GetIcons reads the file's icons from its RT_GROUP_ICON and RT_ICON resources. The first icon
+// is the one Explorer shows, Icon.ICO rebuilds a .ico file and Icon.LargestPNG returns the
+// largest image as PNG. It returns an empty slice for files without icons.
+func (wf *WinFileInfo) GetIcons() ([]*Icon, error) {
+	icons, err := readIconsFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read icons: %w", err)
+	}
+	return icons, nil
+}
+
+// GetStrings reads the file's RT_STRING tables by language and string ID.
+func (wf *WinFileInfo) GetStrings() (ResourceStrings, error) {
+	table, err := readStringsFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read string tables: %w", err)
+	}
+	return table, nil
+}
+
+// Headers summarizes the file's PE headers: machine and code architecture, subsystem, characteristics,
+// time stamp, linker and OS versions, section table and the stored against the recomputed checksum.
+func (wf *WinFileInfo) Headers() (*Headers, error) {
+	headers, err := readHeadersFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read headers: %w", err)
+	}
+	return headers, nil
+}
+
+// Mitigations checks the file's exploit mitigations (ASLR, HighEntropyVA, DEP, CFG, SafeSEH, GS, CET,
+// ForceIntegrity and AppContainer) and reports each as pass, fail or not applicable with a reason.
+func (wf *WinFileInfo) Mitigations() (*MitigationReport, error) {
+	report, err := readMitigationsFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check mitigations: %w", err)
+	}
+	return report, nil
+}
+
+// Imports reads the DLLs and functions the file imports: the import directory, delay-load imports
+// and bound imports. Imports.Imphash computes the import hash.
+func (wf *WinFileInfo) Imports() (*Imports, error) {
+	imports, err := readImportsFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read imports: %w", err)
+	}
+	return imports, nil
+}
+
+// Exports reads the file's export directory including forwarders. It returns nil for files
+// that export nothing.
+func (wf *WinFileInfo) Exports() (*Exports, error) {
+	exports, err := readExportsFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exports: %w", err)
+	}
+	return exports, nil
+}
+
+// GetRichHeader decodes the file's Rich header: the product ID, build and object count of every tool
+// of the MSVC toolset that built it, with the Visual Studio version and the checksum validation.
+// It returns nil for files without a Rich header.
+func (wf *WinFileInfo) GetRichHeader() (*RichHeader, error) {
+	header, err := readRichHeaderFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rich header: %w", err)
+	}
+	return header, nil
+}
+
+// GetDebugInfo reads the file's debug directory with the decoded CodeView, POGO, REPRO and
+// VC_FEATURE entries. DebugInfo.PDBSymbolKey and DebugInfo.ImageSymbolKey return the symbol
+// server paths of the PDB and of the file.
+func (wf *WinFileInfo) GetDebugInfo() (*DebugInfo, error) {
+	info, err := readDebugInfoFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read debug directory: %w", err)
+	}
+	return info, nil
+}
+
+// GetDotNetAssembly reads the CLR header and metadata of a .NET file: the assembly identity, target
+// framework, referenced assemblies, the 32-bit and IL only flags and whether the file is mixed-mode
+// or ReadyToRun. It returns nil for native files.
+func (wf *WinFileInfo) GetDotNetAssembly() (*DotNetAssembly, error) {
+	assembly, err := readDotNetAssemblyFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .NET assembly: %w", err)
+	}
+	return assembly, nil
+}
+
+// GetGoBuildInfo reads the build information embedded by the Go toolchain: the Go version, the main
+// module, the dependencies and the build settings such as the VCS revision. It returns nil for files
+// not built with Go.
+func (wf *WinFileInfo) GetGoBuildInfo() (*GoBuildInfo, error) {
+	info, err := readGoBuildInfoFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Go build info: %w", err)
+	}
+	return info, nil
+}
+
+// DetectRuntimes classifies the runtimes and packagers the file was built with, such as PyInstaller,
+// .NET single-file bundles, Electron, Java launchers and Go, each with a confidence and the evidence
+// found. Verdicts are sorted by confidence, highest first.
+func (wf *WinFileInfo) DetectRuntimes() ([]RuntimeVerdict, error) {
+	verdicts, err := detectRuntimesFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect runtimes: %w", err)
+	}
+	return verdicts, nil
+}
+
+// GetOverlay returns the data appended after the last section of the file, outside of the certificate
+// table, with the archive format it starts with. It returns nil when the file has no overlay.
+func (wf *WinFileInfo) GetOverlay() (*Overlay, error) {
+	overlay, err := readOverlayFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overlay: %w", err)
+	}
+	return overlay, nil
+}
+
+// DetectInstallers classifies the installer the file is, such as NSIS, Inno Setup, InstallShield, WiX
+// Burn bundles, Advanced Installer and self-extracting archives, each with the switches of a silent
+// installation. Installers are sorted by confidence, highest first.
+func (wf *WinFileInfo) DetectInstallers() ([]Installer, error) {
+	installers, err := detectInstallersFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect installers: %w", err)
+	}
+	return installers, nil
 }
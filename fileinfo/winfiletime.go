@@ -1,3 +1,5 @@
+//go:build windows
+
 package fileinfo
 
 import (
@@ -13,6 +15,12 @@ type FileTime struct {
 	LastWriteTime  time.Time
 }
 
+// GetFileTime retrieves the file time information for the file.
+// It returns a WinFileTime struct containing the file time information.
+func (wf *WinFileInfo) GetFileTime() (*FileTime, error) {
+	return wf.getFileTime()
+}
+
 // getFileTime retrieves the creation, last access, and last write times of the file.
 func (wf *WinFileInfo) getFileTime() (*FileTime, error) {
 	// Convert path to UTF-16
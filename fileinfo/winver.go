@@ -1,3 +1,5 @@
+//go:build windows
+
 package fileinfo
 
 import (
@@ -7,6 +9,26 @@ import (
 	"golang.org/x/sys/windows"
 )
 
+// GetVersions retrieves the file version information for the file.
+// It returns a WinFileInfo struct containing the file version information.
+func (wf *WinFileInfo) GetVersions() (*Versions, error) {
+	ffi, err := wf.GetFixedFileInfo()
+	if err != nil {
+		return nil, err
+	}
+	return newWinFileInfo(ffi), nil
+}
+
+// GetFixedFileInfo retrieves the fixed file information for the file.
+// It returns a windows.VS_FIXEDFILEINFO struct containing the fixed file information.
+func (wf *WinFileInfo) GetFixedFileInfo() (*windows.VS_FIXEDFILEINFO, error) {
+	winver, err := initWinVer(wf.path)
+	if err != nil {
+		return nil, err
+	}
+	return winver.queryFixedFileInfo()
+}
+
 type winver struct {
 	dataPointer unsafe.Pointer
 	data        []byte
@@ -54,3 +76,8 @@ func (f *winver) queryFixedFileInfo() (*windows.VS_FIXEDFILEINFO, error) {
 	fixedFileInfo := *(*windows.VS_FIXEDFILEINFO)(unsafe.Pointer(&data[0]))
 	return &fixedFileInfo, nil
 }
+
+// newWinFileInfo creates a new WinFileInfo from the given VS_FIXEDFILEINFO.
+func newWinFileInfo(vsFixedInfo *windows.VS_FIXEDFILEINFO) *Versions {
+	return newVersions(vsFixedInfo.FileVersionMS, vsFixedInfo.FileVersionLS, vsFixedInfo.ProductVersionMS, vsFixedInfo.ProductVersionLS)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package fileinfo
+
+import "fmt"
+
+// GetVersions retrieves the file version information for the file.
+// Outside of Windows the VS_VERSIONINFO resource is parsed directly from the PE file.
+func (wf *WinFileInfo) GetVersions() (*Versions, error) {
+	versions, err := readVersionsFile(wf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version info: %w", err)
+	}
+	return versions, nil
+}
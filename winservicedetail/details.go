@@ -37,6 +37,8 @@ type ExecutableFile struct {
 	Path           string
 	Version        string
 	ProductVersion string
+	ProductName    string
+	CompanyName    string
 	CreationTime   time.Time
 	LastAccessTime time.Time
 	LastWriteTime  time.Time
@@ -126,6 +128,13 @@ func (s *WinSvcManager) GetServiceDetails(name string, includeFiles bool) (*Serv
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file info for %v: %v", name, err)
 	}
+	var productName, companyName string
+	stringFileInfo, err := wf.GetStringFileInfo()
+	// Non-fatal error, the string table is optional
+	if err == nil {
+		productName = stringFileInfo.Value(wfi.StringProductName)
+		companyName = stringFileInfo.Value(wfi.StringCompanyName)
+	}
 	fileTime, err := wf.GetFileTime()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file time for %v: %v", name, err)
@@ -143,8 +152,8 @@ func (s *WinSvcManager) GetServiceDetails(name string, includeFiles bool) (*Serv
 
 	return &ServiceDetails{
 		Name:             name,
-		DisplayName:      config.DisplayName,
-		Description:      config.Description,
+		DisplayName:      resolveIndirectString(config.DisplayName),
+		Description:      resolveIndirectString(config.Description),
 		PathToExecutable: config.BinaryPathName,
 		StartupType:      startTypeToString(config.StartType),
 		ServiceStatus:    stateToString(status.State),
@@ -169,6 +178,8 @@ func (s *WinSvcManager) GetServiceDetails(name string, includeFiles bool) (*Serv
 				Path:           executable,
 				Version:        versions.FileVersion.String(),
 				ProductVersion: versions.ProductVersion.String(),
+				ProductName:    productName,
+				CompanyName:    companyName,
 				CreationTime:   fileTime.CreationTime,
 				LastAccessTime: fileTime.LastAccessTime,
 				LastWriteTime:  fileTime.LastWriteTime,
@@ -178,6 +189,20 @@ func (s *WinSvcManager) GetServiceDetails(name string, includeFiles bool) (*Serv
 	}, nil
 }
 
+// resolveIndirectString resolves indirect strings such as "@%SystemRoot%\system32\wuaueng.dll,-106".
+// The raw value is kept when it cannot be resolved.
+func resolveIndirectString(s string) string {
+	if !wfi.IsIndirectString(s) {
+		return s
+	}
+	resolver := &wfi.IndirectStringResolver{}
+	resolved, err := resolver.Resolve(s)
+	if err != nil {
+		return s
+	}
+	return resolved
+}
+
 func startTypeToString(startType uint32) string {
 	switch startType {
 	case mgr.StartAutomatic:
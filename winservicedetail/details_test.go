@@ -43,6 +43,8 @@ func TestServiceDetails(t *testing.T) {
 	assert.Equal(t, `c:\windows\system32\svchost.exe`, strings.ToLower(executableFile.Path))
 	assert.Regexp(t, `^\d+\.\d+\.\d+\.\d+$`, executableFile.Version)
 	assert.Regexp(t, `^\d+\.\d+\.\d+\.\d+$`, executableFile.ProductVersion)
+	assert.Equal(t, "Microsoft® Windows® Operating System", executableFile.ProductName)
+	assert.Equal(t, "Microsoft Corporation", executableFile.CompanyName)
 	assert.NotEqual(t, time.Time{}, executableFile.CreationTime)
 	assert.NotEqual(t, time.Time{}, executableFile.LastAccessTime)
 	assert.NotEqual(t, time.Time{}, executableFile.LastWriteTime)
@@ -58,3 +60,10 @@ func TestServiceDetails(t *testing.T) {
 	assert.Equal(t, false, d.Recovery.MoreThan3Actions)
 	assert.Equal(t, 24*time.Hour, d.Recovery.ResetFailCountAfter)
 }
+
+func TestResolveIndirectString(t *testing.T) {
+	assert.Equal(t, "Windows Update", resolveIndirectString("Windows Update"))
+	assert.Equal(t, "Windows Update", resolveIndirectString(`@%SystemRoot%\system32\wuaueng.dll,-105`))
+	// Unresolvable references are kept as they are.
+	assert.Equal(t, `@C:\missing\missing.dll,-1`, resolveIndirectString(`@C:\missing\missing.dll,-1`))
+}